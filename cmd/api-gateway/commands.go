@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"time"
 
 	"api-gateway/internal/app"
 	"api-gateway/internal/config"
 	"api-gateway/internal/grpc_server"
+	"api-gateway/internal/tracing"
 	"go.uber.org/zap"
 )
 
@@ -28,17 +33,35 @@ func runServerCommand(debug bool, configPath string, grpcPort string) error {
 
 	// Загрузка конфигурации
 	cfg, err := config.LoadConfig(configPath)
-	if err != nil {
-		logger.Warn("Failed to load config", zap.Error(err))
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Warn("Config file not found, using defaults", zap.String("path", configPath))
 		cfg = config.GetDefaultConfig()
+	} else if err != nil {
+		return fmt.Errorf("failed to load config %s: %w", configPath, err)
+	}
+	if debug {
+		cfg.Logging.GinMode = "debug"
 	}
 
+	// Трейсинг
+	shutdownTracing, err := tracing.Init(context.Background(), cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to init tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Warn("Failed to shutdown tracing", zap.Error(err))
+		}
+	}()
+
 	// Создание приложения
 	application := app.NewApplicationWithConfig(cfg, logger)
 
 	// Создание gRPC сервера
 	videoService := app.GetVideoStreamService(application)
-	grpcServer := grpc_server.NewVideoStreamServer(videoService, logger)
+	grpcServer := grpc_server.NewVideoStreamServer(videoService, logger, cfg.GetGRPCHandlerTimeout())
 
 	// Запуск в dual режиме
 	return runDualServer(application, grpcServer, grpcPort, logger, cfg)
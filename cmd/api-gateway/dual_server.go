@@ -6,8 +6,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
-	"time"
 
 	"api-gateway/internal/app"
 	"api-gateway/internal/config"
@@ -26,6 +26,8 @@ func runDualServer(
 	// Каналы для graceful shutdown
 	httpErrChan := make(chan error, 1)
 	grpcErrChan := make(chan error, 1)
+	httpDone := make(chan struct{})
+	grpcDone := make(chan struct{})
 
 	// Graceful shutdown контекст
 	ctx, stop := signal.NotifyContext(context.Background(),
@@ -37,7 +39,8 @@ func runDualServer(
 
 	// Запуск HTTP сервера
 	go func() {
-		addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+		defer close(httpDone)
+		addr := cfg.Addr()
 		logger.Info("🚀 Запуск HTTP сервера",
 			zap.String("address", fmt.Sprintf("http://%s", addr)))
 
@@ -49,6 +52,7 @@ func runDualServer(
 
 	// Запуск gRPC сервера
 	go func() {
+		defer close(grpcDone)
 		logger.Info("🚀 Запуск gRPC сервера",
 			zap.String("address", fmt.Sprintf(":%s", grpcPort)))
 
@@ -80,17 +84,33 @@ func runDualServer(
 		logger.Error("Ошибка gRPC сервера", zap.Error(err))
 	}
 
-	// Graceful shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Graceful shutdown: оба сервера останавливаются параллельно с общим дедлайном
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.GetShutdownTimeout())
 	defer cancel()
 
-	logger.Info("Остановка серверов...")
-	if err := application.Stop(); err != nil {
-		logger.Error("Ошибка при остановке HTTP сервера", zap.Error(err))
-	}
+	logger.Info("Остановка серверов...",
+		zap.Duration("timeout", cfg.GetShutdownTimeout()))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := application.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Ошибка при остановке HTTP сервера", zap.Error(err))
+			application.Stop()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Ошибка при остановке gRPC сервера", zap.Error(err))
+		}
+	}()
+	wg.Wait()
 
-	// Не используем shutdownCtx, но оставляем для cancel()
-	_ = shutdownCtx
+	// Дожидаемся выхода из Start/Run
+	<-httpDone
+	<-grpcDone
 
 	logger.Info("✅ Сервис остановлен корректно")
 	return nil
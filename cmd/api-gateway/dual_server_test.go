@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/internal/app"
+	"api-gateway/internal/config"
+	"api-gateway/internal/grpc_server"
+	pb "api-gateway/pkg/gen"
+)
+
+// freePort возвращает свободный TCP порт
+func freePort(t *testing.T) int {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+func TestDualServerSIGTERMWithActiveGRPCCall(t *testing.T) {
+	tests := []struct {
+		name string
+		// finish - клиент завершает вызов после сигнала; иначе вызов висит
+		// до истечения shutdown_timeout
+		finish   bool
+		wantCode codes.Code
+	}{
+		{"call completes", true, codes.OK},
+		{"stuck call aborted", false, codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			cfg.Host = "127.0.0.1"
+			cfg.Port = freePort(t)
+			cfg.ShutdownTimeout = 1
+			grpcPort := strconv.Itoa(freePort(t))
+
+			logger := zap.NewNop()
+			application := app.NewApplicationWithConfig(cfg, logger)
+			grpcServer := grpc_server.NewVideoStreamServer(app.GetVideoStreamService(application), logger, cfg.GetGRPCHandlerTimeout())
+
+			done := make(chan error, 1)
+			go func() {
+				done <- runDualServer(application, grpcServer, grpcPort, logger, cfg)
+			}()
+
+			conn, err := grpc.NewClient("127.0.0.1:"+grpcPort, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				t.Fatalf("grpc client: %v", err)
+			}
+			defer conn.Close()
+
+			// Первый чанк с ожиданием готовности: сервер стартует асинхронно
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			stream, err := pb.NewVideoStreamServiceClient(conn).StreamVideo(ctx, grpc.WaitForReady(true))
+			if err != nil {
+				t.Fatalf("StreamVideo: %v", err)
+			}
+			chunk := &pb.VideoChunk{StreamId: "stream-1", ClientId: "cam-1", Data: []byte{1, 2, 3}}
+			if err := stream.Send(chunk); err != nil {
+				t.Fatalf("send chunk: %v", err)
+			}
+			if _, err := stream.Recv(); err != nil {
+				t.Fatalf("first ack: %v", err)
+			}
+
+			// Обработчик сигнала зарегистрирован до запуска серверов
+			if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+				t.Fatalf("SIGTERM: %v", err)
+			}
+
+			if tt.finish {
+				// Начатый вызов продолжает обслуживаться
+				time.Sleep(100 * time.Millisecond)
+				if err := stream.Send(chunk); err != nil {
+					t.Fatalf("send after SIGTERM: %v", err)
+				}
+				if _, err := stream.Recv(); err != nil {
+					t.Fatalf("ack after SIGTERM: %v", err)
+				}
+				stream.CloseSend()
+			}
+			_, err = stream.Recv()
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("call finished with %v, want %v", err, tt.wantCode)
+			}
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("runDualServer() error = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("runDualServer did not return after SIGTERM")
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// latencyBuckets верхние границы корзин гистограммы задержек; последняя
+// корзина (+Inf) - все, что дольше
+var latencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// routeLatency гистограмма задержек одного маршрута
+type routeLatency struct {
+	counts []int64 // по корзинам latencyBuckets плюс +Inf
+	total  int64
+	slow   int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+// AccessLog пишет access log HTTP запросов и копит гистограммы задержек
+// по маршрутам (метод + шаблон пути gin). Запросы дольше slowThreshold
+// логируются на уровне warn с полными деталями. WebSocket и SSE в
+// гистограммы и медленные не попадают: их длительность - время сессии.
+type AccessLog struct {
+	logger        *zap.Logger
+	slowThreshold time.Duration
+
+	mu     sync.Mutex
+	routes map[string]*routeLatency
+}
+
+// NewAccessLog создает access log; slowThreshold 0 выключает выделение
+// медленных запросов
+func NewAccessLog(logger *zap.Logger, slowThreshold time.Duration) *AccessLog {
+	return &AccessLog{
+		logger:        logger,
+		slowThreshold: slowThreshold,
+		routes:        make(map[string]*routeLatency),
+	}
+}
+
+// Middleware логирует запрос после обработки и учитывает его задержку
+func (a *AccessLog) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		streaming := isLongLived(c)
+		slow := !streaming && a.slowThreshold > 0 && latency >= a.slowThreshold
+		if !streaming {
+			a.observe(c.Request.Method+" "+route, latency, slow)
+		}
+
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		requestID := c.GetString(requestIDKey)
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("route", route),
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("latency", latency),
+			zap.Int("response_size", size),
+			zap.String("client_ip", c.ClientIP()),
+			zap.String("request_id", requestID),
+		}
+
+		if !slow {
+			a.logger.Info("HTTP Request", fields...)
+			return
+		}
+
+		fields = append(fields,
+			zap.String("query", c.Request.URL.RawQuery),
+			zap.Int64("request_size", c.Request.ContentLength),
+			zap.String("user_agent", c.Request.UserAgent()),
+			zap.String("protocol", c.Request.Proto),
+			zap.Duration("slow_threshold", a.slowThreshold),
+		)
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+		a.logger.Warn("Slow HTTP request", fields...)
+	}
+}
+
+// isLongLived сообщает, что запрос держал соединение открытым: WebSocket
+// апгрейд или поток Server-Sent Events
+func isLongLived(c *gin.Context) bool {
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		return true
+	}
+	return strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream")
+}
+
+// observe добавляет задержку в гистограмму маршрута
+func (a *AccessLog) observe(route string, latency time.Duration, slow bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	h, ok := a.routes[route]
+	if !ok {
+		h = &routeLatency{counts: make([]int64, len(latencyBuckets)+1)}
+		a.routes[route] = h
+	}
+
+	bucket := sort.Search(len(latencyBuckets), func(i int) bool {
+		return latency <= latencyBuckets[i]
+	})
+	h.counts[bucket]++
+	h.total++
+	h.sum += latency
+	if latency > h.max {
+		h.max = latency
+	}
+	if slow {
+		h.slow++
+	}
+}
+
+// Snapshot возвращает гистограммы по маршрутам: число запросов по
+// корзинам ("le_<мс>" и "le_inf"), всего, медленных, среднюю и
+// максимальную задержку в миллисекундах
+func (a *AccessLog) Snapshot() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	routes := make(map[string]interface{}, len(a.routes))
+	for route, h := range a.routes {
+		buckets := make(map[string]int64, len(h.counts))
+		for i, bound := range latencyBuckets {
+			buckets["le_"+strconv.FormatInt(bound.Milliseconds(), 10)] = h.counts[i]
+		}
+		buckets["le_inf"] = h.counts[len(latencyBuckets)]
+
+		routes[route] = map[string]interface{}{
+			"count":   h.total,
+			"slow":    h.slow,
+			"avg_ms":  float64(h.sum) / float64(h.total) / float64(time.Millisecond),
+			"max_ms":  float64(h.max) / float64(time.Millisecond),
+			"buckets": buckets,
+		}
+	}
+	return routes
+}
+
+// Handler отдает Snapshot в JSON
+func (a *AccessLog) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":            "ok",
+			"slow_threshold_ms": a.slowThreshold.Milliseconds(),
+			"routes":            a.Snapshot(),
+			"timestamp":         time.Now().Unix(),
+		})
+	}
+}
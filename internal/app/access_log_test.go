@@ -0,0 +1,68 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLogSlowRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.InfoLevel)
+	accessLog := NewAccessLog(zap.New(core), time.Millisecond)
+
+	router := gin.New()
+	router.Use(accessLog.Middleware())
+	wait := func() { time.Sleep(5 * time.Millisecond) }
+	router.GET("/slow", func(c *gin.Context) {
+		wait()
+		c.Status(http.StatusOK)
+	})
+	router.GET("/events", func(c *gin.Context) {
+		wait()
+		c.SSEvent("stats", gin.H{"frames": 1})
+	})
+	router.GET("/ws", func(c *gin.Context) {
+		wait()
+		c.Status(http.StatusBadRequest)
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		header   http.Header
+		wantSlow bool // в логе медленных и в гистограмме задержек
+	}{
+		{"slow request", "/slow", nil, true},
+		{"server-sent events", "/events", nil, false},
+		{"websocket upgrade", "/ws", http.Header{
+			"Connection": {"Upgrade"},
+			"Upgrade":    {"websocket"},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.TakeAll()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			slow := logs.FilterMessage("Slow HTTP request").Len() > 0
+			if slow != tt.wantSlow {
+				t.Errorf("slow logged = %v, want %v", slow, tt.wantSlow)
+			}
+			_, observed := accessLog.Snapshot()["GET "+tt.path]
+			if observed != tt.wantSlow {
+				t.Errorf("in latency histogram = %v, want %v", observed, tt.wantSlow)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"api-gateway/internal/config"
+)
+
+// newAdminServer создает admin HTTP сервер с обработчиками net/http/pprof
+// под /debug/pprof/. Возвращает nil, если профилирование выключено.
+// Обработчики монтируются на собственный mux, а не на DefaultServeMux,
+// поэтому на основном порту профиль недоступен.
+func newAdminServer(cfg *config.Config) *http.Server {
+	if !cfg.Debug.Pprof {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return &http.Server{
+		Addr:    cfg.Debug.AdminAddress,
+		Handler: mux,
+	}
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/config"
+)
+
+func TestAdminServerPprof(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	if server := newAdminServer(cfg); server != nil {
+		t.Fatal("admin server is created with pprof disabled")
+	}
+
+	cfg.Debug.Pprof = true
+	server := newAdminServer(cfg)
+	if server == nil {
+		t.Fatal("admin server is not created with pprof enabled")
+	}
+	if server.Addr != "127.0.0.1:6060" {
+		t.Errorf("admin address = %q, want localhost by default", server.Addr)
+	}
+
+	tests := []struct {
+		path     string
+		wantBody string
+	}{
+		{"/debug/pprof/", "goroutine"},
+		{"/debug/pprof/goroutine?debug=1", "goroutine profile"},
+		{"/debug/pprof/cmdline", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s: status %d, body %.100q; want 200 with %q", tt.path, rec.Code, rec.Body.String(), tt.wantBody)
+		}
+	}
+
+	// На основном порту профиль недоступен
+	clientHandler, videoHandler, wsHandler := newTestRouterHandlers(t)
+	router := NewRouter(clientHandler, videoHandler, wsHandler, zap.NewNop(), config.SecurityConfig{},
+		WithGinMode(gin.TestMode))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code == http.StatusOK {
+		t.Errorf("main router serves /debug/pprof/ with status %d", rec.Code)
+	}
+}
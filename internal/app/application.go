@@ -1,13 +1,15 @@
 package app
 
 import (
-	"fmt"
+	"context"
 	"net/http"
+	"time"
 
 	"go.uber.org/zap"
 
 	"api-gateway/internal/config"
 	"api-gateway/internal/controller"
+	"api-gateway/internal/gateway"
 	"api-gateway/internal/handler"
 )
 
@@ -17,30 +19,70 @@ type Application struct {
 	logger             *zap.Logger
 	router             http.Handler
 	server             *http.Server
+	adminServer        *http.Server // nil, если debug.pprof выключен
+	closeClientStore   func()
 	clientInfoService  *controller.ClientInfoServiceImpl
 	videoStreamService *controller.VideoStreamServiceImpl
 	clientInfoHandler  *handler.ClientInfoHandler
 	videoStreamHandler *handler.VideoStreamHandler
+	webSocketHandler   *handler.WebSocketHandler
 }
 
 // NewApplicationWithConfig создает новое приложение с конфигурацией
 func NewApplicationWithConfig(cfg *config.Config, logger *zap.Logger) *Application {
 	// Создаем сервисы
-	clientInfoService := controller.NewClientInfoService(logger)
-	videoStreamService := controller.NewVideoStreamService(logger)
+	clientStore, closeClientStore := newClientStore(cfg, logger)
+	clientInfoService := controller.NewClientInfoService(logger, controller.WithClientStore(clientStore))
+	frameVerifier := handler.NewFrameVerifier(cfg.Auth.FrameSigningKeys, cfg.GetFrameSignatureWindow())
+	videoStreamService := controller.NewVideoStreamService(logger,
+		controller.WithStartLimit(cfg.Limits.MaxConcurrentStarts,
+			time.Duration(cfg.Limits.StartQueueTimeoutMs)*time.Millisecond),
+		controller.WithStreamBitrateLimit(cfg.Limits.MaxStreamBitrate,
+			time.Duration(cfg.Limits.ThrottleMaxWaitMs)*time.Millisecond),
+		controller.WithIdleStreamTimeout(time.Duration(cfg.Limits.StreamIdleTimeout)*time.Second),
+		controller.WithIdempotencyTTL(time.Duration(cfg.Limits.IdempotencyTTL)*time.Second),
+		controller.WithIdempotencyMaxKeys(cfg.Limits.IdempotencyMaxKeys),
+		controller.WithFormatChangePolicy(cfg.Video.FormatChangePolicy),
+		controller.WithMaxStreams(cfg.Gateway.MaxStreams),
+		controller.WithSigningClients(frameVerifier.SigningClients()))
 
 	// Создаем хендлеры
 	clientInfoHandler := handler.NewClientInfoHandler(logger, clientInfoService)
-	videoStreamHandler := handler.NewVideoStreamHandler(logger, videoStreamService)
+	videoStreamHandler := handler.NewVideoStreamHandler(logger, videoStreamService,
+		handler.WithMaxFrameSize(int64(cfg.Video.MaxFrameSize)),
+		handler.WithBatchLimits(cfg.Video.MaxBatchFrames, int64(cfg.Video.MaxBatchBytes)),
+		handler.WithFrameVerifier(frameVerifier),
+		handler.WithStrictMetadata(cfg.Video.StrictMetadata),
+		handler.WithChunkAssembler(handler.NewChunkAssembler(handler.ChunkLimits{
+			MaxFrameSize:    int64(cfg.Video.MaxChunkedFrameSize),
+			Timeout:         cfg.GetChunkTimeout(),
+			MaxPending:      cfg.Video.MaxPendingChunkedFrames,
+			MaxPendingBytes: int64(cfg.Video.MaxPendingChunkBytes),
+			MaxClientFrames: cfg.Video.MaxClientChunkedFrames,
+			MaxClientBytes:  int64(cfg.Video.MaxClientChunkBytes),
+		})))
+	webSocketHandler := handler.NewWebSocketHandler(logger, videoStreamService, clientInfoService,
+		cfg.Security.AllowedOrigins, gateway.NewConfigChannelAuthorizer(cfg),
+		gateway.ClientLimits{
+			SendBufferSize:    cfg.Gateway.SendBufferSize,
+			ClientSendBuffers: cfg.Gateway.ClientSendBuffers,
+		})
 
 	// Создаем роутер
-	router := NewRouter(clientInfoHandler, videoStreamHandler, logger)
+	accessLog := NewAccessLog(logger, cfg.GetSlowRequestThreshold())
+	middleware := append(DefaultMiddleware(logger, cfg.Security, accessLog),
+		APIKeyMiddleware(cfg.Auth.APIKeys), JWTMiddleware(cfg.JWT.Secret, cfg.Auth.AdminRoles))
+	router := NewRouter(clientInfoHandler, videoStreamHandler, webSocketHandler, logger, cfg.Security,
+		WithMiddleware(middleware...), WithAccessLog(accessLog), WithGinMode(cfg.GetGinMode()),
+		WithTrustedProxies(cfg.Security.TrustedProxies), WithAuthRequired(cfg.Auth.HTTPRequired))
 
 	// Настраиваем HTTP сервер
-	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	addr := cfg.Addr()
 	server := &http.Server{
-		Addr:    addr,
-		Handler: router,
+		Addr:      addr,
+		Handler:   router,
+		Protocols: cfg.GetHTTPProtocols(),
+		HTTP2:     cfg.GetHTTP2Config(),
 	}
 
 	return &Application{
@@ -48,10 +90,13 @@ func NewApplicationWithConfig(cfg *config.Config, logger *zap.Logger) *Applicati
 		logger:             logger,
 		router:             router,
 		server:             server,
+		adminServer:        newAdminServer(cfg),
+		closeClientStore:   closeClientStore,
 		clientInfoService:  clientInfoService,
 		videoStreamService: videoStreamService,
 		clientInfoHandler:  clientInfoHandler,
 		videoStreamHandler: videoStreamHandler,
+		webSocketHandler:   webSocketHandler,
 	}
 }
 
@@ -75,13 +120,60 @@ func (app *Application) Start() error {
 	app.logger.Info("Starting application",
 		zap.String("address", app.server.Addr))
 
+	if app.adminServer != nil {
+		go app.startAdminServer()
+	}
+
+	if app.config.Server.EnableTLS && app.config.Server.TLSCert != "" && app.config.Server.TLSKey != "" {
+		return app.server.ListenAndServeTLS(app.config.Server.TLSCert, app.config.Server.TLSKey)
+	}
 	return app.server.ListenAndServe()
 }
 
 // Stop останавливает приложение
 func (app *Application) Stop() error {
 	app.logger.Info("Stopping application")
-	return app.server.Close()
+	app.videoStreamService.Close()
+	app.videoStreamHandler.Close()
+	if app.adminServer != nil {
+		app.adminServer.Close()
+	}
+	err := app.server.Close()
+	// Клиентам WebSocket все же отправляем close фрейм, но ждем недолго
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	app.webSocketHandler.Shutdown(ctx)
+	app.closeClientStore()
+	return err
+}
+
+// startAdminServer обслуживает admin сервер; его ошибка не останавливает
+// приложение
+func (app *Application) startAdminServer() {
+	app.logger.Info("Starting admin server with pprof",
+		zap.String("address", app.adminServer.Addr))
+
+	if err := app.adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		app.logger.Error("Admin server failed", zap.Error(err))
+	}
+}
+
+// Shutdown останавливает HTTP сервер, дожидаясь текущих запросов и
+// закрытия WebSocket клиентов до истечения ctx
+func (app *Application) Shutdown(ctx context.Context) error {
+	app.logger.Info("Shutting down application")
+	app.videoStreamService.Close()
+	app.videoStreamHandler.Close()
+	if app.adminServer != nil {
+		app.adminServer.Shutdown(ctx)
+	}
+	err := app.server.Shutdown(ctx)
+	// Перехваченные WebSocket соединения сервер не ждет: закрываем их сами
+	if wsErr := app.webSocketHandler.Shutdown(ctx); wsErr != nil {
+		app.logger.Warn("WebSocket clients did not close in time", zap.Error(wsErr))
+	}
+	app.closeClientStore()
+	return err
 }
 
 // GetRouter возвращает роутер
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/gateway"
+	"api-gateway/internal/types"
+)
+
+// APIKeyHeader заголовок с API ключом server-to-server интеграций
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware аутентифицирует запросы с заголовком X-API-Key по
+// SHA-256 хешам ключей из конфига и кладет Identity в контекст под
+// types.IdentityContextKey. Запросы без заголовка пропускаются дальше
+// (к JWT аутентификации; обязательность проверяет handler.RequireIdentity);
+// неизвестный ключ - 401. Если личность уже установлена предыдущим
+// middleware, она не перезаписывается.
+func APIKeyMiddleware(keys []config.APIKey) gin.HandlerFunc {
+	type keyEntry struct {
+		hash     []byte
+		identity *types.Identity
+	}
+
+	entries := make([]keyEntry, 0, len(keys))
+	for _, key := range keys {
+		hash, err := hex.DecodeString(strings.TrimSpace(key.KeyHash))
+		if err != nil || len(hash) != sha256.Size {
+			continue
+		}
+		entries = append(entries, keyEntry{
+			hash: hash,
+			identity: &types.Identity{
+				ClientID: key.ClientID,
+				UserID:   key.UserID,
+				Method:   "api_key",
+				Admin:    key.Admin,
+			},
+		})
+	}
+
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader(APIKeyHeader)
+		if apiKey == "" {
+			c.Next()
+			return
+		}
+		if _, exists := c.Get(types.IdentityContextKey); exists {
+			c.Next()
+			return
+		}
+
+		sum := sha256.Sum256([]byte(apiKey))
+
+		// Проверяем все ключи, чтобы время ответа не зависело от позиции
+		var identity *types.Identity
+		for _, entry := range entries {
+			if subtle.ConstantTimeCompare(sum[:], entry.hash) == 1 {
+				identity = entry.identity
+			}
+		}
+
+		if identity == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "invalid API key",
+			})
+			return
+		}
+
+		c.Set(types.IdentityContextKey, identity)
+		c.Next()
+	}
+}
+
+// JWTMiddleware аутентифицирует запросы с Authorization: Bearer по JWT
+// (HS256, секрет jwt.secret) и кладет Identity в контекст: клиент - claim
+// client_id или sub, пользователь - sub, администратор - при любой роли из
+// adminRoles; сами claims - под types.TokenClaimsContextKey. Апгрейд на
+// WebSocket может передать токен так же, как в /ws/video (query token или
+// подпротокол bearer). Запросы без токена пропускаются дальше, неверный или
+// просроченный токен - 401. Пустой secret выключает JWT аутентификацию.
+func JWTMiddleware(secret string, adminRoles []string) gin.HandlerFunc {
+	admin := make(map[string]struct{}, len(adminRoles))
+	for _, role := range adminRoles {
+		admin[role] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok && websocket.IsWebSocketUpgrade(c.Request) {
+			token, _ = gateway.WebSocketToken(c.Request)
+		}
+		if token == "" || secret == "" {
+			c.Next()
+			return
+		}
+		if _, exists := c.Get(types.IdentityContextKey); exists {
+			c.Next()
+			return
+		}
+
+		claims, err := gateway.ValidateToken(secret, token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		identity := &types.Identity{
+			ClientID: claims.ClientID,
+			UserID:   claims.Subject,
+			Method:   "jwt",
+		}
+		if identity.ClientID == "" {
+			identity.ClientID = claims.Subject
+		}
+		for _, role := range claims.Roles {
+			if _, ok := admin[role]; ok {
+				identity.Admin = true
+			}
+		}
+
+		c.Set(types.IdentityContextKey, identity)
+		c.Set(types.TokenClaimsContextKey, claims)
+		c.Next()
+	}
+}
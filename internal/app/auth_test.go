@@ -0,0 +1,201 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/controller"
+	"api-gateway/internal/gateway"
+	"api-gateway/internal/handler"
+)
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+func signTestToken(t *testing.T, claims gateway.TokenClaims) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(testJWTSecret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newAuthTestRouter собирает роутер с production маршрутами и
+// аутентификацией: ключ service-key (клиент svc) и admin-key (администратор)
+func newAuthTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	logger := zap.NewNop()
+	clientService := controller.NewClientInfoService(logger)
+	videoService := controller.NewVideoStreamService(logger)
+	t.Cleanup(videoService.Close)
+
+	videoHandler := handler.NewVideoStreamHandler(logger, videoService)
+	t.Cleanup(videoHandler.Close)
+
+	keys := []config.APIKey{
+		{KeyHash: keyHash("service-key"), ClientID: "svc", UserID: "svc-user"},
+		{KeyHash: keyHash("admin-key"), ClientID: "ops", UserID: "ops", Admin: true},
+	}
+	return NewTestRouter(
+		handler.NewClientInfoHandler(logger, clientService),
+		videoHandler,
+		handler.NewWebSocketHandler(logger, videoService, clientService,
+			[]string{"https://app.example"}, gateway.NewConfigChannelAuthorizer(config.GetDefaultConfig()),
+			gateway.ClientLimits{}),
+		WithMiddleware(APIKeyMiddleware(keys), JWTMiddleware(testJWTSecret, []string{"admin"})),
+		WithAuthRequired(true),
+	)
+}
+
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	router := newAuthTestRouter(t)
+
+	userToken := signTestToken(t, gateway.TokenClaims{Subject: "user-1", ClientID: "cam-1"})
+	adminToken := signTestToken(t, gateway.TokenClaims{Subject: "root", Roles: []string{"admin"}})
+	expiredToken := signTestToken(t, gateway.TokenClaims{Subject: "user-1", ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		apiKey     string
+		bearer     string
+		body       string
+		wantStatus int
+	}{
+		{"anonymous", http.MethodGet, "/api/v1/video/active", "", "", "", http.StatusUnauthorized},
+		{"unknown api key", http.MethodGet, "/api/v1/video/active", "wrong", "", "", http.StatusUnauthorized},
+		{"api key", http.MethodGet, "/api/v1/video/active", "service-key", "", "", http.StatusOK},
+		{"invalid token", http.MethodGet, "/api/v1/video/active", "", "not.a.token", "", http.StatusUnauthorized},
+		{"expired token", http.MethodGet, "/api/v1/video/active", "", expiredToken, "", http.StatusUnauthorized},
+		{"token", http.MethodGet, "/api/v1/video/active", "", userToken, "", http.StatusOK},
+		{"health stays public", http.MethodGet, "/health", "", "", "", http.StatusOK},
+		{"own client streams", http.MethodGet, "/api/v1/video/client/cam-1/streams", "", userToken, "", http.StatusOK},
+		{"foreign client streams", http.MethodGet, "/api/v1/video/client/svc/streams", "", userToken, "", http.StatusForbidden},
+		{"foreign stop-all", http.MethodPost, "/api/v1/video/client/cam-1/stop-all", "service-key", "", "", http.StatusForbidden},
+		{"admin stop-all", http.MethodPost, "/api/v1/video/client/cam-1/stop-all", "admin-key", "", "", http.StatusOK},
+		{"admin route without role", http.MethodGet, "/api/v1/clients/active", "", userToken, "", http.StatusForbidden},
+		{"admin route with role", http.MethodGet, "/api/v1/clients/active", "", adminToken, "", http.StatusOK},
+		{"start as another client", http.MethodPost, "/api/v1/video/start", "", userToken, `{"client_id":"svc"}`, http.StatusForbidden},
+		{"start as self", http.MethodPost, "/api/v1/video/start", "", userToken, `{"camera_name":"front"}`, http.StatusOK},
+		{"sample without admin role", http.MethodGet, "/api/v1/admin/streams/missing/sample", "", userToken, "", http.StatusForbidden},
+		{"sample unknown stream", http.MethodGet, "/api/v1/admin/streams/missing/sample", "admin-key", "", "", http.StatusNotFound},
+		{"admin route with admin key", http.MethodGet, "/api/v1/clients/active", "admin-key", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s = %d, want %d (body %s)", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	server := httptest.NewServer(newAuthTestRouter(t))
+	t.Cleanup(server.Close)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/ws/video"
+
+	token := signTestToken(t, gateway.TokenClaims{Subject: "user-1", ClientID: "cam-1", Channels: []string{"granted"}})
+
+	t.Run("handshake", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			query      string
+			origin     string
+			wantStatus int // 0 - апгрейд успешен
+		}{
+			{"anonymous", "", "", http.StatusUnauthorized},
+			{"token", "?token=" + token, "", 0},
+			{"allowed origin", "?token=" + token, "https://app.example", 0},
+			{"foreign origin", "?token=" + token, "https://evil.example", http.StatusForbidden},
+			{"foreign client_id", "?token=" + token + "&client_id=svc", "", http.StatusForbidden},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				header := http.Header{}
+				if tt.origin != "" {
+					header.Set("Origin", tt.origin)
+				}
+				conn, resp, err := websocket.DefaultDialer.Dial(wsURL+tt.query, header)
+				if tt.wantStatus == 0 {
+					if err != nil {
+						t.Fatalf("Dial: %v", err)
+					}
+					conn.Close()
+					return
+				}
+				if err == nil {
+					conn.Close()
+					t.Fatalf("Dial succeeded, want status %d", tt.wantStatus)
+				}
+				if resp == nil || resp.StatusCode != tt.wantStatus {
+					t.Fatalf("Dial response = %v, want status %d", resp, tt.wantStatus)
+				}
+			})
+		}
+	})
+
+	t.Run("channels", func(t *testing.T) {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?token="+token, nil)
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		defer conn.Close()
+
+		tests := []struct {
+			channel    string
+			wantAction string
+		}{
+			{"granted", "subscribed"},
+			{"granted/front", "subscribed"},
+			{"someone-else", "error"},
+		}
+		for _, tt := range tests {
+			if err := conn.WriteJSON(map[string]string{"action": "subscribe", "channel": tt.channel}); err != nil {
+				t.Fatalf("WriteJSON: %v", err)
+			}
+			var reply map[string]interface{}
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			if err := conn.ReadJSON(&reply); err != nil {
+				t.Fatalf("ReadJSON: %v", err)
+			}
+			if reply["action"] != tt.wantAction {
+				t.Errorf("subscribe %q: action = %v, want %s", tt.channel, reply["action"], tt.wantAction)
+			}
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/controller"
+	"api-gateway/internal/redisconn"
+)
+
+// redisClientStoreTimeout таймаут подключения и команды к Redis хранилища
+// клиентов
+const redisClientStoreTimeout = time.Second
+
+// newClientStore создает хранилище клиентов по настройке client_store и
+// функцию его закрытия
+func newClientStore(cfg *config.Config, logger *zap.Logger) (controller.ClientStore, func()) {
+	if cfg.ClientStore.Backend != "redis" {
+		return controller.NewClientRepository(), func() {}
+	}
+
+	addr := net.JoinHostPort(cfg.Redis.Host, strconv.Itoa(cfg.Redis.Port))
+	client := redisconn.New(redisconn.Options{
+		Addr:     addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+		Timeout:  redisClientStoreTimeout,
+	})
+	logger.Info("Using Redis client store",
+		zap.String("redis", addr),
+		zap.String("key_prefix", cfg.ClientStore.KeyPrefix))
+	store := controller.NewRedisClientStore(client, cfg.ClientStore.KeyPrefix, cfg.GetClientStoreTTL(), logger)
+	return store, func() {
+		store.Close()
+		client.Close()
+	}
+}
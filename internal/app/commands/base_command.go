@@ -1,12 +1,15 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+
+	"api-gateway/internal/config"
 )
 
 // CommandContext содержит общий контекст для всех команд
@@ -15,14 +18,13 @@ type CommandContext struct {
 	Config *Config
 }
 
-// Config конфигурация приложения
+// Config конфигурация команды: общая схема internal/config плюс параметры,
+// которые задаются только флагами CLI
 type Config struct {
-	Port         int
-	Host         string
+	*config.Config
 	Debug        bool
 	DBConnection string
 	RedisURL     string
-	LogLevel     string
 }
 
 // NewCommandContext создает новый контекст команды
@@ -32,11 +34,14 @@ func NewCommandContext(c *cli.Context) (*CommandContext, error) {
 	logger := createLogger(logLevel)
 
 	// Загружаем конфигурацию
-	config := loadConfig(c)
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return nil, err
+	}
 
 	return &CommandContext{
 		Logger: logger,
-		Config: config,
+		Config: cfg,
 	}, nil
 }
 
@@ -61,14 +66,40 @@ func createLogger(level string) *zap.Logger {
 	return logger
 }
 
-// loadConfig загружает конфигурацию
-func loadConfig(c *cli.Context) *Config {
+// loadConfig загружает файл --config (без файла - значения по умолчанию) и
+// переопределяет его явно заданными флагами
+func loadConfig(c *cli.Context) (*Config, error) {
+	path := c.String("config")
+	if path == "" {
+		path = "./config/config.yaml"
+	}
+
+	base, err := config.LoadConfig(path)
+	if errors.Is(err, os.ErrNotExist) {
+		base = config.GetDefaultConfig()
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load config %s: %w", path, err)
+	}
+
+	if c.IsSet("port") {
+		base.Port = c.Int("port")
+	}
+	if c.IsSet("host") {
+		base.Host = c.String("host")
+	}
+	if c.IsSet("log-level") {
+		base.Logging.Level = c.String("log-level")
+	}
+	if c.Bool("tls") {
+		base.Server.EnableTLS = true
+		base.Server.TLSCert = c.String("cert")
+		base.Server.TLSKey = c.String("key")
+	}
+
 	return &Config{
-		Port:         c.Int("port"),
-		Host:         c.String("host"),
+		Config:       base,
 		Debug:        c.Bool("debug"),
 		DBConnection: c.String("db"),
 		RedisURL:     c.String("redis"),
-		LogLevel:     c.String("log-level"),
-	}
+	}, nil
 }
@@ -26,6 +26,12 @@ Examples:
 				Value:   8080,
 				Usage:   "Server port",
 			},
+			&cli.StringFlag{
+				Name:    "config",
+				Aliases: []string{"c"},
+				Value:   "./config/config.yaml",
+				Usage:   "Config file",
+			},
 			&cli.StringFlag{
 				Name:  "host",
 				Value: "0.0.0.0",
@@ -65,7 +71,7 @@ Examples:
 				zap.Bool("tls", c.Bool("tls")))
 
 			// Создаем приложение
-			application := app.NewApplicationWithConfig(ctx.Config, ctx.Logger)
+			application := app.NewApplicationWithConfig(ctx.Config.Config, ctx.Logger)
 
 			// Запускаем сервер
 			if c.Bool("tls") {
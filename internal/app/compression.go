@@ -0,0 +1,179 @@
+package app
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressionMiddleware распаковывает тела запросов с Content-Encoding
+// gzip/deflate и сжимает ответы по Accept-Encoding. Ответы с уже сжатыми
+// данными (изображения, видео, octet-stream), SSE и WebSocket не сжимаются.
+func compressionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !decompressRequest(c) {
+			return
+		}
+
+		if c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = w
+		defer w.close()
+
+		c.Next()
+	}
+}
+
+// decompressRequest подменяет тело запроса распаковывающим reader'ом.
+// Лимиты размера тела в хендлерах применяются уже к распакованным данным.
+func decompressRequest(c *gin.Context) bool {
+	var body io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding"))) {
+	case "", "identity":
+		return true
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request",
+				"message": "malformed gzip body: " + err.Error(),
+			})
+			return false
+		}
+		body = gz
+	case "deflate":
+		body = flate.NewReader(c.Request.Body)
+	default:
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":   "Unsupported Media Type",
+			"message": "unsupported Content-Encoding, use gzip or deflate",
+		})
+		return false
+	}
+
+	c.Request.Body = body
+	c.Request.Header.Del("Content-Encoding")
+	c.Request.Header.Del("Content-Length")
+	c.Request.ContentLength = -1
+	return true
+}
+
+// negotiateEncoding выбирает gzip или deflate из Accept-Encoding (gzip
+// предпочтительнее); q=0 означает отказ
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// skipCompression проверяет, что ответ не стоит сжимать
+func skipCompression(header http.Header, status int) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return true
+	}
+	if header.Get("Content-Encoding") != "" {
+		return true
+	}
+
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	for _, prefix := range []string{"image/", "video/", "audio/", "application/octet-stream",
+		"application/zip", "application/gzip", "text/event-stream"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter сжимает тело ответа. Решение принимается при первой
+// записи, когда известны статус и Content-Type.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	decided  bool
+	writer   io.WriteCloser // nil - ответ пишется без сжатия
+}
+
+func (w *compressWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if skipCompression(header, w.Status()) {
+		return
+	}
+
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	if w.encoding == "gzip" {
+		w.writer = gzip.NewWriter(w.ResponseWriter)
+	} else {
+		w.writer, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.writer == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.writer.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Flush() {
+	w.decide()
+	if flusher, ok := w.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.Hijack()
+}
+
+func (w *compressWriter) close() {
+	if w.writer != nil {
+		w.writer.Close()
+	}
+}
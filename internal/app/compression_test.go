@@ -0,0 +1,113 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newCompressionRouter роутер только с compressionMiddleware: /frame
+// возвращает поля разобранного JSON кадра, /raw - бинарные данные
+func newCompressionRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(compressionMiddleware())
+	router.POST("/frame", func(c *gin.Context) {
+		var frame struct {
+			FrameID  string `json:"frame_id"`
+			CameraID string `json:"camera_id"`
+		}
+		if err := c.ShouldBindJSON(&frame); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"frame_id": frame.FrameID, "camera_id": frame.CameraID})
+	})
+	router.GET("/raw", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/octet-stream", []byte("binary frame"))
+	})
+	return router
+}
+
+func gzipBody(t *testing.T, data string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	router := newCompressionRouter()
+	frame := `{"frame_id":"f1","camera_id":"cam-1"}`
+
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		body            io.Reader
+		contentEncoding string
+		acceptEncoding  string
+		wantStatus      int
+		wantEncoding    string
+		wantBody        string // подстрока распакованного ответа
+	}{
+		{"gzip request decoded", http.MethodPost, "/frame", gzipBody(t, frame), "gzip", "", http.StatusOK, "", `"frame_id":"f1"`},
+		{"gzip request and response", http.MethodPost, "/frame", gzipBody(t, frame), "gzip", "gzip", http.StatusOK, "gzip", `"camera_id":"cam-1"`},
+		{"plain request, gzip response", http.MethodPost, "/frame", strings.NewReader(frame), "", "br, gzip;q=0.8", http.StatusOK, "gzip", `"frame_id":"f1"`},
+		{"gzip refused with q=0", http.MethodPost, "/frame", strings.NewReader(frame), "", "gzip;q=0", http.StatusOK, "", `"frame_id":"f1"`},
+		{"binary response not compressed", http.MethodGet, "/raw", nil, "", "gzip", http.StatusOK, "", "binary frame"},
+		{"malformed gzip body", http.MethodPost, "/frame", strings.NewReader(frame), "gzip", "", http.StatusBadRequest, "", "malformed gzip body"},
+		{"unsupported encoding", http.MethodPost, "/frame", strings.NewReader(frame), "br", "", http.StatusUnsupportedMediaType, "", "unsupported Content-Encoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, tt.body)
+			req.Header.Set("Content-Type", "application/json")
+			if tt.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tt.contentEncoding)
+			}
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+
+			body := rec.Body.Bytes()
+			if tt.wantEncoding == "gzip" {
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("response is not gzip: %v", err)
+				}
+				if body, err = io.ReadAll(gz); err != nil {
+					t.Fatalf("read gzip response: %v", err)
+				}
+				if !json.Valid(body) {
+					t.Errorf("decoded response is not JSON: %q", body)
+				}
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
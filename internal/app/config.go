@@ -3,36 +3,41 @@ package app
 import (
 	"os"
 	"strconv"
+
+	"api-gateway/internal/config"
 )
 
-// Config содержит конфигурацию приложения
+// Config конфигурация приложения: общая схема internal/config плюс флаги
+// окружения, которых в ней нет
 type Config struct {
-	Port         int
-	Debug        bool
-	DBConnection string
-	LogLevel     string
+	*config.Config
+	Debug bool
 }
 
-// LoadConfig загружает конфигурацию из переменных окружения
+// LoadConfig берет конфигурацию по умолчанию и переопределяет ее
+// переменными окружения PORT, DEBUG, LOG_LEVEL и JWT_SECRET
 func LoadConfig() *Config {
-	port := 8080
+	cfg := &Config{Config: config.GetDefaultConfig()}
+
 	if envPort := os.Getenv("PORT"); envPort != "" {
 		if p, err := strconv.Atoi(envPort); err == nil {
-			port = p
+			cfg.Port = p
 		}
 	}
 
-	debug := false
 	if envDebug := os.Getenv("DEBUG"); envDebug != "" {
 		if d, err := strconv.ParseBool(envDebug); err == nil {
-			debug = d
+			cfg.Debug = d
 		}
 	}
 
-	return &Config{
-		Port:         port,
-		Debug:        debug,
-		DBConnection: os.Getenv("DB_CONNECTION"),
-		LogLevel:     os.Getenv("LOG_LEVEL"),
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		cfg.Logging.Level = level
 	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		cfg.JWT.Secret = secret
+	}
+
+	return cfg
 }
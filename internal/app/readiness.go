@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout время на все проверки зависимостей одного запроса
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck проверяет доступность зависимости; nil - зависимость готова
+type ReadinessCheck func(ctx context.Context) error
+
+// WithReadinessCheck добавляет зависимость в /health/ready. Недоступная
+// зависимость переводит пробу в 503.
+func WithReadinessCheck(name string, check ReadinessCheck) RouterOption {
+	return func(o *routerOptions) {
+		if o.readiness == nil {
+			o.readiness = make(map[string]ReadinessCheck)
+		}
+		o.readiness[name] = check
+	}
+}
+
+// readinessHandler параллельно выполняет проверки зависимостей с общим
+// таймаутом и отвечает 503, если хотя бы одна недоступна. /health остается
+// liveness пробой и зависимости не проверяет.
+func readinessHandler(checks map[string]ReadinessCheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		var (
+			mu           sync.Mutex
+			wg           sync.WaitGroup
+			dependencies = make(map[string]gin.H, len(checks))
+			ready        = true
+		)
+
+		for name, check := range checks {
+			wg.Add(1)
+			go func(name string, check ReadinessCheck) {
+				defer wg.Done()
+
+				start := time.Now()
+				err := check(ctx)
+
+				result := gin.H{
+					"status":     "ok",
+					"latency_ms": time.Since(start).Milliseconds(),
+				}
+				if err != nil {
+					result["status"] = "unavailable"
+					result["error"] = err.Error()
+				}
+
+				mu.Lock()
+				dependencies[name] = result
+				if err != nil {
+					ready = false
+				}
+				mu.Unlock()
+			}(name, check)
+		}
+		wg.Wait()
+
+		status, code := "ready", http.StatusOK
+		if !ready {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+
+		c.JSON(code, gin.H{
+			"status":       status,
+			"service":      "api-gateway",
+			"dependencies": dependencies,
+			"time":         time.Now().Unix(),
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/config"
+)
+
+func TestReadinessProbe(t *testing.T) {
+	ready := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		userCheck  ReadinessCheck
+		wantCode   int
+		wantStatus string
+		wantUser   string
+	}{
+		{"all dependencies ready", ready, http.StatusOK, "ready", "ok"},
+		{"user service down", failing, http.StatusServiceUnavailable, "not_ready", "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientHandler, videoHandler, wsHandler := newTestRouterHandlers(t)
+			router := NewRouter(clientHandler, videoHandler, wsHandler, zap.NewNop(), config.SecurityConfig{},
+				WithGinMode(gin.TestMode),
+				WithReadinessCheck("user_service", tt.userCheck),
+				WithReadinessCheck("redis", ready))
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+
+			var body struct {
+				Status       string `json:"status"`
+				Dependencies map[string]struct {
+					Status string `json:"status"`
+					Error  string `json:"error"`
+				} `json:"dependencies"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", body.Status, tt.wantStatus)
+			}
+			user := body.Dependencies["user_service"]
+			if user.Status != tt.wantUser || (tt.wantUser == "unavailable" && user.Error != "connection refused") {
+				t.Errorf("user_service = %+v, want %s", user, tt.wantUser)
+			}
+			if redis := body.Dependencies["redis"]; redis.Status != "ok" {
+				t.Errorf("redis = %+v, want ok", redis)
+			}
+
+			// Liveness проба зависимости не проверяет
+			rec = httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("/health status %d, want 200", rec.Code)
+			}
+		})
+	}
+}
@@ -3,44 +3,135 @@ package app
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"api-gateway/internal/config"
 	"api-gateway/internal/handler"
+	"api-gateway/internal/requestid"
+	"api-gateway/internal/tracing"
 )
 
-// NewRouter создает новый роутер с настройкой маршрутов
+// RouterOption настраивает роутер при создании
+type RouterOption func(*routerOptions)
+
+type routerOptions struct {
+	middleware []gin.HandlerFunc
+	readiness  map[string]ReadinessCheck
+	accessLog  *AccessLog
+	ginMode    string
+	// nil - заголовкам прокси не доверять, ClientIP - адрес соединения
+	trustedProxies []string
+	// authRequired отклонять анонимные запросы к /api/v1/*
+	authRequired bool
+}
+
+// WithMiddleware задает цепочку middleware вместо стандартной. Позволяет
+// тестам прогонять запросы через полную цепочку или ее часть.
+func WithMiddleware(middleware ...gin.HandlerFunc) RouterOption {
+	return func(o *routerOptions) {
+		o.middleware = middleware
+	}
+}
+
+// WithAccessLog публикует гистограммы задержек accessLog на
+// GET /api/v1/metrics/latency (только администраторам)
+func WithAccessLog(accessLog *AccessLog) RouterOption {
+	return func(o *routerOptions) {
+		o.accessLog = accessLog
+	}
+}
+
+// WithGinMode задает режим Gin (gin.DebugMode, gin.ReleaseMode или
+// gin.TestMode); по умолчанию NewRouter включает release
+func WithGinMode(mode string) RouterOption {
+	return func(o *routerOptions) {
+		o.ginMode = mode
+	}
+}
+
+// WithTrustedProxies задает прокси (IP или CIDR), от которых c.ClientIP()
+// принимает X-Forwarded-For и X-Real-IP
+func WithTrustedProxies(proxies []string) RouterOption {
+	return func(o *routerOptions) {
+		o.trustedProxies = proxies
+	}
+}
+
+// WithAuthRequired требует аутентифицированную личность (X-API-Key или
+// Bearer JWT) на всех маршрутах /api/v1; без нее - 401
+func WithAuthRequired(required bool) RouterOption {
+	return func(o *routerOptions) {
+		o.authRequired = required
+	}
+}
+
+// DefaultMiddleware возвращает production цепочку middleware:
+// request ID, access log, recovery, сжатие, CORS и трейсинг. accessLog nil -
+// access log без выделения медленных запросов.
+func DefaultMiddleware(logger *zap.Logger, security config.SecurityConfig, accessLog *AccessLog) []gin.HandlerFunc {
+	if accessLog == nil {
+		accessLog = NewAccessLog(logger, 0)
+	}
+	return []gin.HandlerFunc{
+		requestIDMiddleware(),
+		accessLog.Middleware(),
+		gin.Recovery(),
+		compressionMiddleware(),
+		corsMiddleware(security),
+		tracingMiddleware(),
+	}
+}
+
+// NewRouter создает новый роутер с настройкой маршрутов. Без
+// WithMiddleware используется DefaultMiddleware с CORS по security.
 func NewRouter(
 	clientInfoHandler *handler.ClientInfoHandler,
 	videoStreamHandler *handler.VideoStreamHandler,
+	webSocketHandler *handler.WebSocketHandler,
 	logger *zap.Logger,
+	security config.SecurityConfig,
+	opts ...RouterOption,
 ) http.Handler {
 
-	// Режим Gin
-	if gin.Mode() == "" {
-		gin.SetMode(gin.ReleaseMode)
+	options := routerOptions{
+		ginMode: gin.ReleaseMode,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.middleware == nil {
+		options.middleware = DefaultMiddleware(logger, security, options.accessLog)
 	}
 
+	// Режим Gin задается явно: gin.Mode() никогда не пуст (по умолчанию debug)
+	gin.SetMode(options.ginMode)
+
+	return buildRouter(clientInfoHandler, videoStreamHandler, webSocketHandler, options)
+}
+
+// buildRouter создает gin.Engine с заданной цепочкой middleware и всеми маршрутами
+func buildRouter(
+	clientInfoHandler *handler.ClientInfoHandler,
+	videoStreamHandler *handler.VideoStreamHandler,
+	webSocketHandler *handler.WebSocketHandler,
+	options routerOptions,
+) *gin.Engine {
 	router := gin.New()
+	// Формат адресов проверяет config.Validate; по умолчанию Gin доверяет
+	// X-Forwarded-For от любого источника
+	_ = router.SetTrustedProxies(options.trustedProxies)
 
 	// Middleware
-	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{
-		Formatter: func(param gin.LogFormatterParams) string {
-			logger.Info("HTTP Request",
-				zap.String("method", param.Method),
-				zap.String("path", param.Path),
-				zap.Int("status", param.StatusCode),
-				zap.Duration("latency", param.Latency),
-				zap.String("client_ip", param.ClientIP),
-			)
-			return ""
-		},
-	}))
-
-	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
+	router.Use(options.middleware...)
 
 	// Статические файлы (если нужно)
 	router.Static("/static", "./static")
@@ -55,8 +146,14 @@ func NewRouter(
 		})
 	})
 
+	// Readiness probe: проверяет зависимости, 503 при недоступности любой
+	router.GET("/health/ready", readinessHandler(options.readiness))
+
 	// API v1
 	apiV1 := router.Group("/api/v1")
+	if options.authRequired {
+		apiV1.Use(handler.RequireIdentity())
+	}
 	{
 		// Client info endpoints
 		clientInfoHandler.RegisterRoutes(apiV1)
@@ -64,6 +161,15 @@ func NewRouter(
 		// Video stream endpoints
 		videoStreamHandler.RegisterRoutes(apiV1)
 
+		// WebSocket endpoints
+		webSocketHandler.RegisterRoutes(apiV1)
+
+		// Гистограммы задержек HTTP по маршрутам: раскрывают маршруты и
+		// нагрузку, поэтому только для администраторов
+		if options.accessLog != nil {
+			apiV1.GET("/metrics/latency", handler.RequireAdmin(), options.accessLog.Handler())
+		}
+
 		// System endpoints
 		apiV1.GET("/status", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
@@ -72,11 +178,18 @@ func NewRouter(
 				"endpoints": []string{
 					"/api/v1/video/start - POST - Start stream",
 					"/api/v1/video/frame - POST - Send frame (auto-creates stream)",
+					"/api/v1/video/frames - POST - Send a batch of frames of one stream",
+					"/api/v1/video/frame/chunk - POST - Upload a large frame in chunks",
 					"/api/v1/video/stop - POST - Stop stream",
 					"/api/v1/video/active - GET - Get active streams",
 					"/api/v1/video/stats/{client_id} - GET - Get stream stats",
+					"/api/v1/video/stats/stream/{stream_id} - GET - Get single stream stats",
 					"/api/v1/video/client/{client_id}/streams - GET - Get client streams",
+					"/api/v1/video/client/{client_id}/stop-all - POST - Stop all client streams",
 					"/api/v1/video/stream/{stream_id} - GET - Get stream info",
+					"/api/v1/video/stream/{stream_id}/transfer - POST - Transfer stream to another client of the same user",
+					"/api/v1/ws/video - WebSocket - Live frames of subscribed streams",
+					"/api/v1/metrics/latency - GET - HTTP latency histograms by route",
 				},
 			})
 		})
@@ -88,6 +201,7 @@ func NewRouter(
 				"message": "Available test endpoints",
 				"endpoints": map[string]string{
 					"health":         "/health",
+					"ready":          "/health/ready",
 					"status":         "/api/v1/status",
 					"start_stream":   "/api/v1/video/start",
 					"send_frame":     "/api/v1/video/frame",
@@ -198,18 +312,75 @@ func NewRouter(
 	return router
 }
 
-// corsMiddleware настраивает CORS
-func corsMiddleware() gin.HandlerFunc {
+// requestIDKey ключ gin контекста с идентификатором запроса
+const requestIDKey = "request_id"
+
+// requestIDMiddleware берет X-Request-ID запроса (или генерирует новый),
+// кладет его в контекст запроса для логов и исходящих вызовов и
+// возвращает в ответе
+func requestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers",
-			"Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods",
-			"POST, OPTIONS, GET, PUT, DELETE, PATCH")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		id := requestid.FromRequest(c.Request)
+		c.Set(requestIDKey, id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Header(requestid.Header, id)
+		c.Next()
+	}
+}
+
+// corsMiddleware настраивает CORS по секции security. Разрешенный origin
+// возвращается в Access-Control-Allow-Origin как есть (с credentials);
+// при "*" в списке - "*" без credentials. Preflight отвечает 204.
+func corsMiddleware(security config.SecurityConfig) gin.HandlerFunc {
+	if !security.EnableCORS {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	allowAny := false
+	origins := make(map[string]struct{}, len(security.AllowedOrigins))
+	for _, origin := range security.AllowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		origins[origin] = struct{}{}
+	}
+	methods := strings.Join(security.AllowedMethods, ", ")
+	headers := strings.Join(security.AllowedHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+
+		_, allowed := origins[origin]
+		if allowed || allowAny {
+			h.Set("Access-Control-Expose-Headers", requestid.Header)
+		}
+		switch {
+		case allowed:
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		case allowAny:
+			h.Set("Access-Control-Allow-Origin", "*")
+		default:
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			h.Set("Access-Control-Max-Age", "86400")
+			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
@@ -217,20 +388,53 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
-// NewTestRouter создает роутер для тестов
+// tracingMiddleware открывает серверный спан на каждый HTTP запрос
+func tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(),
+			propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		ctx, span := tracing.Tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("http.client_ip", c.ClientIP()),
+			))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// NewTestRouter создает роутер для тестов с теми же маршрутами, что и
+// production. По умолчанию middleware нет; полную цепочку можно включить
+// через WithMiddleware(DefaultMiddleware(logger, security, nil)...).
 func NewTestRouter(
 	clientInfoHandler *handler.ClientInfoHandler,
 	videoStreamHandler *handler.VideoStreamHandler,
+	webSocketHandler *handler.WebSocketHandler,
+	opts ...RouterOption,
 ) *gin.Engine {
 
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
 
-	apiV1 := router.Group("/api/v1")
-	{
-		clientInfoHandler.RegisterRoutes(apiV1)
-		videoStreamHandler.RegisterRoutes(apiV1)
+	var options routerOptions
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	return router
+	return buildRouter(clientInfoHandler, videoStreamHandler, webSocketHandler, options)
 }
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/controller"
+	"api-gateway/internal/gateway"
+	"api-gateway/internal/handler"
+)
+
+// newTestRouterHandlers создает production хендлеры для сборки роутера
+func newTestRouterHandlers(t *testing.T) (*handler.ClientInfoHandler, *handler.VideoStreamHandler, *handler.WebSocketHandler) {
+	t.Helper()
+	logger := zap.NewNop()
+	clientService := controller.NewClientInfoService(logger)
+	videoService := controller.NewVideoStreamService(logger)
+	t.Cleanup(videoService.Close)
+
+	videoHandler := handler.NewVideoStreamHandler(logger, videoService)
+	t.Cleanup(videoHandler.Close)
+
+	return handler.NewClientInfoHandler(logger, clientService),
+		videoHandler,
+		handler.NewWebSocketHandler(logger, videoService, clientService,
+			nil, gateway.NewConfigChannelAuthorizer(config.GetDefaultConfig()),
+			gateway.ClientLimits{})
+}
+
+func TestNewRouterDefaultChain(t *testing.T) {
+	clientHandler, videoHandler, wsHandler := newTestRouterHandlers(t)
+	security := config.SecurityConfig{
+		EnableCORS:     true,
+		AllowedOrigins: []string{"https://app.example"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type"},
+	}
+	router := NewRouter(clientHandler, videoHandler, wsHandler, zap.NewNop(), security,
+		WithGinMode(gin.TestMode)).(*gin.Engine)
+	// Маршрут добавляется после Use и проходит через всю цепочку
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		origin     string
+		wantStatus int
+		wantOrigin string
+	}{
+		{"preflight allowed origin", http.MethodOptions, "/api/v1/video/active", "https://app.example", http.StatusNoContent, "https://app.example"},
+		{"preflight disallowed origin", http.MethodOptions, "/api/v1/video/active", "https://evil.example", http.StatusForbidden, ""},
+		{"handler panic recovered", http.MethodGet, "/panic", "https://app.example", http.StatusInternalServerError, "https://app.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if rec.Header().Get("X-Request-ID") == "" {
+				t.Error("X-Request-ID header is missing")
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	security := config.SecurityConfig{
+		EnableCORS:     true,
+		AllowedOrigins: []string{"https://app.example"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+	}
+	wildcard := security
+	wildcard.AllowedOrigins = []string{"*"}
+	disabled := security
+	disabled.EnableCORS = false
+
+	tests := []struct {
+		name            string
+		security        config.SecurityConfig
+		method          string
+		origin          string
+		wantStatus      int
+		wantOrigin      string
+		wantCredentials string
+		wantMethods     string
+	}{
+		{"allowed origin", security, http.MethodGet, "https://app.example", http.StatusOK, "https://app.example", "true", ""},
+		{"disallowed origin", security, http.MethodGet, "https://evil.example", http.StatusOK, "", "", ""},
+		{"preflight allowed", security, http.MethodOptions, "https://app.example", http.StatusNoContent, "https://app.example", "true", "GET, POST"},
+		{"preflight disallowed", security, http.MethodOptions, "https://evil.example", http.StatusForbidden, "", "", ""},
+		{"wildcard without credentials", wildcard, http.MethodGet, "https://any.example", http.StatusOK, "*", "", ""},
+		{"cors disabled", disabled, http.MethodGet, "https://app.example", http.StatusOK, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(corsMiddleware(tt.security))
+			router.GET("/api/v1/video/active", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(tt.method, "/api/v1/video/active", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			h := rec.Header()
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := h.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := h.Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+			if got := h.Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.wantMethods)
+			}
+			if tt.wantMethods != "" && h.Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" {
+				t.Errorf("Access-Control-Allow-Headers = %q", h.Get("Access-Control-Allow-Headers"))
+			}
+		})
+	}
+}
+
+func TestNewRouterGinMode(t *testing.T) {
+	previous := gin.Mode()
+	t.Cleanup(func() { gin.SetMode(previous) })
+	clientHandler, videoHandler, wsHandler := newTestRouterHandlers(t)
+
+	configured := func(level, ginMode string) string {
+		cfg := config.GetDefaultConfig()
+		cfg.Logging.Level = level
+		cfg.Logging.GinMode = ginMode
+		return cfg.GetGinMode()
+	}
+
+	tests := []struct {
+		name string
+		opts []RouterOption
+		want string
+	}{
+		{"default is release", nil, gin.ReleaseMode},
+		{"explicit mode", []RouterOption{WithGinMode(gin.TestMode)}, gin.TestMode},
+		{"config gin_mode", []RouterOption{WithGinMode(configured("info", "debug"))}, gin.DebugMode},
+		{"config debug level", []RouterOption{WithGinMode(configured("debug", ""))}, gin.DebugMode},
+		{"config info level", []RouterOption{WithGinMode(configured("info", ""))}, gin.ReleaseMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Режим от предыдущего случая не должен сохраняться
+			gin.SetMode(gin.DebugMode)
+			if tt.want == gin.DebugMode {
+				gin.SetMode(gin.TestMode)
+			}
+
+			NewRouter(clientHandler, videoHandler, wsHandler, zap.NewNop(), config.SecurityConfig{}, tt.opts...)
+			if got := gin.Mode(); got != tt.want {
+				t.Errorf("gin mode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"api-gateway/internal/tracing"
+)
+
+func TestTracingIngestSpanHierarchy(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(t.Context())
+	})
+
+	clientHandler, videoHandler, wsHandler := newTestRouterHandlers(t)
+	router := NewTestRouter(clientHandler, videoHandler, wsHandler, WithMiddleware(tracingMiddleware()))
+
+	body := `{"stream_id":"stream-1","client_id":"cam-1","frame":{"frame_data":"AQIDBA==","format":"jpeg"}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/video/frame", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	ingest, ok := spans["POST /api/v1/video/frame"]
+	if !ok {
+		t.Fatalf("ingest span not exported, got %v", spanNames(exporter.GetSpans()))
+	}
+	send, ok := spans["VideoStreamService.SendFrame"]
+	if !ok {
+		t.Fatalf("SendFrame span not exported, got %v", spanNames(exporter.GetSpans()))
+	}
+
+	if ingest.SpanKind != trace.SpanKindServer {
+		t.Errorf("ingest span kind = %v, want server", ingest.SpanKind)
+	}
+	if send.SpanContext.TraceID() != ingest.SpanContext.TraceID() {
+		t.Error("SendFrame span belongs to another trace")
+	}
+	if send.Parent.SpanID() != ingest.SpanContext.SpanID() {
+		t.Errorf("SendFrame parent = %s, want ingest span %s", send.Parent.SpanID(), ingest.SpanContext.SpanID())
+	}
+
+	want := []attribute.KeyValue{
+		tracing.AttrStreamID.String("stream-1"),
+		tracing.AttrClientID.String("cam-1"),
+		tracing.AttrFrameSize.Int(4),
+	}
+	for name, span := range map[string]tracetest.SpanStub{"ingest": ingest, "SendFrame": send} {
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes {
+			attrs[kv.Key] = kv.Value
+		}
+		for _, kv := range want {
+			if got, ok := attrs[kv.Key]; !ok || got != kv.Value {
+				t.Errorf("%s span %s = %v, want %v", name, kv.Key, got.Emit(), kv.Value.Emit())
+			}
+		}
+	}
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
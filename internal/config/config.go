@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,7 +15,25 @@ type Config struct {
 	Port int    `yaml:"port"`
 
 	// gRPC
-	GRPCPort string `yaml:"grpc_port"`
+	GRPCPort           string `yaml:"grpc_port"`
+	GRPCHandlerTimeout int    `yaml:"grpc_handler_timeout"` // таймаут unary RPC без дедлайна клиента, секунды
+
+	ShutdownTimeout int `yaml:"shutdown_timeout"` // ожидание текущих HTTP/gRPC запросов при остановке, секунды
+
+	// Server HTTP/WebSocket сервер шлюза (internal/gateway)
+	Server struct {
+		// Адрес слушает шлюз из host и port; WebSocket на том же адресе
+		EnableTLS   bool   `yaml:"enable_tls"`
+		TLSCert     string `yaml:"tls_cert"`
+		TLSKey      string `yaml:"tls_key"`
+		ReadTimeout int    `yaml:"read_timeout"` // секунды
+		IdleTimeout int    `yaml:"idle_timeout"` // секунды
+
+		HTTP2 bool `yaml:"http2"` // HTTP/2 поверх TLS (ALPN h2); без TLS не действует
+		H2C   bool `yaml:"h2c"`   // HTTP/2 без TLS (prior knowledge), например за балансировщиком
+		// Одновременных потоков на HTTP/2 соединение (0 - по умолчанию, 250)
+		HTTP2MaxConcurrentStreams int `yaml:"http2_max_concurrent_streams"`
+	} `yaml:"server"`
 
 	// Database
 	Database struct {
@@ -32,47 +53,382 @@ type Config struct {
 		DB       int    `yaml:"db"`
 	} `yaml:"redis"`
 
+	// ClientStore хранилище зарегистрированных клиентов
+	ClientStore ClientStoreConfig `yaml:"client_store"`
+
+	// Pipeline цепочки процессоров фреймов шлюза
+	Pipeline PipelineConfig `yaml:"pipeline"`
+
 	// JWT
 	JWT struct {
 		Secret     string `yaml:"secret"`
 		Expiration int    `yaml:"expiration"`
 	} `yaml:"jwt"`
 
+	// Security (CORS)
+	Security SecurityConfig `yaml:"security"`
+
+	// Auth дополнительные способы аутентификации
+	Auth struct {
+		APIKeys []APIKey `yaml:"api_keys"` // ключи для заголовка X-API-Key
+
+		// client_id -> общий секрет edge устройства. Кадры этих клиентов
+		// (и кадры в их стримы) должны нести X-Frame-Timestamp и
+		// X-Frame-Signature - HMAC-SHA256 "<timestamp>.<тело>"; остальные
+		// клиенты подпись не передают.
+		FrameSigningKeys map[string]string `yaml:"frame_signing_keys"`
+		// FrameSignatureWindow допустимое расхождение X-Frame-Timestamp с
+		// часами шлюза, секунды; в его пределах подпись принимается один раз
+		FrameSignatureWindow int `yaml:"frame_signature_window"`
+
+		// WebSocketRequired требует JWT при подключении к /ws/video
+		WebSocketRequired bool `yaml:"websocket_required"`
+		// HTTPRequired требует X-API-Key или Bearer JWT на /api/v1/*
+		HTTPRequired bool `yaml:"http_required"`
+
+		// Права на подписку: роли-администраторы видят все каналы,
+		// channel_owners - камеры/стримы пользователя (sub токена)
+		AdminRoles    []string            `yaml:"admin_roles"`
+		ChannelOwners map[string][]string `yaml:"channel_owners"`
+		// Теги доступа пользователя (sub токена) к фреймам с access_tags,
+		// дополняют claim "access_tags"
+		AccessTags map[string][]string `yaml:"access_tags"`
+	} `yaml:"auth"`
+
 	// Logging
 	Logging struct {
 		Level  string `yaml:"level"`
 		Format string `yaml:"format"`
+		// Запросы дольше этого логируются на уровне warn со всеми деталями,
+		// мс (0 - не выделять медленные запросы)
+		SlowRequestMs int `yaml:"slow_request_ms"`
+		// Режим Gin: debug, release или test. Пусто - debug при level debug,
+		// иначе release.
+		GinMode string `yaml:"gin_mode"`
 	} `yaml:"logging"`
 
 	// Video settings
 	Video struct {
-		MaxFrameSize int    `yaml:"max_frame_size"`
-		MaxFPS       int    `yaml:"max_fps"`
-		Codec        string `yaml:"codec"`
+		MaxFrameSize   int    `yaml:"max_frame_size"`
+		MaxFPS         int    `yaml:"max_fps"`
+		Codec          string `yaml:"codec"`
+		MaxBatchFrames int    `yaml:"max_batch_frames"` // кадров в одном запросе /video/frames
+		MaxBatchBytes  int    `yaml:"max_batch_bytes"`  // тело запроса /video/frames, байты
+		// Смена формата кадров посреди стрима: "allow", "warn" (лог) или
+		// "reject" (кадр отклоняется)
+		FormatChangePolicy string `yaml:"format_change_policy"`
+		// Невалидный metadata multipart кадра: true - 400, false - значения по
+		// умолчанию и предупреждение в ответе
+		StrictMetadata bool `yaml:"strict_metadata"`
+		// Кадры по частям (POST /video/frame/chunk): предел собранного кадра,
+		// байты, и время на доставку всех частей, секунды. Часть ограничена
+		// MaxFrameSize.
+		MaxChunkedFrameSize int `yaml:"max_chunked_frame_size"`
+		ChunkTimeout        int `yaml:"chunk_timeout"`
+		// Незавершенные сборки кадров по частям: всего и на клиента, штук и
+		// байт
+		MaxPendingChunkedFrames int `yaml:"max_pending_chunked_frames"`
+		MaxPendingChunkBytes    int `yaml:"max_pending_chunk_bytes"`
+		MaxClientChunkedFrames  int `yaml:"max_client_chunked_frames"`
+		MaxClientChunkBytes     int `yaml:"max_client_chunk_bytes"`
 	} `yaml:"video"`
+
+	// Gateway
+	Gateway struct {
+		BufferSize int `yaml:"buffer_size"` // очередь входящих фреймов; размер фрейма - video.max_frame_size
+
+		SendWorkers   int `yaml:"send_workers"`    // воркеры отправки фреймов в сервисы
+		SendQueueSize int `yaml:"send_queue_size"` // глубина очереди заданий на отправку
+		// ожидание места в очереди отправки, затем фрейм отбрасывается, мс
+		EnqueueTimeoutMs int `yaml:"enqueue_timeout_ms"`
+		// Предел обработки фрейма от приема до отправки во все сервисы, мс
+		// (0 - только таймауты сервисов)
+		FrameTimeoutMs int `yaml:"frame_timeout_ms"`
+
+		MaxConnections          int `yaml:"max_connections"`            // всего WebSocket соединений (0 - без лимита)
+		MaxConnectionsPerIP     int `yaml:"max_connections_per_ip"`     // WebSocket соединений с одного IP (0 - без лимита)
+		MaxConnectionsPerClient int `yaml:"max_connections_per_client"` // WebSocket соединений одного client_id (0 - без лимита)
+
+		// Очередь фреймов WebSocket соединения; при переполнении накопленные
+		// фреймы выбрасываются. ClientSendBuffers - размер по client_id.
+		SendBufferSize    int            `yaml:"send_buffer_size"`
+		ClientSendBuffers map[string]int `yaml:"client_send_buffers"`
+
+		MaxStreams int `yaml:"max_streams"` // одновременно активных стримов (0 - без лимита)
+
+		PingInterval int `yaml:"ping_interval"` // период ping WebSocket клиентам, секунды
+		PongTimeout  int `yaml:"pong_timeout"`  // ожидание pong/сообщения от клиента, секунды
+		WriteTimeout int `yaml:"write_timeout"` // таймаут записи в WebSocket, секунды
+
+		MaxMessageSize   int `yaml:"max_message_size"`   // максимальный размер входящего WebSocket сообщения, байты
+		ControlRateLimit int `yaml:"control_rate_limit"` // команд в секунду на управляющее соединение и на client_id (0 - без лимита)
+		// Где считать лимит на client_id: "local" - в процессе, "redis" - общий
+		// для всех реплик (секция redis); при недоступности Redis - локально
+		RateLimitBackend string `yaml:"rate_limit_backend"`
+
+		AdminToken string `yaml:"admin_token"` // Bearer токен для /api/v1/admin/* (пустой - админ API выключен)
+
+		ShutdownReconnectDelay int `yaml:"shutdown_reconnect_delay"` // рекомендуемая клиентам задержка переподключения при остановке, секунды
+		SessionTimeout         int `yaml:"session_timeout"`          // неактивный клиент отключается, секунды
+		// Сколько секунд после обрыва WebSocket клиент может восстановить
+		// подписки по resume_token (0 - восстановление выключено)
+		ResumeGracePeriod int `yaml:"resume_grace_period"`
+
+		// Повтор для поздних подписчиков: новый подписчик канала сначала
+		// получает последний ключевой кадр и первые ReplayFrames кадров после него
+		ReplayEnabled   bool     `yaml:"replay_enabled"`
+		ReplayFrames    int      `yaml:"replay_frames"`
+		KeyframeFormats []string `yaml:"keyframe_formats"` // форматы, где каждый кадр ключевой; иначе metadata keyframe
+	} `yaml:"gateway"`
+
+	// Limits ограничения API видеостримов
+	Limits struct {
+		MaxConcurrentStarts int `yaml:"max_concurrent_starts"`  // одновременных StartStream (0 - без лимита)
+		StartQueueTimeoutMs int `yaml:"start_queue_timeout_ms"` // ожидание свободного слота, затем отказ
+
+		MaxStreamBitrate  int `yaml:"max_stream_bitrate"`   // бит/с на один стрим (0 - без лимита)
+		ThrottleMaxWaitMs int `yaml:"throttle_max_wait_ms"` // ожидание кадра сверх лимита, затем отказ
+
+		StreamIdleTimeout int `yaml:"stream_idle_timeout"` // стрим без кадров дольше этого останавливается, секунды (0 - никогда)
+
+		IdempotencyTTL     int `yaml:"idempotency_ttl"`      // хранение ответа StartStream/StopStream по Idempotency-Key, секунды
+		IdempotencyMaxKeys int `yaml:"idempotency_max_keys"` // хранимых ключей, сверх - вытесняются самые старые
+	} `yaml:"limits"`
+
+	// Services
+	Services struct {
+		// URL эндпоинтов по типам сервисов
+		VideoProcessing []string `yaml:"video_processing"`
+		Analytics       []string `yaml:"analytics"`
+		Storage         []string `yaml:"storage"`
+		Notification    []string `yaml:"notification"`
+
+		Required []string               `yaml:"required"` // типы сервисов, без которых прием фреймов отклоняется
+		Batching map[string]BatchConfig `yaml:"batching"` // тип сервиса -> пакетная отправка фреймов
+		// URL эндпоинта -> коды ответа, считающиеся успехом (по умолчанию любой 2xx)
+		AcceptedStatuses map[string][]int `yaml:"accepted_statuses"`
+		// URL эндпоинта -> проверка здоровья (по умолчанию GET <url>/health,
+		// ожидается 200)
+		HealthChecks map[string]HealthCheckConfig `yaml:"health_checks"`
+
+		// Тип сервиса -> таймаут запроса, мс (по умолчанию HTTPClient.Timeout)
+		Timeouts map[string]int `yaml:"timeouts_ms"`
+		// Теневые эндпоинты: получают копию доли фреймов, их ответы не
+		// влияют на результат маршрутизации
+		Shadow []ShadowConfig `yaml:"shadow"`
+		// camera_id -> URL эндпоинта video_processing: кадры камеры уходят
+		// только на него (пока он здоров), остальные - на все эндпоинты
+		CameraRoutes map[string]string `yaml:"camera_routes"`
+		// Тип сервиса -> доля фреймов, которая в него отправляется (по
+		// умолчанию все фреймы)
+		Sampling map[string]SamplingConfig `yaml:"sampling"`
+		// Повторы отправки при временных ошибках (сеть, 5xx, 408, 429) с
+		// экспоненциальной задержкой и jitter; все попытки укладываются в
+		// таймаут сервиса. max_attempts 1 - без повторов.
+		Retry struct {
+			MaxAttempts      int `yaml:"max_attempts"`
+			InitialBackoffMs int `yaml:"initial_backoff_ms"`
+			MaxBackoffMs     int `yaml:"max_backoff_ms"`
+		} `yaml:"retry"`
+		// Прерыватель эндпоинта: после FailureThreshold неуспешных запросов
+		// подряд (отправка или прокси) эндпоинт выводится из ротации до
+		// успешного health check
+		Breaker struct {
+			FailureThreshold int `yaml:"failure_threshold"`
+		} `yaml:"breaker"`
+
+		// Период и таймаут health check эндпоинтов, секунды
+		HealthCheckInterval int `yaml:"health_check_interval"`
+		HealthCheckTimeout  int `yaml:"health_check_timeout"`
+
+		// HTTP клиент для запросов к сервисам; все значения в секундах, кроме
+		// числа соединений; 0 - значение по умолчанию. Timeout - таймаут
+		// запроса для типов сервисов без записи в Timeouts.
+		HTTPClient struct {
+			Timeout             int `yaml:"timeout"`
+			DialTimeout         int `yaml:"dial_timeout"`
+			KeepAlive           int `yaml:"keep_alive"`
+			TLSHandshakeTimeout int `yaml:"tls_handshake_timeout"`
+			IdleConnTimeout     int `yaml:"idle_conn_timeout"`
+			MaxIdleConns        int `yaml:"max_idle_conns"`
+			MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`
+			MaxConnsPerHost     int `yaml:"max_conns_per_host"` // всего соединений к одному сервису (0 - без лимита)
+		} `yaml:"http_client"`
+	} `yaml:"services"`
+
+	// Партнерские сервисы: фреймы, подходящие под правило, дополнительно
+	// отправляются партнеру с наивысшим приоритетом (меньшее значение)
+	Partners     []PartnerConfig `yaml:"partners"`
+	RoutingRules []RoutingRule   `yaml:"routing_rules"`
+
+	// Proxy сквозной доступ к сервисам: /proxy/<имя>/<путь> проксируется на
+	// здоровый эндпоинт типа сервиса с таймаутом этого типа
+	Proxy struct {
+		Routes       map[string]string `yaml:"routes"`        // имя в URL -> тип сервиса
+		AuthRequired bool              `yaml:"auth_required"` // требовать Bearer JWT
+	} `yaml:"proxy"`
+
+	// Health пороги сигналов /api/v1/health в процентах (0 - сигнал выключен)
+	Health struct {
+		QueueDegradedPercent     int `yaml:"queue_degraded_percent"` // заполнение очередей фреймов
+		QueueUnhealthyPercent    int `yaml:"queue_unhealthy_percent"`
+		FailRateDegradedPercent  int `yaml:"fail_rate_degraded_percent"` // доля неудачных отправок в сервисы
+		FailRateUnhealthyPercent int `yaml:"fail_rate_unhealthy_percent"`
+		// Окно доли неудачных отправок, секунды (0 - за все время), и минимум
+		// отправок в окне, с которого сигнал учитывается
+		FailRateWindow      int `yaml:"fail_rate_window"`
+		FailRateMinRequests int `yaml:"fail_rate_min_requests"`
+		// Пороги числа нездоровых эндпоинтов сервисов (0 - выключен)
+		UnhealthyServicesDegraded  int `yaml:"unhealthy_services_degraded"`
+		UnhealthyServicesUnhealthy int `yaml:"unhealthy_services_unhealthy"`
+		// Код ответа при статусе degraded (unhealthy - всегда 503)
+		DegradedStatusCode int `yaml:"degraded_status_code"`
+	} `yaml:"health"`
+
+	// Metrics куда выгружать учет шлюза
+	Metrics struct {
+		Backend       string `yaml:"backend"`        // memory (по умолчанию), prometheus (/metrics), statsd
+		Prefix        string `yaml:"prefix"`         // префикс имен метрик
+		StatsDAddress string `yaml:"statsd_address"` // host:port для backend: statsd
+	} `yaml:"metrics"`
+
+	// Tracing (OpenTelemetry)
+	Tracing struct {
+		Enabled      bool    `yaml:"enabled"`
+		ServiceName  string  `yaml:"service_name"`
+		OTLPEndpoint string  `yaml:"otlp_endpoint"`
+		Insecure     bool    `yaml:"insecure"`
+		SampleRatio  float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+
+	// Debug отдельный admin HTTP сервер для диагностики
+	Debug struct {
+		Pprof        bool   `yaml:"pprof"`         // /debug/pprof на admin_address
+		AdminAddress string `yaml:"admin_address"` // host:port admin сервера, по умолчанию только localhost
+	} `yaml:"debug"`
+}
+
+// BatchConfig настройки пакетной отправки фреймов в сервис. Пакет
+// отправляется, когда набралось MaxFrames фреймов или прошло WindowMs
+// с первого фрейма пакета.
+type BatchConfig struct {
+	MaxFrames int `yaml:"max_frames"`
+	WindowMs  int `yaml:"window_ms"`
 }
 
-// LoadConfig загружает конфигурацию из файла
+// HealthCheckConfig проверка здоровья эндпоинта. Path дописывается к пути
+// URL эндпоинта (по умолчанию "/health") или задается полным URL.
+// ExpectStatus - ожидаемый код ответа (по умолчанию 200), ExpectJSON -
+// поля, которые должны быть в JSON объекте ответа с такими значениями.
+type HealthCheckConfig struct {
+	Path         string                 `yaml:"path"`
+	ExpectStatus int                    `yaml:"expect_status"`
+	ExpectJSON   map[string]interface{} `yaml:"expect_json"`
+}
+
+// SamplingConfig выборка фреймов для типа сервиса. Percent - доля фреймов
+// (0-100). Mode "interval" (по умолчанию) отправляет ровно каждый
+// 100/Percent-й фрейм по порядку поступления, "random" - каждый фрейм
+// независимо с вероятностью Percent%.
+type SamplingConfig struct {
+	Percent float64 `yaml:"percent"`
+	Mode    string  `yaml:"mode"`
+}
+
+// ShadowConfig теневой эндпоинт сервиса. Percent - доля фреймов этого
+// типа сервиса (0-100), которая дублируется на эндпоинт.
+type ShadowConfig struct {
+	Service string  `yaml:"service"`
+	URL     string  `yaml:"url"`
+	Percent float64 `yaml:"percent"`
+}
+
+// ClientStoreConfig хранилище клиентов ClientInfoService. Backend "memory"
+// хранит клиентов в процессе, "redis" - в Redis (секция redis), общем для
+// всех реплик. TTL - секунды без подтверждения присутствия, после которых
+// клиент считается отключенным (например, после падения реплики); реплика
+// подтверждает своих клиентов каждые TTL/3 (0 - 60 секунд).
+type ClientStoreConfig struct {
+	Backend   string `yaml:"backend"`
+	KeyPrefix string `yaml:"key_prefix"`
+	TTL       int    `yaml:"ttl"`
+}
+
+// PipelineConfig цепочки процессоров, через которые проходит фрейм перед
+// маршрутизацией. Streams задает цепочку для camera_id и заменяет Default.
+// Встроенные процессоры: validate, dedup (последние DedupWindow frame_id
+// стрима), watermark (metadata watermark = Watermark) и metrics. Custom -
+// имена процессоров, которые приложение регистрирует в коде
+// (FramePipeline.RegisterProcessor); остальные имена в цепочках - ошибка
+// конфигурации.
+type PipelineConfig struct {
+	Default     []string            `yaml:"default"`
+	Streams     map[string][]string `yaml:"streams"`
+	Custom      []string            `yaml:"custom"`
+	DedupWindow int                 `yaml:"dedup_window"`
+	Watermark   string              `yaml:"watermark"`
+}
+
+// BuiltinFrameProcessors имена встроенных процессоров конвейера
+var BuiltinFrameProcessors = []string{"validate", "dedup", "watermark", "metrics"}
+
+// SecurityConfig настройки CORS и доверенных прокси. Origin "*" разрешает
+// любой источник, но без credentials; конкретные origin возвращаются в
+// ответе как есть и допускают credentials.
+type SecurityConfig struct {
+	EnableCORS     bool     `yaml:"enable_cors"`
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	AllowedMethods []string `yaml:"allowed_methods"`
+	AllowedHeaders []string `yaml:"allowed_headers"`
+
+	// IP и CIDR прокси, от которых принимаются X-Forwarded-For и
+	// X-Real-IP. Пусто - адрес клиента берется из соединения.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// APIKey ключ server-to-server интеграции. В конфиге хранится только
+// SHA-256 хеш ключа в hex, а не сам ключ.
+type APIKey struct {
+	KeyHash  string `yaml:"key_hash"`
+	ClientID string `yaml:"client_id"`
+	UserID   string `yaml:"user_id"`
+	Admin    bool   `yaml:"admin"` // права администратора (/api/v1/admin/*)
+}
+
+// LoadConfig загружает конфигурацию из файла поверх значений по умолчанию,
+// подставляет JWT_SECRET из окружения и проверяет результат (Validate)
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	cfg := GetDefaultConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
 
-	return &cfg, nil
+	// Секрет JWT удобнее не хранить в файле
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		cfg.JWT.Secret = secret
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
 }
 
 // GetDefaultConfig возвращает конфигурацию по умолчанию
 func GetDefaultConfig() *Config {
-	return &Config{
+	cfg := &Config{
 		Host:     "localhost",
 		Port:     8080,
 		GRPCPort: "9090",
+
+		GRPCHandlerTimeout: 30,
+		ShutdownTimeout:    10,
 		Database: struct {
 			Host     string `yaml:"host"`
 			Port     int    `yaml:"port"`
@@ -103,24 +459,343 @@ func GetDefaultConfig() *Config {
 			Secret     string `yaml:"secret"`
 			Expiration int    `yaml:"expiration"`
 		}{
-			Secret:     "your-secret-key-change-in-production",
+			Secret:     "",
 			Expiration: 24,
 		},
 		Logging: struct {
 			Level  string `yaml:"level"`
 			Format string `yaml:"format"`
+			// Запросы дольше этого логируются на уровне warn со всеми деталями,
+			// мс (0 - не выделять медленные запросы)
+			SlowRequestMs int `yaml:"slow_request_ms"`
+			// Режим Gin: debug, release или test. Пусто - debug при level debug,
+			// иначе release.
+			GinMode string `yaml:"gin_mode"`
 		}{
 			Level:  "info",
 			Format: "json",
+
+			SlowRequestMs: 1000,
 		},
 		Video: struct {
-			MaxFrameSize int    `yaml:"max_frame_size"`
-			MaxFPS       int    `yaml:"max_fps"`
-			Codec        string `yaml:"codec"`
+			MaxFrameSize   int    `yaml:"max_frame_size"`
+			MaxFPS         int    `yaml:"max_fps"`
+			Codec          string `yaml:"codec"`
+			MaxBatchFrames int    `yaml:"max_batch_frames"` // кадров в одном запросе /video/frames
+			MaxBatchBytes  int    `yaml:"max_batch_bytes"`  // тело запроса /video/frames, байты
+			// Смена формата кадров посреди стрима: "allow", "warn" (лог) или
+			// "reject" (кадр отклоняется)
+			FormatChangePolicy string `yaml:"format_change_policy"`
+			// Невалидный metadata multipart кадра: true - 400, false - значения по
+			// умолчанию и предупреждение в ответе
+			StrictMetadata bool `yaml:"strict_metadata"`
+			// Кадры по частям (POST /video/frame/chunk): предел собранного кадра,
+			// байты, и время на доставку всех частей, секунды. Часть ограничена
+			// MaxFrameSize.
+			MaxChunkedFrameSize int `yaml:"max_chunked_frame_size"`
+			ChunkTimeout        int `yaml:"chunk_timeout"`
+			// Незавершенные сборки кадров по частям: всего и на клиента, штук и
+			// байт
+			MaxPendingChunkedFrames int `yaml:"max_pending_chunked_frames"`
+			MaxPendingChunkBytes    int `yaml:"max_pending_chunk_bytes"`
+			MaxClientChunkedFrames  int `yaml:"max_client_chunked_frames"`
+			MaxClientChunkBytes     int `yaml:"max_client_chunk_bytes"`
 		}{
 			MaxFrameSize: 10 * 1024 * 1024, // 10MB
 			MaxFPS:       30,
 			Codec:        "h264",
+
+			MaxBatchFrames: 100,
+			MaxBatchBytes:  16 * 1024 * 1024, // 16MB
+
+			FormatChangePolicy: "warn",
+
+			MaxChunkedFrameSize: 64 * 1024 * 1024, // 64MB
+			ChunkTimeout:        30,
+
+			MaxPendingChunkedFrames: 256,
+			MaxPendingChunkBytes:    512 * 1024 * 1024, // 512MB
+			MaxClientChunkedFrames:  16,
+			MaxClientChunkBytes:     128 * 1024 * 1024, // 128MB
 		},
 	}
+
+	cfg.Security.EnableCORS = true
+	cfg.Security.AllowedOrigins = []string{"*"}
+	cfg.Security.AllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"}
+	cfg.Security.AllowedHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "X-Requested-With", "Cache-Control", "X-Request-ID", "Idempotency-Key"}
+
+	cfg.Auth.WebSocketRequired = true
+	cfg.Auth.HTTPRequired = true
+	cfg.Auth.AdminRoles = []string{"admin"}
+	cfg.Auth.FrameSignatureWindow = 300
+
+	cfg.Server.ReadTimeout = 30
+	cfg.Server.IdleTimeout = 120
+	cfg.Server.HTTP2 = true
+
+	cfg.ClientStore.Backend = "memory"
+	cfg.ClientStore.KeyPrefix = "api-gateway:clients:"
+	cfg.ClientStore.TTL = 60
+	cfg.Pipeline.DedupWindow = 64
+
+	cfg.Gateway.BufferSize = 1000
+	cfg.Gateway.SendWorkers = 16
+	cfg.Gateway.SendQueueSize = 1024
+	cfg.Gateway.EnqueueTimeoutMs = 100
+	cfg.Gateway.FrameTimeoutMs = 30000
+	cfg.Gateway.MaxConnections = 10000
+	cfg.Gateway.MaxConnectionsPerIP = 50
+	cfg.Gateway.MaxConnectionsPerClient = 5
+	cfg.Gateway.SendBufferSize = 100
+	cfg.Gateway.MaxStreams = 1000
+	cfg.Gateway.PingInterval = 30
+	cfg.Gateway.PongTimeout = 60
+	cfg.Gateway.WriteTimeout = 10
+	cfg.Gateway.MaxMessageSize = 64 * 1024
+	cfg.Gateway.ControlRateLimit = 20
+	cfg.Gateway.RateLimitBackend = "local"
+	cfg.Gateway.ShutdownReconnectDelay = 5
+	cfg.Gateway.SessionTimeout = 300
+	cfg.Gateway.ResumeGracePeriod = 30
+	cfg.Gateway.ReplayEnabled = true
+	cfg.Gateway.KeyframeFormats = []string{"jpeg", "jpg", "png"}
+
+	cfg.Services.HealthCheckInterval = 30
+	cfg.Services.Retry.MaxAttempts = 3
+	cfg.Services.Retry.InitialBackoffMs = 50
+	cfg.Services.Retry.MaxBackoffMs = 1000
+	cfg.Services.Breaker.FailureThreshold = 5
+
+	cfg.Limits.MaxConcurrentStarts = 32
+	cfg.Limits.StartQueueTimeoutMs = 500
+	cfg.Limits.ThrottleMaxWaitMs = 200
+	cfg.Limits.StreamIdleTimeout = 300
+	cfg.Limits.IdempotencyTTL = 86400
+	cfg.Limits.IdempotencyMaxKeys = 10000
+
+	cfg.Health.QueueDegradedPercent = 90
+	cfg.Health.QueueUnhealthyPercent = 100
+	cfg.Health.FailRateDegradedPercent = 10
+	cfg.Health.FailRateUnhealthyPercent = 50
+	cfg.Health.FailRateWindow = 60
+	cfg.Health.FailRateMinRequests = 10
+	cfg.Health.DegradedStatusCode = 200
+
+	cfg.Metrics.Backend = "memory"
+	cfg.Metrics.Prefix = "api_gateway"
+
+	cfg.Tracing.ServiceName = "api-gateway"
+	cfg.Tracing.OTLPEndpoint = "localhost:4317"
+	cfg.Tracing.Insecure = true
+	cfg.Tracing.SampleRatio = 1.0
+
+	cfg.Debug.AdminAddress = "127.0.0.1:6060"
+
+	return cfg
+}
+
+// Addr возвращает адрес HTTP сервера в виде host:port
+func (c *Config) Addr() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// GetPongTimeout возвращает время ожидания pong от WebSocket клиента
+func (c *Config) GetPongTimeout() time.Duration {
+	if c.Gateway.PongTimeout <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.Gateway.PongTimeout) * time.Second
+}
+
+// GetPingInterval возвращает период ping. Он всегда меньше таймаута pong,
+// иначе живое соединение будет закрываться между пингами.
+func (c *Config) GetPingInterval() time.Duration {
+	pongTimeout := c.GetPongTimeout()
+	interval := time.Duration(c.Gateway.PingInterval) * time.Second
+	if interval <= 0 || interval >= pongTimeout {
+		interval = pongTimeout * 9 / 10
+	}
+	return interval
+}
+
+// GetWriteTimeout возвращает таймаут записи в WebSocket
+func (c *Config) GetWriteTimeout() time.Duration {
+	if c.Gateway.WriteTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.Gateway.WriteTimeout) * time.Second
+}
+
+// GetGRPCHandlerTimeout возвращает таймаут для unary RPC, у которых клиент
+// не задал дедлайн
+func (c *Config) GetGRPCHandlerTimeout() time.Duration {
+	if c.GRPCHandlerTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.GRPCHandlerTimeout) * time.Second
+}
+
+// GetMaxMessageSize возвращает лимит размера входящего WebSocket сообщения
+func (c *Config) GetMaxMessageSize() int64 {
+	if c.Gateway.MaxMessageSize <= 0 {
+		return 64 * 1024
+	}
+	return int64(c.Gateway.MaxMessageSize)
+}
+
+// GetShutdownReconnectDelay возвращает задержку переподключения,
+// которую шлюз советует клиентам при остановке
+func (c *Config) GetShutdownReconnectDelay() time.Duration {
+	if c.Gateway.ShutdownReconnectDelay <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.Gateway.ShutdownReconnectDelay) * time.Second
+}
+
+// GetEnqueueTimeout возвращает ожидание места в очереди отправки в сервисы
+func (c *Config) GetEnqueueTimeout() time.Duration {
+	if c.Gateway.EnqueueTimeoutMs <= 0 {
+		return 100 * time.Millisecond
+	}
+	return time.Duration(c.Gateway.EnqueueTimeoutMs) * time.Millisecond
+}
+
+// GetFrameTimeout возвращает предел обработки фрейма (0 - без предела)
+func (c *Config) GetFrameTimeout() time.Duration {
+	return time.Duration(c.Gateway.FrameTimeoutMs) * time.Millisecond
+}
+
+// GetFrameSignatureWindow возвращает окно действия подписи кадра
+func (c *Config) GetFrameSignatureWindow() time.Duration {
+	if c.Auth.FrameSignatureWindow <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.Auth.FrameSignatureWindow) * time.Second
+}
+
+// GetShutdownTimeout возвращает дедлайн graceful остановки серверов
+func (c *Config) GetShutdownTimeout() time.Duration {
+	if c.ShutdownTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.ShutdownTimeout) * time.Second
+}
+
+// GetReadTimeout возвращает таймаут чтения запроса HTTP сервером шлюза
+func (c *Config) GetReadTimeout() time.Duration {
+	if c.Server.ReadTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Server.ReadTimeout) * time.Second
+}
+
+// GetIdleTimeout возвращает время жизни простаивающего keep-alive соединения
+func (c *Config) GetIdleTimeout() time.Duration {
+	if c.Server.IdleTimeout <= 0 {
+		return 120 * time.Second
+	}
+	return time.Duration(c.Server.IdleTimeout) * time.Second
+}
+
+// GetBreakerFailureThreshold возвращает число неуспешных запросов подряд,
+// после которого эндпоинт выводится из ротации
+func (c *Config) GetBreakerFailureThreshold() int {
+	if c.Services.Breaker.FailureThreshold <= 0 {
+		return 5
+	}
+	return c.Services.Breaker.FailureThreshold
+}
+
+// GetHealthCheckInterval возвращает период проверки сервисов и очистки
+// неактивных клиентов
+func (c *Config) GetHealthCheckInterval() time.Duration {
+	if c.Services.HealthCheckInterval <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Services.HealthCheckInterval) * time.Second
+}
+
+// GetSessionTimeout возвращает время неактивности, после которого клиент
+// отключается
+func (c *Config) GetSessionTimeout() time.Duration {
+	if c.Gateway.SessionTimeout <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.Gateway.SessionTimeout) * time.Second
+}
+
+// GetRetryInitialBackoff возвращает задержку перед первым повтором отправки
+// в сервис
+func (c *Config) GetRetryInitialBackoff() time.Duration {
+	if c.Services.Retry.InitialBackoffMs <= 0 {
+		return 50 * time.Millisecond
+	}
+	return time.Duration(c.Services.Retry.InitialBackoffMs) * time.Millisecond
+}
+
+// GetRetryMaxBackoff возвращает потолок задержки между повторами
+func (c *Config) GetRetryMaxBackoff() time.Duration {
+	if c.Services.Retry.MaxBackoffMs <= 0 {
+		return time.Second
+	}
+	return time.Duration(c.Services.Retry.MaxBackoffMs) * time.Millisecond
+}
+
+// GetResumeGracePeriod возвращает окно восстановления WebSocket сессии
+// после обрыва; 0 - восстановление выключено
+func (c *Config) GetResumeGracePeriod() time.Duration {
+	if c.Gateway.ResumeGracePeriod <= 0 {
+		return 0
+	}
+	return time.Duration(c.Gateway.ResumeGracePeriod) * time.Second
+}
+
+// GetHTTPProtocols возвращает протоколы HTTP сервера: HTTP/1.1 всегда,
+// HTTP/2 поверх TLS и h2c по секции server
+func (c *Config) GetHTTPProtocols() *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(c.Server.HTTP2)
+	protocols.SetUnencryptedHTTP2(c.Server.H2C)
+	return protocols
+}
+
+// GetHTTP2Config возвращает настройки HTTP/2 сервера
+func (c *Config) GetHTTP2Config() *http.HTTP2Config {
+	return &http.HTTP2Config{MaxConcurrentStreams: c.Server.HTTP2MaxConcurrentStreams}
+}
+
+// GetSlowRequestThreshold возвращает порог медленного HTTP запроса
+// (0 - медленные запросы не выделяются)
+func (c *Config) GetSlowRequestThreshold() time.Duration {
+	return time.Duration(c.Logging.SlowRequestMs) * time.Millisecond
+}
+
+// GetGinMode возвращает режим Gin: logging.gin_mode, а если он не задан -
+// debug при logging.level debug, иначе release
+func (c *Config) GetGinMode() string {
+	if c.Logging.GinMode != "" {
+		return c.Logging.GinMode
+	}
+	if c.Logging.Level == "debug" {
+		return "debug"
+	}
+	return "release"
+}
+
+// GetChunkTimeout возвращает время на доставку всех частей кадра
+func (c *Config) GetChunkTimeout() time.Duration {
+	return time.Duration(c.Video.ChunkTimeout) * time.Second
+}
+
+// GetFailRateWindow возвращает окно доли неудачных отправок (0 - за все время)
+func (c *Config) GetFailRateWindow() time.Duration {
+	return time.Duration(c.Health.FailRateWindow) * time.Second
+}
+
+// GetClientStoreTTL возвращает срок присутствия клиента в хранилище без
+// подтверждения (0 - по умолчанию хранилища)
+func (c *Config) GetClientStoreTTL() time.Duration {
+	return time.Duration(c.ClientStore.TTL) * time.Second
 }
@@ -0,0 +1,280 @@
+package config
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fullConfigYAML задает все секции, которые читает шлюз, значениями,
+// отличными от значений по умолчанию
+const fullConfigYAML = `
+host: 0.0.0.0
+port: 8081
+grpc_port: "9191"
+server:
+  enable_tls: true
+  tls_cert: /etc/gateway/tls.crt
+  tls_key: /etc/gateway/tls.key
+  read_timeout: 15
+  idle_timeout: 90
+  http2: false
+  h2c: true
+  http2_max_concurrent_streams: 100
+jwt:
+  secret: 0123456789abcdef0123456789abcdef
+security:
+  enable_cors: true
+  allowed_origins: ["https://app.example.com"]
+  allowed_methods: ["GET", "POST"]
+  allowed_headers: ["Authorization"]
+  trusted_proxies: ["10.0.0.0/8"]
+gateway:
+  buffer_size: 500
+  send_workers: 4
+  send_queue_size: 64
+  max_connections: 200
+  max_connections_per_ip: 10
+  max_streams: 20
+  ping_interval: 15
+  pong_timeout: 45
+  write_timeout: 5
+  max_message_size: 32768
+  admin_token: admin-secret
+  session_timeout: 120
+services:
+  video_processing: ["http://video:8080"]
+  analytics: ["http://analytics:8080"]
+  storage: ["http://storage:8080"]
+  notification: ["http://notify:8080"]
+  required: ["video_processing"]
+  timeouts_ms:
+    analytics: 250
+  retry:
+    max_attempts: 2
+    initial_backoff_ms: 20
+    max_backoff_ms: 200
+  breaker:
+    failure_threshold: 3
+  health_check_interval: 10
+  http_client:
+    timeout: 7
+    max_idle_conns_per_host: 32
+`
+
+func TestLoadConfigFullYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(fullConfigYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_SECRET", "")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	tests := []struct {
+		field string
+		got   interface{}
+		want  interface{}
+	}{
+		{"addr", cfg.Addr(), "0.0.0.0:8081"},
+		{"grpc_port", cfg.GRPCPort, "9191"},
+
+		{"server.enable_tls", cfg.Server.EnableTLS, true},
+		{"server.tls_cert", cfg.Server.TLSCert, "/etc/gateway/tls.crt"},
+		{"server.tls_key", cfg.Server.TLSKey, "/etc/gateway/tls.key"},
+		{"server.read_timeout", cfg.Server.ReadTimeout, 15},
+		{"server.idle_timeout", cfg.Server.IdleTimeout, 90},
+		{"server.http2", cfg.Server.HTTP2, false},
+		{"server.h2c", cfg.Server.H2C, true},
+		{"server.http2_max_concurrent_streams", cfg.Server.HTTP2MaxConcurrentStreams, 100},
+
+		{"security.allowed_origins", cfg.Security.AllowedOrigins, []string{"https://app.example.com"}},
+		{"security.allowed_methods", cfg.Security.AllowedMethods, []string{"GET", "POST"}},
+		{"security.allowed_headers", cfg.Security.AllowedHeaders, []string{"Authorization"}},
+		{"security.trusted_proxies", cfg.Security.TrustedProxies, []string{"10.0.0.0/8"}},
+
+		{"gateway.buffer_size", cfg.Gateway.BufferSize, 500},
+		{"gateway.send_workers", cfg.Gateway.SendWorkers, 4},
+		{"gateway.send_queue_size", cfg.Gateway.SendQueueSize, 64},
+		{"gateway.max_connections", cfg.Gateway.MaxConnections, 200},
+		{"gateway.max_connections_per_ip", cfg.Gateway.MaxConnectionsPerIP, 10},
+		{"gateway.max_streams", cfg.Gateway.MaxStreams, 20},
+		{"gateway.ping_interval", cfg.Gateway.PingInterval, 15},
+		{"gateway.pong_timeout", cfg.Gateway.PongTimeout, 45},
+		{"gateway.write_timeout", cfg.Gateway.WriteTimeout, 5},
+		{"gateway.max_message_size", cfg.Gateway.MaxMessageSize, 32768},
+		{"gateway.admin_token", cfg.Gateway.AdminToken, "admin-secret"},
+		{"gateway.session_timeout", cfg.Gateway.SessionTimeout, 120},
+
+		{"services.video_processing", cfg.Services.VideoProcessing, []string{"http://video:8080"}},
+		{"services.analytics", cfg.Services.Analytics, []string{"http://analytics:8080"}},
+		{"services.storage", cfg.Services.Storage, []string{"http://storage:8080"}},
+		{"services.notification", cfg.Services.Notification, []string{"http://notify:8080"}},
+		{"services.required", cfg.Services.Required, []string{"video_processing"}},
+		{"services.timeouts_ms", cfg.Services.Timeouts, map[string]int{"analytics": 250}},
+		{"services.retry.max_attempts", cfg.Services.Retry.MaxAttempts, 2},
+		{"services.retry.initial_backoff_ms", cfg.Services.Retry.InitialBackoffMs, 20},
+		{"services.retry.max_backoff_ms", cfg.Services.Retry.MaxBackoffMs, 200},
+		{"services.breaker.failure_threshold", cfg.Services.Breaker.FailureThreshold, 3},
+		{"services.health_check_interval", cfg.Services.HealthCheckInterval, 10},
+		{"services.http_client.timeout", cfg.Services.HTTPClient.Timeout, 7},
+		{"services.http_client.max_idle_conns_per_host", cfg.Services.HTTPClient.MaxIdleConnsPerHost, 32},
+
+		// Не заданные в файле поля сохраняют значения по умолчанию
+		{"gateway.max_connections_per_client", cfg.Gateway.MaxConnectionsPerClient, 5},
+		{"video.max_frame_size", cfg.Video.MaxFrameSize, 10 * 1024 * 1024},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.field, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigRepoFile(t *testing.T) {
+	t.Setenv("JWT_SECRET", "0123456789abcdef0123456789abcdef")
+
+	// Конфиг из репозитория должен загружаться и проходить Validate
+	cfg, err := LoadConfig(filepath.Join("..", "..", "config", "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port == 0 || cfg.Gateway.BufferSize == 0 || len(cfg.Security.AllowedMethods) == 0 {
+		t.Errorf("config sections are empty: port=%d buffer_size=%d methods=%v",
+			cfg.Port, cfg.Gateway.BufferSize, cfg.Security.AllowedMethods)
+	}
+}
+
+func TestDurationAccessors(t *testing.T) {
+	configured := validConfig()
+	configured.Server.ReadTimeout = 15
+	configured.Server.IdleTimeout = 90
+	configured.Services.HealthCheckInterval = 10
+	configured.Gateway.SessionTimeout = 120
+	configured.Gateway.PongTimeout = 40
+	configured.Gateway.PingInterval = 20
+	configured.Gateway.WriteTimeout = 3
+	configured.Services.Retry.InitialBackoffMs = 20
+	configured.Services.Retry.MaxBackoffMs = 400
+
+	// Нулевые значения - accessor подставляет значение по умолчанию
+	empty := &Config{}
+
+	// Ping не реже таймаута pong, иначе живое соединение закроется
+	pingAfterPong := &Config{}
+	pingAfterPong.Gateway.PongTimeout = 10
+	pingAfterPong.Gateway.PingInterval = 30
+
+	tests := []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{"configured read timeout", configured.GetReadTimeout(), 15 * time.Second},
+		{"configured idle timeout", configured.GetIdleTimeout(), 90 * time.Second},
+		{"configured health check interval", configured.GetHealthCheckInterval(), 10 * time.Second},
+		{"configured session timeout", configured.GetSessionTimeout(), 2 * time.Minute},
+		{"configured pong timeout", configured.GetPongTimeout(), 40 * time.Second},
+		{"configured ping interval", configured.GetPingInterval(), 20 * time.Second},
+		{"configured write timeout", configured.GetWriteTimeout(), 3 * time.Second},
+		{"configured retry initial backoff", configured.GetRetryInitialBackoff(), 20 * time.Millisecond},
+		{"configured retry max backoff", configured.GetRetryMaxBackoff(), 400 * time.Millisecond},
+
+		{"default read timeout", empty.GetReadTimeout(), 30 * time.Second},
+		{"default idle timeout", empty.GetIdleTimeout(), 120 * time.Second},
+		{"default health check interval", empty.GetHealthCheckInterval(), 30 * time.Second},
+		{"default session timeout", empty.GetSessionTimeout(), 5 * time.Minute},
+		{"default pong timeout", empty.GetPongTimeout(), 60 * time.Second},
+		{"default ping interval", empty.GetPingInterval(), 54 * time.Second},
+		{"default write timeout", empty.GetWriteTimeout(), 10 * time.Second},
+		{"default retry initial backoff", empty.GetRetryInitialBackoff(), 50 * time.Millisecond},
+		{"default retry max backoff", empty.GetRetryMaxBackoff(), time.Second},
+		{"ping interval capped by pong timeout", pingAfterPong.GetPingInterval(), 9 * time.Second},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Значения по умолчанию GetDefaultConfig совпадают с запасными значениями
+	// accessor'ов
+	defaults := GetDefaultConfig()
+	if defaults.GetReadTimeout() != empty.GetReadTimeout() ||
+		defaults.GetHealthCheckInterval() != empty.GetHealthCheckInterval() ||
+		defaults.GetSessionTimeout() != empty.GetSessionTimeout() {
+		t.Errorf("defaults differ from accessor fallbacks: read=%v health=%v session=%v",
+			defaults.GetReadTimeout(), defaults.GetHealthCheckInterval(), defaults.GetSessionTimeout())
+	}
+}
+
+func TestHTTPProtocolsNegotiated(t *testing.T) {
+	tests := []struct {
+		name      string
+		http2     bool
+		h2c       bool
+		tls       bool
+		wantProto string // "" - HTTP/2 клиент без TLS не может подключиться
+	}{
+		{"http2 over tls", true, false, true, "HTTP/2.0"},
+		{"http2 disabled", false, false, true, "HTTP/1.1"},
+		{"h2c", false, true, false, "HTTP/2.0"},
+		{"h2c disabled", true, false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := GetDefaultConfig()
+			cfg.Server.HTTP2 = tt.http2
+			cfg.Server.H2C = tt.h2c
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Proto))
+			}))
+			server.Config.Protocols = cfg.GetHTTPProtocols()
+			server.Config.HTTP2 = cfg.GetHTTP2Config()
+
+			// Клиент предлагает HTTP/2 (без TLS - только HTTP/2 prior knowledge)
+			clientProtocols := new(http.Protocols)
+			transport := &http.Transport{Protocols: clientProtocols}
+			if tt.tls {
+				server.EnableHTTP2 = tt.http2
+				server.StartTLS()
+				clientProtocols.SetHTTP1(true)
+				clientProtocols.SetHTTP2(true)
+				transport.TLSClientConfig = &tls.Config{RootCAs: server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+			} else {
+				server.Start()
+				clientProtocols.SetUnencryptedHTTP2(true)
+			}
+			defer server.Close()
+			client := &http.Client{Transport: transport}
+			defer transport.CloseIdleConnections()
+
+			resp, err := client.Get(server.URL)
+			if tt.wantProto == "" {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("HTTP/2 request succeeded with proto %s, want failure", resp.Proto)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+			served, _ := io.ReadAll(resp.Body)
+			if resp.Proto != tt.wantProto || string(served) != tt.wantProto {
+				t.Errorf("negotiated %s (server saw %s), want %s", resp.Proto, served, tt.wantProto)
+			}
+		})
+	}
+}
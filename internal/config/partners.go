@@ -1,5 +1,10 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+)
+
 type PartnerConfig struct {
 	Name      string `yaml:"name"`
 	URL       string `yaml:"url"`
@@ -13,3 +18,71 @@ type RoutingRule struct {
 	Condition string `yaml:"condition"` // "client_id LIKE 'partner1_%'"
 	Partner   string `yaml:"partner"`
 }
+
+// RoutingCondition разобранное условие правила маршрутизации
+type RoutingCondition struct {
+	Field   string // client_id или camera_id
+	Pattern string
+	Like    bool // LIKE: '%' - любая подстрока, остальные символы (и '_') буквально
+}
+
+// routingFields поля фрейма, доступные в условиях
+var routingFields = map[string]bool{"client_id": true, "camera_id": true}
+
+// ParseCondition разбирает условие вида client_id LIKE 'prefix_%' или
+// client_id = 'value'
+func (r RoutingRule) ParseCondition() (*RoutingCondition, error) {
+	fields := strings.Fields(r.Condition)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("condition %q: expected <field> LIKE|= '<pattern>'", r.Condition)
+	}
+
+	field := strings.ToLower(fields[0])
+	if !routingFields[field] {
+		return nil, fmt.Errorf("condition %q: unsupported field %q", r.Condition, fields[0])
+	}
+
+	var like bool
+	switch strings.ToUpper(fields[1]) {
+	case "LIKE":
+		like = true
+	case "=":
+	default:
+		return nil, fmt.Errorf("condition %q: unsupported operator %q", r.Condition, fields[1])
+	}
+
+	// Значение берем из исходной строки, чтобы сохранить пробелы в кавычках
+	_, rest, _ := strings.Cut(r.Condition, fields[1])
+	rest = strings.TrimSpace(rest)
+	if len(rest) < 2 || rest[0] != '\'' || rest[len(rest)-1] != '\'' {
+		return nil, fmt.Errorf("condition %q: pattern must be in single quotes", r.Condition)
+	}
+
+	return &RoutingCondition{Field: field, Pattern: rest[1 : len(rest)-1], Like: like}, nil
+}
+
+// Match проверяет значение поля по условию
+func (c *RoutingCondition) Match(value string) bool {
+	if !c.Like {
+		return value == c.Pattern
+	}
+
+	parts := strings.Split(c.Pattern, "%")
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+
+	last := len(parts) - 1
+	if last == 0 {
+		return value == ""
+	}
+	for _, part := range parts[1:last] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[last])
+}
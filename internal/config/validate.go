@@ -0,0 +1,359 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PlaceholderJWTSecret пример секрета из поставляемого config.yaml; с ним
+// токены может подписать любой, кто видел репозиторий
+const PlaceholderJWTSecret = "your-secret-key-change-in-production"
+
+// MinJWTSecretLength минимальная длина секрета HS256 (256 бит)
+const MinJWTSecretLength = 32
+
+// ValidationError все ошибки конфигурации, найденные Validate
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+// validator накапливает ошибки вида "поле: причина"
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(field, format string, args ...interface{}) {
+	v.problems = append(v.problems, field+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) port(field string, port int) {
+	if port < 1 || port > 65535 {
+		v.addf(field, "must be between 1 and 65535, got %d", port)
+	}
+}
+
+func (v *validator) portString(field, port string) {
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		v.addf(field, "must be a number, got %q", port)
+		return
+	}
+	v.port(field, p)
+}
+
+func (v *validator) positive(field string, value int) {
+	if value <= 0 {
+		v.addf(field, "must be positive, got %d", value)
+	}
+}
+
+func (v *validator) nonNegative(field string, value int) {
+	if value < 0 {
+		v.addf(field, "must not be negative, got %d", value)
+	}
+}
+
+func (v *validator) percent(field string, value int) {
+	if value < 0 || value > 100 {
+		v.addf(field, "must be between 0 and 100, got %d", value)
+	}
+}
+
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf(field, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+// Validate проверяет обязательные поля и диапазоны значений. Возвращает
+// *ValidationError со всеми найденными ошибками, а не только с первой.
+func (c *Config) Validate() error {
+	var v validator
+
+	v.port("port", c.Port)
+	v.portString("grpc_port", c.GRPCPort)
+	v.positive("grpc_handler_timeout", c.GRPCHandlerTimeout)
+	v.positive("shutdown_timeout", c.ShutdownTimeout)
+
+	if c.Server.EnableTLS && (c.Server.TLSCert == "" || c.Server.TLSKey == "") {
+		v.addf("server.tls_cert", "server.tls_cert and server.tls_key are both required when server.enable_tls is set")
+	} else if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+		v.addf("server.tls_cert", "server.tls_cert and server.tls_key must be set together")
+	}
+	v.nonNegative("server.read_timeout", c.Server.ReadTimeout)
+	v.nonNegative("server.idle_timeout", c.Server.IdleTimeout)
+	v.nonNegative("server.http2_max_concurrent_streams", c.Server.HTTP2MaxConcurrentStreams)
+
+	// Без API ключей HTTP аутентификация возможна только по JWT
+	jwtRequired := c.Auth.WebSocketRequired || (c.Auth.HTTPRequired && len(c.Auth.APIKeys) == 0)
+	if jwtRequired || c.JWT.Secret != "" {
+		switch {
+		case c.JWT.Secret == "":
+			v.addf("jwt.secret", "required when auth.websocket_required or auth.http_required (without auth.api_keys) is set")
+		case c.JWT.Secret == PlaceholderJWTSecret:
+			v.addf("jwt.secret", "must be changed from the example value")
+		case len(c.JWT.Secret) < MinJWTSecretLength:
+			v.addf("jwt.secret", "must be at least %d bytes, got %d", MinJWTSecretLength, len(c.JWT.Secret))
+		}
+	}
+	if len(c.Auth.FrameSigningKeys) > 0 {
+		v.positive("auth.frame_signature_window", c.Auth.FrameSignatureWindow)
+	}
+	for i, key := range c.Auth.APIKeys {
+		if hash, err := hex.DecodeString(strings.TrimSpace(key.KeyHash)); err != nil || len(hash) != 32 {
+			v.addf(fmt.Sprintf("auth.api_keys[%d].key_hash", i), "must be a SHA-256 hex digest")
+		}
+	}
+
+	if c.Security.EnableCORS && len(c.Security.AllowedOrigins) == 0 {
+		v.addf("security.allowed_origins", "required when security.enable_cors is set")
+	}
+	for i, proxy := range c.Security.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			v.addf(fmt.Sprintf("security.trusted_proxies[%d]", i), "must be an IP or CIDR, got %q", proxy)
+		}
+	}
+
+	v.nonNegative("logging.slow_request_ms", c.Logging.SlowRequestMs)
+	if c.Logging.GinMode != "" {
+		v.oneOf("logging.gin_mode", c.Logging.GinMode, "debug", "release", "test")
+	}
+
+	v.positive("video.max_frame_size", c.Video.MaxFrameSize)
+	v.positive("video.max_fps", c.Video.MaxFPS)
+	v.positive("video.max_batch_frames", c.Video.MaxBatchFrames)
+	v.positive("video.max_batch_bytes", c.Video.MaxBatchBytes)
+	v.positive("video.max_chunked_frame_size", c.Video.MaxChunkedFrameSize)
+	v.positive("video.chunk_timeout", c.Video.ChunkTimeout)
+	v.positive("video.max_pending_chunked_frames", c.Video.MaxPendingChunkedFrames)
+	v.positive("video.max_pending_chunk_bytes", c.Video.MaxPendingChunkBytes)
+	v.positive("video.max_client_chunked_frames", c.Video.MaxClientChunkedFrames)
+	v.positive("video.max_client_chunk_bytes", c.Video.MaxClientChunkBytes)
+	v.oneOf("video.format_change_policy", c.Video.FormatChangePolicy, "allow", "warn", "reject")
+
+	v.positive("gateway.buffer_size", c.Gateway.BufferSize)
+	v.positive("gateway.send_workers", c.Gateway.SendWorkers)
+	v.positive("gateway.send_queue_size", c.Gateway.SendQueueSize)
+	v.nonNegative("gateway.enqueue_timeout_ms", c.Gateway.EnqueueTimeoutMs)
+	v.nonNegative("gateway.frame_timeout_ms", c.Gateway.FrameTimeoutMs)
+	v.nonNegative("gateway.replay_frames", c.Gateway.ReplayFrames)
+	v.nonNegative("gateway.max_connections", c.Gateway.MaxConnections)
+	v.nonNegative("gateway.max_connections_per_ip", c.Gateway.MaxConnectionsPerIP)
+	v.nonNegative("gateway.max_connections_per_client", c.Gateway.MaxConnectionsPerClient)
+	v.positive("gateway.send_buffer_size", c.Gateway.SendBufferSize)
+	for clientID, size := range c.Gateway.ClientSendBuffers {
+		v.positive("gateway.client_send_buffers."+clientID, size)
+	}
+	v.nonNegative("gateway.max_streams", c.Gateway.MaxStreams)
+	v.positive("gateway.ping_interval", c.Gateway.PingInterval)
+	v.positive("gateway.pong_timeout", c.Gateway.PongTimeout)
+	if c.Gateway.PingInterval > 0 && c.Gateway.PongTimeout > 0 && c.Gateway.PongTimeout <= c.Gateway.PingInterval {
+		v.addf("gateway.pong_timeout", "must be greater than gateway.ping_interval (%d), got %d",
+			c.Gateway.PingInterval, c.Gateway.PongTimeout)
+	}
+	v.positive("gateway.write_timeout", c.Gateway.WriteTimeout)
+	v.positive("gateway.max_message_size", c.Gateway.MaxMessageSize)
+	v.nonNegative("gateway.control_rate_limit", c.Gateway.ControlRateLimit)
+	v.oneOf("gateway.rate_limit_backend", c.Gateway.RateLimitBackend, "local", "redis")
+	if c.Gateway.RateLimitBackend == "redis" {
+		if c.Redis.Host == "" {
+			v.addf("redis.host", "required when gateway.rate_limit_backend is redis")
+		}
+		v.port("redis.port", c.Redis.Port)
+	}
+	v.oneOf("client_store.backend", c.ClientStore.Backend, "memory", "redis")
+	if c.ClientStore.Backend == "redis" {
+		if c.Redis.Host == "" {
+			v.addf("redis.host", "required when client_store.backend is redis")
+		}
+		v.port("redis.port", c.Redis.Port)
+	}
+	v.nonNegative("client_store.ttl", c.ClientStore.TTL)
+	v.nonNegative("pipeline.dedup_window", c.Pipeline.DedupWindow)
+	known := make(map[string]bool, len(BuiltinFrameProcessors)+len(c.Pipeline.Custom))
+	for _, name := range BuiltinFrameProcessors {
+		known[name] = true
+	}
+	for _, name := range c.Pipeline.Custom {
+		known[name] = true
+	}
+	checkChain := func(field string, chain []string) {
+		for i, name := range chain {
+			if !known[name] {
+				v.addf(fmt.Sprintf("%s[%d]", field, i), "unknown frame processor %q (built-in: %s; register others in pipeline.custom)",
+					name, strings.Join(BuiltinFrameProcessors, ", "))
+			}
+		}
+	}
+	checkChain("pipeline.default", c.Pipeline.Default)
+	for streamID, chain := range c.Pipeline.Streams {
+		checkChain("pipeline.streams."+streamID, chain)
+	}
+	v.nonNegative("gateway.shutdown_reconnect_delay", c.Gateway.ShutdownReconnectDelay)
+	v.nonNegative("gateway.session_timeout", c.Gateway.SessionTimeout)
+	v.nonNegative("gateway.resume_grace_period", c.Gateway.ResumeGracePeriod)
+
+	v.nonNegative("limits.max_concurrent_starts", c.Limits.MaxConcurrentStarts)
+	v.nonNegative("limits.start_queue_timeout_ms", c.Limits.StartQueueTimeoutMs)
+	v.nonNegative("limits.max_stream_bitrate", c.Limits.MaxStreamBitrate)
+	v.nonNegative("limits.throttle_max_wait_ms", c.Limits.ThrottleMaxWaitMs)
+	v.nonNegative("limits.stream_idle_timeout", c.Limits.StreamIdleTimeout)
+	v.positive("limits.idempotency_ttl", c.Limits.IdempotencyTTL)
+	v.positive("limits.idempotency_max_keys", c.Limits.IdempotencyMaxKeys)
+
+	endpoints := map[string][]string{
+		"video_processing": c.Services.VideoProcessing,
+		"analytics":        c.Services.Analytics,
+		"storage":          c.Services.Storage,
+		"notification":     c.Services.Notification,
+	}
+	for _, serviceType := range []string{"video_processing", "analytics", "storage", "notification"} {
+		for i, url := range endpoints[serviceType] {
+			if strings.TrimSpace(url) == "" {
+				v.addf(fmt.Sprintf("services.%s[%d]", serviceType, i), "must not be empty")
+			}
+		}
+	}
+	for i, serviceType := range c.Services.Required {
+		if strings.TrimSpace(serviceType) == "" {
+			v.addf(fmt.Sprintf("services.required[%d]", i), "must not be empty")
+		} else if len(endpoints[serviceType]) == 0 {
+			v.addf(fmt.Sprintf("services.required[%d]", i), "service %q has no endpoints configured", serviceType)
+		}
+	}
+	for serviceType, batch := range c.Services.Batching {
+		v.positive("services.batching."+serviceType+".max_frames", batch.MaxFrames)
+		v.positive("services.batching."+serviceType+".window_ms", batch.WindowMs)
+	}
+	for camera, url := range c.Services.CameraRoutes {
+		if !slices.Contains(c.Services.VideoProcessing, url) {
+			v.addf("services.camera_routes."+camera, "%q is not in services.video_processing", url)
+		}
+	}
+	for i, shadow := range c.Services.Shadow {
+		field := fmt.Sprintf("services.shadow[%d]", i)
+		v.oneOf(field+".service", shadow.Service, "video_processing", "analytics", "storage", "notification")
+		if strings.TrimSpace(shadow.URL) == "" {
+			v.addf(field+".url", "is required")
+		}
+		if shadow.Percent < 0 || shadow.Percent > 100 {
+			v.addf(field+".percent", "must be between 0 and 100, got %g", shadow.Percent)
+		}
+	}
+	for endpoint, check := range c.Services.HealthChecks {
+		field := "services.health_checks." + endpoint
+		if _, err := url.Parse(check.Path); err != nil {
+			v.addf(field+".path", "invalid path: %v", err)
+		}
+		if check.ExpectStatus != 0 && (check.ExpectStatus < 100 || check.ExpectStatus > 599) {
+			v.addf(field+".expect_status", "must be an HTTP status code, got %d", check.ExpectStatus)
+		}
+	}
+	for serviceType, sampling := range c.Services.Sampling {
+		field := "services.sampling." + serviceType
+		v.oneOf(field, serviceType, "video_processing", "analytics", "storage", "notification")
+		if sampling.Percent < 0 || sampling.Percent > 100 {
+			v.addf(field+".percent", "must be between 0 and 100, got %g", sampling.Percent)
+		}
+		if sampling.Mode != "" {
+			v.oneOf(field+".mode", sampling.Mode, "interval", "random")
+		}
+	}
+	for serviceType, timeout := range c.Services.Timeouts {
+		v.positive("services.timeouts_ms."+serviceType, timeout)
+	}
+	v.nonNegative("services.retry.max_attempts", c.Services.Retry.MaxAttempts)
+	v.nonNegative("services.retry.initial_backoff_ms", c.Services.Retry.InitialBackoffMs)
+	v.nonNegative("services.retry.max_backoff_ms", c.Services.Retry.MaxBackoffMs)
+	v.positive("services.breaker.failure_threshold", c.Services.Breaker.FailureThreshold)
+	v.nonNegative("services.health_check_interval", c.Services.HealthCheckInterval)
+	v.nonNegative("services.health_check_timeout", c.Services.HealthCheckTimeout)
+	v.nonNegative("services.http_client.timeout", c.Services.HTTPClient.Timeout)
+	v.nonNegative("services.http_client.dial_timeout", c.Services.HTTPClient.DialTimeout)
+	v.nonNegative("services.http_client.keep_alive", c.Services.HTTPClient.KeepAlive)
+	v.nonNegative("services.http_client.tls_handshake_timeout", c.Services.HTTPClient.TLSHandshakeTimeout)
+	v.nonNegative("services.http_client.idle_conn_timeout", c.Services.HTTPClient.IdleConnTimeout)
+	v.nonNegative("services.http_client.max_idle_conns", c.Services.HTTPClient.MaxIdleConns)
+	v.nonNegative("services.http_client.max_idle_conns_per_host", c.Services.HTTPClient.MaxIdleConnsPerHost)
+	v.nonNegative("services.http_client.max_conns_per_host", c.Services.HTTPClient.MaxConnsPerHost)
+
+	partners := make(map[string]bool, len(c.Partners))
+	for i, partner := range c.Partners {
+		field := fmt.Sprintf("partners[%d]", i)
+		if partner.Name == "" {
+			v.addf(field+".name", "is required")
+		} else if partners[partner.Name] {
+			v.addf(field+".name", "duplicate partner %q", partner.Name)
+		}
+		partners[partner.Name] = true
+		if partner.Enabled && partner.URL == "" {
+			v.addf(field+".url", "is required for an enabled partner")
+		}
+		v.nonNegative(field+".rate_limit", partner.RateLimit)
+	}
+	for i, rule := range c.RoutingRules {
+		field := fmt.Sprintf("routing_rules[%d]", i)
+		if _, err := rule.ParseCondition(); err != nil {
+			v.addf(field+".condition", "%v", err)
+		}
+		if !partners[rule.Partner] {
+			v.addf(field+".partner", "unknown partner %q", rule.Partner)
+		}
+	}
+
+	for name, serviceType := range c.Proxy.Routes {
+		field := "proxy.routes." + name
+		if name == "" || strings.Contains(name, "/") {
+			v.addf(field, "route name must be non-empty and must not contain '/'")
+		}
+		v.oneOf(field, serviceType, "video_processing", "analytics", "storage", "notification")
+	}
+
+	v.percent("health.queue_degraded_percent", c.Health.QueueDegradedPercent)
+	v.percent("health.queue_unhealthy_percent", c.Health.QueueUnhealthyPercent)
+	v.percent("health.fail_rate_degraded_percent", c.Health.FailRateDegradedPercent)
+	v.percent("health.fail_rate_unhealthy_percent", c.Health.FailRateUnhealthyPercent)
+	v.nonNegative("health.fail_rate_window", c.Health.FailRateWindow)
+	v.nonNegative("health.fail_rate_min_requests", c.Health.FailRateMinRequests)
+	v.nonNegative("health.unhealthy_services_degraded", c.Health.UnhealthyServicesDegraded)
+	v.nonNegative("health.unhealthy_services_unhealthy", c.Health.UnhealthyServicesUnhealthy)
+	if code := c.Health.DegradedStatusCode; code != 0 && (code < 200 || code > 599) {
+		v.addf("health.degraded_status_code", "must be an HTTP status code, got %d", code)
+	}
+
+	v.oneOf("metrics.backend", c.Metrics.Backend, "memory", "prometheus", "statsd")
+	if c.Metrics.Backend == "statsd" && c.Metrics.StatsDAddress == "" {
+		v.addf("metrics.statsd_address", "required when metrics.backend is statsd")
+	}
+
+	if c.Tracing.Enabled && c.Tracing.OTLPEndpoint == "" {
+		v.addf("tracing.otlp_endpoint", "required when tracing.enabled is set")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.addf("tracing.sample_ratio", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	if c.Debug.Pprof && c.Debug.AdminAddress == "" {
+		v.addf("debug.admin_address", "required when debug.pprof is set")
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// validConfig конфигурация по умолчанию, проходящая Validate
+func validConfig() *Config {
+	cfg := GetDefaultConfig()
+	cfg.JWT.Secret = "0123456789abcdef0123456789abcdef"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*Config)
+		wantField string // "" - конфигурация валидна
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"built-in processors", func(c *Config) { c.Pipeline.Default = []string{"validate", "dedup"} }, ""},
+		{"unknown processor", func(c *Config) { c.Pipeline.Default = []string{"validate", "dedupe"} }, "pipeline.default[1]"},
+		{"unknown stream processor", func(c *Config) {
+			c.Pipeline.Streams = map[string][]string{"cam-1": {"watermrk"}}
+		}, "pipeline.streams.cam-1[0]"},
+		{"declared custom processor", func(c *Config) {
+			c.Pipeline.Custom = []string{"blur"}
+			c.Pipeline.Default = []string{"blur"}
+		}, ""},
+		{"http auth without credentials", func(c *Config) {
+			c.Auth.WebSocketRequired = false
+			c.JWT.Secret = ""
+		}, "jwt.secret"},
+		{"http auth with api keys only", func(c *Config) {
+			c.Auth.WebSocketRequired = false
+			c.JWT.Secret = ""
+			c.Auth.APIKeys = []APIKey{{KeyHash: strings.Repeat("ab", 32), ClientID: "svc"}}
+		}, ""},
+		{"short jwt secret", func(c *Config) { c.JWT.Secret = "short" }, "jwt.secret"},
+		{"placeholder jwt secret", func(c *Config) { c.JWT.Secret = PlaceholderJWTSecret }, "jwt.secret"},
+		{"websocket auth without jwt secret", func(c *Config) {
+			c.Auth.WebSocketRequired = true
+			c.JWT.Secret = ""
+			c.Auth.APIKeys = []APIKey{{KeyHash: strings.Repeat("ab", 32), ClientID: "svc"}}
+		}, "jwt.secret"},
+		{"bad api key hash", func(c *Config) {
+			c.Auth.APIKeys = []APIKey{{KeyHash: "not-a-digest", ClientID: "svc"}}
+		}, "auth.api_keys[0].key_hash"},
+		{"trusted proxies", func(c *Config) {
+			c.Security.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.10", "::1"}
+		}, ""},
+		{"bad trusted proxy", func(c *Config) {
+			c.Security.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"}
+		}, "security.trusted_proxies[1]"},
+		{"frame signing without window", func(c *Config) {
+			c.Auth.FrameSigningKeys = map[string]string{"cam-1": "secret"}
+			c.Auth.FrameSignatureWindow = 0
+		}, "auth.frame_signature_window"},
+		{"camera route to unknown endpoint", func(c *Config) {
+			c.Services.VideoProcessing = []string{"http://video-1"}
+			c.Services.CameraRoutes = map[string]string{"front": "http://video-2"}
+		}, "services.camera_routes.front"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.configure(cfg)
+			err := cfg.Validate()
+
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() = %v, want *ValidationError", err)
+			}
+			for _, problem := range verr.Problems {
+				if strings.HasPrefix(problem, tt.wantField+":") {
+					return
+				}
+			}
+			t.Errorf("Validate() problems %v, want one for %s", verr.Problems, tt.wantField)
+		})
+	}
+}
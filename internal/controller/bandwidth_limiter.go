@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StreamThrottledError - кадр превышает лимит битрейта стрима
+type StreamThrottledError struct {
+	StreamID   string
+	RetryAfter time.Duration
+}
+
+func (e *StreamThrottledError) Error() string {
+	return fmt.Sprintf("stream %s exceeds bitrate limit, retry after %s", e.StreamID, e.RetryAfter)
+}
+
+// bandwidthLimiter token bucket в байтах для одного стрима. Запас
+// пополняется со скоростью rate байт/с до одной секунды трафика. Кадр
+// крупнее запаса пропускается в долг, следующие кадры ждут его погашения.
+type bandwidthLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	tokens   float64
+	lastFill time.Time
+}
+
+// newBandwidthLimiter создает лимитер на bitsPerSecond с полным запасом
+func newBandwidthLimiter(bitsPerSecond int) *bandwidthLimiter {
+	rate := float64(bitsPerSecond) / 8
+	return &bandwidthLimiter{
+		rate:     rate,
+		tokens:   rate,
+		lastFill: time.Now(),
+	}
+}
+
+// reserve списывает size байт и возвращает, сколько нужно подождать перед
+// приемом кадра, пока гасится долг предыдущих кадров. Если ожидание больше
+// maxWait, ничего не списывается и возвращается ok=false.
+func (l *bandwidthLimiter) reserve(size int, maxWait time.Duration) (wait time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.lastFill).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.lastFill = now
+
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+		if wait > maxWait {
+			return wait, false
+		}
+	}
+
+	l.tokens -= float64(size)
+	return wait, true
+}
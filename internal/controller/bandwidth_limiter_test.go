@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	pb "api-gateway/pkg/gen"
+)
+
+func TestStreamBitrateThrottling(t *testing.T) {
+	// 1000 байт/с, кадры по 100 байт: запас на 10 кадров, 11-й уходит в
+	// долг, дальше каждый кадр ждет ~100ms
+	const frames = 15
+	tests := []struct {
+		name        string
+		maxWait     time.Duration
+		wantBlocked time.Duration // минимальное суммарное ожидание
+		wantReject  bool
+	}{
+		{"blocks within max wait", time.Second, 300 * time.Millisecond, false},
+		{"rejects over max wait", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewVideoStreamService(zap.NewNop(), WithStreamBitrateLimit(8000, tt.maxWait))
+			t.Cleanup(s.Close)
+			ctx := context.Background()
+			started, err := s.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-1"})
+			if err != nil {
+				t.Fatalf("StartStream: %v", err)
+			}
+
+			start := time.Now()
+			var throttled *StreamThrottledError
+			accepted := 0
+			for i := 0; i < frames; i++ {
+				frame := &pb.VideoFrame{FrameId: "f", ClientId: "cam-1", Format: "jpeg", FrameData: make([]byte, 100)}
+				_, err := s.SendFrameInternal(ctx, started.StreamId, "cam-1", "cam-1", frame)
+				if errors.As(err, &throttled) {
+					continue
+				}
+				if err != nil {
+					t.Fatalf("frame %d: %v", i, err)
+				}
+				accepted++
+			}
+			elapsed := time.Since(start)
+
+			if tt.wantReject {
+				if throttled == nil || throttled.RetryAfter <= 0 || throttled.StreamID != started.StreamId {
+					t.Fatalf("throttled error = %v, want StreamThrottledError with retry guidance", throttled)
+				}
+				if accepted < 10 || accepted == frames {
+					t.Errorf("accepted %d of %d frames, want the burst only", accepted, frames)
+				}
+				return
+			}
+			if throttled != nil {
+				t.Fatalf("frame rejected within max wait: %v", throttled)
+			}
+			if elapsed < tt.wantBlocked {
+				t.Errorf("%d frames sent in %v, want throttled to at least %v", frames, elapsed, tt.wantBlocked)
+			}
+		})
+	}
+}
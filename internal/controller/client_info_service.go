@@ -2,29 +2,48 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"api-gateway/internal/requestid"
 	pb "api-gateway/pkg/gen"
 	"go.uber.org/zap"
 )
 
+// ErrClientNotFound - клиент не найден
+var ErrClientNotFound = errors.New("client not found")
+
 // ClientInfoServiceImpl - реализация сервиса
 type ClientInfoServiceImpl struct {
 	logger *zap.Logger
-	repo   *ClientRepository
+	repo   ClientStore
+}
+
+// ClientInfoOption настройка ClientInfoServiceImpl
+type ClientInfoOption func(*ClientInfoServiceImpl)
+
+// WithClientStore задает хранилище клиентов (по умолчанию в памяти процесса)
+func WithClientStore(store ClientStore) ClientInfoOption {
+	return func(s *ClientInfoServiceImpl) {
+		s.repo = store
+	}
 }
 
 // NewClientInfoService создает новый сервис
-func NewClientInfoService(logger *zap.Logger) *ClientInfoServiceImpl {
-	return &ClientInfoServiceImpl{
+func NewClientInfoService(logger *zap.Logger, opts ...ClientInfoOption) *ClientInfoServiceImpl {
+	s := &ClientInfoServiceImpl{
 		logger: logger,
 		repo:   NewClientRepository(),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // ClientConnected - клиент подключился
 func (s *ClientInfoServiceImpl) ClientConnected(ctx context.Context, req *pb.ConnectionEvent) (*pb.ApiResponse, error) {
-	s.logger.Info("Client connected",
+	requestid.Logger(ctx, s.logger).Info("Client connected",
 		zap.String("client_id", req.ClientId),
 		zap.String("ip", req.IpAddress))
 
@@ -40,7 +59,7 @@ func (s *ClientInfoServiceImpl) ClientConnected(ctx context.Context, req *pb.Con
 
 // ClientDisconnected - клиент отключился
 func (s *ClientInfoServiceImpl) ClientDisconnected(ctx context.Context, req *pb.ConnectionEvent) (*pb.ApiResponse, error) {
-	s.logger.Info("Client disconnected",
+	requestid.Logger(ctx, s.logger).Info("Client disconnected",
 		zap.String("client_id", req.ClientId))
 
 	// Удаляем или обновляем статус
@@ -53,9 +72,30 @@ func (s *ClientInfoServiceImpl) ClientDisconnected(ctx context.Context, req *pb.
 	}, nil
 }
 
+// ForceDisconnect - принудительно отключить клиента
+func (s *ClientInfoServiceImpl) ForceDisconnect(ctx context.Context, clientID string) (*pb.ApiResponse, error) {
+	if s.repo.GetClient(clientID) == nil {
+		return nil, ErrClientNotFound
+	}
+
+	requestid.Logger(ctx, s.logger).Info("Force disconnecting client",
+		zap.String("client_id", clientID))
+
+	s.repo.RemoveClient(clientID)
+
+	return &pb.ApiResponse{
+		Status:    "ok",
+		Message:   "Client disconnected",
+		Timestamp: time.Now().Unix(),
+		Metadata: map[string]string{
+			"client_id": clientID,
+		},
+	}, nil
+}
+
 // UpdateClientInfo - обновить информацию о клиенте
 func (s *ClientInfoServiceImpl) UpdateClientInfo(ctx context.Context, req *pb.UpdateClientRequest) (*pb.ApiResponse, error) {
-	s.logger.Info("Updating client info",
+	requestid.Logger(ctx, s.logger).Info("Updating client info",
 		zap.String("client_id", req.ClientId))
 
 	if req.ClientInfo != nil {
@@ -71,7 +111,7 @@ func (s *ClientInfoServiceImpl) UpdateClientInfo(ctx context.Context, req *pb.Up
 
 // GetClientInfo - получить информацию о клиенте
 func (s *ClientInfoServiceImpl) GetClientInfo(ctx context.Context, req *pb.GetClientInfoRequest) (*pb.ClientInfo, error) {
-	s.logger.Debug("Getting client info",
+	requestid.Logger(ctx, s.logger).Debug("Getting client info",
 		zap.String("client_id", req.ClientId))
 
 	client := s.repo.GetClient(req.ClientId)
@@ -84,7 +124,7 @@ func (s *ClientInfoServiceImpl) GetClientInfo(ctx context.Context, req *pb.GetCl
 
 // ListActiveClients - список активных клиентов
 func (s *ClientInfoServiceImpl) ListActiveClients(ctx context.Context, req *pb.ListClientsRequest) (*pb.ListClientsResponse, error) {
-	s.logger.Debug("Listing active clients")
+	requestid.Logger(ctx, s.logger).Debug("Listing active clients")
 
 	allClients := s.repo.GetAllClients()
 
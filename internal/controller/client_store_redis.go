@@ -0,0 +1,288 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	pb "api-gateway/pkg/gen"
+)
+
+// DefaultClientPresenceTTL срок присутствия клиента без подтверждения, если
+// он не задан
+const DefaultClientPresenceTTL = time.Minute
+
+// Клиенты хранятся в хеше prefix+"{clients}:data" (client_id -> ClientInfo),
+// время последнего подтверждения присутствия - в sorted set
+// prefix+"{clients}:presence". Общий hash tag держит оба ключа в одном слоте
+// Redis Cluster; скрипты обращаются только к переданным KEYS.
+
+// saveClientsScript сохраняет клиентов и подтверждает их присутствие.
+// KEYS[1] - хеш, KEYS[2] - присутствие; ARGV: now_ms, затем пары client_id,
+// данные.
+var saveClientsScript = redis.NewScript(`
+for i = 2, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	redis.call('ZADD', KEYS[2], ARGV[1], ARGV[i])
+end
+return 1
+`)
+
+// removeClientsScript удаляет клиентов. KEYS как у saveClientsScript;
+// ARGV - client_id.
+var removeClientsScript = redis.NewScript(`
+for _, id in ipairs(ARGV) do
+	redis.call('HDEL', KEYS[1], id)
+	redis.call('ZREM', KEYS[2], id)
+end
+return 1
+`)
+
+// getClientScript возвращает данные клиента, если его присутствие
+// подтверждено не раньше ttl назад. ARGV: client_id, now_ms, ttl_ms.
+var getClientScript = redis.NewScript(`
+local seen = redis.call('ZSCORE', KEYS[2], ARGV[1])
+if not seen or tonumber(seen) < tonumber(ARGV[2]) - tonumber(ARGV[3]) then
+	return false
+end
+return redis.call('HGET', KEYS[1], ARGV[1])
+`)
+
+// listClientsScript удаляет клиентов без подтверждения дольше ttl и
+// возвращает данные остальных по времени подтверждения. ARGV: now_ms, ttl_ms.
+var listClientsScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', tonumber(ARGV[1]) - tonumber(ARGV[2]))
+for _, id in ipairs(expired) do
+	redis.call('HDEL', KEYS[1], id)
+	redis.call('ZREM', KEYS[2], id)
+end
+local result = {}
+for _, id in ipairs(redis.call('ZRANGE', KEYS[2], 0, -1)) do
+	local data = redis.call('HGET', KEYS[1], id)
+	if data then
+		table.insert(result, data)
+	else
+		redis.call('ZREM', KEYS[2], id)
+	end
+end
+return result
+`)
+
+// redisStoreWarnInterval как часто повторять предупреждение о
+// недоступности Redis
+const redisStoreWarnInterval = 30 * time.Second
+
+// RedisClientStore хранит клиентов в Redis, чтобы все реплики шлюза видели
+// общий список. Клиенты этой реплики дублируются в памяти: если Redis
+// недоступен, ответы строятся по ним. Реплика подтверждает присутствие
+// своих клиентов каждые ttl/3; клиенты упавшей реплики пропадают через ttl.
+// Удаления, не дошедшие до Redis, повторяются при следующем подтверждении.
+type RedisClientStore struct {
+	client redis.Cmdable
+	prefix string
+	ttl    time.Duration
+	local  *ClientRepository
+	logger *zap.Logger
+
+	mu              sync.Mutex
+	pendingRemovals map[string]struct{} // удаления, которые не удалось выполнить
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	lastWarn  atomic.Int64
+}
+
+// NewRedisClientStore создает хранилище с ключами prefix+"{clients}:*" и
+// запускает подтверждение присутствия клиентов реплики; остановить его -
+// Close. ttl - срок присутствия без подтверждения (<= 0 -
+// DefaultClientPresenceTTL).
+func NewRedisClientStore(client redis.Cmdable, prefix string, ttl time.Duration, logger *zap.Logger) *RedisClientStore {
+	if ttl <= 0 {
+		ttl = DefaultClientPresenceTTL
+	}
+	s := &RedisClientStore{
+		client:          client,
+		prefix:          prefix,
+		ttl:             ttl,
+		local:           NewClientRepository(),
+		logger:          logger,
+		pendingRemovals: make(map[string]struct{}),
+		stop:            make(chan struct{}),
+	}
+	go s.heartbeatLoop()
+	return s
+}
+
+// Close останавливает подтверждение присутствия; клиенты реплики
+// пропадут из общего списка через ttl
+func (s *RedisClientStore) Close() {
+	s.closeOnce.Do(func() { close(s.stop) })
+}
+
+// SaveClient сохраняет клиента
+func (s *RedisClientStore) SaveClient(client *pb.ClientInfo) {
+	if client == nil {
+		return
+	}
+	s.local.SaveClient(client)
+
+	s.mu.Lock()
+	delete(s.pendingRemovals, client.ClientId)
+	s.mu.Unlock()
+
+	args, err := s.saveArgs(time.Now(), client)
+	if err != nil {
+		s.logger.Error("Failed to marshal client", zap.String("client_id", client.ClientId), zap.Error(err))
+		return
+	}
+	if err := saveClientsScript.Run(context.Background(), s.client, s.keys(), args...).Err(); err != nil {
+		s.warn(err)
+	}
+}
+
+// GetClient получает клиента по ID
+func (s *RedisClientStore) GetClient(clientID string) *pb.ClientInfo {
+	data, err := getClientScript.Run(context.Background(), s.client, s.keys(),
+		clientID, time.Now().UnixMilli(), s.ttl.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		s.warn(err)
+		return s.local.GetClient(clientID)
+	}
+	return s.unmarshal([]byte(data))
+}
+
+// RemoveClient удаляет клиента. Если Redis недоступен, удаление
+// повторяется при следующем подтверждении присутствия.
+func (s *RedisClientStore) RemoveClient(clientID string) {
+	s.local.RemoveClient(clientID)
+
+	err := removeClientsScript.Run(context.Background(), s.client, s.keys(), clientID).Err()
+	if err != nil {
+		s.warn(err)
+		s.mu.Lock()
+		s.pendingRemovals[clientID] = struct{}{}
+		s.mu.Unlock()
+	}
+}
+
+// GetAllClients возвращает клиентов всех реплик в порядке подтверждения
+func (s *RedisClientStore) GetAllClients() []*pb.ClientInfo {
+	items, err := listClientsScript.Run(context.Background(), s.client, s.keys(),
+		time.Now().UnixMilli(), s.ttl.Milliseconds()).StringSlice()
+	if err != nil {
+		s.warn(err)
+		return s.local.GetAllClients()
+	}
+
+	clients := make([]*pb.ClientInfo, 0, len(items))
+	for _, item := range items {
+		if client := s.unmarshal([]byte(item)); client != nil {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// heartbeatLoop подтверждает присутствие клиентов реплики каждые ttl/3
+func (s *RedisClientStore) heartbeatLoop() {
+	ticker := time.NewTicker(s.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.refresh()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// refresh повторяет неудавшиеся удаления и заново сохраняет клиентов
+// реплики с текущим временем подтверждения (в том числе после потери
+// данных Redis)
+func (s *RedisClientStore) refresh() error {
+	ctx := context.Background()
+
+	s.mu.Lock()
+	removals := make([]interface{}, 0, len(s.pendingRemovals))
+	for clientID := range s.pendingRemovals {
+		removals = append(removals, clientID)
+	}
+	s.mu.Unlock()
+
+	if len(removals) > 0 {
+		if err := removeClientsScript.Run(ctx, s.client, s.keys(), removals...).Err(); err != nil {
+			s.warn(err)
+			return err
+		}
+		s.mu.Lock()
+		for _, clientID := range removals {
+			delete(s.pendingRemovals, clientID.(string))
+		}
+		s.mu.Unlock()
+	}
+
+	clients := s.local.GetAllClients()
+	if len(clients) == 0 {
+		return nil
+	}
+	args, err := s.saveArgs(time.Now(), clients...)
+	if err != nil {
+		s.logger.Error("Failed to marshal clients", zap.Error(err))
+		return err
+	}
+	if err := saveClientsScript.Run(ctx, s.client, s.keys(), args...).Err(); err != nil {
+		s.warn(err)
+		return err
+	}
+	return nil
+}
+
+// saveArgs собирает ARGV saveClientsScript
+func (s *RedisClientStore) saveArgs(now time.Time, clients ...*pb.ClientInfo) ([]interface{}, error) {
+	args := make([]interface{}, 0, 1+2*len(clients))
+	args = append(args, now.UnixMilli())
+	for _, client := range clients {
+		data, err := proto.Marshal(client)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, client.ClientId, data)
+	}
+	return args, nil
+}
+
+// keys возвращает хеш клиентов и set присутствия
+func (s *RedisClientStore) keys() []string {
+	return []string{s.prefix + "{clients}:data", s.prefix + "{clients}:presence"}
+}
+
+func (s *RedisClientStore) unmarshal(data []byte) *pb.ClientInfo {
+	var client pb.ClientInfo
+	if err := proto.Unmarshal(data, &client); err != nil {
+		s.logger.Error("Failed to unmarshal client from Redis", zap.Error(err))
+		return nil
+	}
+	return &client
+}
+
+// warn пишет предупреждение о переходе на клиентов этой реплики не чаще
+// redisStoreWarnInterval
+func (s *RedisClientStore) warn(err error) {
+	now := time.Now().UnixNano()
+	last := s.lastWarn.Load()
+	if now-last < int64(redisStoreWarnInterval) || !s.lastWarn.CompareAndSwap(last, now) {
+		return
+	}
+	s.logger.Warn("Redis client store unavailable, serving this replica's clients", zap.Error(err))
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/redisconn"
+	pb "api-gateway/pkg/gen"
+)
+
+// newTestRedisStore реплика хранилища клиентов на общем miniredis
+func newTestRedisStore(t *testing.T, server *miniredis.Miniredis, ttl time.Duration) *RedisClientStore {
+	t.Helper()
+	client := redisconn.New(redisconn.Options{Addr: server.Addr(), Timeout: 200 * time.Millisecond})
+	store := NewRedisClientStore(client, "test:", ttl, zap.NewNop())
+	t.Cleanup(func() {
+		store.Close()
+		client.Close()
+	})
+	return store
+}
+
+func clientIDs(clients []*pb.ClientInfo) map[string]bool {
+	ids := make(map[string]bool, len(clients))
+	for _, client := range clients {
+		ids[client.ClientId] = true
+	}
+	return ids
+}
+
+func TestRedisClientStorePresence(t *testing.T) {
+	const ttl = 300 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		stop    bool // реплика клиента остановлена (упала)
+		visible bool
+	}{
+		{"heartbeat keeps client", false, true},
+		{"stopped replica expires", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			owner := newTestRedisStore(t, server, ttl)
+			other := newTestRedisStore(t, server, ttl)
+
+			owner.SaveClient(&pb.ClientInfo{ClientId: "cam-1"})
+			if other.GetClient("cam-1") == nil {
+				t.Fatal("client is not visible to another replica")
+			}
+			if tt.stop {
+				owner.Close()
+			}
+
+			time.Sleep(3 * ttl)
+			if got := clientIDs(other.GetAllClients())["cam-1"]; got != tt.visible {
+				t.Errorf("GetAllClients contains cam-1 = %v, want %v", got, tt.visible)
+			}
+			if got := other.GetClient("cam-1") != nil; got != tt.visible {
+				t.Errorf("GetClient found cam-1 = %v, want %v", got, tt.visible)
+			}
+		})
+	}
+}
+
+func TestRedisClientStoreRetriesRemoval(t *testing.T) {
+	server := miniredis.RunT(t)
+	owner := newTestRedisStore(t, server, time.Minute)
+	other := newTestRedisStore(t, server, time.Minute)
+
+	owner.SaveClient(&pb.ClientInfo{ClientId: "cam-1"})
+	owner.SaveClient(&pb.ClientInfo{ClientId: "cam-2"})
+
+	server.Close()
+	owner.RemoveClient("cam-1")
+	if err := owner.refresh(); err == nil {
+		t.Fatal("refresh with Redis down succeeded")
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("restart Redis: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond) // пауза размыкателя после сбоев
+	if err := owner.refresh(); err != nil {
+		t.Fatalf("refresh after recovery: %v", err)
+	}
+
+	ids := clientIDs(other.GetAllClients())
+	if ids["cam-1"] || !ids["cam-2"] {
+		t.Errorf("clients after recovery = %v, want only cam-2", ids)
+	}
+}
+
+func TestRedisClientStoreSingleSlot(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := newTestRedisStore(t, server, time.Minute)
+	store.SaveClient(&pb.ClientInfo{ClientId: "cam-1"})
+
+	// Все ключи хранилища в одном слоте Redis Cluster
+	for _, key := range server.Keys() {
+		if key != "test:{clients}:data" && key != "test:{clients}:presence" {
+			t.Errorf("unexpected key %q", key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/requestid"
+)
+
+// Политики смены формата кадров посреди стрима
+const (
+	FormatChangeAllow  = "allow"
+	FormatChangeWarn   = "warn"
+	FormatChangeReject = "reject"
+)
+
+// ErrFormatChanged - формат кадра отличается от предыдущих кадров стрима
+var ErrFormatChanged = errors.New("frame format changed mid-stream")
+
+// WithFormatChangePolicy задает реакцию на смену формата кадров стрима:
+// FormatChangeAllow, FormatChangeWarn (по умолчанию) или FormatChangeReject
+func WithFormatChangePolicy(policy string) VideoStreamOption {
+	return func(s *VideoStreamServiceImpl) {
+		if policy != "" {
+			s.formatPolicy = policy
+		}
+	}
+}
+
+// NormalizeFrameFormat приводит формат кадра к виду для статистики:
+// нижний регистр, MIME тип "image/jpeg" -> "jpeg"
+func NormalizeFrameFormat(format string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if i := strings.IndexByte(format, '/'); i >= 0 {
+		format = format[i+1:]
+	}
+	if i := strings.IndexByte(format, ';'); i >= 0 {
+		format = strings.TrimSpace(format[:i])
+	}
+	return format
+}
+
+// checkFrameFormat сравнивает формат кадра с форматом предыдущих кадров
+// стрима и применяет политику смены формата
+func (s *VideoStreamServiceImpl) checkFrameFormat(ctx context.Context, streamID, format string) error {
+	format = NormalizeFrameFormat(format)
+	previous := s.repo.GetFormat(streamID)
+	if format == "" || previous == "" || format == previous {
+		return nil
+	}
+
+	switch s.formatPolicy {
+	case FormatChangeAllow:
+		return nil
+	case FormatChangeReject:
+		return fmt.Errorf("%w: %s -> %s", ErrFormatChanged, previous, format)
+	default:
+		requestid.Logger(ctx, s.logger).Warn("Frame format changed mid-stream",
+			zap.String("stream_id", streamID),
+			zap.String("previous_format", previous),
+			zap.String("format", format))
+		return nil
+	}
+}
+
+// GetFormatHistogram возвращает число кадров стрима по форматам
+func (s *VideoStreamServiceImpl) GetFormatHistogram(streamID string) map[string]int64 {
+	return s.repo.GetFormatHistogram(streamID)
+}
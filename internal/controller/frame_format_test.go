@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	pb "api-gateway/pkg/gen"
+)
+
+func TestFormatHistogram(t *testing.T) {
+	formats := []string{"jpeg", "image/jpeg", "JPEG", "png", "h264", "jpeg"}
+
+	tests := []struct {
+		policy       string
+		wantRejected int
+		want         map[string]int64
+	}{
+		{FormatChangeAllow, 0, map[string]int64{"jpeg": 4, "png": 1, "h264": 1}},
+		{FormatChangeWarn, 0, map[string]int64{"jpeg": 4, "png": 1, "h264": 1}},
+		// Кадры другого формата отклоняются и в гистограмму не попадают
+		{FormatChangeReject, 2, map[string]int64{"jpeg": 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			s := NewVideoStreamService(zap.NewNop(), WithFormatChangePolicy(tt.policy))
+			t.Cleanup(s.Close)
+			ctx := context.Background()
+			stream, err := s.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-1"})
+			if err != nil {
+				t.Fatalf("StartStream: %v", err)
+			}
+
+			rejected := 0
+			for _, format := range formats {
+				frame := &pb.VideoFrame{FrameId: "f", ClientId: "cam-1", Format: format, FrameData: []byte{1, 2, 3, 4}}
+				_, err := s.SendFrameInternal(ctx, stream.StreamId, "cam-1", "cam-1", frame)
+				switch {
+				case errors.Is(err, ErrFormatChanged):
+					rejected++
+				case err != nil:
+					t.Fatalf("SendFrame %s: %v", format, err)
+				}
+			}
+
+			if rejected != tt.wantRejected {
+				t.Errorf("rejected %d frames, want %d", rejected, tt.wantRejected)
+			}
+			if got := s.GetFormatHistogram(stream.StreamId); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("histogram = %v, want %v", got, tt.want)
+			}
+			if got := s.GetTotalStats()["formats"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("total formats = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"sync"
+
+	pb "api-gateway/pkg/gen"
+)
+
+// FrameSubscriber получатель кадров из FrameHub
+type FrameSubscriber struct {
+	C chan *pb.VideoFrame
+}
+
+// FrameHub рассылает кадры стримов подписчикам (например, WebSocket
+// клиентам). Медленный подписчик пропускает кадры, а не тормозит прием.
+type FrameHub struct {
+	mu   sync.RWMutex
+	subs map[string]map[*FrameSubscriber]struct{} // stream_id -> подписчики
+}
+
+// NewFrameHub создает хаб
+func NewFrameHub() *FrameHub {
+	return &FrameHub{
+		subs: make(map[string]map[*FrameSubscriber]struct{}),
+	}
+}
+
+// NewSubscriber создает подписчика с буфером на buffer кадров
+func (h *FrameHub) NewSubscriber(buffer int) *FrameSubscriber {
+	return &FrameSubscriber{C: make(chan *pb.VideoFrame, buffer)}
+}
+
+// Subscribe подписывает на кадры стрима
+func (h *FrameHub) Subscribe(sub *FrameSubscriber, streamID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subs[streamID] == nil {
+		h.subs[streamID] = make(map[*FrameSubscriber]struct{})
+	}
+	h.subs[streamID][sub] = struct{}{}
+}
+
+// Unsubscribe отписывает от кадров стрима
+func (h *FrameHub) Unsubscribe(sub *FrameSubscriber, streamID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.unsubscribeLocked(sub, streamID)
+}
+
+// Remove отписывает подписчика от всех стримов
+func (h *FrameHub) Remove(sub *FrameSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for streamID := range h.subs {
+		h.unsubscribeLocked(sub, streamID)
+	}
+}
+
+func (h *FrameHub) unsubscribeLocked(sub *FrameSubscriber, streamID string) {
+	delete(h.subs[streamID], sub)
+	if len(h.subs[streamID]) == 0 {
+		delete(h.subs, streamID)
+	}
+}
+
+// Publish отправляет кадр подписчикам стрима без блокировки
+func (h *FrameHub) Publish(streamID string, frame *pb.VideoFrame) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs[streamID] {
+		select {
+		case sub.C <- frame:
+		default:
+			// Буфер подписчика полон - кадр пропускается
+		}
+	}
+}
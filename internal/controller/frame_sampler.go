@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	pb "api-gateway/pkg/gen"
+)
+
+const (
+	// MaxSampleFrames максимальное число кадров в одной выборке
+	MaxSampleFrames = 100
+	// MaxSampleTimeout максимальное время ожидания выборки
+	MaxSampleTimeout = 60 * time.Second
+	// MaxConcurrentSamples максимальное число одновременных выборок по всем
+	// стримам: каждая держит запрос и копирует кадры под общей блокировкой
+	MaxConcurrentSamples = 8
+	// maxThumbnailSize кадры больше этого размера возвращаются без данных
+	maxThumbnailSize = 256 * 1024
+)
+
+// ErrTooManySamples - одновременно идет MaxConcurrentSamples выборок
+var ErrTooManySamples = errors.New("too many concurrent frame samples")
+
+// FrameSample метаданные кадра, попавшего в выборку
+type FrameSample struct {
+	FrameID    string `json:"frame_id"`
+	Timestamp  int64  `json:"timestamp"`
+	Width      int32  `json:"width"`
+	Height     int32  `json:"height"`
+	Format     string `json:"format"`
+	Size       int    `json:"size"`
+	ReceivedAt int64  `json:"received_at"`
+	// Thumbnail исходные данные кадра, если запрошены и кадр небольшой
+	Thumbnail []byte `json:"thumbnail,omitempty"`
+}
+
+// sampleSession одна активная выборка по стриму
+type sampleSession struct {
+	limit         int
+	withThumbnail bool
+	samples       []FrameSample
+	done          chan struct{}
+}
+
+// FrameSampler снимает копии метаданных кадров для отладки. Выборка
+// не влияет на обработку кадра и останавливается сама после N кадров.
+type FrameSampler struct {
+	mu       sync.Mutex
+	sessions map[string][]*sampleSession
+	active   int // выборки, ожидающие кадры
+}
+
+// NewFrameSampler создает сэмплер
+func NewFrameSampler() *FrameSampler {
+	return &FrameSampler{
+		sessions: make(map[string][]*sampleSession),
+	}
+}
+
+// Sample ждет следующие limit кадров стрима или истечения timeout и
+// возвращает собранное. Сверх MaxConcurrentSamples одновременных выборок
+// возвращает ErrTooManySamples.
+func (s *FrameSampler) Sample(
+	ctx context.Context,
+	streamID string,
+	limit int,
+	withThumbnail bool,
+	timeout time.Duration,
+) ([]FrameSample, error) {
+	if limit <= 0 {
+		limit = 1
+	}
+	if limit > MaxSampleFrames {
+		limit = MaxSampleFrames
+	}
+	if timeout <= 0 || timeout > MaxSampleTimeout {
+		timeout = MaxSampleTimeout
+	}
+
+	session := &sampleSession{
+		limit:         limit,
+		withThumbnail: withThumbnail,
+		samples:       make([]FrameSample, 0, limit),
+		done:          make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	if s.active >= MaxConcurrentSamples {
+		s.mu.Unlock()
+		return nil, ErrTooManySamples
+	}
+	s.active++
+	s.sessions[streamID] = append(s.sessions[streamID], session)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-session.done:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	s.removeLocked(streamID, session)
+
+	return session.samples, nil
+}
+
+// Observe добавляет кадр во все активные выборки стрима
+func (s *FrameSampler) Observe(streamID string, frame *pb.VideoFrame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := s.sessions[streamID]
+	if len(sessions) == 0 {
+		return
+	}
+
+	for _, session := range sessions {
+		sample := FrameSample{
+			FrameID:    frame.FrameId,
+			Timestamp:  frame.Timestamp,
+			Width:      frame.Width,
+			Height:     frame.Height,
+			Format:     frame.Format,
+			Size:       len(frame.FrameData),
+			ReceivedAt: time.Now().UnixMilli(),
+		}
+		if session.withThumbnail && len(frame.FrameData) <= maxThumbnailSize {
+			sample.Thumbnail = append([]byte(nil), frame.FrameData...)
+		}
+
+		session.samples = append(session.samples, sample)
+		if len(session.samples) == session.limit {
+			close(session.done)
+			s.removeLocked(streamID, session)
+		}
+	}
+}
+
+// removeLocked убирает выборку из активных, вызывается под блокировкой
+func (s *FrameSampler) removeLocked(streamID string, session *sampleSession) {
+	sessions := s.sessions[streamID]
+	for i, existing := range sessions {
+		if existing == session {
+			sessions = append(sessions[:i:i], sessions[i+1:]...)
+			break
+		}
+	}
+
+	if len(sessions) == 0 {
+		delete(s.sessions, streamID)
+	} else {
+		s.sessions[streamID] = sessions
+	}
+}
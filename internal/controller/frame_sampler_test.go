@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "api-gateway/pkg/gen"
+)
+
+func TestFrameSamplerLimitsConcurrentSamples(t *testing.T) {
+	s := NewFrameSampler()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan error, MaxConcurrentSamples)
+	for i := 0; i < MaxConcurrentSamples; i++ {
+		go func() {
+			_, err := s.Sample(ctx, "stream-1", 1, false, MaxSampleTimeout)
+			results <- err
+		}()
+	}
+
+	// Ждем, пока все выборки встанут в ожидание
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		active := s.active
+		s.mu.Unlock()
+		if active == MaxConcurrentSamples {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("active samples = %d, want %d", active, MaxConcurrentSamples)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := s.Sample(ctx, "stream-2", 1, false, time.Second); !errors.Is(err, ErrTooManySamples) {
+		t.Fatalf("Sample() over the limit error = %v, want ErrTooManySamples", err)
+	}
+
+	s.Observe("stream-1", &pb.VideoFrame{FrameId: "f1"})
+	for i := 0; i < MaxConcurrentSamples; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("Sample() error = %v", err)
+		}
+	}
+
+	if _, err := s.Sample(ctx, "stream-2", 1, false, time.Millisecond); err != nil {
+		t.Errorf("Sample() after release error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrFrameSignatureRequired - кадр клиента, обязанного подписывать кадры
+// (или в его стрим), пришел путем без проверки подписи
+var ErrFrameSignatureRequired = errors.New("frames of this client must be signed")
+
+type verifiedSignatureCtx struct{}
+
+// WithVerifiedSignature отмечает, что подпись кадра проверена транспортом
+func WithVerifiedSignature(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verifiedSignatureCtx{}, true)
+}
+
+func signatureVerified(ctx context.Context) bool {
+	verified, _ := ctx.Value(verifiedSignatureCtx{}).(bool)
+	return verified
+}
+
+// WithSigningClients задает клиентов, кадры которых принимаются только с
+// проверенной подписью (WithVerifiedSignature). Так gRPC и другие пути без
+// проверки подписи не обходят ее для подписывающих edge устройств.
+func WithSigningClients(clientIDs []string) VideoStreamOption {
+	return func(s *VideoStreamServiceImpl) {
+		s.signingClients = make(map[string]struct{}, len(clientIDs))
+		for _, clientID := range clientIDs {
+			s.signingClients[clientID] = struct{}{}
+		}
+	}
+}
+
+// checkFrameSigned отклоняет неподписанный кадр подписывающего клиента или
+// в стрим такого клиента
+func (s *VideoStreamServiceImpl) checkFrameSigned(ctx context.Context, streamID, clientID string) error {
+	if len(s.signingClients) == 0 || signatureVerified(ctx) {
+		return nil
+	}
+	if _, ok := s.signingClients[clientID]; ok {
+		return ErrFrameSignatureRequired
+	}
+	if stream := s.repo.GetStream(streamID); stream != nil {
+		if _, ok := s.signingClients[stream.ClientId]; ok {
+			return ErrFrameSignatureRequired
+		}
+	}
+	return nil
+}
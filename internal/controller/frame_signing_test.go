@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	pb "api-gateway/pkg/gen"
+)
+
+func TestSigningClients(t *testing.T) {
+	s := NewVideoStreamService(zap.NewNop(), WithSigningClients([]string{"edge-1"}))
+	t.Cleanup(s.Close)
+
+	signed, err := s.StartStream(context.Background(), &pb.StartStreamRequest{ClientId: "edge-1"})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		streamID string
+		clientID string
+		verified bool // подпись проверена транспортом
+		wantErr  error
+	}{
+		{"unsigned client", "", "cam-1", false, nil},
+		{"signing client without signature", "", "edge-1", false, ErrFrameSignatureRequired},
+		{"signing client with signature", "", "edge-1", true, nil},
+		{"stream of signing client", signed.StreamId, "cam-1", false, ErrFrameSignatureRequired},
+		{"stream of signing client with signature", signed.StreamId, "cam-1", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.verified {
+				ctx = WithVerifiedSignature(ctx)
+			}
+			frame := &pb.VideoFrame{FrameId: "f-1", FrameData: []byte{1}, Format: "jpeg"}
+			_, err := s.SendFrameInternal(ctx, tt.streamID, tt.clientID, "user", frame)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SendFrameInternal() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// IdempotencyKeyHeader заголовок HTTP (и ключ метаданных gRPC в нижнем
+// регистре) с ключом идемпотентности
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultIdempotencyTTL время хранения ответа по ключу по умолчанию
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultIdempotencyMaxKeys предел хранимых ключей по умолчанию
+const DefaultIdempotencyMaxKeys = 10000
+
+// ErrIdempotencyKeyReused - ключ идемпотентности повторен с другим запросом
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+type (
+	idempotencyKeyCtx     struct{}
+	requestFingerprintCtx struct{}
+)
+
+// WithIdempotencyKey кладет ключ идемпотентности в контекст запроса
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+// IdempotencyKeyFromContext возвращает ключ идемпотентности запроса
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyCtx{}).(string)
+	return key
+}
+
+// WithRequestFingerprint кладет в контекст отпечаток запроса в том виде,
+// в каком его прислал клиент (до заполнения значений по умолчанию). Без него
+// отпечаток считается по запросу, переданному сервису.
+func WithRequestFingerprint(ctx context.Context, fingerprint string) context.Context {
+	return context.WithValue(ctx, requestFingerprintCtx{}, fingerprint)
+}
+
+// RequestFingerprint возвращает отпечаток запроса request и дополнительных
+// полей extra для сравнения повторов с одним ключом идемпотентности
+func RequestFingerprint(request proto.Message, extra ...string) string {
+	data, _ := proto.MarshalOptions{Deterministic: true}.Marshal(request)
+	sum := sha256.New()
+	sum.Write(data)
+	for _, value := range extra {
+		sum.Write([]byte{0})
+		sum.Write([]byte(value))
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// requestFingerprint возвращает отпечаток из ctx или считает его по request
+func requestFingerprint(ctx context.Context, request proto.Message, extra ...string) string {
+	if fingerprint, ok := ctx.Value(requestFingerprintCtx{}).(string); ok {
+		return fingerprint
+	}
+	return RequestFingerprint(request, extra...)
+}
+
+// WithIdempotencyTTL задает, сколько хранится ответ StartStream/StopStream
+// по ключу идемпотентности
+func WithIdempotencyTTL(ttl time.Duration) VideoStreamOption {
+	return func(s *VideoStreamServiceImpl) {
+		if ttl > 0 {
+			s.idempotencyTTL = ttl
+		}
+	}
+}
+
+// WithIdempotencyMaxKeys ограничивает число хранимых ключей идемпотентности
+func WithIdempotencyMaxKeys(maxKeys int) VideoStreamOption {
+	return func(s *VideoStreamServiceImpl) {
+		if maxKeys > 0 {
+			s.idempotency = NewIdempotencyRepository(maxKeys)
+		}
+	}
+}
+
+// runIdempotent выполняет call один раз на (operation, clientID, ключ из
+// ctx). Повтор с тем же ключом и отпечатком запроса fingerprint в пределах
+// TTL получает копию первого ответа без повторных побочных эффектов;
+// параллельный повтор ждет завершения первого вызова. Тот же ключ с другим
+// запросом - ErrIdempotencyKeyReused. Ошибки не сохраняются: после неудачи
+// ключ можно повторить.
+func runIdempotent[T proto.Message](
+	ctx context.Context,
+	s *VideoStreamServiceImpl,
+	operation, clientID, fingerprint string,
+	call func() (T, error),
+) (T, error) {
+	key := IdempotencyKeyFromContext(ctx)
+	if key == "" {
+		return call()
+	}
+	scope := operation + "\x00" + clientID + "\x00" + key
+
+	for {
+		entry, owner, err := s.idempotency.Begin(scope, fingerprint)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		if owner {
+			response, err := call()
+			if err != nil {
+				s.idempotency.Abort(scope, entry)
+				return response, err
+			}
+			s.idempotency.Complete(scope, entry, proto.Clone(response), s.idempotencyTTL)
+			return response, nil
+		}
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+
+		// Первый вызов завершился ошибкой - пробуем выполнить сами
+		if entry.response == nil {
+			continue
+		}
+		return proto.Clone(entry.response).(T), nil
+	}
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	pb "api-gateway/pkg/gen"
+)
+
+func TestIdempotentStart(t *testing.T) {
+	first := &pb.StartStreamRequest{ClientId: "cam-1", CameraName: "front"}
+
+	tests := []struct {
+		name       string
+		key        string
+		retry      *pb.StartStreamRequest
+		wantErr    error
+		wantSameID bool // повтор вернул стрим первого вызова
+	}{
+		{"same request", "k1", &pb.StartStreamRequest{ClientId: "cam-1", CameraName: "front"}, nil, true},
+		{"different body", "k1", &pb.StartStreamRequest{ClientId: "cam-1", CameraName: "back"}, ErrIdempotencyKeyReused, false},
+		{"other client", "k1", &pb.StartStreamRequest{ClientId: "cam-2", CameraName: "back"}, nil, false},
+		{"without key", "", &pb.StartStreamRequest{ClientId: "cam-1", CameraName: "front"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewVideoStreamService(zap.NewNop())
+			t.Cleanup(s.Close)
+			ctx := WithIdempotencyKey(context.Background(), tt.key)
+
+			started, err := s.StartStream(ctx, first)
+			if err != nil {
+				t.Fatalf("StartStream: %v", err)
+			}
+			retried, err := s.StartStream(ctx, tt.retry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("retry error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if same := retried.StreamId == started.StreamId; same != tt.wantSameID {
+				t.Errorf("retry stream %q, first %q: same = %v, want %v", retried.StreamId, started.StreamId, same, tt.wantSameID)
+			}
+		})
+	}
+
+	t.Run("fingerprint from context", func(t *testing.T) {
+		s := NewVideoStreamService(zap.NewNop())
+		t.Cleanup(s.Close)
+		ctx := WithRequestFingerprint(WithIdempotencyKey(context.Background(), "k1"), "client-body")
+
+		// Значения по умолчанию отличаются, запрос клиента тот же
+		started, err := s.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-1", Filename: "a.mp4"})
+		if err != nil {
+			t.Fatalf("StartStream: %v", err)
+		}
+		retried, err := s.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-1", Filename: "b.mp4"})
+		if err != nil || retried.StreamId != started.StreamId {
+			t.Errorf("retry = %v, %v, want stream %q", retried, err, started.StreamId)
+		}
+	})
+}
+
+func TestIdempotencyRepositoryLimit(t *testing.T) {
+	r := NewIdempotencyRepository(2)
+	for _, key := range []string{"a", "b", "c"} {
+		entry, owner, err := r.Begin(key, "fp")
+		if err != nil || !owner {
+			t.Fatalf("Begin(%q) = owner %v, %v", key, owner, err)
+		}
+		r.Complete(key, entry, &pb.ApiResponse{Status: key}, DefaultIdempotencyTTL)
+	}
+
+	if n := r.Count(); n != 2 {
+		t.Errorf("Count() = %d, want 2", n)
+	}
+	if _, owner, _ := r.Begin("a", "fp"); !owner {
+		t.Error("oldest key a was not evicted")
+	}
+	if _, owner, _ := r.Begin("c", "fp"); owner {
+		t.Error("newest key c was evicted")
+	}
+}
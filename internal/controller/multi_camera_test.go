@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	pb "api-gateway/pkg/gen"
+)
+
+func TestMultiCameraStreamRoutesFrames(t *testing.T) {
+	s := NewVideoStreamService(zap.NewNop())
+	t.Cleanup(s.Close)
+	ctx := context.Background()
+
+	started, err := s.StartMultiCameraStream(ctx, &pb.StartStreamRequest{ClientId: "rig-1"}, []string{"front", "back"})
+	if err != nil {
+		t.Fatalf("StartMultiCameraStream: %v", err)
+	}
+	streamID := started.StreamId
+
+	subs := map[string]*FrameSubscriber{}
+	for _, camera := range []string{"front", "back"} {
+		subs[camera] = s.FrameHub().NewSubscriber(4)
+		s.FrameHub().Subscribe(subs[camera], CameraChannel(streamID, camera))
+	}
+
+	tests := []struct {
+		cameraID string
+		wantErr  error
+	}{
+		{"front", nil},
+		{"back", nil},
+		{"side", ErrUnknownCamera},
+	}
+	for _, tt := range tests {
+		frame := &pb.VideoFrame{FrameId: tt.cameraID + "-1", CameraId: tt.cameraID, Format: "jpeg", FrameData: []byte{1}}
+		_, err := s.SendFrameInternal(ctx, streamID, "rig-1", "rig-1", frame)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("SendFrame(camera %q) error = %v, want %v", tt.cameraID, err, tt.wantErr)
+		}
+	}
+
+	for camera, sub := range subs {
+		if got := len(sub.C); got != 1 {
+			t.Fatalf("camera %q channel got %d frames, want 1", camera, got)
+		}
+		if frame := <-sub.C; frame.CameraId != camera {
+			t.Errorf("camera %q channel got frame of camera %q", camera, frame.CameraId)
+		}
+	}
+}
@@ -1,13 +1,23 @@
 package controller
 
 import (
+	"container/list"
 	"sync"
 	"time"
 
 	pb "api-gateway/pkg/gen"
 	videopb "api-gateway/pkg/gen"
+	"google.golang.org/protobuf/proto"
 )
 
+// ClientStore хранилище зарегистрированных клиентов
+type ClientStore interface {
+	SaveClient(client *pb.ClientInfo)
+	GetClient(clientID string) *pb.ClientInfo
+	RemoveClient(clientID string)
+	GetAllClients() []*pb.ClientInfo
+}
+
 // ClientRepository - репозиторий для клиентов (in-memory)
 type ClientRepository struct {
 	clients map[string]*pb.ClientInfo
@@ -70,16 +80,36 @@ func (r *ClientRepository) GetAllClients() []*pb.ClientInfo {
 
 // StreamRepository - репозиторий для стримов
 type StreamRepository struct {
-	streams map[string]*videopb.ActiveStream
-	stats   map[string]*videopb.StreamStats
-	mu      sync.RWMutex
+	streams    map[string]*videopb.ActiveStream
+	stats      map[string]*videopb.StreamStats
+	fpsWindows map[string]*fpsWindow
+	limiters   map[string]*bandwidthLimiter
+	cameras    map[string][]string         // stream_id -> камеры многокамерного стрима
+	lastFrame  map[string]time.Time        // stream_id -> время последнего кадра (или старта)
+	formats    map[string]map[string]int64 // stream_id -> формат -> число кадров
+	format     map[string]string           // stream_id -> формат последнего кадра
+	owners     map[string]string           // stream_id -> пользователь, подтвержденный аутентификацией
+	mu         sync.RWMutex
+}
+
+// fpsWindow окно подсчета текущего FPS
+type fpsWindow struct {
+	start  time.Time
+	frames int
 }
 
 // NewStreamRepository создает новый репозиторий
 func NewStreamRepository() *StreamRepository {
 	return &StreamRepository{
-		streams: make(map[string]*videopb.ActiveStream),
-		stats:   make(map[string]*videopb.StreamStats),
+		streams:    make(map[string]*videopb.ActiveStream),
+		stats:      make(map[string]*videopb.StreamStats),
+		fpsWindows: make(map[string]*fpsWindow),
+		limiters:   make(map[string]*bandwidthLimiter),
+		cameras:    make(map[string][]string),
+		lastFrame:  make(map[string]time.Time),
+		formats:    make(map[string]map[string]int64),
+		format:     make(map[string]string),
+		owners:     make(map[string]string),
 	}
 }
 
@@ -88,7 +118,42 @@ func (r *StreamRepository) SaveStream(streamID string, stream *videopb.ActiveStr
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.saveStreamLocked(streamID, stream)
+}
+
+// TrySaveStream сохраняет новый стрим, если стримов меньше maxStreams
+// (0 - без лимита). Проверка и вставка идут под одной блокировкой, поэтому
+// параллельные вызовы не превышают лимит. Уже существующий стрим не
+// перезаписывается: created=false, ok=true.
+func (r *StreamRepository) TrySaveStream(streamID string, stream *videopb.ActiveStream, maxStreams int) (created, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.streams[streamID]; exists {
+		return false, true
+	}
+	if maxStreams > 0 && len(r.streams) >= maxStreams {
+		return false, false
+	}
+	r.saveStreamLocked(streamID, stream)
+	return true, true
+}
+
+// Count возвращает число стримов
+func (r *StreamRepository) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.streams)
+}
+
+// saveStreamLocked сохраняет стрим и инициализирует его статистику;
+// вызывается под r.mu
+func (r *StreamRepository) saveStreamLocked(streamID string, stream *videopb.ActiveStream) {
 	r.streams[streamID] = stream
+	if _, exists := r.lastFrame[streamID]; !exists {
+		r.lastFrame[streamID] = time.Now()
+	}
 
 	// Инициализируем статистику
 	if _, exists := r.stats[streamID]; !exists {
@@ -120,6 +185,7 @@ func (r *StreamRepository) UpdateStats(streamID string, frame *videopb.VideoFram
 	}
 
 	stats.FramesReceived++
+	r.lastFrame[streamID] = time.Now()
 	if frame != nil {
 		// Добавляем реальный размер кадра
 		stats.BytesReceived += int64(len(frame.FrameData))
@@ -132,6 +198,17 @@ func (r *StreamRepository) UpdateStats(streamID string, frame *videopb.VideoFram
 			stats.Height = frame.Height
 		}
 
+		if format := NormalizeFrameFormat(frame.Format); format != "" {
+			histogram, ok := r.formats[streamID]
+			if !ok {
+				histogram = make(map[string]int64)
+				r.formats[streamID] = histogram
+			}
+			histogram[format]++
+			r.format[streamID] = format
+			stats.Codec = format
+		}
+
 		// Рассчитываем средний FPS
 		now := time.Now().Unix()
 		duration := float64(now - stats.StartTime)
@@ -143,6 +220,19 @@ func (r *StreamRepository) UpdateStats(streamID string, frame *videopb.VideoFram
 		stats.Duration = now - stats.StartTime
 	}
 
+	// Текущий FPS считаем по окну около секунды
+	window, ok := r.fpsWindows[streamID]
+	if !ok {
+		window = &fpsWindow{start: time.Now()}
+		r.fpsWindows[streamID] = window
+	}
+	window.frames++
+	if elapsed := time.Since(window.start); elapsed >= time.Second {
+		stats.CurrentFps = float32(float64(window.frames) / elapsed.Seconds())
+		window.start = time.Now()
+		window.frames = 0
+	}
+
 	return stats
 }
 
@@ -175,6 +265,60 @@ func (r *StreamRepository) GetStream(streamID string) *videopb.ActiveStream {
 	return r.streams[streamID]
 }
 
+// GetStreamWithStats возвращает копии стрима и его статистики
+func (r *StreamRepository) GetStreamWithStats(streamID string) (*videopb.ActiveStream, *videopb.StreamStats) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stream, exists := r.streams[streamID]
+	if !exists {
+		return nil, nil
+	}
+
+	var stats *videopb.StreamStats
+	if s := r.stats[streamID]; s != nil {
+		stats = proto.Clone(s).(*videopb.StreamStats)
+	}
+	return proto.Clone(stream).(*videopb.ActiveStream), stats
+}
+
+// SetRecording включает или выключает запись стрима. Возвращает false,
+// если стрима нет.
+func (r *StreamRepository) SetRecording(streamID string, enabled bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stream, exists := r.streams[streamID]
+	if !exists {
+		return false
+	}
+
+	stream.IsRecording = enabled
+	if stats := r.stats[streamID]; stats != nil {
+		stats.IsRecording = enabled
+	}
+	return true
+}
+
+// TransferStream передает стрим клиенту newClientID и возвращает прежнего
+// владельца. ok false, если стрима нет.
+func (r *StreamRepository) TransferStream(streamID, newClientID string) (previous string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stream, exists := r.streams[streamID]
+	if !exists {
+		return "", false
+	}
+
+	previous = stream.ClientId
+	stream.ClientId = newClientID
+	if stats := r.stats[streamID]; stats != nil {
+		stats.ClientId = newClientID
+	}
+	return previous, true
+}
+
 // GetAllStreams возвращает все стримы
 func (r *StreamRepository) GetAllStreams() []*videopb.ActiveStream {
 	r.mu.RLock()
@@ -195,6 +339,107 @@ func (r *StreamRepository) RemoveStream(streamID string) {
 
 	delete(r.streams, streamID)
 	delete(r.stats, streamID)
+	delete(r.fpsWindows, streamID)
+	delete(r.limiters, streamID)
+	delete(r.cameras, streamID)
+	delete(r.lastFrame, streamID)
+	delete(r.formats, streamID)
+	delete(r.format, streamID)
+	delete(r.owners, streamID)
+}
+
+// SetOwner запоминает подтвержденного пользователя-владельца стрима
+func (r *StreamRepository) SetOwner(streamID, userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.streams[streamID]; exists {
+		r.owners[streamID] = userID
+	}
+}
+
+// GetOwner возвращает подтвержденного владельца стрима ("" - не подтвержден)
+func (r *StreamRepository) GetOwner(streamID string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.owners[streamID]
+}
+
+// GetFormat возвращает формат последнего кадра стрима
+func (r *StreamRepository) GetFormat(streamID string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.format[streamID]
+}
+
+// GetFormatHistogram возвращает копию числа кадров стрима по форматам
+func (r *StreamRepository) GetFormatHistogram(streamID string) map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	histogram := make(map[string]int64, len(r.formats[streamID]))
+	for format, count := range r.formats[streamID] {
+		histogram[format] = count
+	}
+	return histogram
+}
+
+// GetLastFrameAt возвращает время последнего кадра стрима (до первого
+// кадра - время старта)
+func (r *StreamRepository) GetLastFrameAt(streamID string) (time.Time, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	at, ok := r.lastFrame[streamID]
+	return at, ok
+}
+
+// GetIdleStreams возвращает стримы без кадров дольше idleTimeout
+func (r *StreamRepository) GetIdleStreams(idleTimeout time.Duration) []*videopb.ActiveStream {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var idle []*videopb.ActiveStream
+	for streamID, at := range r.lastFrame {
+		if time.Since(at) > idleTimeout {
+			if stream := r.streams[streamID]; stream != nil {
+				idle = append(idle, stream)
+			}
+		}
+	}
+	return idle
+}
+
+// SetCameras задает камеры многокамерного стрима
+func (r *StreamRepository) SetCameras(streamID string, cameras []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cameras[streamID] = append([]string(nil), cameras...)
+}
+
+// GetCameras возвращает камеры стрима (nil - стрим однокамерный)
+func (r *StreamRepository) GetCameras(streamID string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.cameras[streamID]...)
+}
+
+// GetLimiter возвращает лимитер битрейта стрима, создавая его при первом
+// обращении
+func (r *StreamRepository) GetLimiter(streamID string, bitsPerSecond int) *bandwidthLimiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limiter, ok := r.limiters[streamID]
+	if !ok {
+		limiter = newBandwidthLimiter(bitsPerSecond)
+		r.limiters[streamID] = limiter
+	}
+	return limiter
 }
 
 // GetAllActiveStreams возвращает только активные стримы
@@ -211,3 +456,121 @@ func (r *StreamRepository) GetAllActiveStreams() []*videopb.ActiveStream {
 
 	return activeStreams
 }
+
+// idempotencyEntry результат вызова с ключом идемпотентности. done
+// закрывается, когда первый вызов завершился; до этого повторы ждут.
+type idempotencyEntry struct {
+	key         string
+	fingerprint string // отпечаток запроса, с которым ключ использован впервые
+	done        chan struct{}
+	response    proto.Message
+	expiresAt   time.Time
+	elem        *list.Element
+}
+
+// IdempotencyRepository хранит ответы вызовов по ключу идемпотентности
+// в течение TTL (in-memory), не больше maxEntries ключей: сверх предела
+// вытесняются самые старые
+type IdempotencyRepository struct {
+	entries    map[string]*idempotencyEntry
+	order      *list.List // записи в порядке создания
+	maxEntries int
+	mu         sync.Mutex
+}
+
+// NewIdempotencyRepository создает пустой репозиторий не больше чем на
+// maxEntries ключей (<= 0 - DefaultIdempotencyMaxKeys)
+func NewIdempotencyRepository(maxEntries int) *IdempotencyRepository {
+	if maxEntries <= 0 {
+		maxEntries = DefaultIdempotencyMaxKeys
+	}
+	return &IdempotencyRepository{
+		entries:    make(map[string]*idempotencyEntry),
+		order:      list.New(),
+		maxEntries: maxEntries,
+	}
+}
+
+// Begin возвращает запись ключа. owner=true означает, что записи не было и
+// вызывающий должен выполнить операцию и вызвать Complete или Abort;
+// иначе нужно дождаться entry.done и взять entry.response. Ключ, уже
+// использованный с другим отпечатком запроса, - ErrIdempotencyKeyReused.
+func (r *IdempotencyRepository) Begin(key, fingerprint string) (entry *idempotencyEntry, owner bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.purgeExpiredLocked(now)
+
+	if entry, ok := r.entries[key]; ok {
+		if entry.expiresAt.IsZero() || now.Before(entry.expiresAt) {
+			if entry.fingerprint != fingerprint {
+				return nil, false, ErrIdempotencyKeyReused
+			}
+			return entry, false, nil
+		}
+		r.removeLocked(entry)
+	}
+
+	for len(r.entries) >= r.maxEntries {
+		r.removeLocked(r.order.Front().Value.(*idempotencyEntry))
+	}
+
+	entry = &idempotencyEntry{key: key, fingerprint: fingerprint, done: make(chan struct{})}
+	entry.elem = r.order.PushBack(entry)
+	r.entries[key] = entry
+	return entry, true, nil
+}
+
+// Complete сохраняет ответ на ttl и будит ожидающие повторы
+func (r *IdempotencyRepository) Complete(key string, entry *idempotencyEntry, response proto.Message, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.response = response
+	entry.expiresAt = time.Now().Add(ttl)
+	close(entry.done)
+}
+
+// Abort удаляет незавершенную запись: операция не удалась, следующий
+// вызов с тем же ключом выполнит ее заново
+func (r *IdempotencyRepository) Abort(key string, entry *idempotencyEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.entries[key] == entry {
+		r.removeLocked(entry)
+	}
+	close(entry.done)
+}
+
+// Count возвращает число сохраненных ключей
+func (r *IdempotencyRepository) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.entries)
+}
+
+// removeLocked удаляет запись из индекса и очереди
+func (r *IdempotencyRepository) removeLocked(entry *idempotencyEntry) {
+	if r.entries[entry.key] == entry {
+		delete(r.entries, entry.key)
+	}
+	if entry.elem != nil {
+		r.order.Remove(entry.elem)
+		entry.elem = nil
+	}
+}
+
+// purgeExpiredLocked удаляет истекшие записи с начала очереди: TTL у всех
+// записей общий, поэтому они истекают примерно в порядке создания
+func (r *IdempotencyRepository) purgeExpiredLocked(now time.Time) {
+	for elem := r.order.Front(); elem != nil; elem = r.order.Front() {
+		entry := elem.Value.(*idempotencyEntry)
+		if entry.expiresAt.IsZero() || now.Before(entry.expiresAt) {
+			return
+		}
+		r.removeLocked(entry)
+	}
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/requestid"
+	pb "api-gateway/pkg/gen"
+)
+
+var (
+	// ErrStreamUserMismatch - новый клиент принадлежит другому пользователю
+	ErrStreamUserMismatch = errors.New("new client belongs to a different user")
+	// ErrStreamOwnerUnverified - стрим создан без аутентификации, его
+	// пользователь не подтвержден
+	ErrStreamOwnerUnverified = errors.New("stream owner is not verified")
+)
+
+type verifiedOwnerCtx struct{}
+
+// WithVerifiedOwner отмечает, что клиент и пользователь запроса подтверждены
+// аутентификацией. Пользователь стрима, созданного с таким контекстом,
+// запоминается как владелец для TransferStream.
+func WithVerifiedOwner(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verifiedOwnerCtx{}, true)
+}
+
+func ownerVerified(ctx context.Context) bool {
+	verified, _ := ctx.Value(verifiedOwnerCtx{}).(bool)
+	return verified
+}
+
+// TransferStream передает стрим другому клиенту того же пользователя
+// (например, новому процессу после падения прежнего) и возвращает копию
+// стрима. userID - пользователь нового клиента, подтвержденный
+// аутентификацией; он сравнивается с подтвержденным владельцем стрима.
+// Пустой userID - передача администратором без проверки пользователя.
+func (s *VideoStreamServiceImpl) TransferStream(ctx context.Context, streamID, newClientID, userID string) (*pb.ActiveStream, error) {
+	stream, _ := s.repo.GetStreamWithStats(streamID)
+	if stream == nil {
+		return nil, ErrStreamNotFound
+	}
+
+	if userID != "" {
+		owner := s.repo.GetOwner(streamID)
+		if owner == "" {
+			return nil, ErrStreamOwnerUnverified
+		}
+		if owner != userID {
+			return nil, ErrStreamUserMismatch
+		}
+	}
+	if stream.ClientId == newClientID {
+		return stream, nil
+	}
+
+	previous, ok := s.repo.TransferStream(streamID, newClientID)
+	if !ok {
+		return nil, ErrStreamNotFound
+	}
+
+	requestid.Logger(ctx, s.logger).Info("Stream transferred",
+		zap.String("stream_id", streamID),
+		zap.String("previous_client_id", previous),
+		zap.String("client_id", newClientID),
+		zap.String("user_id", userID))
+
+	stream, _ = s.repo.GetStreamWithStats(streamID)
+	if stream == nil {
+		return nil, ErrStreamNotFound
+	}
+	return stream, nil
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	pb "api-gateway/pkg/gen"
+)
+
+func TestTransferStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		verified    bool   // стрим создан аутентифицированным запросом
+		newClientID string // клиент, которому передается стрим
+		userID      string // подтвержденный пользователь нового клиента
+		wantErr     error
+	}{
+		{"same user", true, "cam-2", "alice", nil},
+		{"same client", true, "cam-1", "alice", nil},
+		{"other user", true, "cam-2", "mallory", ErrStreamUserMismatch},
+		{"unverified owner", false, "cam-2", "alice", ErrStreamOwnerUnverified},
+		{"admin", false, "cam-2", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewVideoStreamService(zap.NewNop())
+			t.Cleanup(s.Close)
+
+			ctx := context.Background()
+			if tt.verified {
+				ctx = WithVerifiedOwner(ctx)
+			}
+			started, err := s.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-1", UserId: "alice"})
+			if err != nil {
+				t.Fatalf("StartStream: %v", err)
+			}
+
+			stream, err := s.TransferStream(context.Background(), started.StreamId, tt.newClientID, tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TransferStream() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if stream.ClientId != tt.newClientID {
+				t.Errorf("ClientId = %q, want %q", stream.ClientId, tt.newClientID)
+			}
+		})
+	}
+
+	t.Run("unknown stream", func(t *testing.T) {
+		s := NewVideoStreamService(zap.NewNop())
+		t.Cleanup(s.Close)
+		if _, err := s.TransferStream(context.Background(), "missing", "cam-2", "alice"); !errors.Is(err, ErrStreamNotFound) {
+			t.Errorf("TransferStream() error = %v, want ErrStreamNotFound", err)
+		}
+	})
+}
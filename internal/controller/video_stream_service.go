@@ -2,26 +2,208 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"api-gateway/internal/requestid"
+	"api-gateway/internal/tracing"
 	pb "api-gateway/pkg/gen"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
+// ErrStreamNotFound - стрим не найден
+var ErrStreamNotFound = errors.New("stream not found")
+
+// ErrTooManyStarts - превышен лимит одновременных StartStream
+var ErrTooManyStarts = errors.New("too many concurrent stream starts")
+
+// ErrStreamCapacity - достигнут лимит одновременно активных стримов
+var ErrStreamCapacity = errors.New("stream capacity reached")
+
+// ErrUnknownCamera - кадр многокамерного стрима от незаявленной камеры
+var ErrUnknownCamera = errors.New("camera is not part of the stream")
+
 // VideoStreamServiceImpl - сервис для управления видеостримами
 type VideoStreamServiceImpl struct {
-	repo   *StreamRepository
-	logger *zap.Logger
-	mu     sync.RWMutex
+	repo    *StreamRepository
+	sampler *FrameSampler
+	hub     *FrameHub
+	logger  *zap.Logger
+	mu      sync.RWMutex
+
+	// Лимит одновременных StartStream (nil - без лимита)
+	startSlots   chan struct{}
+	startTimeout time.Duration
+
+	// Лимит битрейта одного стрима, бит/с (0 - без лимита)
+	maxBitrate      int
+	throttleMaxWait time.Duration
+
+	// Лимит одновременно активных стримов (0 - без лимита)
+	maxStreams int
+
+	// Стримы без кадров дольше idleTimeout останавливаются (0 - никогда)
+	idleTimeout time.Duration
+	stopReaper  chan struct{}
+	closeOnce   sync.Once
+
+	// Реакция на смену формата кадров посреди стрима
+	formatPolicy string
+
+	// Ответы StartStream/StopStream по ключу идемпотентности
+	idempotency    *IdempotencyRepository
+	idempotencyTTL time.Duration
+
+	// Клиенты, кадры которых принимаются только с проверенной подписью
+	signingClients map[string]struct{}
+}
+
+// VideoStreamOption настраивает сервис при создании
+type VideoStreamOption func(*VideoStreamServiceImpl)
+
+// WithStartLimit ограничивает число одновременных StartStream. Лишние
+// вызовы ждут свободный слот не дольше wait, затем получают ErrTooManyStarts.
+func WithStartLimit(maxConcurrent int, wait time.Duration) VideoStreamOption {
+	return func(s *VideoStreamServiceImpl) {
+		if maxConcurrent > 0 {
+			s.startSlots = make(chan struct{}, maxConcurrent)
+			s.startTimeout = wait
+		}
+	}
+}
+
+// WithStreamBitrateLimit ограничивает битрейт каждого стрима. Кадр сверх
+// лимита ждет не дольше maxWait, иначе отклоняется с StreamThrottledError.
+func WithStreamBitrateLimit(bitsPerSecond int, maxWait time.Duration) VideoStreamOption {
+	return func(s *VideoStreamServiceImpl) {
+		if bitsPerSecond > 0 {
+			s.maxBitrate = bitsPerSecond
+			s.throttleMaxWait = maxWait
+		}
+	}
+}
+
+// WithMaxStreams ограничивает число одновременно активных стримов.
+// StartStream и автосоздание стрима по первому кадру сверх лимита
+// получают ErrStreamCapacity.
+func WithMaxStreams(maxStreams int) VideoStreamOption {
+	return func(s *VideoStreamServiceImpl) {
+		if maxStreams > 0 {
+			s.maxStreams = maxStreams
+		}
+	}
+}
+
+// WithIdleStreamTimeout останавливает стримы, не получавшие кадров дольше
+// timeout. Проверка идет в фоне до вызова Close.
+func WithIdleStreamTimeout(timeout time.Duration) VideoStreamOption {
+	return func(s *VideoStreamServiceImpl) {
+		s.idleTimeout = timeout
+	}
 }
 
 // NewVideoStreamService создает новый сервис
-func NewVideoStreamService(logger *zap.Logger) *VideoStreamServiceImpl {
-	return &VideoStreamServiceImpl{
-		repo:   NewStreamRepository(),
-		logger: logger,
+func NewVideoStreamService(logger *zap.Logger, opts ...VideoStreamOption) *VideoStreamServiceImpl {
+	s := &VideoStreamServiceImpl{
+		repo:    NewStreamRepository(),
+		sampler: NewFrameSampler(),
+		hub:     NewFrameHub(),
+		logger:  logger,
+
+		idempotency:    NewIdempotencyRepository(DefaultIdempotencyMaxKeys),
+		idempotencyTTL: DefaultIdempotencyTTL,
+		formatPolicy:   FormatChangeWarn,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.stopReaper = make(chan struct{})
+	if s.idleTimeout > 0 {
+		go s.reapIdleStreamsLoop()
+	}
+	return s
+}
+
+// Close останавливает фоновые задачи сервиса
+func (s *VideoStreamServiceImpl) Close() {
+	s.closeOnce.Do(func() { close(s.stopReaper) })
+}
+
+// reapIdleStreamsLoop периодически останавливает простаивающие стримы
+func (s *VideoStreamServiceImpl) reapIdleStreamsLoop() {
+	interval := s.idleTimeout / 2
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.ReapIdleStreams(s.idleTimeout)
+		case <-s.stopReaper:
+			return
+		}
+	}
+}
+
+// ReapIdleStreams останавливает стримы без кадров дольше idleTimeout и
+// возвращает их ID
+func (s *VideoStreamServiceImpl) ReapIdleStreams(idleTimeout time.Duration) []string {
+	var reaped []string
+	for _, stream := range s.repo.GetIdleStreams(idleTimeout) {
+		lastFrameAt, _ := s.repo.GetLastFrameAt(stream.StreamId)
+		s.logger.Info("Stopping idle stream",
+			zap.String("stream_id", stream.StreamId),
+			zap.String("client_id", stream.ClientId),
+			zap.Time("last_frame_at", lastFrameAt))
+
+		if _, err := s.StopStream(context.Background(), &pb.StopStreamRequest{
+			StreamId: stream.StreamId,
+			ClientId: stream.ClientId,
+			EndTime:  time.Now().Unix(),
+		}); err != nil {
+			continue
+		}
+		reaped = append(reaped, stream.StreamId)
+	}
+	return reaped
+}
+
+// acquireStartSlot занимает слот для StartStream; release нужно вызвать
+// по завершении
+func (s *VideoStreamServiceImpl) acquireStartSlot(ctx context.Context) (release func(), err error) {
+	if s.startSlots == nil {
+		return func() {}, nil
+	}
+
+	release = func() { <-s.startSlots }
+
+	select {
+	case s.startSlots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if s.startTimeout <= 0 {
+		return nil, ErrTooManyStarts
+	}
+
+	timer := time.NewTimer(s.startTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.startSlots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrTooManyStarts
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 }
 
@@ -30,9 +212,55 @@ func (s *VideoStreamServiceImpl) StartStream(
 	ctx context.Context,
 	req *pb.StartStreamRequest,
 ) (*pb.StartStreamResponse, error) {
-	s.logger.Info("Starting stream",
+	return s.StartMultiCameraStream(ctx, req, nil)
+}
+
+// StartMultiCameraStream начинает стрим с несколькими камерами. Кадры такого
+// стрима должны нести camera_id из списка и дополнительно публикуются в
+// подканал "<stream_id>/<camera_id>" (CameraChannel). Сервис сам кадры в
+// сервисы видеообработки не отправляет: эндпоинт камеры выбирает шлюз по
+// services.camera_routes. Пустой список - обычный стрим с req.CameraName. Повтор с тем же ключом идемпотентности (см.
+// WithIdempotencyKey) и client_id возвращает исходный ответ, тот же ключ с
+// другим запросом - ErrIdempotencyKeyReused.
+func (s *VideoStreamServiceImpl) StartMultiCameraStream(
+	ctx context.Context,
+	req *pb.StartStreamRequest,
+	cameras []string,
+) (*pb.StartStreamResponse, error) {
+	fingerprint := requestFingerprint(ctx, req, cameras...)
+	return runIdempotent(ctx, s, "start", req.ClientId, fingerprint, func() (*pb.StartStreamResponse, error) {
+		return s.startStream(ctx, req, cameras)
+	})
+}
+
+// startStream выполняет StartMultiCameraStream без учета идемпотентности
+func (s *VideoStreamServiceImpl) startStream(
+	ctx context.Context,
+	req *pb.StartStreamRequest,
+	cameras []string,
+) (*pb.StartStreamResponse, error) {
+	_, span := tracing.StartSpan(ctx, "VideoStreamService.StartStream",
+		tracing.AttrClientID.String(req.ClientId))
+	defer span.End()
+
+	// Запрос уже отменен или истек дедлайн клиента
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	release, err := s.acquireStartSlot(ctx)
+	if err != nil {
+		requestid.Logger(ctx, s.logger).Warn("Stream start rejected",
+			zap.String("client_id", req.ClientId),
+			zap.Error(err))
+		return nil, err
+	}
+	defer release()
+
+	requestid.Logger(ctx, s.logger).Info("Starting stream",
 		zap.String("client_id", req.ClientId),
-		zap.String("camera", req.CameraName))
+		zap.String("camera", req.CameraName),
+		zap.Strings("cameras", cameras))
 
 	streamID := fmt.Sprintf("stream_%s_%d", req.ClientId, time.Now().UnixNano())
 
@@ -45,7 +273,21 @@ func (s *VideoStreamServiceImpl) StartStream(
 		IsStreaming: true,
 	}
 
-	s.repo.SaveStream(streamID, activeStream)
+	created, ok := s.repo.TrySaveStream(streamID, activeStream, s.maxStreams)
+	if !ok {
+		requestid.Logger(ctx, s.logger).Warn("Stream start rejected",
+			zap.String("client_id", req.ClientId),
+			zap.Int("max_streams", s.maxStreams),
+			zap.Error(ErrStreamCapacity))
+		return nil, fmt.Errorf("%w: limit %d", ErrStreamCapacity, s.maxStreams)
+	}
+	if created && ownerVerified(ctx) {
+		s.repo.SetOwner(streamID, activeStream.UserName)
+	}
+	if len(cameras) > 0 {
+		s.repo.SetCameras(streamID, cameras)
+	}
+	span.SetAttributes(tracing.AttrStreamID.String(streamID))
 
 	return &pb.StartStreamResponse{
 		StreamId: streamID,
@@ -60,6 +302,7 @@ func (s *VideoStreamServiceImpl) SendFrame(
 	req *pb.SendFrameRequest,
 ) (*pb.ApiResponse, error) {
 	return s.SendFrameInternal(
+		ctx,
 		req.StreamId,
 		req.ClientId,
 		req.UserName,
@@ -69,15 +312,28 @@ func (s *VideoStreamServiceImpl) SendFrame(
 
 // SendFrameInternal - внутренний метод для обработки кадра
 func (s *VideoStreamServiceImpl) SendFrameInternal(
+	ctx context.Context,
 	streamID, clientID, userName string,
 	frame *pb.VideoFrame,
 ) (*pb.ApiResponse, error) {
+	_, span := tracing.StartSpan(ctx, "VideoStreamService.SendFrame",
+		tracing.AttrStreamID.String(streamID),
+		tracing.AttrClientID.String(clientID))
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if frame == nil {
 		return &pb.ApiResponse{
 			Status:  "error",
 			Message: "Frame is nil",
 		}, nil
 	}
+	if err := s.checkFrameSigned(ctx, streamID, clientID); err != nil {
+		return nil, err
+	}
 
 	// Автоматически создаем стрим если его нет
 	s.mu.RLock()
@@ -85,10 +341,6 @@ func (s *VideoStreamServiceImpl) SendFrameInternal(
 	s.mu.RUnlock()
 
 	if stream == nil {
-		s.logger.Info("Auto-creating stream",
-			zap.String("stream_id", streamID),
-			zap.String("client_id", clientID))
-
 		activeStream := &pb.ActiveStream{
 			StreamId:    streamID,
 			ClientId:    clientID,
@@ -99,14 +351,56 @@ func (s *VideoStreamServiceImpl) SendFrameInternal(
 		}
 
 		s.mu.Lock()
-		s.repo.SaveStream(streamID, activeStream)
+		created, ok := s.repo.TrySaveStream(streamID, activeStream, s.maxStreams)
 		s.mu.Unlock()
+
+		if !ok {
+			requestid.Logger(ctx, s.logger).Warn("Stream auto-creation rejected",
+				zap.String("stream_id", streamID),
+				zap.String("client_id", clientID),
+				zap.Int("max_streams", s.maxStreams))
+			return nil, fmt.Errorf("%w: limit %d", ErrStreamCapacity, s.maxStreams)
+		}
+		if created && ownerVerified(ctx) {
+			s.repo.SetOwner(streamID, userName)
+		}
+		if created {
+			requestid.Logger(ctx, s.logger).Info("Auto-creating stream",
+				zap.String("stream_id", streamID),
+				zap.String("client_id", clientID))
+		}
+	}
+
+	span.SetAttributes(
+		tracing.AttrFrameSize.Int(len(frame.FrameData)),
+		attribute.String("frame.id", frame.FrameId),
+	)
+
+	cameras := s.repo.GetCameras(streamID)
+	if len(cameras) > 0 && !containsString(cameras, frame.CameraId) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCamera, frame.CameraId)
+	}
+
+	if err := s.checkFrameFormat(ctx, streamID, frame.Format); err != nil {
+		return nil, err
+	}
+
+	if err := s.throttle(ctx, streamID, len(frame.FrameData)); err != nil {
+		requestid.Logger(ctx, s.logger).Debug("Frame throttled",
+			zap.String("stream_id", streamID),
+			zap.Error(err))
+		return nil, err
 	}
 
 	// Обновляем статистику
 	stats := s.repo.UpdateStats(streamID, frame)
+	s.sampler.Observe(streamID, frame)
+	s.hub.Publish(streamID, frame)
+	if len(cameras) > 0 {
+		s.hub.Publish(CameraChannel(streamID, frame.CameraId), frame)
+	}
 
-	s.logger.Debug("Frame received",
+	requestid.Logger(ctx, s.logger).Debug("Frame received",
 		zap.String("stream_id", streamID),
 		zap.String("client_id", clientID),
 		zap.Int64("frame_size", int64(len(frame.FrameData))),
@@ -128,12 +422,109 @@ func (s *VideoStreamServiceImpl) SendFrameInternal(
 	}, nil
 }
 
-// StopStream - остановка стрима
+// throttle применяет лимит битрейта стрима: ждет погашения долга не дольше
+// throttleMaxWait или возвращает StreamThrottledError
+func (s *VideoStreamServiceImpl) throttle(ctx context.Context, streamID string, size int) error {
+	if s.maxBitrate <= 0 {
+		return nil
+	}
+
+	wait, ok := s.repo.GetLimiter(streamID, s.maxBitrate).reserve(size, s.throttleMaxWait)
+	if !ok {
+		return &StreamThrottledError{StreamID: streamID, RetryAfter: wait}
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StopAllForClient останавливает все стримы клиента с текущим временем
+// окончания и возвращает результаты остановки
+func (s *VideoStreamServiceImpl) StopAllForClient(ctx context.Context, clientID string) ([]*pb.ApiResponse, error) {
+	streams := s.GetStreamsByClient(clientID)
+	results := make([]*pb.ApiResponse, 0, len(streams))
+
+	for _, stream := range streams {
+		response, err := s.stopStream(ctx, &pb.StopStreamRequest{
+			StreamId: stream.StreamId,
+			ClientId: clientID,
+			EndTime:  time.Now().Unix(),
+		})
+		if err != nil {
+			return results, err
+		}
+		results = append(results, response)
+	}
+
+	if len(results) > 0 {
+		requestid.Logger(ctx, s.logger).Info("Stopped all client streams",
+			zap.String("client_id", clientID),
+			zap.Int("count", len(results)))
+	}
+	return results, nil
+}
+
+// CameraChannel возвращает канал FrameHub для одной камеры стрима
+func CameraChannel(streamID, cameraID string) string {
+	return streamID + "/" + cameraID
+}
+
+// GetLastFrameAt возвращает время последнего кадра стрима
+func (s *VideoStreamServiceImpl) GetLastFrameAt(streamID string) (time.Time, bool) {
+	return s.repo.GetLastFrameAt(streamID)
+}
+
+// GetStreamCameras возвращает камеры многокамерного стрима
+func (s *VideoStreamServiceImpl) GetStreamCameras(streamID string) []string {
+	return s.repo.GetCameras(streamID)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// StopStream - остановка стрима. Повтор с тем же ключом идемпотентности
+// и client_id возвращает исходный ответ, тот же ключ с другим запросом -
+// ErrIdempotencyKeyReused.
 func (s *VideoStreamServiceImpl) StopStream(
 	ctx context.Context,
 	req *pb.StopStreamRequest,
 ) (*pb.ApiResponse, error) {
-	s.logger.Info("Stopping stream",
+	return runIdempotent(ctx, s, "stop", req.ClientId, requestFingerprint(ctx, req), func() (*pb.ApiResponse, error) {
+		return s.stopStream(ctx, req)
+	})
+}
+
+// stopStream выполняет StopStream без учета идемпотентности
+func (s *VideoStreamServiceImpl) stopStream(
+	ctx context.Context,
+	req *pb.StopStreamRequest,
+) (*pb.ApiResponse, error) {
+	_, span := tracing.StartSpan(ctx, "VideoStreamService.StopStream",
+		tracing.AttrStreamID.String(req.StreamId),
+		tracing.AttrClientID.String(req.ClientId))
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	requestid.Logger(ctx, s.logger).Info("Stopping stream",
 		zap.String("stream_id", req.StreamId),
 		zap.String("client_id", req.ClientId))
 
@@ -158,13 +549,55 @@ func (s *VideoStreamServiceImpl) GetStreamStats(
 	ctx context.Context,
 	req *pb.GetStreamStatsRequest,
 ) (*pb.StreamStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	stats := s.repo.GetStats(req.StreamId)
 	if stats == nil {
-		return nil, fmt.Errorf("stream %s not found", req.StreamId)
+		return nil, fmt.Errorf("stream %s: %w", req.StreamId, ErrStreamNotFound)
 	}
 	return stats, nil
 }
 
+// FrameHub - рассылка принятых кадров подписчикам
+func (s *VideoStreamServiceImpl) FrameHub() *FrameHub {
+	return s.hub
+}
+
+// SampleFrames - отладочная выборка следующих кадров стрима; для
+// неизвестного стрима - ErrStreamNotFound, сверх лимита одновременных
+// выборок - ErrTooManySamples
+func (s *VideoStreamServiceImpl) SampleFrames(
+	ctx context.Context,
+	streamID string,
+	limit int,
+	withThumbnail bool,
+	timeout time.Duration,
+) ([]FrameSample, error) {
+	if s.repo.GetStream(streamID) == nil {
+		return nil, ErrStreamNotFound
+	}
+	return s.sampler.Sample(ctx, streamID, limit, withThumbnail, timeout)
+}
+
+// GetStream - стрим и его статистика; stream == nil, если стрима нет
+func (s *VideoStreamServiceImpl) GetStream(streamID string) (*pb.ActiveStream, *pb.StreamStats) {
+	return s.repo.GetStreamWithStats(streamID)
+}
+
+// SetRecording - включение/пауза записи без остановки live стрима
+func (s *VideoStreamServiceImpl) SetRecording(streamID string, enabled bool) error {
+	if !s.repo.SetRecording(streamID, enabled) {
+		return ErrStreamNotFound
+	}
+
+	s.logger.Info("Stream recording toggled",
+		zap.String("stream_id", streamID),
+		zap.Bool("recording", enabled))
+	return nil
+}
+
 // GetAllActiveStreams - получение всех активных стримов
 func (s *VideoStreamServiceImpl) GetAllActiveStreams() []*pb.ActiveStream {
 	return s.repo.GetAllActiveStreams()
@@ -200,18 +633,25 @@ func (s *VideoStreamServiceImpl) GetTotalStats() map[string]interface{} {
 
 	var totalFrames int64
 	var totalBytes int64
+	formats := make(map[string]int64)
 
 	for _, stats := range allStats {
 		totalFrames += stats.FramesReceived
 		totalBytes += stats.BytesReceived
+		for format, count := range s.repo.GetFormatHistogram(stats.StreamId) {
+			formats[format] += count
+		}
 	}
 
 	return map[string]interface{}{
-		"active_streams": len(allStats),
-		"total_frames":   totalFrames,
-		"total_bytes":    totalBytes,
-		"average_fps":    calculateAverageFPS(allStats),
-		"timestamp":      time.Now().Unix(),
+		"active_streams":   len(allStats),
+		"max_streams":      s.maxStreams,
+		"total_frames":     totalFrames,
+		"total_bytes":      totalBytes,
+		"average_fps":      calculateAverageFPS(allStats),
+		"idempotency_keys": s.idempotency.Count(),
+		"formats":          formats,
+		"timestamp":        time.Now().Unix(),
 	}
 }
 
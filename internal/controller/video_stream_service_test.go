@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	pb "api-gateway/pkg/gen"
+)
+
+func TestStartLimitCapsConcurrency(t *testing.T) {
+	s := NewVideoStreamService(zap.NewNop(), WithStartLimit(3, 0))
+	t.Cleanup(s.Close)
+
+	// Слоты держатся до конца теста, как при долгом StartStream
+	var (
+		mu       sync.Mutex
+		acquired int
+		rejected int
+		releases []func()
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := s.acquireStartSlot(context.Background())
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, ErrTooManyStarts) {
+				rejected++
+				return
+			}
+			acquired++
+			releases = append(releases, release)
+		}()
+	}
+	wg.Wait()
+
+	if acquired != 3 || rejected != 17 {
+		t.Errorf("acquired %d, rejected %d, want 3 and 17", acquired, rejected)
+	}
+	if _, err := s.StartStream(context.Background(), &pb.StartStreamRequest{ClientId: "cam-1"}); !errors.Is(err, ErrTooManyStarts) {
+		t.Errorf("StartStream with all slots busy: error = %v, want ErrTooManyStarts", err)
+	}
+
+	for _, release := range releases {
+		release()
+	}
+	if _, err := s.StartStream(context.Background(), &pb.StartStreamRequest{ClientId: "cam-1"}); err != nil {
+		t.Errorf("StartStream after slots freed: %v", err)
+	}
+}
+
+func TestStartLimitQueuesExcess(t *testing.T) {
+	tests := []struct {
+		name      string
+		wait      time.Duration
+		releaseIn time.Duration // когда освобождается занятый слот
+		wantErr   error
+	}{
+		{"queued until slot frees", time.Second, 50 * time.Millisecond, nil},
+		{"queue wait expires", 50 * time.Millisecond, time.Second, ErrTooManyStarts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewVideoStreamService(zap.NewNop(), WithStartLimit(1, tt.wait))
+			t.Cleanup(s.Close)
+
+			release, err := s.acquireStartSlot(context.Background())
+			if err != nil {
+				t.Fatalf("acquire first slot: %v", err)
+			}
+			timer := time.AfterFunc(tt.releaseIn, release)
+			defer func() {
+				if timer.Stop() {
+					release()
+				}
+			}()
+
+			_, err = s.StartStream(context.Background(), &pb.StartStreamRequest{ClientId: "cam-1"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("StartStream error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIdleStreamsReaped(t *testing.T) {
+	s := NewVideoStreamService(zap.NewNop(), WithIdleStreamTimeout(200*time.Millisecond))
+	t.Cleanup(s.Close)
+	ctx := context.Background()
+
+	idle, err := s.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-idle"})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+	active, err := s.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-active"})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+	frame := &pb.VideoFrame{FrameId: "f", ClientId: "cam-idle", Format: "jpeg", FrameData: []byte{1, 2, 3, 4}}
+	if _, err := s.SendFrameInternal(ctx, idle.StreamId, "cam-idle", "cam-idle", frame); err != nil {
+		t.Fatalf("SendFrame: %v", err)
+	}
+
+	// Активный стрим получает кадры чаще таймаута, пока фоновая проверка
+	// несколько раз проходит по стримам
+	for deadline := time.Now().Add(600 * time.Millisecond); time.Now().Before(deadline); {
+		frame := &pb.VideoFrame{FrameId: "f", ClientId: "cam-active", Format: "jpeg", FrameData: []byte{1, 2, 3, 4}}
+		if _, err := s.SendFrameInternal(ctx, active.StreamId, "cam-active", "cam-active", frame); err != nil {
+			t.Fatalf("SendFrame: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if stream, _ := s.GetStream(idle.StreamId); stream != nil {
+		t.Error("idle stream was not reaped")
+	}
+	if stream, _ := s.GetStream(active.StreamId); stream == nil {
+		t.Error("active stream was reaped")
+	}
+	if _, ok := s.GetLastFrameAt(active.StreamId); !ok {
+		t.Error("active stream has no last frame time")
+	}
+}
+
+func TestMaxStreams(t *testing.T) {
+	s := NewVideoStreamService(zap.NewNop(), WithMaxStreams(3))
+	t.Cleanup(s.Close)
+	ctx := context.Background()
+
+	// Одновременные запуски не превышают лимит
+	var (
+		mu      sync.Mutex
+		started []string
+		full    int
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := s.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-1"})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, ErrStreamCapacity):
+				full++
+			case err != nil:
+				t.Errorf("StartStream: %v", err)
+			default:
+				started = append(started, resp.StreamId)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(started) != 3 || full != 7 {
+		t.Fatalf("started %d, rejected %d, want 3 and 7", len(started), full)
+	}
+
+	// Автосоздание стрима кадром подчиняется тому же лимиту
+	frame := &pb.VideoFrame{FrameId: "f", ClientId: "cam-2", Format: "jpeg", FrameData: []byte{1, 2, 3, 4}}
+	if _, err := s.SendFrameInternal(ctx, "auto-stream", "cam-2", "cam-2", frame); !errors.Is(err, ErrStreamCapacity) {
+		t.Errorf("auto-create at capacity: error = %v, want ErrStreamCapacity", err)
+	}
+
+	if _, err := s.StopStream(ctx, &pb.StopStreamRequest{StreamId: started[0], ClientId: "cam-1"}); err != nil {
+		t.Fatalf("StopStream: %v", err)
+	}
+	if _, err := s.SendFrameInternal(ctx, "auto-stream", "cam-2", "cam-2", frame); err != nil {
+		t.Errorf("auto-create after a stream stopped: %v", err)
+	}
+	if _, err := s.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-1"}); !errors.Is(err, ErrStreamCapacity) {
+		t.Errorf("StartStream with the freed slot taken: error = %v, want ErrStreamCapacity", err)
+	}
+	if got := s.GetActiveStreamsCount(); got != 3 {
+		t.Errorf("active streams = %d, want 3", got)
+	}
+}
@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+)
+
+// bandwidthWindow размер скользящего окна в секундах
+const bandwidthWindow = 10
+
+// BandwidthMeter считает переданные байты в скользящем окне по секундам
+type BandwidthMeter struct {
+	mu      sync.Mutex
+	start   time.Time
+	buckets [bandwidthWindow]int64
+	seconds [bandwidthWindow]int64 // unix-секунда, к которой относится бакет
+	total   int64
+}
+
+// NewBandwidthMeter создает счетчик
+func NewBandwidthMeter() *BandwidthMeter {
+	return &BandwidthMeter{start: time.Now()}
+}
+
+// Add учитывает n переданных байт
+func (m *BandwidthMeter) Add(n int) {
+	now := time.Now().Unix()
+	idx := now % bandwidthWindow
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.seconds[idx] != now {
+		m.seconds[idx] = now
+		m.buckets[idx] = 0
+	}
+	m.buckets[idx] += int64(n)
+	m.total += int64(n)
+}
+
+// Rate возвращает среднюю скорость в байтах в секунду за окно.
+// Для недавно созданного счетчика делит на прошедшее время.
+func (m *BandwidthMeter) Rate() float64 {
+	now := time.Now()
+	cutoff := now.Unix() - bandwidthWindow
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sum int64
+	for i, second := range m.seconds {
+		if second > cutoff {
+			sum += m.buckets[i]
+		}
+	}
+
+	elapsed := now.Sub(m.start).Seconds()
+	if elapsed > bandwidthWindow {
+		elapsed = bandwidthWindow
+	}
+	if elapsed < 1 {
+		elapsed = 1
+	}
+	return float64(sum) / elapsed
+}
+
+// Total возвращает общее количество переданных байт
+func (m *BandwidthMeter) Total() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.total
+}
@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"api-gateway/pkg/proto"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+const defaultBatchWindow = 200 * time.Millisecond
+
+// pendingBatch накапливаемый пакет для одного эндпоинта
+type pendingBatch struct {
+	service *ServiceEndpoint
+	frames  []*proto.VideoFrame
+	timer   *time.Timer
+}
+
+// FrameBatcher копит фреймы по эндпоинтам и отправляет их пакетами через
+// пул отправки. Работает только для типов сервисов с настройкой batching.
+type FrameBatcher struct {
+	ctx     context.Context
+	pool    *SendPool
+	configs map[string]config.BatchConfig
+
+	mu      sync.Mutex
+	pending map[string]*pendingBatch // ID эндпоинта -> пакет
+}
+
+// NewFrameBatcher создает батчер; configs - настройки по типу сервиса
+func NewFrameBatcher(ctx context.Context, pool *SendPool, configs map[string]config.BatchConfig) *FrameBatcher {
+	return &FrameBatcher{
+		ctx:     ctx,
+		pool:    pool,
+		configs: configs,
+		pending: make(map[string]*pendingBatch),
+	}
+}
+
+// Add добавляет фрейм в пакет эндпоинта. Возвращает false, если для типа
+// сервиса пакетная отправка не настроена и фрейм надо отправить сразу.
+func (b *FrameBatcher) Add(service *ServiceEndpoint, frame *proto.VideoFrame) bool {
+	cfg, ok := b.configs[service.Service]
+	if !ok || cfg.MaxFrames <= 1 {
+		return false
+	}
+
+	b.mu.Lock()
+	batch, exists := b.pending[service.ID]
+	if !exists {
+		window := time.Duration(cfg.WindowMs) * time.Millisecond
+		if window <= 0 {
+			window = defaultBatchWindow
+		}
+
+		batch = &pendingBatch{
+			service: service,
+			frames:  make([]*proto.VideoFrame, 0, cfg.MaxFrames),
+		}
+		b.pending[service.ID] = batch
+		batch.timer = time.AfterFunc(window, func() {
+			b.flush(service.ID, batch)
+		})
+	}
+
+	batch.frames = append(batch.frames, frame)
+	full := len(batch.frames) >= cfg.MaxFrames
+	b.mu.Unlock()
+
+	if full {
+		b.flush(service.ID, batch)
+	}
+	return true
+}
+
+// flush отправляет пакет, если он еще не был отправлен
+func (b *FrameBatcher) flush(serviceID string, batch *pendingBatch) {
+	b.mu.Lock()
+	if b.pending[serviceID] != batch {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, serviceID)
+	batch.timer.Stop()
+	b.mu.Unlock()
+
+	if err := b.pool.SubmitBatch(b.ctx, batch.service, batch.frames); err != nil {
+		log.Printf("Batch for service %s dropped (%d frames): %v", serviceID, len(batch.frames), err)
+	}
+}
+
+// Stop останавливает таймеры; неотправленные пакеты отбрасываются
+func (b *FrameBatcher) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for serviceID, batch := range b.pending {
+		batch.timer.Stop()
+		if len(batch.frames) > 0 {
+			log.Printf("Batch for service %s dropped on shutdown (%d frames)", serviceID, len(batch.frames))
+		}
+		delete(b.pending, serviceID)
+	}
+}
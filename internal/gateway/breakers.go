@@ -0,0 +1,83 @@
+package gateway
+
+import (
+	"sort"
+	"time"
+)
+
+// Состояния прерывателя эндпоинта. Прерыватель открывается после
+// services.breaker.failure_threshold неуспешных отправок или запросов
+// прокси подряд либо неуспешным health check и закрывается
+// следующим успешным health check или сбросом через админ API. Выведенный
+// вручную эндпоинт (drain) считается отдельным состоянием.
+const (
+	BreakerClosed  = "closed"
+	BreakerOpen    = "open"
+	BreakerDrained = "drained"
+)
+
+// BreakerState состояние прерывателя эндпоинта
+type BreakerState struct {
+	ID        string    `json:"id"`
+	Service   string    `json:"service"`
+	URL       string    `json:"url"`
+	State     string    `json:"state"`
+	LastCheck time.Time `json:"last_check"`
+	Requests  int64     `json:"requests"`
+	Errors    int64     `json:"errors"`
+	// Неуспешных запросов подряд с последнего успеха
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// breakerState возвращает состояние прерывателя, вызывается под блокировкой
+func breakerState(endpoint *ServiceEndpoint) BreakerState {
+	state := BreakerClosed
+	switch {
+	case endpoint.Drained:
+		state = BreakerDrained
+	case !endpoint.Healthy:
+		state = BreakerOpen
+	}
+	return BreakerState{
+		ID:        endpoint.ID,
+		Service:   endpoint.Service,
+		URL:       endpoint.URL,
+		State:     state,
+		LastCheck: endpoint.LastCheck,
+		Requests:  endpoint.Stats.TotalRequests,
+		Errors:    endpoint.Stats.ErrorCount,
+
+		ConsecutiveFailures: endpoint.Failures,
+	}
+}
+
+// Breakers возвращает состояния прерывателей всех эндпоинтов по ID
+func (sr *ServiceRegistry) Breakers() []BreakerState {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	var states []BreakerState
+	for _, endpoints := range sr.services {
+		for _, endpoint := range endpoints {
+			states = append(states, breakerState(endpoint))
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+	return states
+}
+
+// ResetBreaker принудительно закрывает прерыватель эндпоинта: он снова
+// получает фреймы, не дожидаясь health check. Выведенный вручную эндпоинт
+// остается выведенным. false - эндпоинт не найден.
+func (sr *ServiceRegistry) ResetBreaker(id string) (BreakerState, bool) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	endpoint := sr.findEndpointLocked(id)
+	if endpoint == nil {
+		return BreakerState{}, false
+	}
+	endpoint.Healthy = true
+	endpoint.Failures = 0
+	return breakerState(endpoint), true
+}
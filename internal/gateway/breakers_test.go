@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-gateway/pkg/proto"
+)
+
+func TestAdminBreakersInspectAndReset(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(service.Close)
+	g := newForwardingGateway(t, service.URL)
+	g.config.Gateway.AdminToken = "admin-secret"
+	g.config.Services.Breaker.FailureThreshold = 2
+	breakers := g.requireAdmin(g.handleAdminServiceBreakers)
+
+	endpoint := g.services.services["video_processing"][0]
+	frame := &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", ClientID: "cam-1"}
+	for i := 0; i < 2; i++ {
+		if err := g.services.SendToService(context.Background(), endpoint, frame); err == nil {
+			t.Fatalf("send %d: expected error from failing service", i)
+		}
+	}
+
+	call := func(method, path, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		breakers(w, r)
+		return w
+	}
+	state := func() BreakerState {
+		t.Helper()
+		w := call(http.MethodGet, "/api/v1/admin/services/breakers", "admin-secret")
+		if w.Code != http.StatusOK {
+			t.Fatalf("breakers: status = %d (%s)", w.Code, w.Body.String())
+		}
+		var body struct {
+			Breakers []BreakerState `json:"breakers"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode breakers: %v", err)
+		}
+		if len(body.Breakers) != 1 || body.Breakers[0].ID != endpoint.ID {
+			t.Fatalf("breakers = %+v, want only %s", body.Breakers, endpoint.ID)
+		}
+		return body.Breakers[0]
+	}
+
+	if got := state(); got.State != BreakerOpen || got.ConsecutiveFailures != 2 || got.Errors != 2 {
+		t.Fatalf("tripped breaker = %+v, want open with 2 failures", got)
+	}
+
+	resetPath := "/api/v1/admin/services/" + endpoint.ID + "/reset"
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"no token", http.MethodGet, "/api/v1/admin/services/breakers", "", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, resetPath, "wrong", http.StatusUnauthorized},
+		{"list requires GET", http.MethodPost, "/api/v1/admin/services/breakers", "admin-secret", http.StatusMethodNotAllowed},
+		{"reset requires POST", http.MethodGet, resetPath, "admin-secret", http.StatusMethodNotAllowed},
+		{"unknown endpoint", http.MethodPost, "/api/v1/admin/services/no-such/reset", "admin-secret", http.StatusNotFound},
+		{"bad path", http.MethodPost, "/api/v1/admin/services/" + endpoint.ID + "/close", "admin-secret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := call(tt.method, tt.path, tt.token); w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+	}
+	// Отклоненные запросы не закрывают прерыватель
+	if got := state(); got.State != BreakerOpen {
+		t.Fatalf("breaker after rejected requests = %s, want open", got.State)
+	}
+
+	w := call(http.MethodPost, resetPath, "admin-secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("reset: status = %d (%s)", w.Code, w.Body.String())
+	}
+	var reset struct {
+		Breaker BreakerState `json:"breaker"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &reset); err != nil {
+		t.Fatalf("decode reset: %v", err)
+	}
+	if reset.Breaker.State != BreakerClosed {
+		t.Errorf("reset response state = %s, want closed", reset.Breaker.State)
+	}
+	if got := state(); got.State != BreakerClosed || got.ConsecutiveFailures != 0 {
+		t.Errorf("breaker after reset = %+v, want closed with no failures", got)
+	}
+
+	// Выведенный вручную эндпоинт остается выведенным после сброса
+	g.services.SetEndpointHealthy(endpoint.ID, false)
+	call(http.MethodPost, resetPath, "admin-secret")
+	if got := state(); got.State != BreakerDrained {
+		t.Errorf("drained endpoint after reset = %s, want drained", got.State)
+	}
+}
@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"strings"
+
+	"api-gateway/internal/config"
+)
+
+// ChannelSet множество каналов, которые разрешено смотреть клиенту
+type ChannelSet struct {
+	all      bool
+	channels map[string]struct{}
+}
+
+// Allows проверяет доступ к каналу. Канал камеры "stream/camera" разрешен,
+// если разрешен сам стрим.
+func (s *ChannelSet) Allows(channel string) bool {
+	if s.all {
+		return true
+	}
+	if _, ok := s.channels[channel]; ok {
+		return true
+	}
+	streamID, _, isCamera := strings.Cut(channel, "/")
+	if !isCamera {
+		return false
+	}
+	_, ok := s.channels[streamID]
+	return ok
+}
+
+// ChannelAuthorizer определяет каналы, доступные пользователю
+type ChannelAuthorizer interface {
+	PermittedChannels(claims *TokenClaims) (*ChannelSet, error)
+}
+
+// ConfigChannelAuthorizer берет права из токена и секции auth конфига:
+// роли из admin_roles видят все каналы, остальным доступны каналы из claim
+// "channels" и камеры пользователя из channel_owners
+type ConfigChannelAuthorizer struct {
+	owners     map[string][]string
+	adminRoles map[string]struct{}
+}
+
+// NewConfigChannelAuthorizer создает авторизатор по секции auth
+func NewConfigChannelAuthorizer(cfg *config.Config) *ConfigChannelAuthorizer {
+	adminRoles := make(map[string]struct{}, len(cfg.Auth.AdminRoles))
+	for _, role := range cfg.Auth.AdminRoles {
+		adminRoles[role] = struct{}{}
+	}
+	return &ConfigChannelAuthorizer{
+		owners:     cfg.Auth.ChannelOwners,
+		adminRoles: adminRoles,
+	}
+}
+
+// PermittedChannels реализует ChannelAuthorizer
+func (a *ConfigChannelAuthorizer) PermittedChannels(claims *TokenClaims) (*ChannelSet, error) {
+	for _, role := range claims.Roles {
+		if _, ok := a.adminRoles[role]; ok {
+			return &ChannelSet{all: true}, nil
+		}
+	}
+
+	set := &ChannelSet{channels: make(map[string]struct{})}
+	for _, channel := range claims.Channels {
+		set.channels[channel] = struct{}{}
+	}
+	for _, channel := range a.owners[claims.Subject] {
+		set.channels[channel] = struct{}{}
+	}
+	return set, nil
+}
+
+// subscriberAccessTags возвращает теги доступа подписчика: из claim
+// "access_tags" токена и из auth.access_tags по его sub. Теги из команды
+// subscribe не принимаются - клиент не может выдать их себе сам.
+func (g *APIGateway) subscriberAccessTags(session *WebSocketSession) []string {
+	claims := session.ClientInfo.Claims
+	if claims == nil {
+		return nil
+	}
+	tags := append([]string(nil), claims.AccessTags...)
+	return append(tags, g.config.Auth.AccessTags[claims.Subject]...)
+}
+
+// canSubscribe проверяет подписку на канал. Права вычисляются один раз на
+// соединение: команды сессии обрабатываются одной горутиной чтения.
+func (g *APIGateway) canSubscribe(session *WebSocketSession, channel string) bool {
+	claims := session.ClientInfo.Claims
+	if claims == nil {
+		return true
+	}
+
+	if session.permitted == nil {
+		permitted, err := g.channelAuth.PermittedChannels(claims)
+		if err != nil {
+			return false
+		}
+		session.permitted = permitted
+	}
+	return session.permitted.Allows(channel)
+}
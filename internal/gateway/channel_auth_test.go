@@ -0,0 +1,37 @@
+package gateway
+
+import (
+	"reflect"
+	"testing"
+
+	"api-gateway/internal/config"
+)
+
+func TestSubscriberAccessTags(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.Auth.AccessTags = map[string][]string{"user-1": {"restricted"}}
+	g := &APIGateway{config: cfg}
+
+	tests := []struct {
+		name   string
+		claims *TokenClaims
+		want   []string
+	}{
+		{"anonymous session has no tags", nil, nil},
+		{"tags from token", &TokenClaims{Subject: "user-2", AccessTags: []string{"vip"}}, []string{"vip"}},
+		{"tags from config", &TokenClaims{Subject: "user-1"}, []string{"restricted"}},
+		{"token and config combined", &TokenClaims{Subject: "user-1", AccessTags: []string{"vip"}}, []string{"vip", "restricted"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &WebSocketSession{ClientInfo: &ClientInfo{Claims: tt.claims}}
+			got := g.subscriberAccessTags(session)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subscriberAccessTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
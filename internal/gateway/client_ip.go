@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// trustedProxies адреса балансировщиков и прокси (security.trusted_proxies),
+// которым разрешено передавать адрес клиента в X-Forwarded-For и X-Real-IP
+type trustedProxies []netip.Prefix
+
+// parseTrustedProxies разбирает список IP и CIDR
+func parseTrustedProxies(entries []string) (trustedProxies, error) {
+	proxies := make(trustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: not an IP or CIDR", entry)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return proxies, nil
+}
+
+// contains сообщает, является ли ip доверенным прокси
+func (p trustedProxies) contains(ip string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP возвращает адрес клиента. Заголовки X-Forwarded-For и X-Real-IP
+// учитываются только от доверенного прокси; из X-Forwarded-For берется
+// самый правый адрес, не принадлежащий доверенным прокси: левые элементы
+// списка клиент может подставить сам.
+func (p trustedProxies) clientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !p.contains(remote) {
+		return remote
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			if !p.contains(hop) {
+				return hop
+			}
+			remote = hop
+		}
+		return remote
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	return remote
+}
+
+// clientIP адрес клиента запроса с учетом security.trusted_proxies
+func (g *APIGateway) clientIP(r *http.Request) string {
+	return g.trustedProxies.clientIP(r)
+}
@@ -0,0 +1,175 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/redisconn"
+)
+
+// ClientLimiter ограничивает частоту действий по ключу (например, client_id)
+type ClientLimiter interface {
+	Allow(key string) bool
+}
+
+// localIdleTTL через сколько неиспользуемый лимитер ключа удаляется
+const localIdleTTL = 5 * time.Minute
+
+// LocalClientLimiter token bucket на ключ в памяти процесса. Лимит
+// действует только в пределах одной реплики шлюза.
+type LocalClientLimiter struct {
+	rate int
+
+	mu        sync.Mutex
+	limiters  map[string]*localLimiterEntry
+	lastSweep time.Time
+}
+
+type localLimiterEntry struct {
+	limiter  *RateLimiter
+	lastUsed time.Time
+}
+
+// NewLocalClientLimiter создает лимитер на rate действий в секунду на ключ
+func NewLocalClientLimiter(rate int) *LocalClientLimiter {
+	return &LocalClientLimiter{
+		rate:      rate,
+		limiters:  make(map[string]*localLimiterEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow проверяет лимит ключа
+func (l *LocalClientLimiter) Allow(key string) bool {
+	if l.rate <= 0 {
+		return true
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	if now.Sub(l.lastSweep) > localIdleTTL {
+		for k, entry := range l.limiters {
+			if now.Sub(entry.lastUsed) > localIdleTTL {
+				delete(l.limiters, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	entry, ok := l.limiters[key]
+	if !ok {
+		entry = &localLimiterEntry{limiter: NewRateLimiter(l.rate, l.rate)}
+		l.limiters[key] = entry
+	}
+	entry.lastUsed = now
+	l.mu.Unlock()
+
+	return entry.limiter.Allow()
+}
+
+// redisLimiterTimeout таймаут подключения и команды к Redis; лимитер на
+// горячем пути, поэтому при медленном Redis лучше перейти на локальный лимит
+const redisLimiterTimeout = 500 * time.Millisecond
+
+// newClientLimiter создает лимитер по настройке Gateway.RateLimitBackend
+func newClientLimiter(cfg *config.Config, rate int) (ClientLimiter, func()) {
+	local := NewLocalClientLimiter(rate)
+	if cfg.Gateway.RateLimitBackend != "redis" {
+		return local, func() {}
+	}
+
+	client := redisconn.New(redisconn.Options{
+		Addr:     net.JoinHostPort(cfg.Redis.Host, strconv.Itoa(cfg.Redis.Port)),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+		Timeout:  redisLimiterTimeout,
+	})
+	limiter := NewRedisClientLimiter(client, "api-gateway:ratelimit:", rate, time.Second, local)
+	return limiter, func() { client.Close() }
+}
+
+// slidingWindowScript атомарно чистит окно, считает запросы и добавляет
+// текущий, если лимит не исчерпан. KEYS[1] - ключ, ARGV: now_ms, window_ms,
+// limit, member.
+var slidingWindowScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], 0, tonumber(ARGV[1]) - tonumber(ARGV[2]))
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// redisWarnInterval как часто повторять предупреждение о недоступности Redis
+const redisWarnInterval = 30 * time.Second
+
+// RedisClientLimiter скользящее окно в Redis: лимит общий для всех реплик
+// шлюза. Если Redis недоступен, решение принимает локальный лимитер.
+type RedisClientLimiter struct {
+	client   redis.Scripter
+	prefix   string
+	limit    int
+	window   time.Duration
+	fallback ClientLimiter
+
+	instance string // уникальная часть member, чтобы реплики не перезаписывали друг друга
+	seq      uint64
+	lastWarn atomic.Int64
+}
+
+// NewRedisClientLimiter создает лимитер на limit действий за window на ключ
+func NewRedisClientLimiter(client redis.Scripter, prefix string, limit int, window time.Duration, fallback ClientLimiter) *RedisClientLimiter {
+	instance := make([]byte, 8)
+	rand.Read(instance)
+
+	return &RedisClientLimiter{
+		client:   client,
+		prefix:   prefix,
+		limit:    limit,
+		window:   window,
+		fallback: fallback,
+		instance: hex.EncodeToString(instance),
+	}
+}
+
+// Allow проверяет лимит ключа в Redis
+func (l *RedisClientLimiter) Allow(key string) bool {
+	if l.limit <= 0 {
+		return true
+	}
+
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%s-%d", now, l.instance, atomic.AddUint64(&l.seq, 1))
+
+	allowed, err := slidingWindowScript.Run(context.Background(), l.client, []string{l.prefix + key},
+		now, l.window.Milliseconds(), l.limit, member).Int64()
+	if err != nil {
+		l.warn(err)
+		return l.fallback.Allow(key)
+	}
+	return allowed == 1
+}
+
+// warn пишет предупреждение о переходе на локальный лимит не чаще
+// redisWarnInterval
+func (l *RedisClientLimiter) warn(err error) {
+	now := time.Now().UnixNano()
+	last := l.lastWarn.Load()
+	if now-last < int64(redisWarnInterval) || !l.lastWarn.CompareAndSwap(last, now) {
+		return
+	}
+	log.Printf("Redis rate limiter unavailable, falling back to in-process limits: %v", err)
+}
@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"api-gateway/internal/redisconn"
+)
+
+// fixedLimiter запасной лимитер с постоянным ответом
+type fixedLimiter bool
+
+func (l fixedLimiter) Allow(string) bool { return bool(l) }
+
+func TestRedisClientLimiterSharedWindow(t *testing.T) {
+	server := miniredis.RunT(t)
+	newLimiter := func() *RedisClientLimiter {
+		client := redisconn.New(redisconn.Options{Addr: server.Addr(), Timeout: time.Second})
+		t.Cleanup(func() { client.Close() })
+		return NewRedisClientLimiter(client, "test:", 3, time.Minute, fixedLimiter(false))
+	}
+	replicas := []*RedisClientLimiter{newLimiter(), newLimiter()}
+
+	tests := []struct {
+		replica int
+		key     string
+		want    bool
+	}{
+		{0, "cam-1", true},
+		{1, "cam-1", true},
+		{0, "cam-1", true},
+		{1, "cam-1", false}, // окно общее: четвертый запрос сверх лимита 3
+		{0, "cam-1", false},
+		{1, "cam-2", true}, // у другого ключа свое окно
+	}
+	for i, tt := range tests {
+		if got := replicas[tt.replica].Allow(tt.key); got != tt.want {
+			t.Errorf("#%d replica %d Allow(%q) = %v, want %v", i, tt.replica, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRedisClientLimiterFallback(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redisconn.New(redisconn.Options{Addr: server.Addr(), Timeout: 200 * time.Millisecond})
+	t.Cleanup(func() { client.Close() })
+	limiter := NewRedisClientLimiter(client, "test:", 1, time.Minute, fixedLimiter(true))
+
+	if !limiter.Allow("cam-1") || limiter.Allow("cam-1") {
+		t.Fatal("Redis window: want first request allowed and second denied")
+	}
+
+	server.Close()
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("cam-1") {
+			t.Errorf("Allow #%d with Redis down = false, want fallback decision", i)
+		}
+	}
+}
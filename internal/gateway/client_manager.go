@@ -1,17 +1,60 @@
 package gateway
 
 import (
-	"api-gateway/proto"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 )
 
+var (
+	// ErrTooManyConnections превышен общий лимит соединений
+	ErrTooManyConnections = errors.New("too many connections")
+	// ErrTooManyConnectionsPerIP превышен лимит соединений с одного IP
+	ErrTooManyConnectionsPerIP = errors.New("too many connections from this IP")
+	// ErrTooManyConnectionsPerClient превышен лимит соединений одного client_id
+	ErrTooManyConnectionsPerClient = errors.New("too many connections for this client")
+)
+
+// ClientLimits лимиты соединений (0 - без лимита)
+type ClientLimits struct {
+	MaxConnections          int
+	MaxConnectionsPerIP     int
+	MaxConnectionsPerClient int
+
+	SendBufferSize    int            // очередь фреймов соединения (0 - defaultSendBufferSize)
+	ClientSendBuffers map[string]int // размер очереди по client_id
+}
+
+// defaultSendBufferSize очередь фреймов соединения, если размер не задан
+const defaultSendBufferSize = 100
+
+// SendBuffer возвращает размер очереди фреймов соединений клиента
+func (l ClientLimits) SendBuffer(clientID string) int {
+	if size := l.ClientSendBuffers[clientID]; size > 0 {
+		return size
+	}
+	if l.SendBufferSize > 0 {
+		return l.SendBufferSize
+	}
+	return defaultSendBufferSize
+}
+
 // ClientManager управляет информацией о клиентах
 type ClientManager struct {
-	mu      sync.RWMutex
-	clients map[string]*ClientInfo
+	mu           sync.RWMutex
+	clients      map[string]*ClientInfo
+	ipCounts     map[string]int
+	clientCounts map[string]int              // client_id -> число соединений
+	aliases      map[string]string           // старый id канала -> новый
+	suspended    map[string]*suspendedClient // токен восстановления -> клиент
+	limits       ClientLimits
+	replay       *ReplayBuffer // кадры для новых подписчиков (nil - выключено)
+
+	presence       ClientPresence  // общий для реплик список клиентов (nil - выключено)
+	presenceEvents []presenceEvent // события для presence, отправляются после снятия блокировки
+	presenceMu     sync.Mutex
 }
 
 type ClientInfo struct {
@@ -22,9 +65,30 @@ type ClientInfo struct {
 	ConnectedAt  time.Time
 	LastSeen     time.Time
 	IsActive     bool
-	SendChan     chan *proto.VideoFrame
-	Channels     map[string]struct{} // Каналы/комнаты
+	SendChan     chan []byte              // сериализованные фреймы, общие для всех получателей
+	EventChan    chan interface{}         // служебные уведомления клиенту
+	Channels     map[string]*Subscription // Каналы/комнаты
 	ClientData   *ClientData
+	CloseCode    int             // код close фрейма (0 - по умолчанию)
+	CloseReason  string          // причина отключения, отправляется в close фрейме
+	Bandwidth    *BandwidthMeter // фреймы, записанные в соединение клиента
+	Claims       *TokenClaims    // личность из токена (nil без аутентификации)
+	ResumeToken  string          // одноразовый токен восстановления сессии после обрыва
+}
+
+// Subscription подписка клиента на канал
+type Subscription struct {
+	AccessTags map[string]struct{} // теги доступа подписчика
+}
+
+// Allows проверяет, что подписка содержит все требуемые теги фрейма
+func (s *Subscription) Allows(requiredTags []string) bool {
+	for _, tag := range requiredTags {
+		if _, ok := s.AccessTags[tag]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 type ClientData struct {
@@ -37,17 +101,59 @@ type ClientData struct {
 	Metadata      map[string]string
 }
 
-func NewClientManager() *ClientManager {
+func NewClientManager(limits ClientLimits) *ClientManager {
 	return &ClientManager{
-		clients: make(map[string]*ClientInfo),
+		clients:      make(map[string]*ClientInfo),
+		ipCounts:     make(map[string]int),
+		clientCounts: make(map[string]int),
+		aliases:      make(map[string]string),
+		suspended:    make(map[string]*suspendedClient),
+		limits:       limits,
+	}
+}
+
+// SetReplayBuffer включает повтор последнего ключевого кадра канала новым
+// подписчикам. Вызывается до начала рассылки.
+func (cm *ClientManager) SetReplayBuffer(replay *ReplayBuffer) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.replay = replay
+}
+
+// CheckConnectionLimits проверяет, можно ли принять еще одно соединение
+// клиента с IP
+func (cm *ClientManager) CheckConnectionLimits(clientID, ip string) error {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.checkLimitsLocked(clientID, ip)
+}
+
+// checkLimitsLocked проверяет лимиты, вызывается под блокировкой
+func (cm *ClientManager) checkLimitsLocked(clientID, ip string) error {
+	if cm.limits.MaxConnections > 0 && len(cm.clients) >= cm.limits.MaxConnections {
+		return ErrTooManyConnections
+	}
+	if cm.limits.MaxConnectionsPerIP > 0 && cm.ipCounts[ip] >= cm.limits.MaxConnectionsPerIP {
+		return ErrTooManyConnectionsPerIP
 	}
+	if cm.limits.MaxConnectionsPerClient > 0 && cm.clientCounts[clientID] >= cm.limits.MaxConnectionsPerClient {
+		return ErrTooManyConnectionsPerClient
+	}
+	return nil
 }
 
 // RegisterClient регистрирует нового клиента
 func (cm *ClientManager) RegisterClient(clientID, ip, userAgent string) (*ClientInfo, error) {
+	defer cm.syncPresence()
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	if err := cm.checkLimitsLocked(clientID, ip); err != nil {
+		return nil, err
+	}
+
 	// Генерируем уникальный ID соединения
 	connID := fmt.Sprintf("%s-%d", clientID, time.Now().UnixNano())
 
@@ -59,8 +165,11 @@ func (cm *ClientManager) RegisterClient(clientID, ip, userAgent string) (*Client
 		ConnectedAt:  time.Now(),
 		LastSeen:     time.Now(),
 		IsActive:     true,
-		SendChan:     make(chan *proto.VideoFrame, 100),
-		Channels:     make(map[string]struct{}),
+		SendChan:     make(chan []byte, cm.limits.SendBuffer(clientID)),
+		EventChan:    make(chan interface{}, 16),
+		Channels:     make(map[string]*Subscription),
+		Bandwidth:    NewBandwidthMeter(),
+		ResumeToken:  newResumeToken(),
 		ClientData: &ClientData{
 			SessionID:     connID,
 			Authenticated: false,
@@ -69,6 +178,9 @@ func (cm *ClientManager) RegisterClient(clientID, ip, userAgent string) (*Client
 	}
 
 	cm.clients[connID] = client
+	cm.ipCounts[ip]++
+	cm.clientCounts[clientID]++
+	cm.clientConnectedLocked(client)
 
 	log.Printf("Client registered: %s (connection: %s)", clientID, connID)
 	return client, nil
@@ -96,8 +208,23 @@ func (cm *ClientManager) GetClientInfoByID(clientID string) (*ClientInfo, bool)
 	return nil, false
 }
 
-// SubscribeClient подписывает клиента на канал
-func (cm *ClientManager) SubscribeClient(connID, channel string) error {
+// GetClientsByID возвращает все соединения логического клиента
+func (cm *ClientManager) GetClientsByID(clientID string) []*ClientInfo {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	var clients []*ClientInfo
+	for _, client := range cm.clients {
+		if client.ID == clientID {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// SubscribeClient подписывает клиента на канал с опциональными тегами
+// доступа. Новый подписчик сначала получает кадры из буфера повтора.
+func (cm *ClientManager) SubscribeClient(connID, channel string, accessTags ...string) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -106,13 +233,50 @@ func (cm *ClientManager) SubscribeClient(connID, channel string) error {
 		return fmt.Errorf("client not found: %s", connID)
 	}
 
-	client.Channels[channel] = struct{}{}
+	channel = cm.resolveChannelLocked(channel)
+	sub := &Subscription{AccessTags: make(map[string]struct{}, len(accessTags))}
+	for _, tag := range accessTags {
+		sub.AccessTags[tag] = struct{}{}
+	}
+
+	_, resubscribed := client.Channels[channel]
+	client.Channels[channel] = sub
 	client.LastSeen = time.Now()
 
-	log.Printf("Client %s subscribed to channel %s", client.ID, channel)
+	replayed := 0
+	if cm.replay != nil && !resubscribed {
+		replayed = cm.replayLocked(client, channel, sub)
+	}
+
+	log.Printf("Client %s subscribed to channel %s (replayed %d frames)", client.ID, channel, replayed)
 	return nil
 }
 
+// replayLocked отправляет клиенту доступные ему кадры из буфера повтора
+// канала. Вызывается под записью cm.mu: рассылка новых кадров ждет, и
+// клиент получает их строго после повторенных. Повтор укладывается в
+// свободное место SendChan и обрывается на первом не поместившемся кадре:
+// вытеснять ключевой кадр ради следующих бессмысленно. Недоступный
+// подписчику ключевой кадр отменяет повтор целиком.
+func (cm *ClientManager) replayLocked(client *ClientInfo, channel string, sub *Subscription) int {
+	replayed := 0
+	for _, frame := range cm.replay.Frames(channel) {
+		if !sub.Allows(frame.requiredTags) {
+			if replayed == 0 {
+				return 0
+			}
+			continue
+		}
+		select {
+		case client.SendChan <- frame.data:
+		default:
+			return replayed
+		}
+		replayed++
+	}
+	return replayed
+}
+
 // UnsubscribeClient отписывает клиента от канала
 func (cm *ClientManager) UnsubscribeClient(connID, channel string) error {
 	cm.mu.Lock()
@@ -123,7 +287,7 @@ func (cm *ClientManager) UnsubscribeClient(connID, channel string) error {
 		return fmt.Errorf("client not found: %s", connID)
 	}
 
-	delete(client.Channels, channel)
+	delete(client.Channels, cm.resolveChannelLocked(channel))
 	client.LastSeen = time.Now()
 
 	log.Printf("Client %s unsubscribed from channel %s", client.ID, channel)
@@ -135,6 +299,8 @@ func (cm *ClientManager) GetClientsByChannel(channel string) []*ClientInfo {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
+	channel = cm.resolveChannelLocked(channel)
+
 	var clients []*ClientInfo
 	for _, client := range cm.clients {
 		if _, subscribed := client.Channels[channel]; subscribed {
@@ -144,6 +310,168 @@ func (cm *ClientManager) GetClientsByChannel(channel string) []*ClientInfo {
 	return clients
 }
 
+// GetClientsForFrame возвращает подписчиков канала, чьи теги доступа
+// удовлетворяют требуемым тегам фрейма
+func (cm *ClientManager) GetClientsForFrame(channel string, requiredTags []string) []*ClientInfo {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	channel = cm.resolveChannelLocked(channel)
+
+	var clients []*ClientInfo
+	for _, client := range cm.clients {
+		sub, subscribed := client.Channels[channel]
+		if subscribed && sub.Allows(requiredTags) {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// BroadcastFrame рассылает уже сериализованный фрейм подписчикам канала,
+// чьи теги доступа удовлетворяют requiredTags. Байты data общие для всех
+// клиентов и не должны изменяться. Если буфер клиента полон, накопленные
+// в нем фреймы отбрасываются и остается только последний. Возвращает
+// число получателей и отброшенных устаревших фреймов. Фрейм запоминается
+// в буфере повтора; keyframe отмечает ключевой кадр.
+func (cm *ClientManager) BroadcastFrame(channel string, requiredTags []string, data []byte, keyframe bool) (delivered, dropped int) {
+	// Чтение под блокировкой: SendChan закрывается только под записью
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	channel = cm.resolveChannelLocked(channel)
+	if cm.replay != nil {
+		cm.replay.Record(channel, requiredTags, data, keyframe)
+	}
+
+	for _, client := range cm.clients {
+		sub, subscribed := client.Channels[channel]
+		if !subscribed || !sub.Allows(requiredTags) {
+			continue
+		}
+
+		dropped += coalesceSend(client.SendChan, data)
+		delivered++
+	}
+	return delivered, dropped
+}
+
+// coalesceSend кладет data в канал; при полном канале выбрасывает из него
+// устаревшие фреймы. Возвращает число выброшенных фреймов.
+func coalesceSend(ch chan []byte, data []byte) int {
+	dropped := 0
+	for {
+		select {
+		case ch <- data:
+			return dropped
+		default:
+		}
+
+		// Канал полон: клиент не успевает, устаревшие фреймы ему не нужны
+		for drained := false; !drained; {
+			select {
+			case <-ch:
+				dropped++
+			default:
+				drained = true
+			}
+		}
+	}
+}
+
+// ResolveChannel возвращает актуальный id канала с учетом алиасов
+func (cm *ClientManager) ResolveChannel(channel string) string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.resolveChannelLocked(channel)
+}
+
+// resolveChannelLocked проходит цепочку алиасов, вызывается под блокировкой
+func (cm *ClientManager) resolveChannelLocked(channel string) string {
+	for i := 0; i <= len(cm.aliases); i++ {
+		next, ok := cm.aliases[channel]
+		if !ok {
+			break
+		}
+		channel = next
+	}
+	return channel
+}
+
+// RenameChannel сохраняет алиас старого канала на новый и переносит
+// подписки. Возвращает клиентов, чьи подписки были перенесены.
+func (cm *ClientManager) RenameChannel(oldChannel, newChannel string) ([]*ClientInfo, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if oldChannel == "" || newChannel == "" {
+		return nil, fmt.Errorf("channel ids must not be empty")
+	}
+
+	target := cm.resolveChannelLocked(newChannel)
+	if target == oldChannel {
+		return nil, fmt.Errorf("alias %s -> %s would create a cycle", oldChannel, newChannel)
+	}
+
+	cm.aliases[oldChannel] = newChannel
+	if cm.replay != nil {
+		// Кадры старого канала не продолжают поток нового
+		cm.replay.Forget(oldChannel)
+	}
+
+	var migrated []*ClientInfo
+	for _, client := range cm.clients {
+		sub, subscribed := client.Channels[oldChannel]
+		if !subscribed {
+			continue
+		}
+		delete(client.Channels, oldChannel)
+		if _, exists := client.Channels[target]; !exists {
+			client.Channels[target] = sub
+		}
+		migrated = append(migrated, client)
+	}
+
+	log.Printf("Channel %s renamed to %s, migrated %d subscribers", oldChannel, target, len(migrated))
+	return migrated, nil
+}
+
+// RemoveChannelAlias удаляет алиас канала
+func (cm *ClientManager) RemoveChannelAlias(oldChannel string) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if _, exists := cm.aliases[oldChannel]; !exists {
+		return false
+	}
+	delete(cm.aliases, oldChannel)
+	return true
+}
+
+// GetChannelAliases возвращает копию таблицы алиасов
+func (cm *ClientManager) GetChannelAliases() map[string]string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	aliases := make(map[string]string, len(cm.aliases))
+	for from, to := range cm.aliases {
+		aliases[from] = to
+	}
+	return aliases
+}
+
+// NotifyClient отправляет клиенту служебное уведомление без блокировки
+func (cm *ClientManager) NotifyClient(client *ClientInfo, event interface{}) bool {
+	select {
+	case client.EventChan <- event:
+		return true
+	default:
+		log.Printf("Client %s event channel full, dropping event", client.ID)
+		return false
+	}
+}
+
 // GetAllClients возвращает всех клиентов
 func (cm *ClientManager) GetAllClients() []*ClientInfo {
 	cm.mu.RLock()
@@ -158,6 +486,7 @@ func (cm *ClientManager) GetAllClients() []*ClientInfo {
 
 // RemoveClient удаляет клиента
 func (cm *ClientManager) RemoveClient(connID string) {
+	defer cm.syncPresence()
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -166,25 +495,85 @@ func (cm *ClientManager) RemoveClient(connID string) {
 		return
 	}
 
+	cm.deleteClientLocked(connID, client)
+
+	log.Printf("Client removed: %s (connection: %s)", client.ID, connID)
+}
+
+// DisconnectClient принудительно отключает соединение с указанной причиной.
+// Возвращает false, если соединение не найдено.
+func (cm *ClientManager) DisconnectClient(connID, reason string) bool {
+	defer cm.syncPresence()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	client, exists := cm.clients[connID]
+	if !exists {
+		return false
+	}
+
+	client.CloseReason = reason
+	cm.deleteClientLocked(connID, client)
+
+	log.Printf("Client disconnected: %s (connection: %s, reason: %s)", client.ID, connID, reason)
+	return true
+}
+
+// DisconnectClientByID отключает все соединения логического клиента.
+// Возвращает идентификаторы отключенных соединений.
+func (cm *ClientManager) DisconnectClientByID(clientID, reason string) []string {
+	defer cm.syncPresence()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	var removed []string
+	for connID, client := range cm.clients {
+		if client.ID != clientID {
+			continue
+		}
+		client.CloseReason = reason
+		cm.deleteClientLocked(connID, client)
+		removed = append(removed, connID)
+	}
+
+	if len(removed) > 0 {
+		log.Printf("Client disconnected: %s (%d connections, reason: %s)", clientID, len(removed), reason)
+	}
+	return removed
+}
+
+// deleteClientLocked закрывает канал клиента и удаляет его из индексов,
+// вызывается под блокировкой
+func (cm *ClientManager) deleteClientLocked(connID string, client *ClientInfo) {
 	close(client.SendChan)
 	delete(cm.clients, connID)
 
-	log.Printf("Client removed: %s (connection: %s)", client.ID, connID)
+	cm.ipCounts[client.IPAddress]--
+	if cm.ipCounts[client.IPAddress] <= 0 {
+		delete(cm.ipCounts, client.IPAddress)
+	}
+
+	cm.clientCounts[client.ID]--
+	if cm.clientCounts[client.ID] <= 0 {
+		delete(cm.clientCounts, client.ID)
+	}
+	cm.clientDisconnectedLocked(client.ID)
 }
 
 // CleanupInactiveClients очищает неактивных клиентов
 func (cm *ClientManager) CleanupInactiveClients(timeout time.Duration) {
+	defer cm.syncPresence()
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	now := time.Now()
 	for connID, client := range cm.clients {
 		if now.Sub(client.LastSeen) > timeout {
-			close(client.SendChan)
-			delete(cm.clients, connID)
+			cm.deleteClientLocked(connID, client)
 			log.Printf("Inactive client cleaned up: %s", client.ID)
 		}
 	}
+	cm.cleanupSuspendedLocked(now)
 }
 
 // UpdateClientData обновляет данные клиента
@@ -203,14 +592,30 @@ func (cm *ClientManager) UpdateClientData(connID string, data *ClientData) error
 	return nil
 }
 
-// CloseAll закрывает все соединения
-func (cm *ClientManager) CloseAll() {
+// TouchClient отмечает активность соединения (сообщение или pong)
+func (cm *ClientManager) TouchClient(connID string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if client, exists := cm.clients[connID]; exists {
+		client.LastSeen = time.Now()
+	}
+}
+
+// CloseAll закрывает все соединения. Перед закрытием каждому клиенту
+// отправляется notice (если не nil), а close фрейм несет code и reason.
+func (cm *ClientManager) CloseAll(code int, reason string, notice interface{}) {
+	defer cm.syncPresence()
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	for connID, client := range cm.clients {
-		close(client.SendChan)
-		delete(cm.clients, connID)
+		if notice != nil {
+			cm.NotifyClient(client, notice)
+		}
+		client.CloseCode = code
+		client.CloseReason = reason
+		cm.deleteClientLocked(connID, client)
 		log.Printf("Client disconnected on shutdown: %s", client.ID)
 	}
 }
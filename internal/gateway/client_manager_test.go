@@ -0,0 +1,196 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	pb "api-gateway/pkg/gen"
+)
+
+func TestClientLimitsSendBuffer(t *testing.T) {
+	limits := ClientLimits{
+		SendBufferSize:    50,
+		ClientSendBuffers: map[string]int{"viewer": 500},
+	}
+
+	tests := []struct {
+		name     string
+		limits   ClientLimits
+		clientID string
+		want     int
+	}{
+		{"per client", limits, "viewer", 500},
+		{"configured default", limits, "control", 50},
+		{"built-in default", ClientLimits{}, "control", defaultSendBufferSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.SendBuffer(tt.clientID); got != tt.want {
+				t.Errorf("SendBuffer(%q) = %d, want %d", tt.clientID, got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingPresence записывает события общего списка клиентов
+type recordingPresence struct {
+	events []string
+}
+
+func (p *recordingPresence) SaveClient(client *pb.ClientInfo) {
+	p.events = append(p.events, "+"+client.ClientId)
+}
+
+func (p *recordingPresence) RemoveClient(clientID string) {
+	p.events = append(p.events, "-"+clientID)
+}
+
+func (p *recordingPresence) GetAllClients() []*pb.ClientInfo { return nil }
+
+func TestClientManagerPresence(t *testing.T) {
+	presence := &recordingPresence{}
+	cm := NewClientManager(ClientLimits{})
+	cm.SetPresence(presence)
+
+	first, _ := cm.RegisterClient("cam-1", "10.0.0.1", "test")
+	second, _ := cm.RegisterClient("cam-1", "10.0.0.2", "test")
+	cm.RemoveClient(first.ConnectionID) // у cam-1 осталось соединение
+	cm.SuspendClient(second.ConnectionID, time.Minute)
+	if _, err := cm.ResumeClient(second.ResumeToken, "cam-1", "10.0.0.2", "test"); err != nil {
+		t.Fatalf("ResumeClient: %v", err)
+	}
+	cm.RegisterClient("cam-2", "10.0.0.3", "test")
+	cm.CloseAll(0, "shutdown", nil)
+
+	want := []string{"+cam-1", "-cam-1", "+cam-1", "+cam-2"}
+	// CloseAll удаляет оставшихся клиентов в любом порядке
+	if len(presence.events) != len(want)+2 || !reflect.DeepEqual(presence.events[:len(want)], want) {
+		t.Errorf("presence events = %v, want %v and two removals", presence.events, want)
+	}
+}
+
+func TestRenameChannelMigratesSubscribers(t *testing.T) {
+	g := newTestGateway(t, nil)
+	subscriber, _ := g.clientMgr.RegisterClient("viewer", "10.0.0.1", "test")
+	other, _ := g.clientMgr.RegisterClient("other", "10.0.0.2", "test")
+	g.clientMgr.SubscribeClient(subscriber.ConnectionID, "cam-old")
+	g.clientMgr.SubscribeClient(other.ConnectionID, "cam-x")
+
+	migrated, err := g.RenameChannel("cam-old", "cam-new")
+	if err != nil {
+		t.Fatalf("RenameChannel: %v", err)
+	}
+	if migrated != 1 {
+		t.Fatalf("migrated = %d, want 1", migrated)
+	}
+
+	select {
+	case event := <-subscriber.EventChan:
+		notice, _ := event.(map[string]interface{})
+		if notice["action"] != "channel_renamed" || notice["channel"] != "cam-new" {
+			t.Errorf("event = %v, want channel_renamed to cam-new", event)
+		}
+	default:
+		t.Error("subscriber was not notified about the rename")
+	}
+
+	// Фреймы нового канала и старого (через алиас) доходят до подписчика
+	for _, channel := range []string{"cam-new", "cam-old"} {
+		delivered, _ := g.clientMgr.BroadcastFrame(channel, nil, []byte(channel), false)
+		if delivered != 1 {
+			t.Errorf("BroadcastFrame(%q) delivered to %d clients, want 1", channel, delivered)
+		}
+		select {
+		case data := <-subscriber.SendChan:
+			if string(data) != channel {
+				t.Errorf("subscriber got %q, want %q", data, channel)
+			}
+		default:
+			t.Errorf("subscriber did not receive frame of %q", channel)
+		}
+	}
+	if len(other.SendChan) != 0 {
+		t.Error("client of another channel received frames")
+	}
+}
+
+func TestMaxConnectionsPerClient(t *testing.T) {
+	cm := NewClientManager(ClientLimits{MaxConnectionsPerClient: 2})
+
+	var conns []*ClientInfo
+	for i := 0; i < 2; i++ {
+		client, err := cm.RegisterClient("cam-1", "10.0.0.1", "test")
+		if err != nil {
+			t.Fatalf("connection %d: %v", i+1, err)
+		}
+		conns = append(conns, client)
+	}
+
+	if _, err := cm.RegisterClient("cam-1", "10.0.0.1", "test"); !errors.Is(err, ErrTooManyConnectionsPerClient) {
+		t.Fatalf("connection over the limit: error = %v, want %v", err, ErrTooManyConnectionsPerClient)
+	}
+	if err := cm.CheckConnectionLimits("cam-1", "10.0.0.1"); !errors.Is(err, ErrTooManyConnectionsPerClient) {
+		t.Errorf("CheckConnectionLimits = %v, want %v", err, ErrTooManyConnectionsPerClient)
+	}
+	// Лимит считается на client_id, другие клиенты не затронуты
+	if _, err := cm.RegisterClient("cam-2", "10.0.0.1", "test"); err != nil {
+		t.Errorf("another client rejected: %v", err)
+	}
+
+	cm.RemoveClient(conns[0].ConnectionID)
+	if _, err := cm.RegisterClient("cam-1", "10.0.0.1", "test"); err != nil {
+		t.Errorf("connection after removal: %v", err)
+	}
+}
+
+func TestBroadcastFrameSlowClientGetsLatest(t *testing.T) {
+	cm := NewClientManager(ClientLimits{SendBufferSize: 2, ClientSendBuffers: map[string]int{"fast": 10}})
+	slow, _ := cm.RegisterClient("slow", "10.0.0.1", "test")
+	fast, _ := cm.RegisterClient("fast", "10.0.0.2", "test")
+	cm.SubscribeClient(slow.ConnectionID, "cam-1")
+	cm.SubscribeClient(fast.ConnectionID, "cam-1")
+
+	// Никто не читает: буфер медленного клиента переполняется
+	totalDropped := 0
+	for i := 1; i <= 5; i++ {
+		delivered, dropped := cm.BroadcastFrame("cam-1", nil, []byte(fmt.Sprintf("f%d", i)), false)
+		if delivered != 2 {
+			t.Fatalf("frame %d delivered to %d clients, want 2", i, delivered)
+		}
+		totalDropped += dropped
+	}
+
+	drain := func(ch chan []byte) []string {
+		var frames []string
+		for len(ch) > 0 {
+			frames = append(frames, string(<-ch))
+		}
+		return frames
+	}
+	if got := drain(slow.SendChan); !reflect.DeepEqual(got, []string{"f5"}) {
+		t.Errorf("slow client frames = %v, want only the latest f5", got)
+	}
+	if totalDropped != 4 {
+		t.Errorf("dropped %d stale frames, want 4", totalDropped)
+	}
+	if got := drain(fast.SendChan); !reflect.DeepEqual(got, []string{"f1", "f2", "f3", "f4", "f5"}) {
+		t.Errorf("fast client frames = %v, want all five in order", got)
+	}
+}
+
+func TestBroadcastFrameSharesBytes(t *testing.T) {
+	cm := NewClientManager(ClientLimits{})
+	first, _ := cm.RegisterClient("viewer-1", "10.0.0.1", "test")
+	second, _ := cm.RegisterClient("viewer-2", "10.0.0.2", "test")
+	cm.SubscribeClient(first.ConnectionID, "cam-1")
+	cm.SubscribeClient(second.ConnectionID, "cam-1")
+
+	data := []byte(`{"frame_id":"f"}`)
+	cm.BroadcastFrame("cam-1", nil, data, false)
+	if a, b := <-first.SendChan, <-second.SendChan; &a[0] != &data[0] || &b[0] != &data[0] {
+		t.Error("clients received copies instead of the shared frame bytes")
+	}
+}
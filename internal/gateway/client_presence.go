@@ -0,0 +1,125 @@
+package gateway
+
+import (
+	"log"
+	"net"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/controller"
+	"api-gateway/internal/redisconn"
+	pb "api-gateway/pkg/gen"
+)
+
+// ClientPresence общий для реплик список подключенных клиентов. Реализация
+// сама подтверждает присутствие клиентов реплики, пока они подключены.
+type ClientPresence interface {
+	SaveClient(client *pb.ClientInfo)
+	RemoveClient(clientID string)
+	GetAllClients() []*pb.ClientInfo
+}
+
+// presenceEvent подключение первого или отключение последнего соединения
+// client_id
+type presenceEvent struct {
+	clientID string
+	client   *pb.ClientInfo // nil - клиент отключился
+}
+
+// SetPresence включает публикацию клиентов шлюза в общий список.
+// Вызывается до регистрации клиентов.
+func (cm *ClientManager) SetPresence(presence ClientPresence) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.presence = presence
+}
+
+// ClusterClients возвращает клиентов всех реплик (nil без общего списка)
+func (cm *ClientManager) ClusterClients() []*pb.ClientInfo {
+	cm.mu.RLock()
+	presence := cm.presence
+	cm.mu.RUnlock()
+
+	if presence == nil {
+		return nil
+	}
+	return presence.GetAllClients()
+}
+
+// clientConnectedLocked ставит в очередь публикацию клиента при первом
+// соединении client_id, вызывается под блокировкой после учета соединения
+func (cm *ClientManager) clientConnectedLocked(client *ClientInfo) {
+	if cm.presence == nil || cm.clientCounts[client.ID] != 1 {
+		return
+	}
+	cm.presenceEvents = append(cm.presenceEvents, presenceEvent{
+		clientID: client.ID,
+		client: &pb.ClientInfo{
+			ClientId:    client.ID,
+			IpAddress:   client.IPAddress,
+			UserAgent:   client.UserAgent,
+			SessionId:   client.ConnectionID,
+			ConnectedAt: client.ConnectedAt.Unix(),
+		},
+	})
+}
+
+// clientDisconnectedLocked ставит в очередь удаление клиента после его
+// последнего соединения, вызывается под блокировкой
+func (cm *ClientManager) clientDisconnectedLocked(clientID string) {
+	if cm.presence == nil || cm.clientCounts[clientID] > 0 {
+		return
+	}
+	cm.presenceEvents = append(cm.presenceEvents, presenceEvent{clientID: clientID})
+}
+
+// syncPresence отправляет накопленные события в общий список. Вызывается
+// без блокировки cm.mu: запросы к Redis не должны задерживать рассылку
+// фреймов. presenceMu сохраняет порядок событий.
+func (cm *ClientManager) syncPresence() {
+	cm.presenceMu.Lock()
+	defer cm.presenceMu.Unlock()
+
+	cm.mu.Lock()
+	events := cm.presenceEvents
+	cm.presenceEvents = nil
+	presence := cm.presence
+	cm.mu.Unlock()
+
+	for _, event := range events {
+		if event.client != nil {
+			presence.SaveClient(event.client)
+		} else {
+			presence.RemoveClient(event.clientID)
+		}
+	}
+}
+
+// newClientPresence создает общий список клиентов, если client_store.backend
+// redis, и функцию его закрытия
+func newClientPresence(cfg *config.Config) (ClientPresence, func()) {
+	if cfg.ClientStore.Backend != "redis" {
+		return nil, func() {}
+	}
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		logger = zap.NewNop()
+	}
+	addr := net.JoinHostPort(cfg.Redis.Host, strconv.Itoa(cfg.Redis.Port))
+	client := redisconn.New(redisconn.Options{
+		Addr:     addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+		Timeout:  redisLimiterTimeout,
+	})
+	store := controller.NewRedisClientStore(client, cfg.ClientStore.KeyPrefix+"gateway:", cfg.GetClientStoreTTL(), logger)
+	log.Printf("Sharing client presence via Redis %s", addr)
+	return store, func() {
+		store.Close()
+		client.Close()
+	}
+}
@@ -0,0 +1,133 @@
+package gateway
+
+import (
+	"sort"
+	"time"
+)
+
+// ClientSummary снимок соединения клиента, собранный под блокировкой
+// реестра: его можно читать без ClientManager.mu
+type ClientSummary struct {
+	ID            string    `json:"id"`
+	ConnectionID  string    `json:"connection_id"`
+	IPAddress     string    `json:"ip_address"`
+	ConnectedAt   time.Time `json:"connected_at"`
+	LastSeen      time.Time `json:"last_seen"`
+	IsActive      bool      `json:"is_active"`
+	Channels      []string  `json:"channels"`
+	BytesSent     int64     `json:"bytes_sent"`
+	SendBuffer    int       `json:"send_buffer"` // размер очереди фреймов
+	SendQueued    int       `json:"send_queued"` // фреймов в очереди
+	BandwidthBps  float64   `json:"bandwidth_bps"`
+	Authenticated bool      `json:"authenticated"`
+	UserID        string    `json:"user_id,omitempty"`
+	Roles         []string  `json:"roles,omitempty"`
+}
+
+// FindByChannel возвращает подписчиков канала (с учетом алиасов)
+func (cm *ClientManager) FindByChannel(channel string) []ClientSummary {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	channel = cm.resolveChannelLocked(channel)
+	return cm.findLocked(func(client *ClientInfo) bool {
+		_, subscribed := client.Channels[channel]
+		return subscribed
+	})
+}
+
+// FindByUser возвращает соединения пользователя: по UserID из данных
+// клиента или sub токена
+func (cm *ClientManager) FindByUser(userID string) []ClientSummary {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.findLocked(func(client *ClientInfo) bool {
+		return clientUserID(client) == userID
+	})
+}
+
+// CountByChannel возвращает число подписчиков каждого канала
+func (cm *ClientManager) CountByChannel() map[string]int {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, client := range cm.clients {
+		for channel := range client.Channels {
+			counts[channel]++
+		}
+	}
+	return counts
+}
+
+// findClients возвращает снимки всех клиентов
+func (cm *ClientManager) findClients() []ClientSummary {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.findLocked(func(*ClientInfo) bool { return true })
+}
+
+// findLocked собирает снимки клиентов, подходящих под match, в порядке
+// подключения. Вызывается под cm.mu.
+func (cm *ClientManager) findLocked(match func(*ClientInfo) bool) []ClientSummary {
+	result := make([]ClientSummary, 0)
+	for _, client := range cm.clients {
+		if match(client) {
+			result = append(result, summarizeClient(client))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].ConnectedAt.Equal(result[j].ConnectedAt) {
+			return result[i].ConnectedAt.Before(result[j].ConnectedAt)
+		}
+		return result[i].ConnectionID < result[j].ConnectionID
+	})
+	return result
+}
+
+// clientUserID возвращает пользователя соединения или пустую строку
+func clientUserID(client *ClientInfo) string {
+	if client.ClientData != nil && client.ClientData.UserID != "" {
+		return client.ClientData.UserID
+	}
+	if client.Claims != nil {
+		return client.Claims.Subject
+	}
+	return ""
+}
+
+// summarizeClient копирует поля клиента в снимок
+func summarizeClient(client *ClientInfo) ClientSummary {
+	channels := make([]string, 0, len(client.Channels))
+	for channel := range client.Channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	summary := ClientSummary{
+		ID:           client.ID,
+		ConnectionID: client.ConnectionID,
+		IPAddress:    client.IPAddress,
+		ConnectedAt:  client.ConnectedAt,
+		LastSeen:     client.LastSeen,
+		IsActive:     client.IsActive,
+		Channels:     channels,
+		UserID:       clientUserID(client),
+		SendBuffer:   cap(client.SendChan),
+		SendQueued:   len(client.SendChan),
+	}
+	if client.Bandwidth != nil {
+		summary.BytesSent = client.Bandwidth.Total()
+		summary.BandwidthBps = client.Bandwidth.Rate()
+	}
+	if data := client.ClientData; data != nil {
+		summary.Authenticated = data.Authenticated
+		summary.Roles = append([]string(nil), data.Roles...)
+	} else if client.Claims != nil {
+		summary.Authenticated = true
+		summary.Roles = append([]string(nil), client.Claims.Roles...)
+	}
+	return summary
+}
@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClientQueries(t *testing.T) {
+	g := newTestGateway(t, nil)
+	register := func(clientID string, channels ...string) *ClientInfo {
+		client, err := g.clientMgr.RegisterClient(clientID, "10.0.0.1", "test")
+		if err != nil {
+			t.Fatalf("RegisterClient: %v", err)
+		}
+		for _, channel := range channels {
+			g.clientMgr.SubscribeClient(client.ConnectionID, channel)
+		}
+		return client
+	}
+
+	// Пользователь соединения - из данных клиента или из sub токена
+	a := register("a", "cam-1")
+	g.clientMgr.UpdateClientData(a.ConnectionID, &ClientData{UserID: "user-1", Authenticated: true})
+	b := register("b", "cam-1", "cam-2")
+	b.Claims = &TokenClaims{Subject: "user-1"}
+	c := register("c", "cam-2", "cam-3")
+	g.clientMgr.UpdateClientData(c.ConnectionID, &ClientData{UserID: "user-2", Authenticated: true})
+	register("d")
+
+	// Старое имя канала находит подписчиков нового
+	if _, err := g.clientMgr.RenameChannel("cam-3", "cam-3-new"); err != nil {
+		t.Fatalf("RenameChannel: %v", err)
+	}
+
+	ids := func(summaries []ClientSummary) []string {
+		result := make([]string, 0, len(summaries))
+		for _, summary := range summaries {
+			result = append(result, summary.ID)
+		}
+		return result
+	}
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"by channel cam-1", ids(g.clientMgr.FindByChannel("cam-1")), []string{"a", "b"}},
+		{"by channel cam-2", ids(g.clientMgr.FindByChannel("cam-2")), []string{"b", "c"}},
+		{"by renamed channel", ids(g.clientMgr.FindByChannel("cam-3")), []string{"c"}},
+		{"by unknown channel", ids(g.clientMgr.FindByChannel("cam-9")), []string{}},
+		{"by user-1", ids(g.clientMgr.FindByUser("user-1")), []string{"a", "b"}},
+		{"by user-2", ids(g.clientMgr.FindByUser("user-2")), []string{"c"}},
+		{"by unknown user", ids(g.clientMgr.FindByUser("user-9")), []string{}},
+		{"all clients", ids(g.clientMgr.findClients()), []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: clients = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	wantCounts := map[string]int{"cam-1": 2, "cam-2": 2, "cam-3-new": 1}
+	if counts := g.clientMgr.CountByChannel(); !reflect.DeepEqual(counts, wantCounts) {
+		t.Errorf("CountByChannel = %v, want %v", counts, wantCounts)
+	}
+
+	// Снимок не зависит от последующих изменений клиента
+	summary := g.clientMgr.FindByChannel("cam-2")[0]
+	g.clientMgr.SubscribeClient(b.ConnectionID, "cam-4")
+	if want := []string{"cam-1", "cam-2"}; !reflect.DeepEqual(summary.Channels, want) {
+		t.Errorf("summary channels = %v, want %v", summary.Channels, want)
+	}
+}
@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// ErrInvalidResumeToken токен восстановления неизвестен, истек или выдан
+// другому клиенту
+var ErrInvalidResumeToken = errors.New("invalid or expired resume token")
+
+// suspendedClient клиент, оборвавший соединение, который еще может
+// вернуться по токену восстановления
+type suspendedClient struct {
+	client    *ClientInfo
+	expiresAt time.Time
+}
+
+// newResumeToken генерирует одноразовый токен восстановления сессии
+func newResumeToken() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// SuspendClient убирает соединение из активных. Если grace > 0, клиент с
+// подписками и данными сессии хранится до истечения grace и может быть
+// восстановлен ResumeClient. Соединения, уже отключенные шлюзом
+// (DisconnectClient, CloseAll, очистка неактивных), не восстанавливаются.
+func (cm *ClientManager) SuspendClient(connID string, grace time.Duration) bool {
+	defer cm.syncPresence()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	client, exists := cm.clients[connID]
+	if !exists {
+		return false
+	}
+	cm.deleteClientLocked(connID, client)
+
+	if grace <= 0 || client.ResumeToken == "" {
+		log.Printf("Client removed: %s (connection: %s)", client.ID, connID)
+		return false
+	}
+
+	client.IsActive = false
+	cm.suspended[client.ResumeToken] = &suspendedClient{
+		client:    client,
+		expiresAt: time.Now().Add(grace),
+	}
+
+	log.Printf("Client suspended: %s (connection: %s, resumable for %s)", client.ID, connID, grace)
+	return true
+}
+
+// ResumeClient возвращает в активные клиента, сохраненного SuspendClient.
+// Клиент получает новое соединение и новый токен, подписки и ClientData
+// сохраняются. Токен принимается только от того же client_id.
+func (cm *ClientManager) ResumeClient(token, clientID, ip, userAgent string) (*ClientInfo, error) {
+	defer cm.syncPresence()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	entry, exists := cm.suspended[token]
+	if !exists || entry.client.ID != clientID {
+		return nil, ErrInvalidResumeToken
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(cm.suspended, token)
+		return nil, ErrInvalidResumeToken
+	}
+	if err := cm.checkLimitsLocked(clientID, ip); err != nil {
+		return nil, err
+	}
+	delete(cm.suspended, token)
+
+	connID := fmt.Sprintf("%s-%d", clientID, time.Now().UnixNano())
+
+	client := entry.client
+	client.ConnectionID = connID
+	client.IPAddress = ip
+	client.UserAgent = userAgent
+	client.LastSeen = time.Now()
+	client.IsActive = true
+	client.SendChan = make(chan []byte, cm.limits.SendBuffer(clientID))
+	client.EventChan = make(chan interface{}, 16)
+	client.CloseCode = 0
+	client.CloseReason = ""
+	client.ResumeToken = newResumeToken()
+
+	cm.clients[connID] = client
+	cm.ipCounts[ip]++
+	cm.clientCounts[clientID]++
+	cm.clientConnectedLocked(client)
+
+	log.Printf("Client resumed: %s (connection: %s, %d channels)", clientID, connID, len(client.Channels))
+	return client, nil
+}
+
+// ClientChannels возвращает отсортированный список каналов соединения
+func (cm *ClientManager) ClientChannels(connID string) []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	client, exists := cm.clients[connID]
+	if !exists {
+		return nil
+	}
+
+	channels := make([]string, 0, len(client.Channels))
+	for channel := range client.Channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// GetSuspendedClientCount возвращает число клиентов, ожидающих восстановления
+func (cm *ClientManager) GetSuspendedClientCount() int {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return len(cm.suspended)
+}
+
+// cleanupSuspendedLocked удаляет истекшие сессии, вызывается под блокировкой
+func (cm *ClientManager) cleanupSuspendedLocked(now time.Time) {
+	for token, entry := range cm.suspended {
+		if now.After(entry.expiresAt) {
+			delete(cm.suspended, token)
+			log.Printf("Suspended client expired: %s", entry.client.ID)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"api-gateway/internal/config"
+)
+
+// sessionEvent уведомление о сессии, первое сообщение /ws/video
+type sessionEvent struct {
+	Action      string   `json:"action"`
+	ResumeToken string   `json:"resume_token"`
+	Resumed     bool     `json:"resumed"`
+	Channels    []string `json:"channels"`
+}
+
+// dialVideoSession подключается к /ws/video и возвращает уведомление о сессии
+func dialVideoSession(t *testing.T, server *httptest.Server, query string) (*websocket.Conn, sessionEvent) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/video?" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var event sessionEvent
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(&event); err != nil || event.Action != "session" {
+		t.Fatalf("read session event: %+v, %v", event, err)
+	}
+	return conn, event
+}
+
+// waitSuspended ждет, пока шлюз сохранит n оборванных сессий
+func waitSuspended(t *testing.T, g *APIGateway, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for g.clientMgr.GetSuspendedClientCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("suspended clients = %d, want %d", g.clientMgr.GetSuspendedClientCount(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestResumeTokenRestoresSubscriptions(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Gateway.ResumeGracePeriod = 30
+		cfg.Auth.ChannelOwners = map[string][]string{
+			"user-1": {"cam-1", "cam-2"},
+			"user-2": {"cam-1"},
+		}
+	})
+	server := httptest.NewServer(http.HandlerFunc(g.handleWebSocketVideo))
+	defer server.Close()
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "client-1"})
+
+	conn, first := dialVideoSession(t, server, "token="+token)
+	if first.Resumed || first.ResumeToken == "" {
+		t.Fatalf("first session = %+v, want a new session with a resume token", first)
+	}
+	for _, channel := range []string{"cam-1", "cam-2"} {
+		conn.WriteJSON(map[string]string{"action": "subscribe", "channel": channel})
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, data, err := conn.ReadMessage(); err != nil || !strings.Contains(string(data), "subscribed") {
+			t.Fatalf("subscribe %s: %s, %v", channel, data, err)
+		}
+	}
+
+	// Обрыв по инициативе клиента - сессию можно восстановить
+	conn.Close()
+	waitSuspended(t, g, 1)
+
+	conn, resumed := dialVideoSession(t, server, "token="+token+"&resume_token="+first.ResumeToken)
+	if !resumed.Resumed {
+		t.Fatalf("session = %+v, want resumed", resumed)
+	}
+	if want := []string{"cam-1", "cam-2"}; !reflect.DeepEqual(resumed.Channels, want) {
+		t.Errorf("restored channels = %v, want %v", resumed.Channels, want)
+	}
+	if resumed.ResumeToken == "" || resumed.ResumeToken == first.ResumeToken {
+		t.Errorf("resume token was not rotated: %q", resumed.ResumeToken)
+	}
+	if subscribers := g.clientMgr.GetClientsByChannel("cam-2"); len(subscribers) != 1 {
+		t.Errorf("cam-2 subscribers = %d, want 1", len(subscribers))
+	}
+
+	// Токен одноразовый: повторное использование открывает новую сессию
+	conn.Close()
+	waitSuspended(t, g, 1)
+	_, reused := dialVideoSession(t, server, "token="+token+"&resume_token="+first.ResumeToken)
+	if reused.Resumed || len(reused.Channels) != 0 {
+		t.Errorf("session with a used token = %+v, want a new one", reused)
+	}
+
+	// Восстановленные подписки проверяются по правам нового токена
+	other := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-2", ClientID: "client-1"})
+	_, restricted := dialVideoSession(t, server, "token="+other+"&resume_token="+resumed.ResumeToken)
+	if !restricted.Resumed || !reflect.DeepEqual(restricted.Channels, []string{"cam-1"}) {
+		t.Errorf("session = %+v, want resumed with cam-1 only", restricted)
+	}
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// connSessionKey ключ контекста HTTP соединения
+type connSessionKey struct{}
+
+// connSessions контексты HTTP соединений клиентов. Контекст соединения
+// отменяется, когда клиент его закрывает, поэтому фреймы, принятые
+// асинхронно, перестают отправляться в сервисы после отключения
+// источника, хотя запрос к этому моменту уже завершен.
+type connSessions struct {
+	mu      sync.Mutex
+	cancels map[net.Conn]context.CancelFunc
+}
+
+func newConnSessions() *connSessions {
+	return &connSessions{cancels: make(map[net.Conn]context.CancelFunc)}
+}
+
+// connContext для http.Server.ConnContext
+func (s *connSessions) connContext(ctx context.Context, conn net.Conn) context.Context {
+	session, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancels[conn] = cancel
+	s.mu.Unlock()
+	return context.WithValue(session, connSessionKey{}, session)
+}
+
+// connState для http.Server.ConnState: отменяет контекст закрытого или
+// захваченного (WebSocket) соединения
+func (s *connSessions) connState(conn net.Conn, state http.ConnState) {
+	if state != http.StateClosed && state != http.StateHijacked {
+		return
+	}
+	s.mu.Lock()
+	cancel := s.cancels[conn]
+	delete(s.cancels, conn)
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// sessionContext отвязывает ctx запроса от его завершения: значения
+// (request ID, отправитель) сохраняются, а отмена наступает при закрытии
+// соединения клиента. Без контекста соединения отменяется только cancel.
+func sessionContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	session, ok := ctx.Value(connSessionKey{}).(context.Context)
+	if !ok {
+		return detached, cancel
+	}
+	stop := context.AfterFunc(session, cancel)
+	return detached, func() {
+		stop()
+		cancel()
+	}
+}
+
+// frameReleaseKey ключ счетчика незавершенных отправок фрейма
+type frameReleaseKey struct{}
+
+// frameRelease освобождает контекст фрейма, когда завершены его
+// маршрутизация и все поставленные в пул отправки
+type frameRelease struct {
+	pending int32
+	cancel  context.CancelFunc
+}
+
+// withFrameRelease связывает с ctx счетчик отправок; маршрутизация сама
+// держит одну ссылку и отпускает ее через done
+func withFrameRelease(ctx context.Context, cancel context.CancelFunc) (context.Context, *frameRelease) {
+	release := &frameRelease{pending: 1, cancel: cancel}
+	return context.WithValue(ctx, frameReleaseKey{}, release), release
+}
+
+// acquireFrame учитывает отправку фрейма ctx; возвращает функцию,
+// которую нужно вызвать по ее завершении
+func acquireFrame(ctx context.Context) func() {
+	release, ok := ctx.Value(frameReleaseKey{}).(*frameRelease)
+	if !ok {
+		return func() {}
+	}
+	atomic.AddInt32(&release.pending, 1)
+	return release.done
+}
+
+// done отпускает ссылку; последняя отменяет контекст фрейма
+func (r *frameRelease) done() {
+	if atomic.AddInt32(&r.pending, -1) == 0 {
+		r.cancel()
+	}
+}
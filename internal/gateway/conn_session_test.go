@@ -0,0 +1,159 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+// blockingService эндпоинт, который держит запрос до его отмены. В started
+// приходит сигнал о начале запроса, в aborted - о его отмене.
+func blockingService(t *testing.T) (server *httptest.Server, started, aborted chan struct{}) {
+	t.Helper()
+	started = make(chan struct{}, 8)
+	aborted = make(chan struct{}, 8)
+	release := make(chan struct{})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Без прочитанного тела сервер не замечает разрыв соединения
+		io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			aborted <- struct{}{}
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	return server, started, aborted
+}
+
+// newForwardingGateway шлюз с единственным эндпоинтом video_processing на url
+func newForwardingGateway(t *testing.T, url string) *APIGateway {
+	t.Helper()
+	return newTestGateway(t, func(cfg *config.Config) {
+		cfg.Services.VideoProcessing = []string{url}
+		cfg.Services.Analytics = nil
+		cfg.Services.Storage = nil
+		cfg.Services.Notification = nil
+		cfg.Services.Retry.MaxAttempts = 1
+	})
+}
+
+// waitSignal ждет сигнал в ch не дольше секунды
+func waitSignal(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+// waitIdlePool ждет, пока воркеры пула завершат отправки
+func waitIdlePool(t *testing.T, g *APIGateway) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for g.sendPool.Stats().BusyWorkers > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("send workers still busy: %+v", g.sendPool.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCancelledFrameStopsForwarding(t *testing.T) {
+	server, started, aborted := blockingService(t)
+	g := newForwardingGateway(t, server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.HandleVideoFrame(ctx, &proto.VideoFrame{FrameID: "f1", CameraID: "cam-1", ClientID: "cam-1"})
+	waitSignal(t, started, "send to start")
+
+	cancel()
+	waitSignal(t, aborted, "send to be aborted")
+	waitIdlePool(t, g)
+	if got := g.sendPool.Stats().Cancelled; got != 1 {
+		t.Errorf("cancelled sends = %d, want 1", got)
+	}
+}
+
+func TestFrameContextReleasedAfterSends(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	g := newForwardingGateway(t, server.URL)
+
+	frameCtx := make(chan context.Context, 1)
+	g.Hooks().RegisterPreForward("capture", func(ctx context.Context, frame *proto.VideoFrame) error {
+		frameCtx <- ctx
+		return nil
+	})
+
+	g.HandleVideoFrame(context.Background(), &proto.VideoFrame{FrameID: "f1", CameraID: "cam-1", ClientID: "cam-1"})
+	var ctx context.Context
+	select {
+	case ctx = <-frameCtx:
+	case <-time.After(time.Second):
+		t.Fatal("frame was not routed")
+	}
+
+	// Таймер frame_timeout_ms (30s) не должен удерживать контекст
+	select {
+	case <-ctx.Done():
+		if ctx.Err() != context.Canceled {
+			t.Errorf("frame context error = %v, want context.Canceled", ctx.Err())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("frame context is not released after its sends completed")
+	}
+}
+
+func TestClientDisconnectStopsAsyncForwarding(t *testing.T) {
+	service, started, aborted := blockingService(t)
+	g := newForwardingGateway(t, service.URL)
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "cam-1"})
+
+	mux := http.NewServeMux()
+	g.setupHTTPHandlers(mux)
+	server := httptest.NewUnstartedServer(mux)
+	server.Config.ConnContext = g.connSessions.connContext
+	server.Config.ConnState = g.connSessions.connState
+	server.Start()
+	defer server.Close()
+
+	transport := &http.Transport{}
+	client := &http.Client{Transport: transport}
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/video/stream",
+		strings.NewReader(`{"frame_id":"f1","camera_id":"cam-1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("POST frame: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	// Ответ уже получен, отправка в сервис продолжается
+	waitSignal(t, started, "send to start")
+	select {
+	case <-aborted:
+		t.Fatal("send aborted while the client is still connected")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	transport.CloseIdleConnections()
+	waitSignal(t, aborted, "send to be aborted after disconnect")
+	waitIdlePool(t, g)
+}
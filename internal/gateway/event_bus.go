@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"log"
+	"sync"
+)
+
+// Типы управляющих сообщений
+const (
+	ControlClientConnect    = "client_connect"
+	ControlClientDisconnect = "client_disconnect"
+	ControlSubscribe        = "subscribe"
+	ControlUnsubscribe      = "unsubscribe"
+	ControlServiceHealth    = "service_health"
+	ControlKeyframeRequest  = "keyframe_request"
+)
+
+// ControlSubscriber получает управляющие сообщения подписанного типа
+type ControlSubscriber func(msg *ControlMessage)
+
+type namedSubscriber struct {
+	name       string
+	subscriber ControlSubscriber
+}
+
+// EventBus рассылает управляющие сообщения подписчикам по типу сообщения.
+// Подписчики вызываются синхронно в порядке подписки из горутины обработки
+// controlChan, поэтому не должны блокироваться надолго. Паника подписчика
+// перехватывается и не мешает остальным.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]namedSubscriber
+}
+
+// NewEventBus создает шину без подписчиков
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[string][]namedSubscriber)}
+}
+
+// Subscribe подписывает обработчик на сообщения типа msgType
+func (b *EventBus) Subscribe(msgType, name string, subscriber ControlSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[msgType] = append(b.subscribers[msgType], namedSubscriber{name: name, subscriber: subscriber})
+}
+
+// Unsubscribe удаляет подписчиков с именем name на тип msgType
+func (b *EventBus) Unsubscribe(msgType, name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscribers := b.subscribers[msgType]
+	kept := make([]namedSubscriber, 0, len(subscribers))
+	for _, s := range subscribers {
+		if s.name != name {
+			kept = append(kept, s)
+		}
+	}
+	b.subscribers[msgType] = kept
+}
+
+// Publish доставляет сообщение подписчикам его типа и возвращает их число
+func (b *EventBus) Publish(msg *ControlMessage) int {
+	b.mu.RLock()
+	subscribers := b.subscribers[msg.Type]
+	b.mu.RUnlock()
+
+	for _, s := range subscribers {
+		deliverControlMessage(s, msg)
+	}
+	return len(subscribers)
+}
+
+// deliverControlMessage вызывает подписчика, перехватывая панику
+func deliverControlMessage(s namedSubscriber, msg *ControlMessage) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("Control subscriber %s panicked on %s: %v", s.name, msg.Type, rec)
+		}
+	}()
+
+	s.subscriber(msg)
+}
+
+// registerDefaultSubscribers подписывает встроенные обработчики шлюза
+func (g *APIGateway) registerDefaultSubscribers() {
+	g.events.Subscribe(ControlClientConnect, "log", g.handleClientConnect)
+	g.events.Subscribe(ControlClientDisconnect, "log", g.handleClientDisconnect)
+	g.events.Subscribe(ControlSubscribe, "log", g.handleSubscribe)
+	g.events.Subscribe(ControlUnsubscribe, "log", g.handleUnsubscribe)
+	g.events.Subscribe(ControlServiceHealth, "log", g.handleServiceHealth)
+	g.events.Subscribe(ControlKeyframeRequest, "keyframe", g.handleKeyframeRequest)
+}
@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventBusPublish(t *testing.T) {
+	bus := NewEventBus()
+	var received []string
+	record := func(name string) ControlSubscriber {
+		return func(msg *ControlMessage) { received = append(received, name+":"+msg.Type+":"+msg.Channel) }
+	}
+
+	bus.Subscribe(ControlSubscribe, "metrics", record("metrics"))
+	bus.Subscribe(ControlSubscribe, "broken", func(*ControlMessage) { panic("boom") })
+	bus.Subscribe(ControlSubscribe, "store", record("store"))
+	bus.Subscribe(ControlServiceHealth, "metrics", record("metrics"))
+
+	steps := []struct {
+		name      string
+		msg       *ControlMessage
+		before    func()
+		wantCount int
+		want      []string
+	}{
+		// Паника подписчика не мешает следующим, порядок - порядок подписки
+		{"subscribers in order", &ControlMessage{Type: ControlSubscribe, Channel: "cam-1"}, nil, 3,
+			[]string{"metrics:subscribe:cam-1", "store:subscribe:cam-1"}},
+		{"only subscribers of the type", &ControlMessage{Type: ControlServiceHealth}, nil, 1,
+			[]string{"metrics:service_health:"}},
+		{"no subscribers", &ControlMessage{Type: ControlUnsubscribe, Channel: "cam-1"}, nil, 0, nil},
+		{"unsubscribed by name", &ControlMessage{Type: ControlSubscribe, Channel: "cam-2"},
+			func() { bus.Unsubscribe(ControlSubscribe, "metrics") }, 2,
+			[]string{"store:subscribe:cam-2"}},
+	}
+	for _, step := range steps {
+		received = nil
+		if step.before != nil {
+			step.before()
+		}
+		if count := bus.Publish(step.msg); count != step.wantCount {
+			t.Errorf("%s: delivered to %d subscribers, want %d", step.name, count, step.wantCount)
+		}
+		if !reflect.DeepEqual(received, step.want) {
+			t.Errorf("%s: received %v, want %v", step.name, received, step.want)
+		}
+	}
+}
+
+func TestDefaultSubscribersRegistered(t *testing.T) {
+	g := newTestGateway(t, nil)
+
+	for _, msgType := range []string{ControlClientConnect, ControlClientDisconnect, ControlSubscribe,
+		ControlUnsubscribe, ControlServiceHealth, ControlKeyframeRequest} {
+		if count := g.Events().Publish(&ControlMessage{Type: msgType, ClientID: "client-1"}); count != 1 {
+			t.Errorf("%s: %d default subscribers, want 1", msgType, count)
+		}
+	}
+}
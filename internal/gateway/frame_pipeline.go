@@ -0,0 +1,293 @@
+package gateway
+
+import (
+	"api-gateway/pkg/proto"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// FrameHandler обрабатывает фрейм дальше по конвейеру
+type FrameHandler func(ctx context.Context, frame *proto.VideoFrame) error
+
+// FrameProcessor звено конвейера обработки фрейма. Чтобы передать фрейм
+// дальше, процессор вызывает next; не вызвав next, он отбрасывает фрейм.
+// Ошибка отклоняет фрейм, как ошибка pre-forward хука.
+type FrameProcessor func(ctx context.Context, frame *proto.VideoFrame, next FrameHandler) error
+
+// FrameDroppedError - процессор отбросил фрейм, не передав его дальше
+type FrameDroppedError struct {
+	Processor string
+}
+
+func (e *FrameDroppedError) Error() string {
+	return fmt.Sprintf("frame dropped by processor %s", e.Processor)
+}
+
+// processorStats учет фреймов процессора
+type processorStats struct {
+	processed int64
+	dropped   int64
+	failed    int64
+}
+
+type namedProcessor struct {
+	name      string
+	processor FrameProcessor
+	stats     *processorStats
+}
+
+// FramePipeline конвейер процессоров фреймов. Цепочка выбирается по
+// camera_id фрейма: pipeline.streams, иначе pipeline.default. Процессоры
+// ищутся по имени среди встроенных и зарегистрированных
+// RegisterProcessor. Имена проверяет config.Validate; процессор из
+// pipeline.custom, еще не зарегистрированный, пропускается.
+type FramePipeline struct {
+	defaultChain []string
+	streams      map[string][]string
+
+	mu         sync.RWMutex
+	processors map[string]namedProcessor
+}
+
+// NewFramePipeline создает конвейер по секции pipeline со встроенными
+// процессорами config.BuiltinFrameProcessors
+func NewFramePipeline(cfg config.PipelineConfig) *FramePipeline {
+	p := &FramePipeline{
+		defaultChain: cfg.Default,
+		streams:      cfg.Streams,
+		processors:   make(map[string]namedProcessor),
+	}
+	p.RegisterProcessor("validate", validateFrameProcessor)
+	p.RegisterProcessor("dedup", newDedupProcessor(cfg.DedupWindow, dedupIdleTimeout))
+	p.RegisterProcessor("watermark", newWatermarkProcessor(cfg.Watermark))
+	p.RegisterProcessor("metrics", newFrameMetricsProcessor().process)
+	return p
+}
+
+// RegisterProcessor добавляет или заменяет процессор с именем name
+func (p *FramePipeline) RegisterProcessor(name string, processor FrameProcessor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.processors[name] = namedProcessor{name: name, processor: processor, stats: &processorStats{}}
+}
+
+// UnknownProcessors возвращает имена из настроек, для которых нет
+// процессора
+func (p *FramePipeline) UnknownProcessors() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var unknown []string
+	seen := make(map[string]bool)
+	check := func(names []string) {
+		for _, name := range names {
+			if _, ok := p.processors[name]; !ok && !seen[name] {
+				seen[name] = true
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	check(p.defaultChain)
+	for _, chain := range p.streams {
+		check(chain)
+	}
+	return unknown
+}
+
+// chain возвращает процессоры цепочки стрима
+func (p *FramePipeline) chain(streamID string) []namedProcessor {
+	names, ok := p.streams[streamID]
+	if !ok {
+		names = p.defaultChain
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	chain := make([]namedProcessor, 0, len(names))
+	for _, name := range names {
+		if processor, ok := p.processors[name]; ok {
+			chain = append(chain, processor)
+		}
+	}
+	return chain
+}
+
+// Run пропускает фрейм через цепочку его стрима и вызывает terminal, если
+// все процессоры передали фрейм дальше. Отброшенный фрейм возвращает
+// *FrameDroppedError; паника процессора превращается в ошибку.
+func (p *FramePipeline) Run(ctx context.Context, frame *proto.VideoFrame, terminal FrameHandler) error {
+	chain := p.chain(frame.CameraID)
+
+	var handler func(i int) FrameHandler
+	handler = func(i int) FrameHandler {
+		if i == len(chain) {
+			return terminal
+		}
+		return func(ctx context.Context, frame *proto.VideoFrame) error {
+			return runProcessor(ctx, chain[i], frame, handler(i+1))
+		}
+	}
+	return handler(0)(ctx, frame)
+}
+
+// runProcessor вызывает процессор, учитывая исход и перехватывая панику
+func runProcessor(ctx context.Context, np namedProcessor, frame *proto.VideoFrame, next FrameHandler) (err error) {
+	atomic.AddInt64(&np.stats.processed, 1)
+
+	called := false
+	err = func() (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("frame processor %s panicked: %v", np.name, rec)
+			}
+		}()
+		return np.processor(ctx, frame, func(ctx context.Context, frame *proto.VideoFrame) error {
+			called = true
+			return next(ctx, frame)
+		})
+	}()
+
+	// После вызова next ошибка пришла дальше по цепочке и уже учтена там
+	switch {
+	case err == nil && !called:
+		atomic.AddInt64(&np.stats.dropped, 1)
+		return &FrameDroppedError{Processor: np.name}
+	case err != nil && !called:
+		atomic.AddInt64(&np.stats.failed, 1)
+		return fmt.Errorf("frame processor %s: %w", np.name, err)
+	}
+	return err
+}
+
+// Stats возвращает учет фреймов по процессорам
+func (p *FramePipeline) Stats() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := make(map[string]interface{}, len(p.processors))
+	for name, np := range p.processors {
+		stats[name] = map[string]int64{
+			"processed": atomic.LoadInt64(&np.stats.processed),
+			"dropped":   atomic.LoadInt64(&np.stats.dropped),
+			"failed":    atomic.LoadInt64(&np.stats.failed),
+		}
+	}
+	return stats
+}
+
+// validateFrameProcessor отклоняет фреймы без frame_id, camera_id, данных
+// или с отрицательными размерами
+func validateFrameProcessor(ctx context.Context, frame *proto.VideoFrame, next FrameHandler) error {
+	switch {
+	case frame.FrameID == "":
+		return errors.New("frame_id is required")
+	case frame.CameraID == "":
+		return errors.New("camera_id is required")
+	case frame.FrameData == "":
+		return errors.New("frame_data is empty")
+	case frame.Width < 0 || frame.Height < 0:
+		return fmt.Errorf("invalid frame size %dx%d", frame.Width, frame.Height)
+	}
+	return next(ctx, frame)
+}
+
+// dedupIdleTimeout через сколько без фреймов dedup забывает стрим
+const dedupIdleTimeout = 5 * time.Minute
+
+// newDedupProcessor отбрасывает фрейм, если его frame_id встречался среди
+// последних window фреймов стрима (повтор отправки клиентом). Стримы без
+// фреймов дольше idleTimeout забываются; проверка идет не чаще раза в
+// idleTimeout.
+func newDedupProcessor(window int, idleTimeout time.Duration) FrameProcessor {
+	if window <= 0 {
+		window = 64
+	}
+
+	type recentIDs struct {
+		ids      []string
+		seen     map[string]struct{}
+		lastSeen time.Time
+	}
+	var mu sync.Mutex
+	streams := make(map[string]*recentIDs)
+	lastSweep := time.Now()
+
+	return func(ctx context.Context, frame *proto.VideoFrame, next FrameHandler) error {
+		now := time.Now()
+		mu.Lock()
+		if now.Sub(lastSweep) >= idleTimeout {
+			for streamID, recent := range streams {
+				if now.Sub(recent.lastSeen) >= idleTimeout {
+					delete(streams, streamID)
+				}
+			}
+			lastSweep = now
+		}
+		recent, ok := streams[frame.CameraID]
+		if !ok {
+			recent = &recentIDs{seen: make(map[string]struct{})}
+			streams[frame.CameraID] = recent
+		}
+		recent.lastSeen = now
+		if _, duplicate := recent.seen[frame.FrameID]; duplicate {
+			mu.Unlock()
+			return nil
+		}
+		recent.seen[frame.FrameID] = struct{}{}
+		recent.ids = append(recent.ids, frame.FrameID)
+		if len(recent.ids) > window {
+			delete(recent.seen, recent.ids[0])
+			recent.ids = recent.ids[1:]
+		}
+		mu.Unlock()
+
+		return next(ctx, frame)
+	}
+}
+
+// newWatermarkProcessor добавляет в metadata фрейма подсказку watermark
+// для сервисов обработки; пустой текст - фрейм не изменяется
+func newWatermarkProcessor(text string) FrameProcessor {
+	return func(ctx context.Context, frame *proto.VideoFrame, next FrameHandler) error {
+		if text != "" {
+			if frame.Metadata == nil {
+				frame.Metadata = make(map[string]string)
+			}
+			frame.Metadata["watermark"] = text
+		}
+		return next(ctx, frame)
+	}
+}
+
+// frameMetricsProcessor логирует фреймы, обработка которых дальше по
+// цепочке (маршрутизация и рассылка) заняла больше slowFrameThreshold
+type frameMetricsProcessor struct {
+	slow int64
+}
+
+// slowFrameThreshold порог медленной обработки фрейма процессором metrics
+const slowFrameThreshold = 100 * time.Millisecond
+
+func newFrameMetricsProcessor() *frameMetricsProcessor {
+	return &frameMetricsProcessor{}
+}
+
+func (m *frameMetricsProcessor) process(ctx context.Context, frame *proto.VideoFrame, next FrameHandler) error {
+	start := time.Now()
+	err := next(ctx, frame)
+	if elapsed := time.Since(start); elapsed > slowFrameThreshold {
+		slow := atomic.AddInt64(&m.slow, 1)
+		log.Printf("Slow frame %s of stream %s: %v after pipeline (slow frames: %d)",
+			frame.FrameID, frame.CameraID, elapsed, slow)
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+func TestBuiltinProcessorsRegistered(t *testing.T) {
+	p := NewFramePipeline(config.PipelineConfig{Default: config.BuiltinFrameProcessors})
+	if unknown := p.UnknownProcessors(); len(unknown) > 0 {
+		t.Errorf("built-in processors %v are not registered", unknown)
+	}
+}
+
+func TestDedupProcessor(t *testing.T) {
+	dedup := newDedupProcessor(2, 20*time.Millisecond)
+	passed := func(cameraID, frameID string) bool {
+		called := false
+		dedup(context.Background(), &proto.VideoFrame{CameraID: cameraID, FrameID: frameID},
+			func(context.Context, *proto.VideoFrame) error {
+				called = true
+				return nil
+			})
+		return called
+	}
+
+	steps := []struct {
+		name   string
+		camera string
+		frame  string
+		wait   time.Duration
+		want   bool
+	}{
+		{"first frame", "cam-1", "f1", 0, true},
+		{"duplicate dropped", "cam-1", "f1", 0, false},
+		{"other stream independent", "cam-2", "f1", 0, true},
+		{"second frame", "cam-1", "f2", 0, true},
+		{"third frame pushes f1 out of window", "cam-1", "f3", 0, true},
+		{"f1 outside window", "cam-1", "f1", 0, true},
+		{"idle stream forgotten", "cam-1", "f3", 50 * time.Millisecond, true},
+	}
+	for _, step := range steps {
+		time.Sleep(step.wait)
+		if got := passed(step.camera, step.frame); got != step.want {
+			t.Fatalf("%s: passed = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package gateway
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+
+	"api-gateway/internal/config"
+)
+
+// serviceSampler решает, попадает ли очередной фрейм в выборку типа сервиса
+type serviceSampler struct {
+	percent float64
+	random  bool
+
+	mu      sync.Mutex
+	seen    int64 // фреймов, которые могли уйти в сервис
+	sampled int64 // из них отправлено
+}
+
+// take учитывает фрейм и сообщает, входит ли он в выборку. В режиме
+// interval фрейм берется, когда seen*percent/100 переходит через целое:
+// за любые N фреймов отправляется floor или ceil от N*percent/100.
+func (s *serviceSampler) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seen++
+	var ok bool
+	if s.random {
+		ok = rand.Float64()*100 < s.percent
+	} else {
+		ratio := s.percent / 100
+		ok = math.Floor(float64(s.seen)*ratio) > math.Floor(float64(s.seen-1)*ratio)
+	}
+	if ok {
+		s.sampled++
+	}
+	return ok
+}
+
+// FrameSampling выборка фреймов по типам сервисов; типы без настройки
+// получают все фреймы
+type FrameSampling struct {
+	samplers map[string]*serviceSampler
+}
+
+// NewFrameSampling создает выборку по секции services.sampling
+func NewFrameSampling(configs map[string]config.SamplingConfig) *FrameSampling {
+	f := &FrameSampling{samplers: make(map[string]*serviceSampler, len(configs))}
+	for serviceType, cfg := range configs {
+		f.samplers[serviceType] = &serviceSampler{
+			percent: cfg.Percent,
+			random:  cfg.Mode == "random",
+		}
+	}
+	return f
+}
+
+// Sample сообщает, отправлять ли очередной фрейм в сервисы типа serviceType
+func (f *FrameSampling) Sample(serviceType string) bool {
+	sampler, ok := f.samplers[serviceType]
+	if !ok {
+		return true
+	}
+	return sampler.take()
+}
+
+// Stats возвращает учет выборки по типам сервисов
+func (f *FrameSampling) Stats() map[string]interface{} {
+	stats := make(map[string]interface{}, len(f.samplers))
+	for serviceType, sampler := range f.samplers {
+		sampler.mu.Lock()
+		mode := "interval"
+		if sampler.random {
+			mode = "random"
+		}
+		stats[serviceType] = map[string]interface{}{
+			"percent": sampler.percent,
+			"mode":    mode,
+			"total":   sampler.seen,
+			"sampled": sampler.sampled,
+			"skipped": sampler.seen - sampler.sampled,
+		}
+		sampler.mu.Unlock()
+	}
+	return stats
+}
@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"testing"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+func TestAnalyticsSamplingRate(t *testing.T) {
+	const frames = 4000
+
+	tests := []struct {
+		name     string
+		sampling config.SamplingConfig
+		min, max int // отправлено в аналитику из frames
+	}{
+		// Interval: ровно каждый 10-й фрейм
+		{"interval 10%", config.SamplingConfig{Percent: 10}, 400, 400},
+		{"interval 25%", config.SamplingConfig{Percent: 25, Mode: "interval"}, 1000, 1000},
+		// Random: 25% от 4000 - 1000, стандартное отклонение около 27
+		{"random 25%", config.SamplingConfig{Percent: 25, Mode: "random"}, 850, 1150},
+		{"disabled", config.SamplingConfig{Percent: 0}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			cfg.Services.VideoProcessing = []string{"http://video.example"}
+			cfg.Services.Analytics = []string{"http://analytics.example"}
+			cfg.Services.Storage = nil
+			cfg.Services.Sampling = map[string]config.SamplingConfig{"analytics": tt.sampling}
+			registry := NewServiceRegistry(cfg, NewMemoryStatsSink())
+
+			frame := &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", ClientID: "cam-1",
+				ClientData: &proto.ClientData{Authenticated: true}}
+			analytics, video := 0, 0
+			for i := 0; i < frames; i++ {
+				for _, endpoint := range registry.GetServicesForFrame(frame) {
+					switch endpoint.Service {
+					case "analytics":
+						analytics++
+					case "video_processing":
+						video++
+					}
+				}
+			}
+
+			if analytics < tt.min || analytics > tt.max {
+				t.Errorf("analytics got %d of %d frames, want %d..%d", analytics, frames, tt.min, tt.max)
+			}
+			// Типы без настройки выборки получают все фреймы
+			if video != frames {
+				t.Errorf("video_processing got %d of %d frames", video, frames)
+			}
+
+			stats := registry.sampling.Stats()["analytics"].(map[string]interface{})
+			if stats["total"] != int64(frames) || stats["sampled"] != int64(analytics) {
+				t.Errorf("sampling stats = %v, want total %d, sampled %d", stats, frames, analytics)
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package gateway
 
 import (
-	"api-gateway/proto"
+	"api-gateway/pkg/proto"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"sync"
@@ -12,28 +16,48 @@ import (
 	"github.com/rs/cors"
 
 	"api-gateway/internal/config"
+	"api-gateway/internal/requestid"
+	"api-gateway/internal/retry"
 )
 
 // APIGateway основной шлюз
 type APIGateway struct {
-	config     *config.Config
-	clientMgr  *ClientManager
-	services   *ServiceRegistry
-	stats      *GatewayStats
-	statsMutex sync.RWMutex
+	config      *config.Config
+	clientMgr   *ClientManager
+	services    *ServiceRegistry
+	sendPool    *SendPool
+	batcher     *FrameBatcher
+	hooks       *HookRegistry
+	pipeline    *FramePipeline
+	events      *EventBus            // подписчики управляющих сообщений
+	producers   *StreamProducers     // производители стримов для управляющих запросов
+	streamStats *StreamStatsRegistry // счетчики фреймов по стримам для команды stats
+	replay      *ReplayBuffer        // последние ключевые кадры каналов (nil - выключено)
+	stats       *GatewayStats
+	statsMutex  sync.RWMutex
+	sink        StatsSink // внешний учет (memory, prometheus, statsd)
+
+	// Лимит управляющих команд на client_id (локальный или общий через Redis)
+	controlLimiter      ClientLimiter
+	closeControlLimiter func()
+	closePresence       func() // общий список клиентов реплик (client_store.backend redis)
+	channelAuth         ChannelAuthorizer
+	trustedProxies      trustedProxies // источники X-Forwarded-For (security.trusted_proxies)
 
 	// HTTP сервер
-	httpServer *http.Server
-	wsUpgrader websocket.Upgrader
+	httpServer   *http.Server
+	connSessions *connSessions // контексты соединений для асинхронных фреймов
+	wsUpgrader   websocket.Upgrader
 
 	// Каналы для обработки сообщений
-	videoChan   chan *proto.VideoFrame
+	videoChan   chan queuedFrame
 	controlChan chan *ControlMessage
 
 	// Контекст для graceful shutdown
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	sessions sync.WaitGroup // активные WebSocket сессии
 }
 
 type GatewayStats struct {
@@ -44,6 +68,9 @@ type GatewayStats struct {
 	BytesProcessed int64
 	ErrorCount     int64
 	ServiceHealth  map[string]bool
+
+	ClientFramesDropped int64 // устаревшие фреймы, выброшенные из переполненного SendChan клиента
+	FramesCancelled     int64 // фреймы, отмененные источником или по frame_timeout_ms до маршрутизации
 }
 
 type ControlMessage struct {
@@ -59,15 +86,44 @@ func NewAPIGateway(cfg *config.Config) (*APIGateway, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Создаем менеджер клиентов
-	clientMgr := NewClientManager()
+	clientMgr := NewClientManager(ClientLimits{
+		MaxConnections:          cfg.Gateway.MaxConnections,
+		MaxConnectionsPerIP:     cfg.Gateway.MaxConnectionsPerIP,
+		MaxConnectionsPerClient: cfg.Gateway.MaxConnectionsPerClient,
+		SendBufferSize:          cfg.Gateway.SendBufferSize,
+		ClientSendBuffers:       cfg.Gateway.ClientSendBuffers,
+	})
+
+	sink, err := NewStatsSink(cfg)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create stats sink: %w", err)
+	}
+
+	proxies, err := parseTrustedProxies(cfg.Security.TrustedProxies)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
 
 	// Создаем реестр сервисов
-	serviceRegistry := NewServiceRegistry(cfg)
+	serviceRegistry := NewServiceRegistry(cfg, sink)
 
 	gateway := &APIGateway{
 		config:    cfg,
 		clientMgr: clientMgr,
 		services:  serviceRegistry,
+		sendPool:  NewSendPool(serviceRegistry, cfg.Gateway.SendWorkers, cfg.Gateway.SendQueueSize, cfg.GetEnqueueTimeout(), serviceRetryPolicy(cfg), cfg.GetFailRateWindow()),
+		hooks:     NewHookRegistry(),
+		pipeline:  NewFramePipeline(cfg.Pipeline),
+		events:    NewEventBus(),
+		producers: NewStreamProducers(),
+		sink:      sink,
+
+		streamStats:  NewStreamStatsRegistry(),
+		connSessions: newConnSessions(),
+
+		trustedProxies: proxies,
 		stats: &GatewayStats{
 			StartTime:     time.Now(),
 			ServiceHealth: make(map[string]bool),
@@ -88,12 +144,32 @@ func NewAPIGateway(cfg *config.Config) (*APIGateway, error) {
 				return false
 			},
 		},
-		videoChan:   make(chan *proto.VideoFrame, cfg.Gateway.BufferSize),
+		videoChan:   make(chan queuedFrame, cfg.Gateway.BufferSize),
 		controlChan: make(chan *ControlMessage, 100),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
 
+	gateway.controlLimiter, gateway.closeControlLimiter = newClientLimiter(cfg, cfg.Gateway.ControlRateLimit)
+	gateway.channelAuth = NewConfigChannelAuthorizer(cfg)
+	presence, closePresence := newClientPresence(cfg)
+	gateway.closePresence = closePresence
+	if presence != nil {
+		clientMgr.SetPresence(presence)
+	}
+	if unknown := gateway.pipeline.UnknownProcessors(); len(unknown) > 0 {
+		log.Printf("Custom frame processors %v are not registered and will be skipped until registered", unknown)
+	}
+	if cfg.Gateway.ReplayEnabled {
+		gateway.replay = NewReplayBuffer(cfg.Gateway.ReplayFrames, cfg.Gateway.KeyframeFormats)
+		clientMgr.SetReplayBuffer(gateway.replay)
+	}
+	gateway.registerDefaultSubscribers()
+
+	// Запускаем пул отправки в сервисы
+	gateway.sendPool.Start(ctx)
+	gateway.batcher = NewFrameBatcher(ctx, gateway.sendPool, cfg.Services.Batching)
+
 	// Запускаем обработчики сообщений
 	gateway.startMessageProcessors()
 
@@ -118,19 +194,25 @@ func (g *APIGateway) Start() error {
 			AllowedOrigins:   g.config.Security.AllowedOrigins,
 			AllowedMethods:   g.config.Security.AllowedMethods,
 			AllowedHeaders:   g.config.Security.AllowedHeaders,
+			ExposedHeaders:   []string{requestid.Header},
 			AllowCredentials: true,
 			MaxAge:           86400,
 		})
 		handler = corsHandler.Handler(mux)
 	}
+	handler = withRequestID(handler)
 
 	// Создаем HTTP сервер
 	g.httpServer = &http.Server{
-		Addr:         g.config.Server.HTTPPort,
+		Addr:         g.config.Addr(),
 		Handler:      handler,
 		ReadTimeout:  g.config.GetReadTimeout(),
 		WriteTimeout: g.config.GetWriteTimeout(),
 		IdleTimeout:  g.config.GetIdleTimeout(),
+		Protocols:    g.config.GetHTTPProtocols(),
+		HTTP2:        g.config.GetHTTP2Config(),
+		ConnContext:  g.connSessions.connContext,
+		ConnState:    g.connSessions.connState,
 	}
 
 	// Запускаем сервер в горутине
@@ -138,7 +220,7 @@ func (g *APIGateway) Start() error {
 	go func() {
 		defer g.wg.Done()
 
-		log.Printf("Starting HTTP server on %s", g.config.Server.HTTPPort)
+		log.Printf("Starting HTTP server on %s", g.config.Addr())
 
 		var err error
 		if g.config.Server.EnableTLS && g.config.Server.TLSCert != "" && g.config.Server.TLSKey != "" {
@@ -153,11 +235,21 @@ func (g *APIGateway) Start() error {
 	}()
 
 	log.Printf("API Gateway started successfully")
-	log.Printf("HTTP: %s, WebSocket: %s", g.config.Server.HTTPPort, g.config.Server.WebSocketPort)
+	log.Printf("HTTP and WebSocket: %s", g.config.Addr())
 
 	return nil
 }
 
+// withRequestID берет X-Request-ID запроса (или генерирует новый), кладет
+// его в контекст для запросов к сервисам и возвращает в ответе
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestid.FromRequest(r)
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
 // Stop останавливает API Gateway
 func (g *APIGateway) Stop() {
 	log.Println("Shutting down API Gateway...")
@@ -175,8 +267,8 @@ func (g *APIGateway) Stop() {
 		}
 	}
 
-	// Закрываем все соединения
-	g.clientMgr.CloseAll()
+	// Закрываем все соединения, предупредив клиентов
+	g.closeClientsOnShutdown()
 	g.services.Close()
 
 	// Закрываем каналы
@@ -185,10 +277,44 @@ func (g *APIGateway) Stop() {
 
 	// Ждем завершения всех горутин
 	g.wg.Wait()
+	g.batcher.Stop()
+	g.sendPool.Stop()
+	g.closeControlLimiter()
+	g.closePresence()
+	if closer, ok := g.sink.(io.Closer); ok {
+		closer.Close()
+	}
 
 	log.Println("API Gateway stopped gracefully")
 }
 
+// closeClientsOnShutdown отправляет WebSocket клиентам уведомление и close
+// фрейм GoingAway с рекомендуемой задержкой переподключения, затем ждет,
+// пока сессии успеют их записать
+func (g *APIGateway) closeClientsOnShutdown() {
+	delay := g.config.GetShutdownReconnectDelay()
+	reason := fmt.Sprintf("server shutting down, reconnect after %ds", int(delay.Seconds()))
+
+	g.clientMgr.CloseAll(websocket.CloseGoingAway, reason, map[string]interface{}{
+		"action":             "server_shutdown",
+		"message":            "server shutting down",
+		"reconnect_after_ms": delay.Milliseconds(),
+		"time":               time.Now().Unix(),
+	})
+
+	done := make(chan struct{})
+	go func() {
+		g.sessions.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		log.Println("Timed out waiting for WebSocket sessions to close")
+	}
+}
+
 // startMessageProcessors запускает обработчики сообщений
 func (g *APIGateway) startMessageProcessors() {
 	// Обработчик видеофреймов
@@ -210,8 +336,15 @@ func (g *APIGateway) startMessageProcessors() {
 func (g *APIGateway) processVideoFrames() {
 	for {
 		select {
-		case frame := <-g.videoChan:
-			g.handleVideoFrame(frame)
+		case queued, ok := <-g.videoChan:
+			if !ok {
+				return
+			}
+			// Контекст освобождается, когда завершены маршрутизация и
+			// все поставленные ею отправки
+			ctx, release := withFrameRelease(queued.ctx, queued.cancel)
+			g.handleVideoFrame(ctx, queued.frame)
+			release.done()
 		case <-g.ctx.Done():
 			return
 		}
@@ -230,81 +363,154 @@ func (g *APIGateway) processControlMessages() {
 	}
 }
 
-// handleVideoFrame обрабатывает видеофрейм
-func (g *APIGateway) handleVideoFrame(frame *proto.VideoFrame) {
+// handleVideoFrame обрабатывает видеофрейм. Фрейм, контекст которого
+// отменен или истек, пока он ждал в очереди, не маршрутизируется.
+func (g *APIGateway) handleVideoFrame(ctx context.Context, frame *proto.VideoFrame) {
+	if ctx.Err() != nil {
+		g.statsMutex.Lock()
+		g.stats.FramesCancelled++
+		g.statsMutex.Unlock()
+		return
+	}
+
 	g.statsMutex.Lock()
 	g.stats.TotalFrames++
 	g.stats.BytesProcessed += int64(len(frame.FrameData))
 	g.statsMutex.Unlock()
+	g.sink.RecordFrame(frame.ClientID, len(frame.FrameData))
+	g.recordProducer(ctx, frame.CameraID)
+	g.streamStats.Record(frame.CameraID, len(frame.FrameData))
+
+	// Пользовательская предобработка; ошибка хука отменяет отправку
+	if err := g.hooks.RunPreForward(ctx, frame); err != nil {
+		g.rejectFrame(frame, err)
+		return
+	}
 
-	// Маршрутизируем фрейм в сервисы
-	g.routeFrameToServices(frame)
+	err := g.pipeline.Run(ctx, frame, func(ctx context.Context, frame *proto.VideoFrame) error {
+		// Маршрутизируем фрейм в сервисы
+		results := g.routeFrameToServices(ctx, frame)
 
-	// Рассылаем фрейм подписанным клиентам
-	g.broadcastFrameToClients(frame)
+		// Рассылаем фрейм подписанным клиентам
+		g.broadcastFrameToClients(frame)
+
+		g.hooks.RunPostForward(ctx, frame, results)
+		return nil
+	})
+	var dropped *FrameDroppedError
+	if err != nil && !errors.As(err, &dropped) {
+		g.rejectFrame(frame, err)
+	}
 }
 
-// routeFrameToServices маршрутизирует фрейм в сервисы
-func (g *APIGateway) routeFrameToServices(frame *proto.VideoFrame) {
-	services := g.services.GetServicesForFrame(frame)
+// rejectFrame учитывает фрейм, отклоненный хуком
+func (g *APIGateway) rejectFrame(frame *proto.VideoFrame, err error) {
+	log.Printf("Frame %s rejected: %v", frame.FrameID, err)
+	g.statsMutex.Lock()
+	g.stats.ErrorCount++
+	g.statsMutex.Unlock()
+}
 
-	for _, service := range services {
-		g.wg.Add(1)
-		go func(svc *ServiceEndpoint) {
-			defer g.wg.Done()
-			g.sendToService(svc, frame)
-		}(service)
+// ResetStats обнуляет счетчики GatewayStats, статистику сервисов и
+// MemoryStatsSink, сохраняя StartTime и состояние здоровья сервисов.
+// Счетчики Prometheus/StatsD монотонны и не сбрасываются.
+func (g *APIGateway) ResetStats() {
+	g.statsMutex.Lock()
+	defer g.statsMutex.Unlock()
+
+	*g.stats = GatewayStats{
+		StartTime:     g.stats.StartTime,
+		ServiceHealth: g.stats.ServiceHealth,
+	}
+	g.services.ResetStats()
+	if memory, ok := g.sink.(*MemoryStatsSink); ok {
+		memory.Reset()
 	}
 }
 
-// sendToService отправляет фрейм в сервис
-func (g *APIGateway) sendToService(service *ServiceEndpoint, frame *proto.VideoFrame) {
-	ctx, cancel := context.WithTimeout(g.ctx, 10*time.Second)
-	defer cancel()
+// Hooks возвращает реестр хуков обработки фреймов
+func (g *APIGateway) Hooks() *HookRegistry {
+	return g.hooks
+}
+
+// Pipeline возвращает конвейер процессоров фреймов
+func (g *APIGateway) Pipeline() *FramePipeline {
+	return g.pipeline
+}
 
-	g.services.SendToService(ctx, service, frame)
+// Events возвращает шину управляющих сообщений для подписки компонентов
+func (g *APIGateway) Events() *EventBus {
+	return g.events
 }
 
-// broadcastFrameToClients рассылает фрейм клиентам
-func (g *APIGateway) broadcastFrameToClients(frame *proto.VideoFrame) {
-	clients := g.clientMgr.GetClientsByChannel(frame.CameraID)
+// routeFrameToServices ставит отправку фрейма в сервисы в очередь пула.
+// При заполненной очереди вызов ждет не дольше Gateway.EnqueueTimeoutMs,
+// затем фрейм для сервиса отбрасывается. Возвращает результат постановки
+// по типам сервисов.
+func (g *APIGateway) routeFrameToServices(ctx context.Context, frame *proto.VideoFrame) map[string]string {
+	services := g.services.GetServicesForFrame(frame)
+	results := make(map[string]string)
+	g.mirrorToShadows(ctx, frame, services)
 
-	for _, client := range clients {
-		g.wg.Add(1)
-		go func(cl *ClientInfo) {
-			defer g.wg.Done()
-			g.sendFrameToClient(cl, frame)
-		}(client)
+	for _, service := range services {
+		if g.batcher.Add(service, frame) {
+			results[service.Service] = "batched"
+			continue
+		}
+		if err := g.sendPool.Submit(ctx, service, frame); err != nil {
+			results[service.Service] = "error: " + err.Error()
+			if errors.Is(err, ErrSendQueueFull) {
+				continue
+			}
+			return results
+		}
+		results[service.Service] = "queued"
 	}
+	return results
 }
 
-// sendFrameToClient отправляет фрейм конкретному клиенту
-func (g *APIGateway) sendFrameToClient(client *ClientInfo, frame *proto.VideoFrame) {
-	select {
-	case client.SendChan <- frame:
-		// Успешно отправлено
-	default:
-		// Канал полон
-		log.Printf("Client %s channel full, dropping frame", client.ID)
+// mirrorToShadows ставит в пул копии фрейма для выбранных теневых
+// эндпоинтов. Ответы теневых эндпоинтов не попадают в результат.
+func (g *APIGateway) mirrorToShadows(ctx context.Context, frame *proto.VideoFrame, services []*ServiceEndpoint) {
+	for _, shadow := range g.services.ShadowServicesForFrame(services) {
+		g.sendPool.SubmitShadow(ctx, shadow, frame)
 	}
 }
 
-// handleControlMessage обрабатывает контрольные сообщения
+// broadcastFrameToClients рассылает фрейм клиентам, которым он доступен по
+// тегам. Фрейм сериализуется один раз, байты общие для всех получателей.
+func (g *APIGateway) broadcastFrameToClients(frame *proto.VideoFrame) {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("Failed to marshal frame %s: %v", frame.FrameID, err)
+		return
+	}
+
+	keyframe := g.replay != nil && g.replay.IsKeyframe(frame)
+	_, dropped := g.clientMgr.BroadcastFrame(frame.CameraID, frame.AccessTags, data, keyframe)
+	if dropped > 0 {
+		g.statsMutex.Lock()
+		g.stats.ClientFramesDropped += int64(dropped)
+		g.statsMutex.Unlock()
+	}
+}
+
+// handleControlMessage передает контрольное сообщение подписчикам шины
 func (g *APIGateway) handleControlMessage(msg *ControlMessage) {
-	switch msg.Type {
-	case "client_connect":
-		g.handleClientConnect(msg)
-	case "client_disconnect":
-		g.handleClientDisconnect(msg)
-	case "subscribe":
-		g.handleSubscribe(msg)
-	case "unsubscribe":
-		g.handleUnsubscribe(msg)
-	case "service_health":
-		g.handleServiceHealth(msg)
+	if g.events.Publish(msg) == 0 {
+		log.Printf("No subscribers for control message %s", msg.Type)
 	}
 }
 
+// serviceRetryPolicy политика повторов отправки фреймов в сервисы
+func serviceRetryPolicy(cfg *config.Config) retry.Policy {
+	policy := retry.DefaultPolicy
+	policy.MaxAttempts = cfg.Services.Retry.MaxAttempts
+	policy.InitialBackoff = cfg.GetRetryInitialBackoff()
+	policy.MaxBackoff = cfg.GetRetryMaxBackoff()
+	return policy
+}
+
 // startBackgroundTasks запускает фоновые задачи
 func (g *APIGateway) startBackgroundTasks() {
 	// Очистка неактивных клиентов
@@ -318,6 +524,11 @@ func (g *APIGateway) startBackgroundTasks() {
 			select {
 			case <-ticker.C:
 				g.clientMgr.CleanupInactiveClients(g.config.GetSessionTimeout())
+				g.producers.Cleanup(g.config.GetSessionTimeout())
+				g.streamStats.Cleanup(g.config.GetSessionTimeout())
+				if g.replay != nil {
+					g.replay.Cleanup(g.config.GetSessionTimeout())
+				}
 			case <-g.ctx.Done():
 				return
 			}
@@ -342,13 +553,140 @@ func (g *APIGateway) startBackgroundTasks() {
 	}()
 }
 
-// HandleVideoFrame добавляет видеофрейм в очередь обработки
-func (g *APIGateway) HandleVideoFrame(frame *proto.VideoFrame) {
+// RenameChannel переименовывает канал и уведомляет перенесенных подписчиков.
+// Возвращает количество перенесенных подписок.
+func (g *APIGateway) RenameChannel(oldChannel, newChannel string) (int, error) {
+	migrated, err := g.clientMgr.RenameChannel(oldChannel, newChannel)
+	if err != nil {
+		return 0, err
+	}
+
+	event := map[string]interface{}{
+		"action":      "channel_renamed",
+		"old_channel": oldChannel,
+		"channel":     g.clientMgr.ResolveChannel(newChannel),
+		"time":        time.Now().Unix(),
+	}
+	for _, client := range migrated {
+		g.clientMgr.NotifyClient(client, event)
+	}
+
+	return len(migrated), nil
+}
+
+// ProcessFrameSync обрабатывает фрейм синхронно: отправляет его во все
+// сервисы, дожидается ответов и возвращает результат по типу сервиса
+// ("ok" или "error: ..."). Если эндпоинтов одного типа несколько, тип
+// считается успешным, только когда успешны все. Если фрейм отклонен
+// pre-forward хуком, результат содержит единственный ключ "pre_forward".
+func (g *APIGateway) ProcessFrameSync(ctx context.Context, frame *proto.VideoFrame) map[string]string {
+	g.statsMutex.Lock()
+	g.stats.TotalFrames++
+	g.stats.BytesProcessed += int64(len(frame.FrameData))
+	g.statsMutex.Unlock()
+	g.sink.RecordFrame(frame.ClientID, len(frame.FrameData))
+	g.recordProducer(ctx, frame.CameraID)
+	g.streamStats.Record(frame.CameraID, len(frame.FrameData))
+
+	if err := g.hooks.RunPreForward(ctx, frame); err != nil {
+		g.rejectFrame(frame, err)
+		return map[string]string{"pre_forward": "error: " + err.Error()}
+	}
+
+	var results map[string]string
+	err := g.pipeline.Run(ctx, frame, func(ctx context.Context, frame *proto.VideoFrame) error {
+		results = g.sendFrameSync(ctx, frame)
+		return nil
+	})
+	var dropped *FrameDroppedError
+	switch {
+	case errors.As(err, &dropped):
+		return map[string]string{"pipeline": "dropped by " + dropped.Processor}
+	case err != nil:
+		g.rejectFrame(frame, err)
+		return map[string]string{"pipeline": "error: " + err.Error()}
+	}
+	return results
+}
+
+// sendFrameSync отправляет фрейм во все сервисы, дожидаясь ответов, и
+// рассылает его клиентам
+func (g *APIGateway) sendFrameSync(ctx context.Context, frame *proto.VideoFrame) map[string]string {
+	services := g.services.GetServicesForFrame(frame)
+	errs := make([]error, len(services))
+	g.mirrorToShadows(ctx, frame, services)
+
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Add(1)
+		go func(i int, service *ServiceEndpoint) {
+			defer wg.Done()
+
+			sendCtx, cancel := g.services.WithServiceTimeout(ctx, service.Service)
+			defer cancel()
+			errs[i] = g.services.SendToService(sendCtx, service, frame)
+			if errs[i] != nil && sendCtx.Err() == context.DeadlineExceeded {
+				errs[i] = context.DeadlineExceeded
+			}
+		}(i, service)
+	}
+	wg.Wait()
+
+	results := make(map[string]string)
+	for i, service := range services {
+		if _, seen := results[service.Service]; !seen {
+			results[service.Service] = "ok"
+		}
+		if errs[i] != nil && results[service.Service] == "ok" {
+			if errs[i] == context.DeadlineExceeded {
+				results[service.Service] = "error: timeout"
+			} else {
+				results[service.Service] = "error: " + errs[i].Error()
+			}
+		}
+	}
+
+	g.broadcastFrameToClients(frame)
+	g.hooks.RunPostForward(ctx, frame, results)
+
+	return results
+}
+
+// queuedFrame фрейм в очереди обработки с контекстом принявшего его запроса
+type queuedFrame struct {
+	ctx    context.Context
+	cancel context.CancelFunc // освобождает ctx после обработки фрейма
+	frame  *proto.VideoFrame
+}
+
+// HandleVideoFrame добавляет видеофрейм в очередь обработки. ctx
+// передается в маршрутизацию и запросы к сервисам: после его отмены
+// фрейм больше не отправляется, начатые запросы прерываются. Отправитель
+// для запросов ключевого кадра задается WithFrameProducer.
+func (g *APIGateway) HandleVideoFrame(ctx context.Context, frame *proto.VideoFrame) {
+	g.enqueueVideoFrame(ctx, func() {}, frame)
+}
+
+// enqueueVideoFrame добавляет фрейм в очередь с контекстом ctx,
+// ограниченным Gateway.FrameTimeoutMs. release вызывается, когда фрейм
+// обработан или отброшен.
+func (g *APIGateway) enqueueVideoFrame(ctx context.Context, release context.CancelFunc, frame *proto.VideoFrame) {
+	queued := queuedFrame{ctx: ctx, cancel: release, frame: frame}
+	if timeout := g.config.GetFrameTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		queued.ctx, cancel = context.WithTimeout(ctx, timeout)
+		queued.cancel = func() {
+			cancel()
+			release()
+		}
+	}
+
 	select {
-	case g.videoChan <- frame:
+	case g.videoChan <- queued:
 		// Успешно добавлено
 	default:
 		// Очередь переполнена
+		queued.cancel()
 		log.Printf("Video channel full, dropping frame")
 		g.statsMutex.Lock()
 		g.stats.ErrorCount++
@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+func TestProcessFrameSyncResultMap(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	slow, started, _ := blockingService(t)
+
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Services.VideoProcessing = []string{ok.URL}
+		cfg.Services.Storage = []string{failing.URL}
+		cfg.Services.Analytics = []string{slow.URL}
+		cfg.Services.Notification = nil
+		cfg.Services.Retry.MaxAttempts = 1
+		cfg.Services.Timeouts = map[string]int{"analytics": 100}
+	})
+
+	// Аналитика получает только фреймы аутентифицированных клиентов
+	frame := &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", ClientID: "cam-1",
+		ClientData: &proto.ClientData{Authenticated: true}}
+	results := g.ProcessFrameSync(context.Background(), frame)
+	waitSignal(t, started, "analytics request")
+
+	if !strings.HasPrefix(results["storage"], "error: ") {
+		t.Errorf("storage result = %q, want error", results["storage"])
+	}
+	delete(results, "storage")
+	want := map[string]string{"video_processing": "ok", "analytics": "error: timeout"}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %v, want %v plus storage error", results, want)
+	}
+}
+
+func BenchmarkBroadcastFrameToClients(b *testing.B) {
+	for _, subscribers := range []int{1, 100} {
+		b.Run(fmt.Sprintf("subscribers=%d", subscribers), func(b *testing.B) {
+			cfg := config.GetDefaultConfig()
+			cfg.JWT.Secret = testJWTSecret
+			g, err := NewAPIGateway(cfg)
+			if err != nil {
+				b.Fatalf("NewAPIGateway: %v", err)
+			}
+			defer g.cancel()
+			for i := 0; i < subscribers; i++ {
+				client, err := g.clientMgr.RegisterClient(fmt.Sprintf("viewer-%d", i), fmt.Sprintf("10.0.%d.%d", i/256, i%256), "bench")
+				if err != nil {
+					b.Fatalf("RegisterClient: %v", err)
+				}
+				g.clientMgr.SubscribeClient(client.ConnectionID, "cam-1")
+			}
+
+			// Аллокации на фрейм не растут с числом подписчиков
+			frame := &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", FrameData: "AAECAw==", Format: "jpeg"}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				g.broadcastFrameToClients(frame)
+			}
+		})
+	}
+}
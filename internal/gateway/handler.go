@@ -1,11 +1,14 @@
 package gateway
 
 import (
-	"api-gateway/proto"
+	"api-gateway/pkg/proto"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,10 +20,26 @@ func (g *APIGateway) setupHTTPHandlers(mux *http.ServeMux) {
 	// API эндпоинты
 	mux.HandleFunc("/api/v1/video/stream", g.handleVideoStream)
 	mux.HandleFunc("/api/v1/video/info", g.handleVideoInfo)
-	mux.HandleFunc("/api/v1/clients", g.handleClients)
+	mux.HandleFunc("/api/v1/video/stream/", g.handleStreamControl)
+	mux.HandleFunc("/api/v1/clients", g.requireAdmin(g.handleClients))
+	mux.HandleFunc("/api/v1/clients/", g.requireAdmin(g.handleClientConnection))
 	mux.HandleFunc("/api/v1/stats", g.handleStats)
+	mux.HandleFunc("/api/v1/stats/reset", g.requireAdmin(g.handleStatsReset))
 	mux.HandleFunc("/api/v1/health", g.handleHealth)
 
+	// Метрики Prometheus (metrics.backend: prometheus)
+	if metrics, ok := g.sink.(http.Handler); ok {
+		mux.Handle("/metrics", metrics)
+	}
+
+	// Админские эндпоинты
+	mux.HandleFunc("/api/v1/admin/channels/aliases", g.requireAdmin(g.handleChannelAliases))
+	mux.HandleFunc("/api/v1/admin/services", g.requireAdmin(g.handleAdminServices))
+	mux.HandleFunc("/api/v1/admin/services/", g.requireAdmin(g.handleAdminServiceBreakers))
+
+	// Сквозной прокси к сервисам (proxy.routes)
+	mux.HandleFunc(proxyPathPrefix, g.handleProxy)
+
 	// WebSocket
 	mux.HandleFunc("/ws/video", g.handleWebSocketVideo)
 	mux.HandleFunc("/ws/control", g.handleWebSocketControl)
@@ -53,8 +72,7 @@ func (g *APIGateway) handleRoot(w http.ResponseWriter, r *http.Request) {
     
     <div class="card">
         <h3>Status: <span style="color: green;">● Running</span></h3>
-        <p>HTTP Port: ` + g.config.Server.HTTPPort + `</p>
-        <p>WebSocket Port: ` + g.config.Server.WebSocketPort + `</p>
+        <p>HTTP/WebSocket: ` + g.config.Addr() + `</p>
     </div>
     
     <div class="card">
@@ -62,6 +80,7 @@ func (g *APIGateway) handleRoot(w http.ResponseWriter, r *http.Request) {
         <ul>
             <li><strong>POST /api/v1/video/stream</strong> - Send video frame</li>
             <li><strong>POST /api/v1/video/info</strong> - Video metadata</li>
+            <li><strong>POST /api/v1/video/stream/{stream_id}/keyframe-request</strong> - Request a keyframe from the producer</li>
             <li><strong>GET /api/v1/clients</strong> - Connected clients</li>
             <li><strong>GET /api/v1/stats</strong> - Gateway statistics</li>
             <li><strong>GET /api/v1/health</strong> - Health check</li>
@@ -148,15 +167,39 @@ func (g *APIGateway) handleVideoStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	claims, err := g.authenticateRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// Не принимаем фреймы, если обязательный сервис недоступен
+	if unavailable := g.services.UnavailableServiceTypes(g.config.Services.Required); len(unavailable) > 0 {
+		w.Header().Set("Retry-After", "30")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":               "error",
+			"message":              "Required downstream services are unavailable",
+			"unavailable_services": unavailable,
+		})
+		return
+	}
+
 	// Проверяем размер контента
-	if r.ContentLength > int64(g.config.Gateway.MaxFrameSize) {
+	maxFrameSize := int64(g.config.Video.MaxFrameSize)
+	if r.ContentLength > maxFrameSize {
 		http.Error(w, "Frame too large", http.StatusRequestEntityTooLarge)
 		return
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxFrameSize)
 
 	// Читаем тело
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Frame too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Failed to read request", http.StatusBadRequest)
 		return
 	}
@@ -175,18 +218,60 @@ func (g *APIGateway) handleVideoStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Устанавливаем ClientID если не указан
+	// Производитель стрима - аутентифицированный клиент, без токена -
+	// адрес соединения; client_id тела не может указывать на чужого клиента
+	producer := g.clientIP(r)
+	if claims != nil {
+		producer = claimsClientID(claims)
+		if frame.ClientID != "" && frame.ClientID != producer {
+			http.Error(w, "client_id does not match the access token", http.StatusForbidden)
+			return
+		}
+		frame.ClientID = producer
+	}
 	if frame.ClientID == "" {
-		frame.ClientID = getIPAddress(r)
+		frame.ClientID = producer
+	}
+	// Этот путь подпись кадров не проверяет: подписывающие edge устройства
+	// отправляют кадры через POST /api/v1/video/frame
+	if _, signing := g.config.Auth.FrameSigningKeys[frame.ClientID]; signing {
+		http.Error(w, "frames of this client must be signed, use POST /api/v1/video/frame", http.StatusUnauthorized)
+		return
 	}
+	ctx := WithFrameProducer(r.Context(), producer)
 
 	// Обновляем статистику
 	g.statsMutex.Lock()
 	g.stats.TotalRequests++
 	g.statsMutex.Unlock()
 
-	// Обрабатываем фрейм
-	g.HandleVideoFrame(&frame)
+	// ?sync=true - дожидаемся сервисов и возвращаем результат по каждому
+	if r.URL.Query().Get("sync") == "true" {
+		results := g.ProcessFrameSync(ctx, &frame)
+
+		status := "success"
+		for _, result := range results {
+			if result != "ok" {
+				status = "partial"
+				break
+			}
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":    status,
+			"message":   "Frame processed",
+			"frame_id":  frame.FrameID,
+			"timestamp": time.Now().Unix(),
+			"results":   results,
+		})
+		return
+	}
+
+	// Обрабатываем фрейм. Ответ уходит сразу, поэтому фрейм живет дольше
+	// запроса: отправка прерывается закрытием соединения клиента или
+	// по frame_timeout_ms
+	frameCtx, release := sessionContext(ctx)
+	g.enqueueVideoFrame(frameCtx, release, &frame)
 
 	// Отправляем ответ
 	response := map[string]interface{}{
@@ -201,6 +286,65 @@ func (g *APIGateway) handleVideoStream(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleStreamControl обрабатывает управляющие запросы к стриму:
+// POST /api/v1/video/stream/{stream_id}/keyframe-request. Права те же, что
+// у команды keyframe_request по WebSocket: доступ на подписку к стриму.
+func (g *APIGateway) handleStreamControl(w http.ResponseWriter, r *http.Request) {
+	streamID, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/video/stream/"), "/")
+	if !ok || streamID == "" || action != "keyframe-request" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, err := g.authenticateRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	requestedBy := g.clientIP(r)
+	if claims != nil {
+		requestedBy = claimsClientID(claims)
+	}
+	session := &WebSocketSession{ClientInfo: &ClientInfo{ID: requestedBy, Claims: claims}}
+	if !g.canSubscribe(session, streamID) {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"status":    "error",
+			"message":   "Access to stream denied",
+			"stream_id": streamID,
+		})
+		return
+	}
+
+	producerID, known := g.producers.Producer(streamID)
+	if !known {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"status":    "error",
+			"message":   "No producer for stream",
+			"stream_id": streamID,
+		})
+		return
+	}
+
+	if !g.RequestKeyframe(streamID, requestedBy) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":  "error",
+			"message": "Control queue is full",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"status":    "accepted",
+		"stream_id": streamID,
+		"producer":  producerID,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
 // handleVideoInfo обрабатывает информацию о видео
 func (g *APIGateway) handleVideoInfo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -244,24 +388,17 @@ func (g *APIGateway) handleVideoInfo(w http.ResponseWriter, r *http.Request) {
 func (g *APIGateway) handleClients(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		clients := g.clientMgr.GetAllClients()
-
-		var clientList []map[string]interface{}
-		for _, client := range clients {
-			channels := make([]string, 0, len(client.Channels))
-			for ch := range client.Channels {
-				channels = append(channels, ch)
-			}
-
-			clientList = append(clientList, map[string]interface{}{
-				"id":            client.ID,
-				"connection_id": client.ConnectionID,
-				"ip_address":    client.IPAddress,
-				"connected_at":  client.ConnectedAt,
-				"last_seen":     client.LastSeen,
-				"is_active":     client.IsActive,
-				"channels":      channels,
-			})
+		// ?channel= оставляет только подписчиков канала (с учетом алиасов),
+		// ?user_id= - соединения пользователя
+		var clientList []ClientSummary
+		query := r.URL.Query()
+		switch {
+		case query.Get("channel") != "":
+			clientList = g.clientMgr.FindByChannel(query.Get("channel"))
+		case query.Get("user_id") != "":
+			clientList = g.clientMgr.FindByUser(query.Get("user_id"))
+		default:
+			clientList = g.clientMgr.findClients()
 		}
 
 		response := map[string]interface{}{
@@ -273,6 +410,52 @@ func (g *APIGateway) handleClients(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 
+	case "DELETE":
+		// Отключение всех соединений логического клиента
+		clientID := r.URL.Query().Get("client_id")
+		if clientID == "" {
+			http.Error(w, "client_id is required", http.StatusBadRequest)
+			return
+		}
+
+		removed := g.clientMgr.DisconnectClientByID(clientID, "disconnected by administrator")
+		if len(removed) == 0 {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":         "success",
+			"client_id":      clientID,
+			"connection_ids": removed,
+			"count":          len(removed),
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleClientConnection обрабатывает запросы к конкретному соединению
+func (g *APIGateway) handleClientConnection(w http.ResponseWriter, r *http.Request) {
+	connID := strings.TrimPrefix(r.URL.Path, "/api/v1/clients/")
+	if connID == "" || strings.Contains(connID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "DELETE":
+		if !g.clientMgr.DisconnectClient(connID, "disconnected by administrator") {
+			http.Error(w, "Connection not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":        "success",
+			"connection_id": connID,
+		})
+
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -294,60 +477,340 @@ func (g *APIGateway) handleStats(w http.ResponseWriter, r *http.Request) {
 			"total_requests":  stats.TotalRequests,
 			"total_frames":    stats.TotalFrames,
 			"active_clients":  stats.ActiveClients,
+			"suspended":       g.clientMgr.GetSuspendedClientCount(),
 			"bytes_processed": stats.BytesProcessed,
 			"error_count":     stats.ErrorCount,
-			"frame_rate":      stats.FrameRate,
+			"client_dropped":  stats.ClientFramesDropped,
+			"cancelled":       stats.FramesCancelled,
+			"frame_rate":      stats.FrameRate(),
 			"services_health": g.services.GetHealthStatus(),
+			"partners":        g.services.PartnerStats(),
+			"sampling":        g.services.SamplingStats(),
+			"pipeline":        g.pipeline.Stats(),
 			"queue_size":      len(g.videoChan),
+			"channels":        g.clientMgr.CountByChannel(),
+			"send_pool":       g.sendPool.Stats(),
 		},
 		"timestamp": time.Now().Unix(),
 	}
 
+	if memory, ok := g.sink.(*MemoryStatsSink); ok {
+		response["metrics"] = memory.Snapshot()
+	}
+	if clients := g.clientMgr.ClusterClients(); clients != nil {
+		response["cluster_clients"] = len(clients)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// handleHealth обрабатывает проверку здоровья
+// handleHealth обрабатывает проверку здоровья. Итоговый статус - худший из
+// сигналов в "checks"; unhealthy отвечает 503, чтобы оркестратор снял трафик.
 func (g *APIGateway) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status, checks := g.evaluateHealth()
+
+	var reasons []string
+	for _, check := range checks {
+		if check.Reason != "" {
+			reasons = append(reasons, check.Reason)
+		}
+	}
+	sort.Strings(reasons)
+
 	health := map[string]interface{}{
-		"status":    "healthy",
+		"status":    status,
 		"service":   "api-gateway",
 		"version":   "1.0.0",
 		"timestamp": time.Now().Unix(),
 		"services":  g.services.GetHealthStatus(),
-	}
-
-	// Проверяем критичные компоненты
-	if len(g.videoChan) > g.config.Gateway.BufferSize*90/100 {
-		health["status"] = "degraded"
-		health["warning"] = "High queue load"
+		"checks":    checks,
+		"reasons":   reasons,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case status == HealthUnhealthy:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case status == HealthDegraded && g.config.Health.DegradedStatusCode != 0:
+		w.WriteHeader(g.config.Health.DegradedStatusCode)
+	}
 	json.NewEncoder(w).Encode(health)
 }
 
+// handleChannelAliases управляет алиасами каналов
+//
+//	GET    - список алиасов
+//	POST   - {"from": "old", "to": "new"} переименовать канал
+//	DELETE - ?from=old удалить алиас
+func (g *APIGateway) handleChannelAliases(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"aliases": g.clientMgr.GetChannelAliases(),
+		})
+
+	case "POST":
+		var req struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		migrated, err := g.RenameChannel(req.From, req.To)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":   "success",
+			"from":     req.From,
+			"to":       req.To,
+			"migrated": migrated,
+		})
+
+	case "DELETE":
+		from := r.URL.Query().Get("from")
+		if !g.clientMgr.RemoveChannelAlias(from) {
+			http.Error(w, "Alias not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "success",
+			"from":   from,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminServices управляет эндпоинтами сервисов во время работы
+//
+//	GET    - список эндпоинтов по типам
+//	POST   - {"type": "storage", "url": "...", "priority": 0, "accepted_statuses": [200, 202]}
+//	         добавить эндпоинт (accepted_statuses необязателен, по умолчанию 2xx)
+//	         {"id": "storage_1", "drain": true|false} вывести из ротации / вернуть
+//	DELETE - ?id=storage_1 удалить эндпоинт (&drain=true только вывести из ротации)
+func (g *APIGateway) handleAdminServices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":   "success",
+			"services": g.services.ListEndpoints(),
+		})
+
+	case "POST":
+		var req struct {
+			ID       string `json:"id"`
+			Drain    *bool  `json:"drain"`
+			Type     string `json:"type"`
+			URL      string `json:"url"`
+			Priority int    `json:"priority"`
+
+			AcceptedStatuses []int `json:"accepted_statuses"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if req.ID != "" {
+			if req.Drain == nil {
+				http.Error(w, "drain is required", http.StatusBadRequest)
+				return
+			}
+			if !g.services.SetEndpointHealthy(req.ID, !*req.Drain) {
+				http.Error(w, "Endpoint not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"status":  "success",
+				"id":      req.ID,
+				"drained": *req.Drain,
+			})
+			return
+		}
+
+		endpoint, err := g.services.AddEndpoint(req.Type, req.URL, req.Priority, req.AcceptedStatuses)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"status":   "success",
+			"id":       endpoint.ID,
+			"type":     req.Type,
+			"url":      endpoint.URL,
+			"priority": endpoint.Priority,
+		})
+
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		drain := r.URL.Query().Get("drain") == "true"
+
+		var found bool
+		if drain {
+			found = g.services.SetEndpointHealthy(id, false)
+		} else {
+			found = g.services.RemoveEndpoint(id)
+		}
+		if !found {
+			http.Error(w, "Endpoint not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"id":      id,
+			"drained": drain,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminServiceBreakers обслуживает прерыватели эндпоинтов:
+// GET /api/v1/admin/services/breakers - состояния,
+// POST /api/v1/admin/services/<id>/reset - принудительно закрыть
+func (g *APIGateway) handleAdminServiceBreakers(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/admin/services/")
+
+	if path == "breakers" {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":   "success",
+			"breakers": g.services.Breakers(),
+		})
+		return
+	}
+
+	id, ok := strings.CutSuffix(path, "/reset")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state, found := g.services.ResetBreaker(id)
+	if !found {
+		http.Error(w, "Endpoint not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Breaker of endpoint %s reset by administrator", id)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"breaker": state,
+	})
+}
+
+// handleStatsReset обнуляет счетчики шлюза и сервисов (например, перед
+// замером производительности); время старта и uptime не меняются
+func (g *APIGateway) handleStatsReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.ResetStats()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    "success",
+		"message":   "Statistics reset",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// requireAdmin пропускает только запросы с Bearer токеном администратора
+func (g *APIGateway) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := g.config.Gateway.AdminToken
+		if token == "" {
+			http.Error(w, "Admin API is disabled", http.StatusForbidden)
+			return
+		}
+
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
 // handleWebSocketVideo обрабатывает WebSocket для видео
 func (g *APIGateway) handleWebSocketVideo(w http.ResponseWriter, r *http.Request) {
-	conn, err := g.wsUpgrader.Upgrade(w, r, nil)
+	ip := g.clientIP(r)
+	clientID := r.URL.Query().Get("client_id")
+
+	// Аутентификация до регистрации клиента: отказ - close фрейм 1008
+	claims, viaProtocol, err := g.authenticateWebSocket(r)
+	if err == nil && claims != nil && claims.ClientID != "" {
+		if clientID != "" && clientID != claims.ClientID {
+			err = ErrInvalidToken
+		}
+		clientID = claims.ClientID
+	}
 	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
+		log.Printf("WebSocket authentication failed for %s: %v", ip, err)
+		g.sink.RecordClient(ClientRejected)
+		g.rejectWebSocket(w, r, websocket.ClosePolicyViolation, err.Error())
 		return
 	}
 
-	// Регистрируем клиента
-	clientID := r.URL.Query().Get("client_id")
 	if clientID == "" {
-		clientID = getIPAddress(r)
+		clientID = ip
 	}
 
-	clientInfo, err := g.clientMgr.RegisterClient(clientID, getIPAddress(r), r.UserAgent())
+	// Проверяем лимиты соединений до апгрейда, чтобы ответить 429
+	if err := g.clientMgr.CheckConnectionLimits(clientID, ip); err != nil {
+		log.Printf("WebSocket connection rejected for %s: %v", ip, err)
+		g.sink.RecordClient(ClientRejected)
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	conn, err := g.wsUpgrader.Upgrade(w, r, BearerProtocolHeader(viaProtocol))
 	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	// Регистрируем клиента или восстанавливаем оборванную сессию
+	clientInfo, resumed, err := g.registerWebSocketClient(r, clientID, ip)
+	if err != nil {
+		closeCode := websocket.CloseInternalServerErr
+		if errors.Is(err, ErrTooManyConnections) || errors.Is(err, ErrTooManyConnectionsPerIP) ||
+			errors.Is(err, ErrTooManyConnectionsPerClient) {
+			closeCode = websocket.CloseTryAgainLater
+		}
 		conn.WriteMessage(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "Failed to register client"))
+			websocket.FormatCloseMessage(closeCode, "Failed to register client: "+err.Error()))
 		conn.Close()
+		g.sink.RecordClient(ClientRejected)
 		return
 	}
+	g.sink.RecordClient(ClientConnected)
+	clientInfo.Claims = claims
+	if claims != nil {
+		g.clientMgr.UpdateClientData(clientInfo.ConnectionID, clientDataFromClaims(clientInfo, claims))
+	}
 
 	// Создаем сессию
 	session := &WebSocketSession{
@@ -355,39 +818,102 @@ func (g *APIGateway) handleWebSocketVideo(w http.ResponseWriter, r *http.Request
 		ClientInfo: clientInfo,
 		SendChan:   clientInfo.SendChan,
 		Done:       make(chan struct{}),
+		ReadDone:   make(chan struct{}),
+	}
+
+	// Восстановленные подписки проверяются по правам нового токена, теги
+	// доступа берутся из него же
+	channels := g.clientMgr.ClientChannels(clientInfo.ConnectionID)
+	if resumed {
+		permitted := channels[:0]
+		for _, channel := range channels {
+			if g.canSubscribe(session, channel) {
+				g.clientMgr.SubscribeClient(clientInfo.ConnectionID, channel, g.subscriberAccessTags(session)...)
+				permitted = append(permitted, channel)
+			} else {
+				g.clientMgr.UnsubscribeClient(clientInfo.ConnectionID, channel)
+			}
+		}
+		channels = permitted
 	}
 
+	g.clientMgr.NotifyClient(clientInfo, map[string]interface{}{
+		"action":       "session",
+		"resume_token": clientInfo.ResumeToken,
+		"resumed":      resumed,
+		"channels":     channels,
+		"time":         time.Now().Unix(),
+	})
+
 	// Запускаем обработку
+	g.sessions.Add(1)
 	go g.handleWebSocketSession(session)
 
-	log.Printf("WebSocket client connected: %s", clientID)
+	log.Printf("WebSocket client connected: %s (resumed: %t)", clientID, resumed)
+}
+
+// registerWebSocketClient восстанавливает сессию по resume_token, если он
+// передан и действителен, иначе регистрирует нового клиента
+func (g *APIGateway) registerWebSocketClient(r *http.Request, clientID, ip string) (*ClientInfo, bool, error) {
+	if token := r.URL.Query().Get("resume_token"); token != "" {
+		client, err := g.clientMgr.ResumeClient(token, clientID, ip, r.UserAgent())
+		if err == nil {
+			return client, true, nil
+		}
+		if !errors.Is(err, ErrInvalidResumeToken) {
+			return nil, false, err
+		}
+		log.Printf("WebSocket resume rejected for %s: %v", clientID, err)
+	}
+
+	client, err := g.clientMgr.RegisterClient(clientID, ip, r.UserAgent())
+	return client, false, err
 }
 
-// handleWebSocketControl обрабатывает WebSocket для управления
+// handleWebSocketControl обрабатывает WebSocket для управления. Токен
+// проверяется так же, как для /ws/video; команды выполняются от имени
+// клиента из токена (без аутентификации - адреса соединения).
 func (g *APIGateway) handleWebSocketControl(w http.ResponseWriter, r *http.Request) {
-	conn, err := g.wsUpgrader.Upgrade(w, r, nil)
+	claims, viaProtocol, err := g.authenticateWebSocket(r)
+	if err != nil {
+		log.Printf("Control WebSocket authentication failed for %s: %v", g.clientIP(r), err)
+		g.rejectWebSocket(w, r, websocket.ClosePolicyViolation, err.Error())
+		return
+	}
+
+	clientID := g.clientIP(r)
+	if claims != nil {
+		clientID = claimsClientID(claims)
+	}
+
+	conn, err := g.wsUpgrader.Upgrade(w, r, BearerProtocolHeader(viaProtocol))
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
 	}
 
-	go g.handleControlWebSocket(conn)
+	go g.handleControlWebSocket(conn, clientID, claims)
 }
 
 // WebSocketSession представляет WebSocket сессию
 type WebSocketSession struct {
 	Conn       *websocket.Conn
 	ClientInfo *ClientInfo
-	SendChan   chan *proto.VideoFrame
+	SendChan   chan []byte
 	Done       chan struct{}
+	ReadDone   chan struct{} // закрывается при завершении чтения
+
+	permitted *ChannelSet // кеш разрешенных каналов, см. canSubscribe
 }
 
 // handleWebSocketSession обрабатывает WebSocket сессию
 func (g *APIGateway) handleWebSocketSession(session *WebSocketSession) {
+	defer g.sessions.Done()
 	defer func() {
 		session.Conn.Close()
 		close(session.Done)
-		g.clientMgr.RemoveClient(session.ClientInfo.ConnectionID)
+		g.clientMgr.SuspendClient(session.ClientInfo.ConnectionID, g.config.GetResumeGracePeriod())
+		g.sink.RecordClient(ClientDisconnected)
 	}()
 
 	// Запускаем чтение и запись
@@ -397,6 +923,20 @@ func (g *APIGateway) handleWebSocketSession(session *WebSocketSession) {
 
 // readWebSocketMessages читает сообщения из WebSocket
 func (g *APIGateway) readWebSocketMessages(session *WebSocketSession) {
+	defer close(session.ReadDone)
+
+	// Сообщения больше лимита закрывают соединение с кодом 1009
+	session.Conn.SetReadLimit(g.config.GetMaxMessageSize())
+
+	// Любое сообщение или pong продлевает дедлайн; если клиент молчит
+	// дольше таймаута, чтение завершится ошибкой и сессия закроется
+	pongTimeout := g.config.GetPongTimeout()
+	session.Conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	session.Conn.SetPongHandler(func(string) error {
+		g.clientMgr.TouchClient(session.ClientInfo.ConnectionID)
+		return session.Conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+
 	for {
 		messageType, message, err := session.Conn.ReadMessage()
 		if err != nil {
@@ -408,7 +948,8 @@ func (g *APIGateway) readWebSocketMessages(session *WebSocketSession) {
 			break
 		}
 
-		session.ClientInfo.LastSeen = time.Now()
+		g.clientMgr.TouchClient(session.ClientInfo.ConnectionID)
+		session.Conn.SetReadDeadline(time.Now().Add(pongTimeout))
 
 		if messageType == websocket.TextMessage {
 			g.handleWebSocketCommand(session, message)
@@ -418,42 +959,92 @@ func (g *APIGateway) readWebSocketMessages(session *WebSocketSession) {
 
 // writeWebSocketMessages пишет сообщения в WebSocket
 func (g *APIGateway) writeWebSocketMessages(session *WebSocketSession) {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(g.config.GetPingInterval())
 	defer ticker.Stop()
 
+	writeTimeout := g.config.GetWriteTimeout()
+
 	for {
 		select {
-		case frame, ok := <-session.SendChan:
+		case data, ok := <-session.SendChan:
 			if !ok {
+				// Канал закрыт менеджером: досылаем уведомления и сообщаем причину
+				g.flushEvents(session, writeTimeout)
+
+				code, reason := websocket.CloseNormalClosure, "connection closed"
+				if session.ClientInfo.CloseReason != "" {
+					code, reason = websocket.ClosePolicyViolation, session.ClientInfo.CloseReason
+				}
+				if session.ClientInfo.CloseCode != 0 {
+					code = session.ClientInfo.CloseCode
+				}
+				session.Conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(code, reason),
+					time.Now().Add(time.Second))
 				return
 			}
 
-			data, err := json.Marshal(frame)
+			session.Conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := session.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				return
+			}
+			// Учитываются только записанные фреймы: выброшенные coalesceSend
+			// клиенту не ушли
+			session.ClientInfo.Bandwidth.Add(len(data))
+
+		case event := <-session.ClientInfo.EventChan:
+			data, err := json.Marshal(event)
 			if err != nil {
-				log.Printf("Failed to marshal frame: %v", err)
+				log.Printf("Failed to marshal event: %v", err)
 				continue
 			}
 
-			err = session.Conn.WriteMessage(websocket.TextMessage, data)
-			if err != nil {
+			session.Conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := session.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
 				log.Printf("WebSocket write error: %v", err)
 				return
 			}
 
 		case <-ticker.C:
 			// Ping для поддержания соединения
-			err := session.Conn.WriteMessage(websocket.PingMessage, nil)
+			err := session.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
 			if err != nil {
 				return
 			}
 
+		case <-session.ReadDone:
+			// Клиент отключился или не ответил на ping вовремя
+			return
+
 		case <-session.Done:
 			return
 		}
 	}
 }
 
-// handleWebSocketCommand обрабатывает команды WebSocket
+// flushEvents отправляет клиенту уже поставленные в очередь уведомления
+func (g *APIGateway) flushEvents(session *WebSocketSession, writeTimeout time.Duration) {
+	for {
+		select {
+		case event := <-session.ClientInfo.EventChan:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			session.Conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := session.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// handleWebSocketCommand обрабатывает команды WebSocket. Ответы ставятся в
+// очередь уведомлений клиента: писать в соединение может только
+// writeWebSocketMessages.
 func (g *APIGateway) handleWebSocketCommand(session *WebSocketSession, message []byte) {
 	var command map[string]interface{}
 	if err := json.Unmarshal(message, &command); err != nil {
@@ -469,16 +1060,24 @@ func (g *APIGateway) handleWebSocketCommand(session *WebSocketSession, message [
 	switch action {
 	case "subscribe":
 		if channel, ok := command["channel"].(string); ok {
-			g.clientMgr.SubscribeClient(session.ClientInfo.ConnectionID, channel)
+			if !g.canSubscribe(session, channel) {
+				g.clientMgr.NotifyClient(session.ClientInfo, map[string]interface{}{
+					"action":  "error",
+					"error":   "forbidden",
+					"channel": channel,
+					"time":    time.Now().Unix(),
+				})
+				return
+			}
 
-			response := map[string]interface{}{
+			g.clientMgr.SubscribeClient(session.ClientInfo.ConnectionID, channel,
+				g.subscriberAccessTags(session)...)
+
+			g.clientMgr.NotifyClient(session.ClientInfo, map[string]interface{}{
 				"action":  "subscribed",
 				"channel": channel,
 				"time":    time.Now().Unix(),
-			}
-
-			jsonResponse, _ := json.Marshal(response)
-			session.Conn.WriteMessage(websocket.TextMessage, jsonResponse)
+			})
 		}
 
 	case "unsubscribe":
@@ -486,53 +1085,160 @@ func (g *APIGateway) handleWebSocketCommand(session *WebSocketSession, message [
 			g.clientMgr.UnsubscribeClient(session.ClientInfo.ConnectionID, channel)
 		}
 
+	case "keyframe_request":
+		// Запросить ключевой кадр может только тот, кому доступен стрим
+		if channel, ok := command["channel"].(string); ok && g.canSubscribe(session, channel) {
+			g.RequestKeyframe(channel, session.ClientInfo.ID)
+		}
+
 	case "ping":
-		response := map[string]interface{}{
+		g.clientMgr.NotifyClient(session.ClientInfo, map[string]interface{}{
 			"action": "pong",
 			"time":   time.Now().Unix(),
-		}
+		})
+
+	case "stats":
+		g.clientMgr.NotifyClient(session.ClientInfo, g.streamStatsReply(session, command))
+	}
+}
 
-		jsonResponse, _ := json.Marshal(response)
-		session.Conn.WriteMessage(websocket.TextMessage, jsonResponse)
+// rejectWebSocket завершает апгрейд и сразу закрывает соединение с кодом
+// code: браузер видит причину в close событии, а не безликий HTTP отказ
+func (g *APIGateway) rejectWebSocket(w http.ResponseWriter, r *http.Request, code int, reason string) {
+	_, viaProtocol := WebSocketToken(r)
+	conn, err := g.wsUpgrader.Upgrade(w, r, BearerProtocolHeader(viaProtocol))
+	if err != nil {
+		return
 	}
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(time.Second))
+	conn.Close()
 }
 
-// handleControlWebSocket обрабатывает управляющий WebSocket
-func (g *APIGateway) handleControlWebSocket(conn *websocket.Conn) {
+// BearerProtocolHeader выбирает подпротокол bearer, если токен пришел в
+// Sec-WebSocket-Protocol; иначе браузер оборвет соединение после апгрейда
+func BearerProtocolHeader(viaProtocol bool) http.Header {
+	if !viaProtocol {
+		return nil
+	}
+	return http.Header{"Sec-WebSocket-Protocol": []string{wsTokenProtocol}}
+}
+
+// controlActions управляющие команды, которые принимаются по WebSocket
+var controlActions = map[string]bool{
+	ControlClientConnect:    true,
+	ControlClientDisconnect: true,
+	ControlSubscribe:        true,
+	ControlUnsubscribe:      true,
+	ControlServiceHealth:    true,
+	ControlKeyframeRequest:  true,
+}
+
+// controlCommand управляющая команда от клиента
+type controlCommand struct {
+	Action   string      `json:"action"`
+	ClientID string      `json:"client_id"` // необязателен; должен совпадать с клиентом соединения
+	Channel  string      `json:"channel"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// handleControlWebSocket обрабатывает управляющий WebSocket соединения
+// клиента clientID. client_id команды, если указан, должен совпадать с ним;
+// команды с каналом требуют права на подписку на этот канал.
+func (g *APIGateway) handleControlWebSocket(conn *websocket.Conn, clientID string, claims *TokenClaims) {
 	defer conn.Close()
 
+	// Права вычисляются один раз на соединение, как в canSubscribe
+	session := &WebSocketSession{ClientInfo: &ClientInfo{ID: clientID, Claims: claims}}
+
+	// Сообщения больше лимита закрывают соединение с кодом 1009
+	conn.SetReadLimit(g.config.GetMaxMessageSize())
+	limiter := NewRateLimiter(g.config.Gateway.ControlRateLimit, g.config.Gateway.ControlRateLimit)
+
 	for {
 		messageType, message, err := conn.ReadMessage()
 		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Printf("Control WebSocket message too large, closing connection")
+			}
 			break
 		}
 
-		if messageType == websocket.TextMessage {
-			// Обработка управляющих команд
-			log.Printf("Control command: %s", message)
+		if messageType != websocket.TextMessage {
+			continue
+		}
+
+		if !limiter.Allow() {
+			writeControlReply(conn, "error", "", "rate limit exceeded")
+			continue
+		}
+
+		var cmd controlCommand
+		if err := json.Unmarshal(message, &cmd); err != nil {
+			writeControlReply(conn, "error", "", "invalid command")
+			continue
+		}
+
+		if cmd.ClientID != "" && cmd.ClientID != clientID {
+			writeControlReply(conn, "error", cmd.Action, "client_id does not match the connection")
+			continue
+		}
+
+		// Общий лимит клиента: действует на все его соединения и, при
+		// rate_limit_backend: redis, на все реплики шлюза
+		if !g.controlLimiter.Allow("control:" + clientID) {
+			writeControlReply(conn, "error", cmd.Action, "rate limit exceeded")
+			continue
+		}
+
+		if !controlActions[cmd.Action] {
+			writeControlReply(conn, "error", cmd.Action, "unknown action")
+			continue
+		}
+		if cmd.Channel != "" && !g.canSubscribe(session, cmd.Channel) {
+			writeControlReply(conn, "error", cmd.Action, "forbidden")
+			continue
+		}
+
+		msg := &ControlMessage{
+			Type:      cmd.Action,
+			ClientID:  clientID,
+			Channel:   cmd.Channel,
+			Data:      cmd.Data,
+			Timestamp: time.Now(),
+		}
+
+		select {
+		case g.controlChan <- msg:
+			writeControlReply(conn, "accepted", cmd.Action, "")
+		case <-g.ctx.Done():
+			return
+		default:
+			writeControlReply(conn, "error", cmd.Action, "control queue is full")
 		}
 	}
 }
 
-// Вспомогательная функция для получения IP адреса
-func getIPAddress(r *http.Request) string {
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip == "" {
-		ip = r.Header.Get("X-Real-IP")
+// writeControlReply отправляет ответ на управляющую команду
+func writeControlReply(conn *websocket.Conn, status, action, message string) {
+	reply := map[string]interface{}{
+		"status": status,
+		"action": action,
+		"time":   time.Now().Unix(),
 	}
-	if ip == "" {
-		ip, _, _ = splitHostPort(r.RemoteAddr)
+	if message != "" {
+		reply["message"] = message
 	}
-	return ip
+
+	data, _ := json.Marshal(reply)
+	conn.WriteMessage(websocket.TextMessage, data)
 }
 
-func splitHostPort(hostport string) (host, port string, err error) {
-	// Упрощенная реализация
-	parts := strings.Split(hostport, ":")
-	if len(parts) == 2 {
-		return parts[0], parts[1], nil
-	}
-	return hostport, "", nil
+// writeJSON отправляет JSON ответ с указанным статусом
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
 }
 
 // FrameRate рассчитывает FPS
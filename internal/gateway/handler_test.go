@@ -0,0 +1,668 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+// newTestGateway создает шлюз без запуска фоновых задач
+func newTestGateway(t *testing.T, configure func(*config.Config)) *APIGateway {
+	t.Helper()
+	cfg := config.GetDefaultConfig()
+	cfg.JWT.Secret = testJWTSecret
+	cfg.Gateway.ControlRateLimit = 100
+	if configure != nil {
+		configure(cfg)
+	}
+	g, err := NewAPIGateway(cfg)
+	if err != nil {
+		t.Fatalf("NewAPIGateway: %v", err)
+	}
+	t.Cleanup(g.cancel)
+	return g
+}
+
+// dialControl подключается к /ws/control тестового сервера
+func dialControl(t *testing.T, server *httptest.Server, token string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/control"
+	if token != "" {
+		url += "?token=" + token
+	}
+	return websocket.DefaultDialer.Dial(url, nil)
+}
+
+func TestControlWebSocketBindsClientToToken(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Auth.ChannelOwners = map[string][]string{"user-1": {"cam-1"}}
+	})
+	server := httptest.NewServer(http.HandlerFunc(g.handleWebSocketControl))
+	defer server.Close()
+
+	// Без токена соединение закрывается с 1008
+	conn, _, err := dialControl(t, server, "")
+	if err != nil {
+		t.Fatalf("dial without token: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatalf("without token: got %v, want close 1008", err)
+	}
+	conn.Close()
+
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "client-1"})
+	conn, _, err = dialControl(t, server, token)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	queued := make(chan *ControlMessage, 4)
+	for _, action := range []string{ControlSubscribe, ControlKeyframeRequest} {
+		g.events.Subscribe(action, "test", func(msg *ControlMessage) { queued <- msg })
+	}
+
+	tests := []struct {
+		name       string
+		command    controlCommand
+		wantStatus string
+		wantQueued bool
+	}{
+		{"foreign client_id", controlCommand{Action: ControlSubscribe, ClientID: "victim", Channel: "cam-1"}, "error", false},
+		{"foreign channel", controlCommand{Action: ControlSubscribe, Channel: "cam-2"}, "error", false},
+		{"own channel", controlCommand{Action: ControlSubscribe, Channel: "cam-1"}, "accepted", true},
+		{"own client_id", controlCommand{Action: ControlKeyframeRequest, ClientID: "client-1", Channel: "cam-1"}, "accepted", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := conn.WriteJSON(tt.command); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			var reply map[string]interface{}
+			json.Unmarshal(data, &reply)
+			if reply["status"] != tt.wantStatus {
+				t.Fatalf("reply = %s, want status %s", data, tt.wantStatus)
+			}
+			if !tt.wantQueued {
+				return
+			}
+			select {
+			case msg := <-queued:
+				if msg.ClientID != "client-1" {
+					t.Errorf("queued ClientID = %q, want client-1", msg.ClientID)
+				}
+			case <-time.After(time.Second):
+				t.Error("command was not dispatched")
+			}
+		})
+	}
+}
+
+func TestVideoStreamRecordsAuthenticatedProducer(t *testing.T) {
+	g := newTestGateway(t, nil)
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "client-1"})
+
+	tests := []struct {
+		name         string
+		auth         string
+		body         string
+		wantStatus   int
+		wantProducer string
+	}{
+		{"missing token", "", `{"frame_id":"f1","camera_id":"cam-1"}`, http.StatusUnauthorized, ""},
+		{"foreign client_id", "Bearer " + token, `{"frame_id":"f1","camera_id":"cam-1","client_id":"victim"}`, http.StatusForbidden, ""},
+		{"token client", "Bearer " + token, `{"frame_id":"f1","camera_id":"cam-1"}`, http.StatusOK, "client-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/video/stream?sync=true", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			g.handleVideoStream(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantProducer == "" {
+				return
+			}
+			if producer, _ := g.producers.Producer("cam-1"); producer != tt.wantProducer {
+				t.Errorf("producer = %q, want %q", producer, tt.wantProducer)
+			}
+		})
+	}
+}
+
+func TestStreamControlRequiresStreamAccess(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Auth.ChannelOwners = map[string][]string{"user-1": {"cam-1", "cam-2"}}
+	})
+	g.producers.Record("cam-1", "client-1")
+	g.producers.Record("cam-2", "client-1")
+	owner := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1"})
+	stranger := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-2"})
+
+	tests := []struct {
+		name       string
+		stream     string
+		auth       string
+		wantStatus int
+	}{
+		{"missing token", "cam-1", "", http.StatusUnauthorized},
+		{"foreign stream", "cam-1", "Bearer " + stranger, http.StatusForbidden},
+		{"own stream", "cam-2", "Bearer " + owner, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/video/stream/"+tt.stream+"/keyframe-request", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			g.handleStreamControl(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+// dialVideo подключается к /ws/video тестового сервера и читает
+// уведомление о сессии
+func dialVideo(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/video?" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("read session event: %v", err)
+	}
+	return conn
+}
+
+func TestWebSocketCommandRepliesDuringBroadcast(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Auth.ChannelOwners = map[string][]string{"user-1": {"cam-1"}}
+	})
+	server := httptest.NewServer(http.HandlerFunc(g.handleWebSocketVideo))
+	defer server.Close()
+
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "client-1"})
+	conn := dialVideo(t, server, "token="+token)
+	conn.WriteJSON(map[string]string{"action": "subscribe", "channel": "cam-1"})
+
+	// Фреймы рассылаются, пока клиент получает отказы в подписке
+	stop := make(chan struct{})
+	broadcasting := make(chan struct{})
+	go func() {
+		defer close(broadcasting)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				g.clientMgr.BroadcastFrame("cam-1", nil, []byte(`{"frame_id":"f"}`), false)
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-broadcasting
+	}()
+
+	// Каждая команда ждет ответа: ответы идут через очередь уведомлений
+	// того же писателя, что и фреймы
+	for i := 0; i < 20; i++ {
+		if err := conn.WriteJSON(map[string]string{"action": "subscribe", "channel": "cam-2"}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for forbidden := false; !forbidden; {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("read reply to command %d: %v", i, err)
+			}
+			var reply map[string]interface{}
+			forbidden = json.Unmarshal(data, &reply) == nil && reply["error"] == "forbidden"
+		}
+	}
+}
+
+func TestWebSocketPongTimeout(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Gateway.PongTimeout = 1
+	})
+	server := httptest.NewServer(http.HandlerFunc(g.handleWebSocketVideo))
+	defer server.Close()
+
+	// Клиент, читающий соединение, отвечает на ping автоматически
+	alive := dialVideo(t, server, "token="+signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "alive"}))
+	alive.SetReadDeadline(time.Time{})
+	go func() {
+		for {
+			if _, _, err := alive.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	// Клиент, который перестал читать, на ping не отвечает
+	dialVideo(t, server, "token="+signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "silent"}))
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(g.clientMgr.GetClientsByID("silent")) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("silent client is still connected after pong_timeout")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(1500 * time.Millisecond) // дольше pong_timeout
+	if len(g.clientMgr.GetClientsByID("alive")) != 1 {
+		t.Error("client answering pings was disconnected")
+	}
+}
+
+func TestWebSocketBandwidthCountsWrittenFrames(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Gateway.SendBufferSize = 1
+	})
+	server := httptest.NewServer(http.HandlerFunc(g.handleWebSocketVideo))
+	defer server.Close()
+
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "viewer", Channels: []string{"cam-1"}})
+	conn := dialVideo(t, server, "token="+token)
+	conn.WriteJSON(map[string]string{"action": "subscribe", "channel": "cam-1"})
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil || !strings.Contains(string(data), "subscribed") {
+		t.Fatalf("subscribe reply = %s, %v", data, err)
+	}
+	client := g.clientMgr.GetClientsByID("viewer")[0]
+
+	frame := []byte(`{"frame_id":"` + strings.Repeat("x", 64*1024) + `"}`)
+	dropped := 0
+	for i := 0; i < 200; i++ {
+		_, d := g.clientMgr.BroadcastFrame("cam-1", nil, frame, false)
+		dropped += d
+	}
+	if dropped == 0 {
+		t.Skip("writer kept up with every frame, nothing was coalesced")
+	}
+
+	// Клиент не читал, пока шла рассылка: писатель встал на полном сокете.
+	// Читаем все, что дошло до клиента
+	received := 0
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		received += len(data)
+	}
+
+	if total := client.Bandwidth.Total(); total != int64(received) {
+		t.Errorf("bytes sent = %d, want %d actually written (%d frames coalesced)", total, received, dropped)
+	}
+	if rate := client.Bandwidth.Rate(); rate > float64(received) {
+		t.Errorf("rate = %.0f B/s, more than %d bytes written", rate, received)
+	}
+}
+
+func TestWebSocketStatsCommand(t *testing.T) {
+	g := newTestGateway(t, nil)
+	server := httptest.NewServer(http.HandlerFunc(g.handleWebSocketVideo))
+	defer server.Close()
+
+	g.streamStats.Record("cam-1", 100)
+	g.streamStats.Record("cam-1", 50)
+	g.streamStats.Record("cam-2", 10)
+
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "client-1", Channels: []string{"cam-1", "cam-3"}})
+	conn := dialVideo(t, server, "token="+token)
+
+	tests := []struct {
+		name       string
+		streamID   string
+		wantAction string
+		wantError  string
+		wantFrames int64
+	}{
+		{"permitted stream", "cam-1", "stats", "", 2},
+		{"foreign stream", "cam-2", "error", "forbidden", 0},
+		{"unknown stream", "cam-3", "error", "stream not found", 0},
+		{"missing stream_id", "", "error", "stream_id is required", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := conn.WriteJSON(map[string]string{"action": "stats", "stream_id": tt.streamID}); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			var reply struct {
+				Action   string      `json:"action"`
+				Error    string      `json:"error"`
+				StreamID string      `json:"stream_id"`
+				Stats    StreamStats `json:"stats"`
+			}
+			if err := conn.ReadJSON(&reply); err != nil {
+				t.Fatalf("read reply: %v", err)
+			}
+			if reply.Action != tt.wantAction || reply.Error != tt.wantError {
+				t.Fatalf("reply = %+v, want action %q error %q", reply, tt.wantAction, tt.wantError)
+			}
+			if tt.wantAction == "stats" && (reply.Stats.Frames != tt.wantFrames || reply.Stats.Bytes != 150) {
+				t.Errorf("stats = %+v, want 2 frames and 150 bytes", reply.Stats)
+			}
+		})
+	}
+}
+
+func TestDisconnectClientEndpoints(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Gateway.AdminToken = "admin-secret"
+	})
+	mux := http.NewServeMux()
+	g.setupHTTPHandlers(mux)
+
+	single, _ := g.clientMgr.RegisterClient("single", "10.0.0.1", "test")
+	g.clientMgr.RegisterClient("multi", "10.0.0.2", "test")
+	g.clientMgr.RegisterClient("multi", "10.0.0.3", "test")
+	bystander, _ := g.clientMgr.RegisterClient("bystander", "10.0.0.4", "test")
+
+	tests := []struct {
+		name       string
+		path       string
+		auth       string
+		wantStatus int
+		wantGone   []string // client_id без оставшихся соединений
+	}{
+		{"without admin token", "/api/v1/clients/" + single.ConnectionID, "", http.StatusUnauthorized, nil},
+		{"single connection", "/api/v1/clients/" + single.ConnectionID, "admin-secret", http.StatusOK, []string{"single"}},
+		{"unknown connection", "/api/v1/clients/missing-1", "admin-secret", http.StatusNotFound, nil},
+		{"all connections of client", "/api/v1/clients?client_id=multi", "admin-secret", http.StatusOK, []string{"multi"}},
+		{"unknown client", "/api/v1/clients?client_id=missing", "admin-secret", http.StatusNotFound, nil},
+		{"missing client_id", "/api/v1/clients", "admin-secret", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodDelete, tt.path, nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.auth)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			for _, clientID := range tt.wantGone {
+				if conns := g.clientMgr.GetClientsByID(clientID); len(conns) != 0 {
+					t.Errorf("client %s still has %d connections", clientID, len(conns))
+				}
+			}
+		})
+	}
+
+	if _, open := <-single.SendChan; open {
+		t.Error("disconnected client's send channel is not closed")
+	}
+	if single.CloseReason == "" {
+		t.Error("disconnected client has no close reason")
+	}
+	if _, ok := g.clientMgr.GetClientInfo(bystander.ConnectionID); !ok {
+		t.Error("unrelated client was disconnected")
+	}
+}
+
+func TestVideoStreamRequiredServices(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Services.VideoProcessing = nil
+		cfg.Services.Notification = nil
+		cfg.Services.Storage = []string{"http://127.0.0.1:1"}
+		cfg.Services.Analytics = []string{"http://127.0.0.1:1"}
+		cfg.Services.Required = []string{"storage"}
+	})
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "client-1"})
+
+	tests := []struct {
+		name       string
+		unhealthy  string // ID эндпоинта, помеченного нездоровым
+		wantStatus int
+	}{
+		{"all healthy", "", http.StatusOK},
+		{"required storage unhealthy", "storage_0", http.StatusServiceUnavailable},
+		{"optional analytics unhealthy", "analytics_0", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.unhealthy != "" {
+				g.services.SetEndpointHealthy(tt.unhealthy, false)
+				t.Cleanup(func() { g.services.SetEndpointHealthy(tt.unhealthy, true) })
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/video/stream", strings.NewReader(`{"frame_id":"f1","camera_id":"cam-1"}`))
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			g.handleVideoStream(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusServiceUnavailable {
+				return
+			}
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("503 without Retry-After")
+			}
+			var body struct {
+				Unavailable []string `json:"unavailable_services"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if len(body.Unavailable) != 1 || body.Unavailable[0] != "storage" {
+				t.Errorf("unavailable_services = %v, want [storage]", body.Unavailable)
+			}
+		})
+	}
+}
+
+func TestAdminServicesAddAndDrain(t *testing.T) {
+	var received atomic.Int32
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer service.Close()
+
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Services.VideoProcessing = nil
+		cfg.Services.Analytics = nil
+		cfg.Services.Storage = nil
+		cfg.Services.Notification = nil
+	})
+	admin := func(method, body string) map[string]interface{} {
+		t.Helper()
+		r := httptest.NewRequest(method, "/api/v1/admin/services", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		g.handleAdminServices(w, r)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: status %d (%s)", method, body, w.Code, w.Body.String())
+		}
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	send := func() {
+		t.Helper()
+		g.ProcessFrameSync(context.Background(), &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", ClientID: "cam-1"})
+	}
+
+	added := admin(http.MethodPost, `{"type":"storage","url":"`+service.URL+`"}`)
+	id, _ := added["id"].(string)
+	send()
+	if got := received.Load(); got != 1 {
+		t.Fatalf("added endpoint received %d frames, want 1", got)
+	}
+
+	admin(http.MethodPost, `{"id":"`+id+`","drain":true}`)
+	send()
+	if got := received.Load(); got != 1 {
+		t.Fatalf("drained endpoint received %d frames, want still 1", got)
+	}
+
+	admin(http.MethodPost, `{"id":"`+id+`","drain":false}`)
+	send()
+	if got := received.Load(); got != 2 {
+		t.Errorf("restored endpoint received %d frames, want 2", got)
+	}
+}
+
+func TestClientsListFilterByChannel(t *testing.T) {
+	g := newTestGateway(t, nil)
+	subscribe := func(clientID string, channels ...string) string {
+		client, _ := g.clientMgr.RegisterClient(clientID, "10.0.0.1", "test")
+		for _, channel := range channels {
+			g.clientMgr.SubscribeClient(client.ConnectionID, channel)
+		}
+		return client.ConnectionID
+	}
+	a := subscribe("a", "cam-1")
+	b := subscribe("b", "cam-1", "cam-2")
+	c := subscribe("c", "cam-2")
+	subscribe("d")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"?channel=cam-1", []string{a, b}},
+		{"?channel=cam-2", []string{b, c}},
+		{"?channel=cam-3", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			g.handleClients(w, httptest.NewRequest(http.MethodGet, "/api/v1/clients"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d (%s)", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Clients []ClientSummary `json:"clients"`
+				Count   int             `json:"count"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			got := make([]string, 0, len(resp.Clients))
+			for _, client := range resp.Clients {
+				got = append(got, client.ConnectionID)
+			}
+			if !reflect.DeepEqual(got, tt.want) || resp.Count != len(tt.want) {
+				t.Errorf("clients = %v (count %d), want %v", got, resp.Count, tt.want)
+			}
+		})
+	}
+}
+
+func TestShutdownSendsGoingAway(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Gateway.ShutdownReconnectDelay = 2
+	})
+	server := httptest.NewServer(http.HandlerFunc(g.handleWebSocketVideo))
+	defer server.Close()
+
+	conn := dialVideo(t, server, "token="+signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "cam-1"}))
+	g.closeClientsOnShutdown()
+
+	// Сначала уведомление, затем close фрейм с причиной
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var notice map[string]interface{}
+	if err := conn.ReadJSON(&notice); err != nil {
+		t.Fatalf("read shutdown notice: %v", err)
+	}
+	if notice["action"] != "server_shutdown" || notice["reconnect_after_ms"] != float64(2000) {
+		t.Errorf("notice = %v, want server_shutdown with reconnect_after_ms 2000", notice)
+	}
+
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("read after notice: %v, want close frame", err)
+	}
+	if closeErr.Code != websocket.CloseGoingAway || closeErr.Text != "server shutting down, reconnect after 2s" {
+		t.Errorf("close frame = %d %q", closeErr.Code, closeErr.Text)
+	}
+}
+
+func TestClientsListShowsTokenRoles(t *testing.T) {
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Auth.WebSocketRequired = false
+	})
+	server := httptest.NewServer(http.HandlerFunc(g.handleWebSocketVideo))
+	defer server.Close()
+
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "client-1", Roles: []string{"viewer", "admin"}})
+	dialVideo(t, server, "token="+token)
+	dialVideo(t, server, "client_id=anonymous")
+
+	w := httptest.NewRecorder()
+	g.handleClients(w, httptest.NewRequest(http.MethodGet, "/api/v1/clients", nil))
+	var resp struct {
+		Clients []ClientSummary `json:"clients"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	tests := []struct {
+		id                string
+		wantAuthenticated bool
+		wantUserID        string
+		wantRoles         []string
+	}{
+		{"client-1", true, "user-1", []string{"viewer", "admin"}},
+		{"anonymous", false, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			for _, client := range resp.Clients {
+				if client.ID != tt.id {
+					continue
+				}
+				if client.Authenticated != tt.wantAuthenticated || client.UserID != tt.wantUserID ||
+					!reflect.DeepEqual(client.Roles, tt.wantRoles) {
+					t.Errorf("client = authenticated %v, user %q, roles %v; want %v, %q, %v",
+						client.Authenticated, client.UserID, client.Roles,
+						tt.wantAuthenticated, tt.wantUserID, tt.wantRoles)
+				}
+				return
+			}
+			t.Fatalf("client %s is missing from the listing: %s", tt.id, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package gateway
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Статусы здоровья в порядке ухудшения
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
+// HealthCheck результат одного сигнала здоровья
+type HealthCheck struct {
+	Status string  `json:"status"`
+	Value  float64 `json:"value"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// healthRank порядок статусов для выбора худшего
+var healthRank = map[string]int{
+	HealthHealthy:   0,
+	HealthDegraded:  1,
+	HealthUnhealthy: 2,
+}
+
+// worseHealth возвращает худший из двух статусов
+func worseHealth(a, b string) string {
+	if healthRank[b] > healthRank[a] {
+		return b
+	}
+	return a
+}
+
+// thresholdCheck оценивает значение по порогам degraded/unhealthy
+// (0 - порог выключен)
+func thresholdCheck(name string, value, degraded, unhealthy float64) HealthCheck {
+	check := HealthCheck{Status: HealthHealthy, Value: value}
+	switch {
+	case unhealthy > 0 && value >= unhealthy:
+		check.Status = HealthUnhealthy
+		check.Reason = fmt.Sprintf("%s %.1f%% >= %.1f%%", name, value, unhealthy)
+	case degraded > 0 && value >= degraded:
+		check.Status = HealthDegraded
+		check.Reason = fmt.Sprintf("%s %.1f%% >= %.1f%%", name, value, degraded)
+	}
+	return check
+}
+
+// countCheck оценивает число по порогам degraded/unhealthy (0 - порог выключен)
+func countCheck(name string, value, degraded, unhealthy int) HealthCheck {
+	check := HealthCheck{Status: HealthHealthy, Value: float64(value)}
+	switch {
+	case unhealthy > 0 && value >= unhealthy:
+		check.Status = HealthUnhealthy
+		check.Reason = fmt.Sprintf("%s %d >= %d", name, value, unhealthy)
+	case degraded > 0 && value >= degraded:
+		check.Status = HealthDegraded
+		check.Reason = fmt.Sprintf("%s %d >= %d", name, value, degraded)
+	}
+	return check
+}
+
+// outcomeWindow считает успешные и неудачные операции за скользящее окно
+// посекундными корзинами
+type outcomeWindow struct {
+	mu      sync.Mutex
+	buckets []outcomeBucket
+}
+
+type outcomeBucket struct {
+	second int64
+	total  int64
+	failed int64
+}
+
+// newOutcomeWindow создает окно длиной window (не меньше секунды)
+func newOutcomeWindow(window time.Duration) *outcomeWindow {
+	seconds := int(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &outcomeWindow{buckets: make([]outcomeBucket, seconds)}
+}
+
+// record учитывает операцию
+func (w *outcomeWindow) record(failed bool) {
+	now := time.Now().Unix()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	bucket := &w.buckets[now%int64(len(w.buckets))]
+	if bucket.second != now {
+		*bucket = outcomeBucket{second: now}
+	}
+	bucket.total++
+	if failed {
+		bucket.failed++
+	}
+}
+
+// counts возвращает число операций и неудач за окно
+func (w *outcomeWindow) counts() (total, failed int64) {
+	oldest := time.Now().Unix() - int64(len(w.buckets))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, bucket := range w.buckets {
+		if bucket.second > oldest {
+			total += bucket.total
+			failed += bucket.failed
+		}
+	}
+	return total, failed
+}
+
+// percent возвращает part/total в процентах
+func percent(part, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+// evaluateHealth собирает сигналы здоровья шлюза и итоговый статус
+func (g *APIGateway) evaluateHealth() (string, map[string]HealthCheck) {
+	thresholds := g.config.Health
+	checks := make(map[string]HealthCheck)
+
+	checks["frame_queue"] = thresholdCheck("frame queue fill",
+		percent(int64(len(g.videoChan)), int64(cap(g.videoChan))),
+		float64(thresholds.QueueDegradedPercent), float64(thresholds.QueueUnhealthyPercent))
+
+	poolStats := g.sendPool.Stats()
+	checks["send_queue"] = thresholdCheck("send queue fill",
+		percent(int64(poolStats.QueueLength), int64(poolStats.QueueSize)),
+		float64(thresholds.QueueDegradedPercent), float64(thresholds.QueueUnhealthyPercent))
+
+	// Доля неудач за окно (или за все время без окна); на малой выборке
+	// сигнал не оценивается, чтобы единичная ошибка не снимала трафик
+	processed, failed := poolStats.Processed, poolStats.Failed
+	if thresholds.FailRateWindow > 0 {
+		processed, failed = poolStats.RecentProcessed, poolStats.RecentFailed
+	}
+	sendFailures := thresholdCheck("send failure rate", percent(failed, processed),
+		float64(thresholds.FailRateDegradedPercent), float64(thresholds.FailRateUnhealthyPercent))
+	if processed < int64(thresholds.FailRateMinRequests) {
+		sendFailures.Status = HealthHealthy
+		sendFailures.Reason = ""
+	}
+	checks["send_failures"] = sendFailures
+
+	checks["unhealthy_services"] = countCheck("unhealthy service endpoints",
+		g.services.UnhealthyEndpointCount(),
+		thresholds.UnhealthyServicesDegraded, thresholds.UnhealthyServicesUnhealthy)
+
+	required := HealthCheck{Status: HealthHealthy}
+	if unavailable := g.services.UnavailableServiceTypes(g.config.Services.Required); len(unavailable) > 0 {
+		required.Status = HealthUnhealthy
+		required.Value = float64(len(unavailable))
+		required.Reason = fmt.Sprintf("required services unavailable: %v", unavailable)
+	}
+	checks["required_services"] = required
+
+	status := HealthHealthy
+	for _, check := range checks {
+		status = worseHealth(status, check.Status)
+	}
+	return status, checks
+}
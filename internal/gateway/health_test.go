@@ -0,0 +1,227 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+// healthResponse тело ответа /health
+type healthResponse struct {
+	Status  string                 `json:"status"`
+	Checks  map[string]HealthCheck `json:"checks"`
+	Reasons []string               `json:"reasons"`
+}
+
+// getHealth вызывает /health и возвращает код ответа и тело
+func getHealth(t *testing.T, g *APIGateway) (int, healthResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	g.handleHealth(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var body healthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode health: %v", err)
+	}
+	return w.Code, body
+}
+
+// fillFrameQueue заполняет очередь фреймов на n элементов. Обработчики
+// очереди останавливаются, чтобы она не разбиралась.
+func fillFrameQueue(g *APIGateway, n int) {
+	g.cancel()
+	g.wg.Wait()
+	for i := 0; i < n; i++ {
+		g.videoChan <- queuedFrame{}
+	}
+}
+
+// markUnhealthy добавляет n нездоровых эндпоинтов хранилища
+func markUnhealthy(t *testing.T, g *APIGateway, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		endpoint, err := g.services.AddEndpoint("storage", fmt.Sprintf("http://127.0.0.1:1/%d", i), 0, nil)
+		if err != nil {
+			t.Fatalf("AddEndpoint: %v", err)
+		}
+		endpoint.Healthy = false
+	}
+}
+
+func TestHealthSignals(t *testing.T) {
+	tests := []struct {
+		name       string
+		configure  func(*config.Config)
+		drive      func(t *testing.T, g *APIGateway)
+		wantStatus string
+		wantCode   int
+		wantCheck  string // сигнал, который определяет статус
+		wantReason string
+	}{
+		{
+			name:       "healthy",
+			drive:      func(*testing.T, *APIGateway) {},
+			wantStatus: HealthHealthy,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "frame queue degraded",
+			drive:      func(_ *testing.T, g *APIGateway) { fillFrameQueue(g, 6) },
+			wantStatus: HealthDegraded,
+			wantCode:   http.StatusOK,
+			wantCheck:  "frame_queue",
+			wantReason: "frame queue fill 60.0% >= 50.0%",
+		},
+		{
+			name:       "frame queue unhealthy",
+			drive:      func(_ *testing.T, g *APIGateway) { fillFrameQueue(g, 10) },
+			wantStatus: HealthUnhealthy,
+			wantCode:   http.StatusServiceUnavailable,
+			wantCheck:  "frame_queue",
+			wantReason: "frame queue fill 100.0% >= 90.0%",
+		},
+		{
+			name:       "unhealthy endpoints degraded",
+			drive:      func(t *testing.T, g *APIGateway) { markUnhealthy(t, g, 1) },
+			wantStatus: HealthDegraded,
+			wantCode:   http.StatusOK,
+			wantCheck:  "unhealthy_services",
+			wantReason: "unhealthy service endpoints 1 >= 1",
+		},
+		{
+			name:       "unhealthy endpoints unhealthy",
+			drive:      func(t *testing.T, g *APIGateway) { markUnhealthy(t, g, 2) },
+			wantStatus: HealthUnhealthy,
+			wantCode:   http.StatusServiceUnavailable,
+			wantCheck:  "unhealthy_services",
+			wantReason: "unhealthy service endpoints 2 >= 2",
+		},
+		{
+			name: "required service unavailable",
+			configure: func(cfg *config.Config) {
+				cfg.Services.Required = []string{"storage"}
+			},
+			drive:      func(*testing.T, *APIGateway) {},
+			wantStatus: HealthUnhealthy,
+			wantCode:   http.StatusServiceUnavailable,
+			wantCheck:  "required_services",
+			wantReason: "required services unavailable: [storage]",
+		},
+		{
+			name: "degraded status code",
+			configure: func(cfg *config.Config) {
+				cfg.Health.DegradedStatusCode = http.StatusTooManyRequests
+			},
+			drive:      func(_ *testing.T, g *APIGateway) { fillFrameQueue(g, 5) },
+			wantStatus: HealthDegraded,
+			wantCode:   http.StatusTooManyRequests,
+			wantCheck:  "frame_queue",
+			wantReason: "frame queue fill 50.0% >= 50.0%",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t, func(cfg *config.Config) {
+				cfg.Gateway.BufferSize = 10
+				cfg.Services.VideoProcessing = nil
+				cfg.Services.Analytics = nil
+				cfg.Services.Storage = nil
+				cfg.Services.Notification = nil
+				cfg.Health.QueueDegradedPercent = 50
+				cfg.Health.QueueUnhealthyPercent = 90
+				cfg.Health.UnhealthyServicesDegraded = 1
+				cfg.Health.UnhealthyServicesUnhealthy = 2
+				if tt.configure != nil {
+					tt.configure(cfg)
+				}
+			})
+			tt.drive(t, g)
+
+			code, body := getHealth(t, g)
+			if code != tt.wantCode || body.Status != tt.wantStatus {
+				t.Fatalf("health = %d %s, want %d %s (reasons %v)", code, body.Status, tt.wantCode, tt.wantStatus, body.Reasons)
+			}
+			if tt.wantCheck == "" {
+				if len(body.Reasons) != 0 {
+					t.Errorf("reasons = %v, want none", body.Reasons)
+				}
+				return
+			}
+
+			check := body.Checks[tt.wantCheck]
+			if check.Status != tt.wantStatus || check.Reason != tt.wantReason {
+				t.Errorf("check %s = %+v, want %s %q", tt.wantCheck, check, tt.wantStatus, tt.wantReason)
+			}
+			if len(body.Reasons) != 1 || !strings.Contains(body.Reasons[0], tt.wantReason) {
+				t.Errorf("reasons = %v, want only %q", body.Reasons, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestHealthSendFailureRate(t *testing.T) {
+	var status atomic.Int32
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer service.Close()
+
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Services.VideoProcessing = []string{service.URL}
+		cfg.Services.Analytics = nil
+		cfg.Services.Storage = nil
+		cfg.Services.Notification = nil
+		cfg.Services.Retry.MaxAttempts = 1
+		// Прерыватель не должен выводить эндпоинт из ротации
+		cfg.Services.Breaker.FailureThreshold = 1000
+		cfg.Health.FailRateDegradedPercent = 10
+		cfg.Health.FailRateUnhealthyPercent = 50
+		cfg.Health.FailRateWindow = 60
+		cfg.Health.FailRateMinRequests = 10
+	})
+
+	// Шаги накапливают отправки в одном окне
+	steps := []struct {
+		name       string
+		status     int
+		frames     int
+		wantStatus string
+		wantCode   int
+		wantReason string
+	}{
+		{"successes below min requests", http.StatusOK, 5, HealthHealthy, http.StatusOK, ""},
+		{"failures below min requests", http.StatusServiceUnavailable, 4, HealthHealthy, http.StatusOK, ""},
+		{"failure rate degraded", http.StatusOK, 11, HealthDegraded, http.StatusOK, "send failure rate 20.0% >= 10.0%"},
+		{"failure rate unhealthy", http.StatusServiceUnavailable, 16, HealthUnhealthy, http.StatusServiceUnavailable, "send failure rate 55.6% >= 50.0%"},
+	}
+	sent := int64(0)
+	for _, step := range steps {
+		status.Store(int32(step.status))
+		for i := 0; i < step.frames; i++ {
+			g.HandleVideoFrame(context.Background(), &proto.VideoFrame{FrameID: fmt.Sprintf("f%d", sent), CameraID: "cam-1", ClientID: "cam-1"})
+			sent++
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for g.sendPool.Stats().Processed < sent {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: processed %d of %d sends", step.name, g.sendPool.Stats().Processed, sent)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		code, body := getHealth(t, g)
+		check := body.Checks["send_failures"]
+		if code != step.wantCode || body.Status != step.wantStatus || check.Reason != step.wantReason {
+			t.Errorf("%s: health = %d %s, send_failures %+v; want %d %s %q",
+				step.name, code, body.Status, check, step.wantCode, step.wantStatus, step.wantReason)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"api-gateway/pkg/proto"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// PreForwardHook вызывается до отправки фрейма в сервисы и клиентам.
+// Может изменять фрейм; ошибка отменяет дальнейшую обработку фрейма.
+type PreForwardHook func(ctx context.Context, frame *proto.VideoFrame) error
+
+// PostForwardHook вызывается после отправки фрейма с результатами по типам
+// сервисов ("ok", "queued", "error: ...")
+type PostForwardHook func(ctx context.Context, frame *proto.VideoFrame, results map[string]string)
+
+type namedPreHook struct {
+	name string
+	hook PreForwardHook
+}
+
+type namedPostHook struct {
+	name string
+	hook PostForwardHook
+}
+
+// HookRegistry хуки обработки фреймов (водяные знаки, размытие и т.п.).
+// Регистрируются при старте, вызываются в порядке регистрации. Паника в
+// хуке перехватывается и не роняет шлюз.
+type HookRegistry struct {
+	mu   sync.RWMutex
+	pre  []namedPreHook
+	post []namedPostHook
+}
+
+// NewHookRegistry создает пустой реестр хуков
+func NewHookRegistry() *HookRegistry {
+	return &HookRegistry{}
+}
+
+// RegisterPreForward добавляет хук, вызываемый до отправки фрейма
+func (r *HookRegistry) RegisterPreForward(name string, hook PreForwardHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pre = append(r.pre, namedPreHook{name: name, hook: hook})
+}
+
+// RegisterPostForward добавляет хук, вызываемый после отправки фрейма
+func (r *HookRegistry) RegisterPostForward(name string, hook PostForwardHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.post = append(r.post, namedPostHook{name: name, hook: hook})
+}
+
+// RunPreForward вызывает pre-хуки по порядку. Первая ошибка или паника
+// останавливает цепочку и возвращается вызывающему.
+func (r *HookRegistry) RunPreForward(ctx context.Context, frame *proto.VideoFrame) error {
+	r.mu.RLock()
+	hooks := r.pre
+	r.mu.RUnlock()
+
+	for _, h := range hooks {
+		if err := runPreHook(ctx, h, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunPostForward вызывает post-хуки по порядку. Паника в одном хуке
+// логируется и не мешает остальным.
+func (r *HookRegistry) RunPostForward(ctx context.Context, frame *proto.VideoFrame, results map[string]string) {
+	r.mu.RLock()
+	hooks := r.post
+	r.mu.RUnlock()
+
+	for _, h := range hooks {
+		runPostHook(ctx, h, frame, results)
+	}
+}
+
+// runPreHook вызывает хук, превращая панику в ошибку
+func runPreHook(ctx context.Context, h namedPreHook, frame *proto.VideoFrame) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("pre-forward hook %s panicked: %v", h.name, rec)
+		}
+	}()
+
+	if err := h.hook(ctx, frame); err != nil {
+		return fmt.Errorf("pre-forward hook %s: %v", h.name, err)
+	}
+	return nil
+}
+
+// runPostHook вызывает хук, перехватывая панику
+func runPostHook(ctx context.Context, h namedPostHook, frame *proto.VideoFrame, results map[string]string) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("Post-forward hook %s panicked: %v", h.name, rec)
+		}
+	}()
+
+	h.hook(ctx, frame, results)
+}
@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"api-gateway/pkg/proto"
+)
+
+func TestHooksMutateFrameInOrder(t *testing.T) {
+	watermarks := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var frame proto.VideoFrame
+		json.NewDecoder(r.Body).Decode(&frame)
+		watermarks <- frame.Metadata["watermark"]
+	}))
+	defer server.Close()
+
+	g := newForwardingGateway(t, server.URL)
+	var calls []string
+	var observed map[string]string
+	hooks := g.Hooks()
+	hooks.RegisterPreForward("first", func(ctx context.Context, frame *proto.VideoFrame) error {
+		calls = append(calls, "pre:first")
+		frame.Metadata = map[string]string{"watermark": "a"}
+		return nil
+	})
+	hooks.RegisterPreForward("second", func(ctx context.Context, frame *proto.VideoFrame) error {
+		calls = append(calls, "pre:second")
+		frame.Metadata["watermark"] += "b"
+		return nil
+	})
+	hooks.RegisterPostForward("broken", func(ctx context.Context, frame *proto.VideoFrame, results map[string]string) {
+		calls = append(calls, "post:broken")
+		panic("boom")
+	})
+	hooks.RegisterPostForward("observer", func(ctx context.Context, frame *proto.VideoFrame, results map[string]string) {
+		calls = append(calls, "post:observer")
+		observed = results
+	})
+
+	g.ProcessFrameSync(context.Background(), &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", ClientID: "cam-1"})
+
+	// Сервис получает фрейм с изменениями обоих pre-хуков
+	if got := <-watermarks; got != "ab" {
+		t.Errorf("forwarded watermark = %q, want %q", got, "ab")
+	}
+	want := []string{"pre:first", "pre:second", "post:broken", "post:observer"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("hook calls = %v, want %v", calls, want)
+	}
+	// Паника в post-хуке не мешает следующему
+	if observed["video_processing"] != "ok" {
+		t.Errorf("observed results = %v, want video_processing ok", observed)
+	}
+}
+
+func TestPreForwardHookFailureContained(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    PreForwardHook
+		wantErr string
+	}{
+		{
+			name:    "error",
+			hook:    func(context.Context, *proto.VideoFrame) error { return errors.New("pii detected") },
+			wantErr: "error: pre-forward hook failing: pii detected",
+		},
+		{
+			name:    "panic",
+			hook:    func(context.Context, *proto.VideoFrame) error { panic("boom") },
+			wantErr: "error: pre-forward hook failing panicked: boom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded.Add(1)
+			}))
+			defer server.Close()
+
+			g := newForwardingGateway(t, server.URL)
+			var laterCalled bool
+			g.Hooks().RegisterPreForward("failing", tt.hook)
+			g.Hooks().RegisterPreForward("later", func(context.Context, *proto.VideoFrame) error {
+				laterCalled = true
+				return nil
+			})
+
+			results := g.ProcessFrameSync(context.Background(), &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", ClientID: "cam-1"})
+			if !reflect.DeepEqual(results, map[string]string{"pre_forward": tt.wantErr}) {
+				t.Errorf("results = %v, want pre_forward %q", results, tt.wantErr)
+			}
+			if laterCalled {
+				t.Error("hook after the failing one was called")
+			}
+			if forwarded.Load() != 0 {
+				t.Error("rejected frame was forwarded")
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package gateway
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// keyframeRequestInterval минимальный интервал между запросами ключевого
+// кадра одного стрима: запросы нескольких потребителей объединяются
+const keyframeRequestInterval = time.Second
+
+// streamProducer клиент, последним присылавший фреймы стрима
+type streamProducer struct {
+	clientID         string
+	lastFrame        time.Time
+	lastKeyframeSent time.Time
+}
+
+type frameProducerKey struct{}
+
+// WithFrameProducer связывает с ctx проверенного отправителя фрейма:
+// аутентифицированного клиента или адрес соединения. Производителем стрима
+// (получателем запросов ключевого кадра) записывается только он, а не
+// client_id из тела фрейма.
+func WithFrameProducer(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, frameProducerKey{}, clientID)
+}
+
+// frameProducer возвращает отправителя фрейма из ctx или ""
+func frameProducer(ctx context.Context) string {
+	clientID, _ := ctx.Value(frameProducerKey{}).(string)
+	return clientID
+}
+
+// recordProducer запоминает проверенного отправителя фрейма как
+// производителя стрима; фреймы без него производителя не меняют
+func (g *APIGateway) recordProducer(ctx context.Context, streamID string) {
+	if producer := frameProducer(ctx); producer != "" {
+		g.producers.Record(streamID, producer)
+	}
+}
+
+// StreamProducers отслеживает, какой клиент производит каждый стрим
+// (camera_id фрейма), чтобы доставлять ему управляющие запросы
+type StreamProducers struct {
+	mu      sync.Mutex
+	streams map[string]*streamProducer
+}
+
+// NewStreamProducers создает пустой реестр производителей
+func NewStreamProducers() *StreamProducers {
+	return &StreamProducers{streams: make(map[string]*streamProducer)}
+}
+
+// Record запоминает клиента, приславшего фрейм стрима
+func (p *StreamProducers) Record(streamID, clientID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	producer, ok := p.streams[streamID]
+	if !ok {
+		producer = &streamProducer{}
+		p.streams[streamID] = producer
+	}
+	producer.clientID = clientID
+	producer.lastFrame = time.Now()
+}
+
+// Producer возвращает client_id производителя стрима
+func (p *StreamProducers) Producer(streamID string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	producer, ok := p.streams[streamID]
+	if !ok {
+		return "", false
+	}
+	return producer.clientID, true
+}
+
+// allowKeyframeRequest проверяет, что с прошлого запроса ключевого кадра
+// стрима прошло не меньше keyframeRequestInterval
+func (p *StreamProducers) allowKeyframeRequest(streamID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	producer, ok := p.streams[streamID]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(producer.lastKeyframeSent) < keyframeRequestInterval {
+		return false
+	}
+	producer.lastKeyframeSent = now
+	return true
+}
+
+// Cleanup забывает стримы без фреймов дольше maxAge
+func (p *StreamProducers) Cleanup(maxAge time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for streamID, producer := range p.streams {
+		if now.Sub(producer.lastFrame) > maxAge {
+			delete(p.streams, streamID)
+		}
+	}
+}
+
+// RequestKeyframe ставит в controlChan запрос ключевого кадра стрима.
+// Возвращает false, если очередь управляющих сообщений заполнена.
+func (g *APIGateway) RequestKeyframe(streamID, requestedBy string) bool {
+	msg := &ControlMessage{
+		Type:      ControlKeyframeRequest,
+		ClientID:  requestedBy,
+		Channel:   streamID,
+		Timestamp: time.Now(),
+	}
+
+	select {
+	case g.controlChan <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// handleKeyframeRequest доставляет запрос ключевого кадра во все
+// WebSocket соединения производителя стрима
+func (g *APIGateway) handleKeyframeRequest(msg *ControlMessage) {
+	streamID := msg.Channel
+
+	producerID, ok := g.producers.Producer(streamID)
+	if !ok {
+		log.Printf("Keyframe request for stream %s dropped: no producer", streamID)
+		return
+	}
+	if !g.producers.allowKeyframeRequest(streamID) {
+		return
+	}
+
+	event := map[string]interface{}{
+		"action":       "keyframe_request",
+		"stream_id":    streamID,
+		"requested_by": msg.ClientID,
+		"time":         msg.Timestamp.Unix(),
+	}
+
+	delivered := 0
+	for _, client := range g.clientMgr.GetClientsByID(producerID) {
+		if g.clientMgr.NotifyClient(client, event) {
+			delivered++
+		}
+	}
+	if delivered == 0 {
+		log.Printf("Keyframe request for stream %s not delivered: producer %s has no WebSocket connection",
+			streamID, producerID)
+	}
+}
@@ -0,0 +1,127 @@
+package gateway
+
+import (
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+// partnerRoute партнер с эндпоинтом для отправки и лимитом запросов
+type partnerRoute struct {
+	config    config.PartnerConfig
+	endpoint  *ServiceEndpoint
+	limiter   *RateLimiter
+	throttled int64 // фреймы, не отправленные из-за rate_limit
+}
+
+// partnerRule правило с разобранным условием
+type partnerRule struct {
+	condition *config.RoutingCondition
+	route     *partnerRoute
+}
+
+// PartnerRouter выбирает партнера для фрейма по routing_rules. Если
+// подходят несколько правил, побеждает партнер с меньшим priority, при
+// равенстве - правило, записанное раньше.
+type PartnerRouter struct {
+	rules  []partnerRule
+	routes []*partnerRoute
+}
+
+// NewPartnerRouter строит роутер по секциям partners и routing_rules.
+// Выключенные партнеры и правила с ошибками пропускаются (конфиг
+// проверяется Validate при загрузке).
+func NewPartnerRouter(partners []config.PartnerConfig, rules []config.RoutingRule) *PartnerRouter {
+	router := &PartnerRouter{}
+
+	byName := make(map[string]*partnerRoute, len(partners))
+	for _, partner := range partners {
+		if !partner.Enabled {
+			continue
+		}
+		route := &partnerRoute{
+			config: partner,
+			endpoint: &ServiceEndpoint{
+				ID:        "partner_" + partner.Name,
+				URL:       partner.URL,
+				Type:      "http",
+				Service:   "partner",
+				Priority:  partner.Priority,
+				Healthy:   true,
+				LastCheck: time.Now(),
+				APIKey:    partner.APIKey,
+			},
+			limiter: NewRateLimiter(partner.RateLimit, partner.RateLimit),
+		}
+		byName[partner.Name] = route
+		router.routes = append(router.routes, route)
+	}
+
+	for _, rule := range rules {
+		route, ok := byName[rule.Partner]
+		if !ok {
+			continue
+		}
+		condition, err := rule.ParseCondition()
+		if err != nil {
+			log.Printf("Skipping routing rule for partner %s: %v", rule.Partner, err)
+			continue
+		}
+		router.rules = append(router.rules, partnerRule{condition: condition, route: route})
+	}
+
+	// Стабильная сортировка сохраняет порядок правил при равном приоритете
+	sort.SliceStable(router.rules, func(i, j int) bool {
+		return router.rules[i].route.config.Priority < router.rules[j].route.config.Priority
+	})
+
+	return router
+}
+
+// Match возвращает эндпоинт партнера для фрейма или nil. Если лимит
+// партнера исчерпан, фрейм ему не отправляется.
+func (r *PartnerRouter) Match(frame *proto.VideoFrame) *ServiceEndpoint {
+	for _, rule := range r.rules {
+		if !rule.condition.Match(routingFieldValue(frame, rule.condition.Field)) {
+			continue
+		}
+		if !rule.route.limiter.Allow() {
+			atomic.AddInt64(&rule.route.throttled, 1)
+			return nil
+		}
+		return rule.route.endpoint
+	}
+	return nil
+}
+
+// Stats возвращает статистику партнеров по имени. Вызывается под
+// ServiceRegistry.mu, который защищает счетчики эндпоинтов.
+func (r *PartnerRouter) Stats() map[string]interface{} {
+	stats := make(map[string]interface{}, len(r.routes))
+	for _, route := range r.routes {
+		stats[route.config.Name] = map[string]interface{}{
+			"url":        route.config.URL,
+			"priority":   route.config.Priority,
+			"rate_limit": route.config.RateLimit,
+			"throttled":  atomic.LoadInt64(&route.throttled),
+			"requests":   route.endpoint.Stats.TotalRequests,
+			"errors":     route.endpoint.Stats.ErrorCount,
+		}
+	}
+	return stats
+}
+
+func routingFieldValue(frame *proto.VideoFrame, field string) string {
+	switch field {
+	case "client_id":
+		return frame.ClientID
+	case "camera_id":
+		return frame.CameraID
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+func TestPartnerRouterMatch(t *testing.T) {
+	partners := []config.PartnerConfig{
+		{Name: "acme", URL: "http://acme.example", Enabled: true, Priority: 2},
+		{Name: "acme-vip", URL: "http://vip.example", Enabled: true, Priority: 1},
+		{Name: "cams", URL: "http://cams.example", Enabled: true, Priority: 3},
+		{Name: "disabled", URL: "http://off.example", Enabled: false, Priority: 0},
+	}
+	rules := []config.RoutingRule{
+		{Condition: "client_id LIKE 'acme_%'", Partner: "acme"},
+		{Condition: "client_id = 'acme_vip'", Partner: "acme-vip"},
+		{Condition: "camera_id LIKE '%-lobby'", Partner: "cams"},
+		{Condition: "client_id LIKE '%'", Partner: "disabled"},
+	}
+	router := NewPartnerRouter(partners, rules)
+
+	tests := []struct {
+		name        string
+		frame       *proto.VideoFrame
+		wantPartner string // ID эндпоинта партнера, "" - фрейм партнерам не отправляется
+	}{
+		{"prefix match", &proto.VideoFrame{ClientID: "acme_42", CameraID: "cam-1"}, "partner_acme"},
+		{"higher priority partner wins", &proto.VideoFrame{ClientID: "acme_vip", CameraID: "cam-1"}, "partner_acme-vip"},
+		{"underscore is literal", &proto.VideoFrame{ClientID: "acmex42", CameraID: "cam-1"}, ""},
+		{"camera rule", &proto.VideoFrame{ClientID: "other", CameraID: "hq-lobby"}, "partner_cams"},
+		{"disabled partner skipped", &proto.VideoFrame{ClientID: "other", CameraID: "cam-1"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := router.Match(tt.frame)
+			got := ""
+			if endpoint != nil {
+				got = endpoint.ID
+			}
+			if got != tt.wantPartner {
+				t.Errorf("partner = %q, want %q", got, tt.wantPartner)
+			}
+		})
+	}
+}
+
+func TestPartnerRateLimit(t *testing.T) {
+	router := NewPartnerRouter(
+		[]config.PartnerConfig{{Name: "acme", URL: "http://acme.example", Enabled: true, RateLimit: 3}},
+		[]config.RoutingRule{{Condition: "client_id LIKE 'acme_%'", Partner: "acme"}},
+	)
+	frame := &proto.VideoFrame{ClientID: "acme_1"}
+
+	// Запас лимитера - rate_limit фреймов, дальше партнер пропускается
+	for i := 0; i < 3; i++ {
+		if router.Match(frame) == nil {
+			t.Fatalf("frame %d was throttled within the rate limit", i+1)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if endpoint := router.Match(frame); endpoint != nil {
+			t.Fatalf("frame %d over the rate limit routed to %s", 4+i, endpoint.ID)
+		}
+	}
+
+	stats := router.Stats()["acme"].(map[string]interface{})
+	if throttled := stats["throttled"].(int64); throttled != 2 {
+		t.Errorf("throttled = %d, want 2", throttled)
+	}
+}
+
+func TestPartnerReceivesAPIKey(t *testing.T) {
+	apiKeys := make(chan string, 1)
+	partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKeys <- r.Header.Get("X-API-Key")
+	}))
+	defer partner.Close()
+
+	cfg := config.GetDefaultConfig()
+	cfg.Services.VideoProcessing = nil
+	cfg.Services.Storage = nil
+	cfg.Partners = []config.PartnerConfig{{Name: "acme", URL: partner.URL, APIKey: "acme-key", Enabled: true, RateLimit: 10}}
+	cfg.RoutingRules = []config.RoutingRule{{Condition: "client_id LIKE 'acme_%'", Partner: "acme"}}
+	registry := NewServiceRegistry(cfg, NewMemoryStatsSink())
+
+	frame := &proto.VideoFrame{ClientID: "acme_1", CameraID: "cam-1"}
+	endpoints := registry.GetServicesForFrame(frame)
+	if len(endpoints) != 1 || endpoints[0].ID != "partner_acme" {
+		t.Fatalf("endpoints = %+v, want only partner_acme", endpoints)
+	}
+	if err := registry.SendToService(context.Background(), endpoints[0], frame); err != nil {
+		t.Fatalf("send to partner: %v", err)
+	}
+	if key := <-apiKeys; key != "acme-key" {
+		t.Errorf("X-API-Key = %q, want %q", key, "acme-key")
+	}
+}
@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrometheusStatsSink накапливает счетчики и отдает их в текстовом формате
+// Prometheus через ServeHTTP (эндпоинт /metrics)
+type PrometheusStatsSink struct {
+	prefix string
+
+	mu       sync.Mutex
+	frames   float64
+	bytes    float64
+	calls    map[[2]string]float64 // {service, result} -> запросы
+	latency  map[string]float64    // service -> суммарная задержка, секунды
+	observed map[string]float64    // service -> число замеров задержки
+	clients  map[string]float64    // event -> число событий
+}
+
+// NewPrometheusStatsSink создает sink; prefix добавляется к именам метрик
+func NewPrometheusStatsSink(prefix string) *PrometheusStatsSink {
+	if prefix == "" {
+		prefix = "api_gateway"
+	}
+	return &PrometheusStatsSink{
+		prefix:   strings.ReplaceAll(prefix, ".", "_"),
+		calls:    make(map[[2]string]float64),
+		latency:  make(map[string]float64),
+		observed: make(map[string]float64),
+		clients:  make(map[string]float64),
+	}
+}
+
+// RecordFrame учитывает принятый фрейм
+func (s *PrometheusStatsSink) RecordFrame(clientID string, bytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.frames++
+	s.bytes += float64(bytes)
+}
+
+// RecordServiceCall учитывает запрос к сервису
+func (s *PrometheusStatsSink) RecordServiceCall(service, endpointID string, success bool, latency time.Duration) {
+	result := "success"
+	if !success {
+		result = "error"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[[2]string{service, result}]++
+	s.latency[service] += latency.Seconds()
+	s.observed[service]++
+}
+
+// RecordClient учитывает событие клиента
+func (s *PrometheusStatsSink) RecordClient(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clients[event]++
+}
+
+// ServeHTTP отдает метрики в формате Prometheus text exposition
+func (s *PrometheusStatsSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+
+	s.mu.Lock()
+	s.writeCounter(&b, "frames_total", "Frames received by the gateway", nil, s.frames)
+	s.writeCounter(&b, "frame_bytes_total", "Frame payload bytes received", nil, s.bytes)
+
+	s.writeHeader(&b, "service_requests_total", "counter", "Requests sent to downstream services")
+	callKeys := make([][2]string, 0, len(s.calls))
+	for key := range s.calls {
+		callKeys = append(callKeys, key)
+	}
+	sort.Slice(callKeys, func(i, j int) bool {
+		return callKeys[i][0]+callKeys[i][1] < callKeys[j][0]+callKeys[j][1]
+	})
+	for _, key := range callKeys {
+		s.writeSample(&b, "service_requests_total",
+			map[string]string{"service": key[0], "result": key[1]}, s.calls[key])
+	}
+
+	s.writeHeader(&b, "service_request_duration_seconds", "summary", "Downstream service request latency")
+	for _, service := range sortedKeys(s.latency) {
+		labels := map[string]string{"service": service}
+		s.writeSample(&b, "service_request_duration_seconds_sum", labels, s.latency[service])
+		s.writeSample(&b, "service_request_duration_seconds_count", labels, s.observed[service])
+	}
+
+	s.writeHeader(&b, "client_events_total", "counter", "WebSocket client events")
+	for _, event := range sortedKeys(s.clients) {
+		s.writeSample(&b, "client_events_total", map[string]string{"event": event}, s.clients[event])
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func (s *PrometheusStatsSink) writeHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", s.prefix, name, help, s.prefix, name, kind)
+}
+
+func (s *PrometheusStatsSink) writeCounter(b *strings.Builder, name, help string, labels map[string]string, value float64) {
+	s.writeHeader(b, name, "counter", help)
+	s.writeSample(b, name, labels, value)
+}
+
+func (s *PrometheusStatsSink) writeSample(b *strings.Builder, name string, labels map[string]string, value float64) {
+	b.WriteString(s.prefix + "_" + name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels))
+		for _, key := range sortedKeys(labels) {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", key, labels[key]))
+		}
+		b.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	fmt.Fprintf(b, " %g\n", value)
+}
+
+// sortedKeys возвращает ключи карты по алфавиту для стабильного вывода
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
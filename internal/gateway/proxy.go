@@ -0,0 +1,161 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"api-gateway/internal/requestid"
+)
+
+// proxyPathPrefix префикс маршрута сквозного прокси: /proxy/<имя>/<путь>
+const proxyPathPrefix = "/proxy/"
+
+// Заголовки с личностью клиента, которые шлюз выставляет в проксируемых
+// запросах по проверенному токену. Присланные клиентом значения
+// отбрасываются; для анонимного запроса заголовки не передаются.
+const (
+	ProxyClientIDHeader = "X-Client-ID"
+	ProxyUserIDHeader   = "X-User-ID"
+	ProxyRolesHeader    = "X-User-Roles"
+)
+
+// proxyIdentity клиент, от имени которого проксируется запрос; пустой
+// для анонимного запроса
+type proxyIdentity struct {
+	ClientID string
+	UserID   string // sub токена
+	Roles    []string
+}
+
+// handleProxy проксирует /proxy/<имя>/<путь> в сервис из proxy.routes
+func (g *APIGateway) handleProxy(w http.ResponseWriter, r *http.Request) {
+	name, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, proxyPathPrefix), "/")
+	serviceType, ok := g.config.Proxy.Routes[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	identity, err := g.resolveProxyIdentity(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	endpoint := g.services.ProxyEndpoint(serviceType)
+	if endpoint == nil {
+		http.Error(w, "Service unavailable: "+serviceType, http.StatusServiceUnavailable)
+		return
+	}
+	target, err := url.Parse(endpoint.URL)
+	if err != nil {
+		http.Error(w, "Invalid service URL", http.StatusBadGateway)
+		return
+	}
+
+	ctx, cancel := g.services.WithServiceTimeout(r.Context(), serviceType)
+	defer cancel()
+
+	g.services.newReverseProxy(endpoint, target, path, identity).ServeHTTP(w, r.WithContext(ctx))
+}
+
+// resolveProxyIdentity определяет клиента по Bearer JWT. Без токена (если
+// proxy.auth_required выключен) запрос анонимный: присланному клиентом
+// client_id не доверяем.
+func (g *APIGateway) resolveProxyIdentity(r *http.Request) (*proxyIdentity, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		if g.config.Proxy.AuthRequired {
+			return nil, ErrMissingToken
+		}
+		return &proxyIdentity{}, nil
+	}
+
+	claims, err := ValidateToken(g.config.JWT.Secret, token)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyIdentity{
+		ClientID: claimsClientID(claims),
+		UserID:   claims.Subject,
+		Roles:    claims.Roles,
+	}, nil
+}
+
+// ProxyEndpoint возвращает эндпоинт для сквозного прокси: здоровый
+// эндпоинт типа сервиса с наивысшим приоритетом или nil
+func (sr *ServiceRegistry) ProxyEndpoint(serviceType string) *ServiceEndpoint {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	healthy := sr.getHealthyServices(serviceType)
+	if len(healthy) == 0 {
+		return nil
+	}
+	return healthy[0]
+}
+
+// newReverseProxy создает прокси запроса на target/path. Метод, заголовки
+// и тело запроса сохраняются; ответ и ошибки учитываются в статистике и
+// прерывателе эндпоинта (сетевая ошибка и 5xx - сбой).
+func (sr *ServiceRegistry) newReverseProxy(endpoint *ServiceEndpoint, target *url.URL, path string, identity *proxyIdentity) *httputil.ReverseProxy {
+	start := time.Now()
+
+	return &httputil.ReverseProxy{
+		Transport: sr.client.Transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			out := *target
+			out.Path = strings.TrimSuffix(target.Path, "/") + "/" + path
+			out.RawPath = ""
+			out.RawQuery = pr.In.URL.RawQuery
+			pr.Out.URL = &out
+			pr.Out.Host = ""
+			pr.SetXForwarded()
+
+			header := pr.Out.Header
+			header.Set("X-API-Gateway", "video-streaming")
+			header.Del(ProxyClientIDHeader)
+			header.Del(ProxyUserIDHeader)
+			header.Del(ProxyRolesHeader)
+			if identity.ClientID != "" {
+				header.Set(ProxyClientIDHeader, identity.ClientID)
+			}
+			if identity.UserID != "" {
+				header.Set(ProxyUserIDHeader, identity.UserID)
+			}
+			if len(identity.Roles) > 0 {
+				header.Set(ProxyRolesHeader, strings.Join(identity.Roles, ","))
+			}
+			if endpoint.APIKey != "" {
+				header.Set("X-API-Key", endpoint.APIKey)
+			}
+			requestid.SetHeader(pr.In.Context(), header)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			success := resp.StatusCode < http.StatusInternalServerError
+			sr.recordOutcome(endpoint, success, time.Since(start))
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// Отмена запроса клиентом не говорит о здоровье сервиса
+			if sendCancelled(r.Context()) {
+				sr.updateServiceStats(endpoint, false, time.Since(start))
+				return
+			}
+			sr.recordOutcome(endpoint, false, time.Since(start))
+			log.Printf("Proxy to %s (%s) failed: %v", endpoint.ID, endpoint.Service, err)
+
+			status := http.StatusBadGateway
+			if errors.Is(err, context.DeadlineExceeded) {
+				status = http.StatusGatewayTimeout
+			}
+			http.Error(w, "Service request failed", status)
+		},
+	}
+}
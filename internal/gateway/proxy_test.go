@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-gateway/internal/config"
+)
+
+func TestProxyIdentityHeaders(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	cfg := config.GetDefaultConfig()
+	cfg.JWT.Secret = "0123456789abcdef0123456789abcdef"
+	cfg.Services.Analytics = []string{upstream.URL}
+	cfg.Proxy.Routes = map[string]string{"analytics": "analytics"}
+	g := &APIGateway{config: cfg, services: NewServiceRegistry(cfg, NewMemoryStatsSink())}
+
+	token := signTestToken(t, cfg.JWT.Secret, TokenClaims{Subject: "user-1", ClientID: "cam-1", Roles: []string{"viewer"}})
+
+	tests := []struct {
+		name         string
+		target       string
+		auth         string
+		spoofed      string
+		wantClientID string
+		wantUserID   string
+	}{
+		{"anonymous caller gets no identity", "/proxy/analytics/x?client_id=victim", "", "victim", "", ""},
+		{"token identity replaces spoofed header", "/proxy/analytics/x", "Bearer " + token, "victim", "cam-1", "user-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Header.Set(ProxyClientIDHeader, tt.spoofed)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			g.handleProxy(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", w.Code, w.Body.String())
+			}
+			if id := got.Get(ProxyClientIDHeader); id != tt.wantClientID {
+				t.Errorf("%s = %q, want %q", ProxyClientIDHeader, id, tt.wantClientID)
+			}
+			if id := got.Get(ProxyUserIDHeader); id != tt.wantUserID {
+				t.Errorf("%s = %q, want %q", ProxyUserIDHeader, id, tt.wantUserID)
+			}
+		})
+	}
+}
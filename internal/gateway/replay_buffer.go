@@ -0,0 +1,132 @@
+package gateway
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway/pkg/proto"
+)
+
+// KeyframeMetadataKey ключ metadata фрейма, отмечающий ключевой кадр
+// ("true" или "1")
+const KeyframeMetadataKey = "keyframe"
+
+// replayFrame сериализованный фрейм в буфере повтора
+type replayFrame struct {
+	data         []byte
+	requiredTags []string
+}
+
+// channelReplay последний ключевой кадр канала и кадры сразу после него
+type channelReplay struct {
+	keyframe *replayFrame
+	after    []replayFrame // первые кадры после ключевого, не больше ReplayBuffer.frames
+	lastSeen time.Time     // последний записанный кадр канала, см. Cleanup
+}
+
+// ReplayBuffer хранит по каналам последний ключевой кадр и первые frames
+// кадров после него, чтобы новый подписчик мог начать декодирование сразу,
+// не дожидаясь следующего ключевого кадра. Кадры после frames-го не
+// сохраняются: без пропуска декодер может восстановить только начало
+// группы кадров. Кадры до первого ключевого не сохраняются.
+type ReplayBuffer struct {
+	frames          int
+	keyframeFormats map[string]struct{}
+
+	mu       sync.Mutex
+	channels map[string]*channelReplay
+}
+
+// NewReplayBuffer создает буфер повтора. frames - сколько кадров после
+// ключевого хранить (0 - только ключевой); в форматах keyframeFormats
+// (например, jpeg) каждый кадр считается ключевым.
+func NewReplayBuffer(frames int, keyframeFormats []string) *ReplayBuffer {
+	if frames < 0 {
+		frames = 0
+	}
+	formats := make(map[string]struct{}, len(keyframeFormats))
+	for _, format := range keyframeFormats {
+		formats[strings.ToLower(format)] = struct{}{}
+	}
+	return &ReplayBuffer{
+		frames:          frames,
+		keyframeFormats: formats,
+		channels:        make(map[string]*channelReplay),
+	}
+}
+
+// IsKeyframe сообщает, является ли фрейм ключевым: по metadata keyframe
+// или по формату
+func (b *ReplayBuffer) IsKeyframe(frame *proto.VideoFrame) bool {
+	switch frame.Metadata[KeyframeMetadataKey] {
+	case "true", "1":
+		return true
+	}
+	_, ok := b.keyframeFormats[strings.ToLower(frame.Format)]
+	return ok
+}
+
+// Record запоминает фрейм канала. Ключевой кадр заменяет предыдущий и
+// сбрасывает кадры после него; после frames кадров запись до следующего
+// ключевого прекращается.
+func (b *ReplayBuffer) Record(channel string, requiredTags []string, data []byte, keyframe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	replay := b.channels[channel]
+	if keyframe {
+		if replay == nil {
+			replay = &channelReplay{}
+			b.channels[channel] = replay
+		}
+		replay.keyframe = &replayFrame{data: data, requiredTags: requiredTags}
+		replay.after = replay.after[:0]
+		replay.lastSeen = time.Now()
+		return
+	}
+	if replay == nil {
+		return
+	}
+	replay.lastSeen = time.Now()
+	if len(replay.after) < b.frames {
+		replay.after = append(replay.after, replayFrame{data: data, requiredTags: requiredTags})
+	}
+}
+
+// Frames возвращает кадры для повтора новому подписчику канала: ключевой
+// кадр и затем сохраненные кадры после него в порядке поступления
+func (b *ReplayBuffer) Frames(channel string) []replayFrame {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	replay := b.channels[channel]
+	if replay == nil {
+		return nil
+	}
+	frames := make([]replayFrame, 0, 1+len(replay.after))
+	frames = append(frames, *replay.keyframe)
+	return append(frames, replay.after...)
+}
+
+// Forget удаляет буфер канала
+func (b *ReplayBuffer) Forget(channel string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.channels, channel)
+}
+
+// Cleanup удаляет буферы каналов без кадров дольше maxAge: остановленный
+// стрим не держит свой последний ключевой кадр в памяти
+func (b *ReplayBuffer) Cleanup(maxAge time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for channel, replay := range b.channels {
+		if now.Sub(replay.lastSeen) > maxAge {
+			delete(b.channels, channel)
+		}
+	}
+}
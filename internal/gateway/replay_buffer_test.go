@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+// replayData возвращает содержимое кадров буфера повтора канала
+func replayData(b *ReplayBuffer, channel string) []string {
+	var data []string
+	for _, frame := range b.Frames(channel) {
+		data = append(data, string(frame.data))
+	}
+	return data
+}
+
+func TestReplayBufferRecord(t *testing.T) {
+	type record struct {
+		data     string
+		keyframe bool
+	}
+
+	tests := []struct {
+		name    string
+		frames  int
+		records []record
+		want    []string
+	}{
+		{"nothing before keyframe", 2, []record{{"p1", false}}, nil},
+		{"keyframe only", 0, []record{{"k1", true}, {"p1", false}}, []string{"k1"}},
+		{"frames after keyframe", 3, []record{{"k1", true}, {"p1", false}, {"p2", false}}, []string{"k1", "p1", "p2"}},
+		{"stops after limit without gaps", 2, []record{{"k1", true}, {"p1", false}, {"p2", false}, {"p3", false}}, []string{"k1", "p1", "p2"}},
+		{"new keyframe resets", 2, []record{{"k1", true}, {"p1", false}, {"k2", true}, {"p2", false}}, []string{"k2", "p2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewReplayBuffer(tt.frames, nil)
+			for _, r := range tt.records {
+				b.Record("cam", nil, []byte(r.data), r.keyframe)
+			}
+			got := replayData(b, "cam")
+			if len(got) != len(tt.want) {
+				t.Fatalf("Frames() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Frames() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayBufferCleanup(t *testing.T) {
+	b := NewReplayBuffer(1, nil)
+	b.Record("stale", nil, []byte("k"), true)
+	b.channels["stale"].lastSeen = time.Now().Add(-time.Hour)
+	b.Record("live", nil, []byte("k"), true)
+
+	b.Cleanup(time.Minute)
+
+	if b.Frames("stale") != nil {
+		t.Error("stale channel was not evicted")
+	}
+	if b.Frames("live") == nil {
+		t.Error("live channel was evicted")
+	}
+}
+
+func TestReplayFitsSubscriberBuffer(t *testing.T) {
+	tests := []struct {
+		name       string
+		sendBuffer int
+		required   []string
+		want       []string
+	}{
+		{"whole replay fits", 4, nil, []string{"k1", "p1", "p2"}},
+		{"keyframe kept when buffer is small", 1, nil, []string{"k1"}},
+		{"forbidden keyframe skips replay", 4, []string{"secret"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := NewClientManager(ClientLimits{SendBufferSize: tt.sendBuffer})
+			replay := NewReplayBuffer(2, nil)
+			cm.SetReplayBuffer(replay)
+			replay.Record("cam", tt.required, []byte("k1"), true)
+			replay.Record("cam", nil, []byte("p1"), false)
+			replay.Record("cam", nil, []byte("p2"), false)
+
+			client, err := cm.RegisterClient("client-1", "127.0.0.1", "test")
+			if err != nil {
+				t.Fatalf("RegisterClient: %v", err)
+			}
+			if err := cm.SubscribeClient(client.ConnectionID, "cam"); err != nil {
+				t.Fatalf("SubscribeClient: %v", err)
+			}
+
+			var got []string
+			for len(client.SendChan) > 0 {
+				got = append(got, string(<-client.SendChan))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("replayed %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("replayed %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
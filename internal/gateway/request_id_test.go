@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway/internal/requestid"
+)
+
+func TestRequestIDForwardedToServices(t *testing.T) {
+	forwarded := make(chan string, 8)
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		forwarded <- r.Header.Get(requestid.Header)
+	}))
+	t.Cleanup(service.Close)
+
+	g := newForwardingGateway(t, service.URL)
+	handler := withRequestID(http.HandlerFunc(g.handleVideoStream))
+	token := signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "cam-1"})
+
+	tests := []struct {
+		name   string
+		target string
+		sent   string // "" - шлюз генерирует идентификатор сам
+	}{
+		{"client id, sync", "/api/v1/video/stream?sync=true", "req-sync-1"},
+		{"client id, queued", "/api/v1/video/stream", "req-queued-1"},
+		{"generated id", "/api/v1/video/stream?sync=true", ""},
+		{"invalid id replaced", "/api/v1/video/stream?sync=true", "bad id with spaces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(`{"frame_id":"f1","camera_id":"cam-1"}`))
+			r.Header.Set("Authorization", "Bearer "+token)
+			if tt.sent != "" {
+				r.Header.Set(requestid.Header, tt.sent)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d (%s)", w.Code, w.Body.String())
+			}
+
+			id := w.Header().Get(requestid.Header)
+			if !requestid.Valid(id) {
+				t.Fatalf("response %s = %q", requestid.Header, id)
+			}
+			if requestid.Valid(tt.sent) && id != tt.sent {
+				t.Errorf("response %s = %q, want %q", requestid.Header, id, tt.sent)
+			}
+
+			select {
+			case got := <-forwarded:
+				if got != id {
+					t.Errorf("forwarded %s = %q, want %q", requestid.Header, got, id)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("frame was not forwarded")
+			}
+		})
+	}
+}
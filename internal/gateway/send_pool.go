@@ -0,0 +1,270 @@
+package gateway
+
+import (
+	"api-gateway/pkg/proto"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/requestid"
+	"api-gateway/internal/retry"
+)
+
+const (
+	defaultSendWorkers   = 16
+	defaultSendQueueSize = 1024
+)
+
+// ErrSendQueueFull - задание не поместилось в очередь за время ожидания
+var ErrSendQueueFull = errors.New("send queue full")
+
+// sendJob задание на отправку фрейма (или пакета фреймов) в сервис
+type sendJob struct {
+	service *ServiceEndpoint
+	frame   *proto.VideoFrame
+	batch   []*proto.VideoFrame
+	ctx     context.Context // контекст фрейма: request ID, отмена и предел обработки (у пакета нет)
+	done    func()          // отпускает контекст фрейма после отправки
+}
+
+// SendPool ограниченный пул воркеров для отправки фреймов в сервисы.
+// Вместо горутины на каждую пару (фрейм, сервис) задания ставятся в
+// общую очередь; при заполнении очереди Submit ждет не дольше
+// enqueueTimeout, после чего задание отбрасывается. Временные ошибки
+// отправки повторяются по retryPolicy.
+type SendPool struct {
+	registry       *ServiceRegistry
+	jobs           chan sendJob
+	workers        int
+	enqueueTimeout time.Duration
+	retryPolicy    retry.Policy
+	wg             sync.WaitGroup
+
+	busy      int32
+	submitted int64
+	processed int64
+	failed    int64
+	dropped   int64
+	cancelled int64 // задания, контекст фрейма которых отменен до отправки
+
+	// Исходы отправок за окно health.fail_rate_window
+	recent *outcomeWindow
+
+	// Теневые задания не входят в счетчики выше (по ним считается health)
+	shadowSubmitted int64
+	shadowDropped   int64
+	shadowFailed    int64
+}
+
+// SendPoolStats статистика пула отправки
+type SendPoolStats struct {
+	Workers     int   `json:"workers"`
+	BusyWorkers int32 `json:"busy_workers"`
+	QueueSize   int   `json:"queue_size"`
+	QueueLength int   `json:"queue_length"`
+	Submitted   int64 `json:"submitted"`
+	Processed   int64 `json:"processed"`
+	Failed      int64 `json:"failed"`
+	Dropped     int64 `json:"dropped"`
+	Cancelled   int64 `json:"cancelled"`
+
+	RecentProcessed int64 `json:"recent_processed"` // за окно health.fail_rate_window
+	RecentFailed    int64 `json:"recent_failed"`
+
+	ShadowSubmitted int64 `json:"shadow_submitted"`
+	ShadowDropped   int64 `json:"shadow_dropped"`
+	ShadowFailed    int64 `json:"shadow_failed"`
+}
+
+// NewSendPool создает пул отправки. enqueueTimeout <= 0 - ждать место в
+// очереди без ограничения. failWindow - окно учета последних исходов
+// отправок для health.
+func NewSendPool(registry *ServiceRegistry, workers, queueSize int, enqueueTimeout time.Duration, retryPolicy retry.Policy, failWindow time.Duration) *SendPool {
+	if workers <= 0 {
+		workers = defaultSendWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultSendQueueSize
+	}
+
+	return &SendPool{
+		registry: registry,
+		jobs:     make(chan sendJob, queueSize),
+		workers:  workers,
+
+		enqueueTimeout: enqueueTimeout,
+		retryPolicy:    retryPolicy,
+		recent:         newOutcomeWindow(failWindow),
+	}
+}
+
+// Start запускает воркеры, которые работают до отмены контекста
+func (p *SendPool) Start(ctx context.Context) {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.worker(ctx)
+		}()
+	}
+}
+
+// Stop ожидает завершения воркеров (контекст должен быть отменен)
+func (p *SendPool) Stop() {
+	p.wg.Wait()
+}
+
+// Submit ставит задание в очередь. Если очередь заполнена, вызов
+// блокируется до освобождения места или отмены контекста. Отправка
+// выполняется в ctx: отмененный к тому времени фрейм не отправляется.
+func (p *SendPool) Submit(ctx context.Context, service *ServiceEndpoint, frame *proto.VideoFrame) error {
+	done := acquireFrame(ctx)
+	err := p.enqueue(ctx, sendJob{service: service, frame: frame, ctx: ctx, done: done})
+	if err != nil {
+		done()
+	}
+	return err
+}
+
+// SubmitBatch ставит в очередь отправку пакета фреймов одним запросом
+func (p *SendPool) SubmitBatch(ctx context.Context, service *ServiceEndpoint, frames []*proto.VideoFrame) error {
+	return p.enqueue(ctx, sendJob{service: service, batch: frames})
+}
+
+// SubmitShadow ставит в очередь копию фрейма для теневого эндпоинта. Не
+// ждет места в очереди: при заполненной очереди копия отбрасывается, чтобы
+// теневой трафик не задерживал боевой.
+func (p *SendPool) SubmitShadow(ctx context.Context, service *ServiceEndpoint, frame *proto.VideoFrame) bool {
+	done := acquireFrame(ctx)
+	select {
+	case p.jobs <- sendJob{service: service, frame: frame, ctx: ctx, done: done}:
+		atomic.AddInt64(&p.shadowSubmitted, 1)
+		return true
+	default:
+		done()
+		atomic.AddInt64(&p.shadowDropped, 1)
+		return false
+	}
+}
+
+// enqueue ставит задание в очередь с учетом отмены контекста
+func (p *SendPool) enqueue(ctx context.Context, job sendJob) error {
+	select {
+	case p.jobs <- job:
+		atomic.AddInt64(&p.submitted, 1)
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if p.enqueueTimeout > 0 {
+		timer := time.NewTimer(p.enqueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case p.jobs <- job:
+		atomic.AddInt64(&p.submitted, 1)
+		return nil
+	case <-timeout:
+		atomic.AddInt64(&p.dropped, 1)
+		return ErrSendQueueFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats возвращает текущую статистику пула
+func (p *SendPool) Stats() SendPoolStats {
+	recentProcessed, recentFailed := p.recent.counts()
+	return SendPoolStats{
+		Workers:     p.workers,
+		BusyWorkers: atomic.LoadInt32(&p.busy),
+		QueueSize:   cap(p.jobs),
+		QueueLength: len(p.jobs),
+		Submitted:   atomic.LoadInt64(&p.submitted),
+		Processed:   atomic.LoadInt64(&p.processed),
+		Failed:      atomic.LoadInt64(&p.failed),
+		Dropped:     atomic.LoadInt64(&p.dropped),
+		Cancelled:   atomic.LoadInt64(&p.cancelled),
+
+		RecentProcessed: recentProcessed,
+		RecentFailed:    recentFailed,
+
+		ShadowSubmitted: atomic.LoadInt64(&p.shadowSubmitted),
+		ShadowDropped:   atomic.LoadInt64(&p.shadowDropped),
+		ShadowFailed:    atomic.LoadInt64(&p.shadowFailed),
+	}
+}
+
+// worker обрабатывает задания из очереди
+func (p *SendPool) worker(ctx context.Context) {
+	for {
+		select {
+		case job := <-p.jobs:
+			p.process(ctx, job)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// process отправляет фрейм в сервис с таймаутом его типа. Отправка
+// прерывается отменой контекста фрейма или остановкой пула; фрейм,
+// отмененный до начала отправки, учитывается как cancelled.
+func (p *SendPool) process(ctx context.Context, job sendJob) {
+	atomic.AddInt32(&p.busy, 1)
+	defer atomic.AddInt32(&p.busy, -1)
+	if job.done != nil {
+		defer job.done()
+	}
+
+	parent := job.ctx
+	if parent == nil {
+		parent = ctx
+	}
+	if parent.Err() != nil {
+		atomic.AddInt64(&p.cancelled, 1)
+		return
+	}
+
+	sendCtx, cancel := p.registry.WithServiceTimeout(parent, job.service.Service)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	// Повторы укладываются в общий таймаут сервиса; теневые копии не повторяются
+	policy := p.retryPolicy
+	if job.service.Shadow {
+		policy.MaxAttempts = 1
+	}
+	err := retry.Do(sendCtx, policy, func(ctx context.Context) error {
+		if job.batch != nil {
+			return p.registry.SendBatchToService(ctx, job.service, job.batch)
+		}
+		return p.registry.SendToService(ctx, job.service, job.frame)
+	})
+	if job.service.Shadow {
+		if err != nil && !sendCancelled(sendCtx) {
+			atomic.AddInt64(&p.shadowFailed, 1)
+		}
+		return
+	}
+	if err != nil && sendCancelled(sendCtx) {
+		// Фрейм отменен источником, истек его frame_timeout_ms или пул
+		// остановлен - это не сбой сервиса
+		atomic.AddInt64(&p.cancelled, 1)
+		return
+	}
+	if err != nil {
+		atomic.AddInt64(&p.failed, 1)
+		log.Printf("Send to service %s failed (request_id=%s): %v",
+			job.service.ID, requestid.FromContext(sendCtx), err)
+	}
+	atomic.AddInt64(&p.processed, 1)
+	p.recent.record(err != nil)
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/internal/retry"
+	"api-gateway/pkg/proto"
+)
+
+func TestSendPoolCapsConcurrency(t *testing.T) {
+	const workers, queueSize, flood = 4, 8, 50
+
+	var inFlight, maxInFlight atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		<-release
+	}))
+	defer server.Close()
+
+	registry, endpoint := newTestRegistry(t, server.URL)
+	pool := NewSendPool(registry, workers, queueSize, 20*time.Millisecond, retry.Policy{MaxAttempts: 1}, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Start(ctx)
+	defer func() {
+		cancel()
+		pool.Stop()
+	}()
+
+	// Сначала заняты все воркеры, затем поток фреймов больше, чем
+	// вмещает очередь
+	frame := &proto.VideoFrame{ClientID: "cam-1"}
+	for i := 0; i < workers; i++ {
+		if err := pool.Submit(context.Background(), endpoint, frame); err != nil {
+			t.Fatalf("submit %d: %v", i, err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for inFlight.Load() < workers {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d workers busy", inFlight.Load(), workers)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	var full atomic.Int32
+	for i := workers; i < flood; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pool.Submit(context.Background(), endpoint, frame); errors.Is(err, ErrSendQueueFull) {
+				full.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := pool.Stats()
+	if stats.Submitted != workers+queueSize || stats.Dropped != flood-workers-queueSize || int(full.Load()) != flood-workers-queueSize {
+		t.Errorf("submitted %d, dropped %d (%d ErrSendQueueFull), want %d and %d",
+			stats.Submitted, stats.Dropped, full.Load(), workers+queueSize, flood-workers-queueSize)
+	}
+	if got := maxInFlight.Load(); got != workers {
+		t.Errorf("max concurrent sends = %d, want %d", got, workers)
+	}
+
+	close(release)
+	deadline = time.Now().Add(2 * time.Second)
+	for pool.Stats().Processed < workers+queueSize {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool processed %d of %d jobs", pool.Stats().Processed, workers+queueSize)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := maxInFlight.Load(); got != workers {
+		t.Errorf("max concurrent sends after drain = %d, want %d", got, workers)
+	}
+}
+
+func BenchmarkSendPoolSubmit(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cfg := config.GetDefaultConfig()
+	cfg.Services.VideoProcessing = []string{server.URL}
+	registry := NewServiceRegistry(cfg, NewMemoryStatsSink())
+	endpoint := registry.services["video_processing"][0]
+
+	pool := NewSendPool(registry, defaultSendWorkers, defaultSendQueueSize, 0, retry.Policy{MaxAttempts: 1}, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Start(ctx)
+	defer func() {
+		cancel()
+		pool.Stop()
+	}()
+
+	frame := &proto.VideoFrame{ClientID: "cam-1", FrameData: "AAECAw=="}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Submit(context.Background(), endpoint, frame)
+		}
+	})
+	for pool.Stats().Processed < int64(b.N) {
+		time.Sleep(time.Millisecond)
+	}
+}
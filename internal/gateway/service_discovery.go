@@ -1,17 +1,26 @@
 package gateway
 
 import (
-	"api-gateway/proto"
+	"api-gateway/pkg/proto"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
-	"strings"
+	"net/url"
+	"reflect"
+	"strconv"
 	"sync"
 	"time"
 
 	"api-gateway/internal/config"
+	"api-gateway/internal/requestid"
+	"api-gateway/internal/retry"
 )
 
 // ServiceRegistry управляет подключениями к сервисам
@@ -20,16 +29,33 @@ type ServiceRegistry struct {
 	services map[string][]*ServiceEndpoint
 	config   *config.Config
 	client   *http.Client
+	sink     StatsSink
+	partners *PartnerRouter
+	sampling *FrameSampling
 }
 
 type ServiceEndpoint struct {
 	ID        string
 	URL       string
 	Type      string // "http", "grpc"
+	Service   string // тип сервиса: video_processing, analytics, ...
 	Priority  int
 	Healthy   bool
+	Drained   bool // выведен из ротации вручную, health check его не возвращает
+	Failures  int  // неуспешных запросов подряд; при пороге прерыватель открывается
 	LastCheck time.Time
 	Stats     ServiceStats
+
+	// AcceptedStatuses коды ответа, считающиеся успехом (пусто - любой 2xx)
+	AcceptedStatuses []int
+
+	// APIKey отправляется в X-API-Key (партнерские эндпоинты)
+	APIKey string
+
+	// Shadow - теневой эндпоинт: получает копию ShadowPercent% фреймов
+	// своего типа сервиса, в обычную маршрутизацию не входит
+	Shadow        bool
+	ShadowPercent float64
 }
 
 type ServiceStats struct {
@@ -40,12 +66,34 @@ type ServiceStats struct {
 	AverageTime   time.Duration
 }
 
-func NewServiceRegistry(cfg *config.Config) *ServiceRegistry {
+// IsSuccessStatus проверяет, считается ли код ответа эндпоинта успешным
+func (e *ServiceEndpoint) IsSuccessStatus(code int) bool {
+	if len(e.AcceptedStatuses) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, accepted := range e.AcceptedStatuses {
+		if code == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+func NewServiceRegistry(cfg *config.Config, sink StatsSink) *ServiceRegistry {
 	registry := &ServiceRegistry{
 		services: make(map[string][]*ServiceEndpoint),
 		config:   cfg,
+		sink:     sink,
+		partners: NewPartnerRouter(cfg.Partners, cfg.RoutingRules),
+		sampling: NewFrameSampling(cfg.Services.Sampling),
+		// Таймаут задается на каждый запрос через контекст (ServiceTimeout),
+		// поэтому общий Timeout клиента не ставится
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Transport: newServiceTransport(cfg),
+			// Редиректы не выполняем: 3xx классифицируется по AcceptedStatuses
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
 		},
 	}
 
@@ -55,6 +103,52 @@ func NewServiceRegistry(cfg *config.Config) *ServiceRegistry {
 	return registry
 }
 
+// newServiceTransport создает транспорт для запросов к сервисам с
+// настройками пула соединений и таймаутов из конфигурации
+func newServiceTransport(cfg *config.Config) *http.Transport {
+	hc := cfg.Services.HTTPClient
+
+	dialer := &net.Dialer{
+		Timeout:   httpClientSetting(hc.DialTimeout, 5*time.Second),
+		KeepAlive: httpClientSetting(hc.KeepAlive, 30*time.Second),
+	}
+
+	maxIdleConns := hc.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = 100
+	}
+	maxIdleConnsPerHost := hc.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = 32
+	}
+
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        maxIdleConns,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		MaxConnsPerHost:     hc.MaxConnsPerHost,
+		IdleConnTimeout:     httpClientSetting(hc.IdleConnTimeout, 90*time.Second),
+		TLSHandshakeTimeout: httpClientSetting(hc.TLSHandshakeTimeout, 5*time.Second),
+	}
+}
+
+// httpClientSetting переводит секунды из конфигурации в Duration,
+// подставляя значение по умолчанию для нуля
+func httpClientSetting(seconds int, def time.Duration) time.Duration {
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// drainAndClose дочитывает тело ответа, чтобы соединение вернулось в пул
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 64*1024))
+	body.Close()
+}
+
 func (sr *ServiceRegistry) initializeServices() {
 	// Видеообработка
 	for i, url := range sr.config.Services.VideoProcessing {
@@ -62,9 +156,12 @@ func (sr *ServiceRegistry) initializeServices() {
 			ID:        fmt.Sprintf("video_%d", i),
 			URL:       url,
 			Type:      "http",
+			Service:   "video_processing",
 			Priority:  i,
 			Healthy:   true,
 			LastCheck: time.Now(),
+
+			AcceptedStatuses: sr.config.Services.AcceptedStatuses[url],
 		}
 		sr.services["video_processing"] = append(sr.services["video_processing"], endpoint)
 	}
@@ -75,9 +172,12 @@ func (sr *ServiceRegistry) initializeServices() {
 			ID:        fmt.Sprintf("analytics_%d", i),
 			URL:       url,
 			Type:      "http",
+			Service:   "analytics",
 			Priority:  i,
 			Healthy:   true,
 			LastCheck: time.Now(),
+
+			AcceptedStatuses: sr.config.Services.AcceptedStatuses[url],
 		}
 		sr.services["analytics"] = append(sr.services["analytics"], endpoint)
 	}
@@ -88,9 +188,12 @@ func (sr *ServiceRegistry) initializeServices() {
 			ID:        fmt.Sprintf("storage_%d", i),
 			URL:       url,
 			Type:      "http",
+			Service:   "storage",
 			Priority:  i,
 			Healthy:   true,
 			LastCheck: time.Now(),
+
+			AcceptedStatuses: sr.config.Services.AcceptedStatuses[url],
 		}
 		sr.services["storage"] = append(sr.services["storage"], endpoint)
 	}
@@ -101,12 +204,34 @@ func (sr *ServiceRegistry) initializeServices() {
 			ID:        fmt.Sprintf("notification_%d", i),
 			URL:       url,
 			Type:      "http",
+			Service:   "notification",
 			Priority:  i,
 			Healthy:   true,
 			LastCheck: time.Now(),
+
+			AcceptedStatuses: sr.config.Services.AcceptedStatuses[url],
 		}
 		sr.services["notification"] = append(sr.services["notification"], endpoint)
 	}
+
+	// Теневые эндпоинты
+	for i, shadow := range sr.config.Services.Shadow {
+		endpoint := &ServiceEndpoint{
+			ID:        fmt.Sprintf("%s_shadow_%d", serviceIDPrefixes[shadow.Service], i),
+			URL:       shadow.URL,
+			Type:      "http",
+			Service:   shadow.Service,
+			Priority:  len(sr.services[shadow.Service]),
+			Healthy:   true,
+			LastCheck: time.Now(),
+
+			AcceptedStatuses: sr.config.Services.AcceptedStatuses[shadow.URL],
+
+			Shadow:        true,
+			ShadowPercent: shadow.Percent,
+		}
+		sr.services[shadow.Service] = append(sr.services[shadow.Service], endpoint)
+	}
 }
 
 // GetServicesForFrame возвращает сервисы для обработки фрейма
@@ -117,77 +242,416 @@ func (sr *ServiceRegistry) GetServicesForFrame(frame *proto.VideoFrame) []*Servi
 	var endpoints []*ServiceEndpoint
 
 	// Всегда отправляем в видеообработку
-	endpoints = append(endpoints, sr.getHealthyServices("video_processing")...)
+	endpoints = append(endpoints, sr.routeCamera(frame.CameraID, sr.getSampledServices("video_processing"))...)
 
 	// Отправляем в аналитику если включена
 	if frame.ClientData != nil && frame.ClientData.Authenticated {
-		endpoints = append(endpoints, sr.getHealthyServices("analytics")...)
+		endpoints = append(endpoints, sr.getSampledServices("analytics")...)
 	}
 
 	// Отправляем в хранилище
-	endpoints = append(endpoints, sr.getHealthyServices("storage")...)
+	endpoints = append(endpoints, sr.getSampledServices("storage")...)
+
+	// Партнер по routing_rules (с учетом его rate_limit)
+	if partner := sr.partners.Match(frame); partner != nil {
+		endpoints = append(endpoints, partner)
+	}
+
+	return endpoints
+}
 
+// routeCamera оставляет из эндпоинтов видеообработки эндпоинт камеры по
+// services.camera_routes. Если маршрута нет или эндпоинт камеры нездоров,
+// возвращает все эндпоинты.
+func (sr *ServiceRegistry) routeCamera(cameraID string, endpoints []*ServiceEndpoint) []*ServiceEndpoint {
+	url, ok := sr.config.Services.CameraRoutes[cameraID]
+	if !ok {
+		return endpoints
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.URL == url {
+			return []*ServiceEndpoint{endpoint}
+		}
+	}
 	return endpoints
 }
 
-// getHealthyServices возвращает только здоровые сервисы
+// ShadowServicesForFrame возвращает здоровые теневые эндпоинты типов
+// сервисов, в которые уже направлен фрейм. Каждый эндпоинт выбирается
+// независимо с вероятностью ShadowPercent%.
+func (sr *ServiceRegistry) ShadowServicesForFrame(routed []*ServiceEndpoint) []*ServiceEndpoint {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	var shadows []*ServiceEndpoint
+	seen := make(map[string]bool, len(routed))
+	for _, service := range routed {
+		if seen[service.Service] {
+			continue
+		}
+		seen[service.Service] = true
+
+		for _, endpoint := range sr.services[service.Service] {
+			if !endpoint.Shadow || !endpoint.Healthy || endpoint.Drained {
+				continue
+			}
+			if rand.Float64()*100 < endpoint.ShadowPercent {
+				shadows = append(shadows, endpoint)
+			}
+		}
+	}
+	return shadows
+}
+
+// getSampledServices возвращает здоровые сервисы типа, если фрейм попал в
+// выборку services.sampling. Фреймы без здоровых сервисов в выборке не
+// учитываются.
+func (sr *ServiceRegistry) getSampledServices(serviceType string) []*ServiceEndpoint {
+	healthy := sr.getHealthyServices(serviceType)
+	if len(healthy) == 0 || !sr.sampling.Sample(serviceType) {
+		return nil
+	}
+	return healthy
+}
+
+// getHealthyServices возвращает только здоровые сервисы (без теневых)
 func (sr *ServiceRegistry) getHealthyServices(serviceType string) []*ServiceEndpoint {
 	var healthy []*ServiceEndpoint
 	for _, endpoint := range sr.services[serviceType] {
-		if endpoint.Healthy {
+		if endpoint.Healthy && !endpoint.Drained && !endpoint.Shadow {
 			healthy = append(healthy, endpoint)
 		}
 	}
 	return healthy
 }
 
-// SendToService отправляет фрейм в сервис
-func (sr *ServiceRegistry) SendToService(ctx context.Context, service *ServiceEndpoint, frame *proto.VideoFrame) error {
-	startTime := time.Now()
+// UnavailableServiceTypes возвращает типы из списка, у которых нет ни одного
+// здорового эндпоинта
+func (sr *ServiceRegistry) UnavailableServiceTypes(serviceTypes []string) []string {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	var unavailable []string
+	for _, serviceType := range serviceTypes {
+		if len(sr.getHealthyServices(serviceType)) == 0 {
+			unavailable = append(unavailable, serviceType)
+		}
+	}
+	return unavailable
+}
+
+// UnhealthyEndpointCount возвращает число нездоровых эндпоинтов сервисов;
+// выведенные вручную и теневые не считаются
+func (sr *ServiceRegistry) UnhealthyEndpointCount() int {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	count := 0
+	for _, endpoints := range sr.services {
+		for _, endpoint := range endpoints {
+			if !endpoint.Healthy && !endpoint.Drained && !endpoint.Shadow {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// serviceIDPrefixes префиксы ID эндпоинтов по типу сервиса
+var serviceIDPrefixes = map[string]string{
+	"video_processing": "video",
+	"analytics":        "analytics",
+	"storage":          "storage",
+	"notification":     "notification",
+}
+
+// AddEndpoint добавляет эндпоинт сервиса во время работы и возвращает его.
+// acceptedStatuses - коды успешного ответа (пусто - любой 2xx).
+func (sr *ServiceRegistry) AddEndpoint(serviceType, url string, priority int, acceptedStatuses []int) (*ServiceEndpoint, error) {
+	prefix, ok := serviceIDPrefixes[serviceType]
+	if !ok {
+		return nil, fmt.Errorf("unknown service type: %s", serviceType)
+	}
+	if url == "" {
+		return nil, fmt.Errorf("service url is required")
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	endpoints := sr.services[serviceType]
+	for _, endpoint := range endpoints {
+		if endpoint.URL == url {
+			return nil, fmt.Errorf("endpoint %s already registered as %s", url, endpoint.ID)
+		}
+	}
+
+	// Подбираем свободный ID
+	var id string
+	for i := len(endpoints); ; i++ {
+		id = fmt.Sprintf("%s_%d", prefix, i)
+		if sr.findEndpointLocked(id) == nil {
+			break
+		}
+	}
+
+	endpoint := &ServiceEndpoint{
+		ID:        id,
+		URL:       url,
+		Type:      "http",
+		Service:   serviceType,
+		Priority:  priority,
+		Healthy:   true,
+		LastCheck: time.Now(),
+
+		AcceptedStatuses: acceptedStatuses,
+	}
+
+	// Сохраняем порядок по приоритету
+	pos := len(endpoints)
+	for i, existing := range endpoints {
+		if existing.Priority > priority {
+			pos = i
+			break
+		}
+	}
+	endpoints = append(endpoints, nil)
+	copy(endpoints[pos+1:], endpoints[pos:])
+	endpoints[pos] = endpoint
+	sr.services[serviceType] = endpoints
+
+	log.Printf("Service endpoint added: %s (%s) %s", id, serviceType, url)
+	return endpoint, nil
+}
+
+// RemoveEndpoint удаляет эндпоинт по ID
+func (sr *ServiceRegistry) RemoveEndpoint(id string) bool {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	for serviceType, endpoints := range sr.services {
+		for i, endpoint := range endpoints {
+			if endpoint.ID == id {
+				sr.services[serviceType] = append(endpoints[:i:i], endpoints[i+1:]...)
+				log.Printf("Service endpoint removed: %s (%s)", id, serviceType)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetEndpointHealthy вручную выводит эндпоинт из ротации (healthy=false)
+// или возвращает его обратно. Выведенный эндпоинт остается в реестре.
+func (sr *ServiceRegistry) SetEndpointHealthy(id string, healthy bool) bool {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	endpoint := sr.findEndpointLocked(id)
+	if endpoint == nil {
+		return false
+	}
 
-	// Подготавливаем данные для отправки
+	endpoint.Drained = !healthy
+	if healthy {
+		endpoint.Healthy = true
+		endpoint.Failures = 0
+	}
+	return true
+}
+
+// findEndpointLocked ищет эндпоинт по ID, вызывается под блокировкой
+func (sr *ServiceRegistry) findEndpointLocked(id string) *ServiceEndpoint {
+	for _, endpoints := range sr.services {
+		for _, endpoint := range endpoints {
+			if endpoint.ID == id {
+				return endpoint
+			}
+		}
+	}
+	return nil
+}
+
+// ListEndpoints возвращает описание всех эндпоинтов по типам сервисов
+func (sr *ServiceRegistry) ListEndpoints() map[string][]map[string]interface{} {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	result := make(map[string][]map[string]interface{})
+	for serviceType, endpoints := range sr.services {
+		list := make([]map[string]interface{}, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			list = append(list, map[string]interface{}{
+				"id":         endpoint.ID,
+				"url":        endpoint.URL,
+				"priority":   endpoint.Priority,
+				"healthy":    endpoint.Healthy,
+				"drained":    endpoint.Drained,
+				"last_check": endpoint.LastCheck,
+				"shadow":     endpoint.Shadow,
+
+				"accepted_statuses": endpoint.AcceptedStatuses,
+			})
+		}
+		result[serviceType] = list
+	}
+	return result
+}
+
+// ServiceTimeout возвращает таймаут запроса к сервису данного типа:
+// Services.Timeouts, иначе Services.HTTPClient.Timeout, иначе 10 секунд
+func (sr *ServiceRegistry) ServiceTimeout(serviceType string) time.Duration {
+	if ms := sr.config.Services.Timeouts[serviceType]; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return httpClientSetting(sr.config.Services.HTTPClient.Timeout, 10*time.Second)
+}
+
+// ErrServiceTimeout причина отмены контекста запроса по таймауту сервиса
+// (WithServiceTimeout); только такое истечение считается сбоем эндпоинта
+var ErrServiceTimeout = errors.New("service timeout exceeded")
+
+// WithServiceTimeout ограничивает ctx таймаутом сервиса данного типа
+// (ServiceTimeout). Истечение именно этого таймаута отличается от отмены
+// или дедлайна родителя (frame_timeout_ms, отключение клиента): см.
+// sendCancelled.
+func (sr *ServiceRegistry) WithServiceTimeout(ctx context.Context, serviceType string) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, sr.ServiceTimeout(serviceType), ErrServiceTimeout)
+}
+
+// sendCancelled сообщает, что запрос прерван не по вине сервиса: ctx
+// отменен или истек дедлайн родителя, а не таймаут сервиса
+func sendCancelled(ctx context.Context) bool {
+	return ctx.Err() != nil && !errors.Is(context.Cause(ctx), ErrServiceTimeout)
+}
+
+// SendToService отправляет фрейм в сервис. Таймаут задает ctx вызывающего
+// (см. WithServiceTimeout); отмена ctx или истечение дедлайна фрейма не
+// учитываются как сбой эндпоинта.
+func (sr *ServiceRegistry) SendToService(ctx context.Context, service *ServiceEndpoint, frame *proto.VideoFrame) error {
 	data, err := json.Marshal(frame)
 	if err != nil {
 		sr.updateServiceStats(service, false, 0)
-		return fmt.Errorf("failed to marshal frame: %v", err)
+		return retry.Permanent(fmt.Errorf("failed to marshal frame: %v", err))
 	}
 
-	// Создаем запрос
-	req, err := http.NewRequestWithContext(ctx, "POST", service.URL, strings.NewReader(string(data)))
+	header := http.Header{}
+	header.Set("X-Client-ID", frame.ClientID)
+	return sr.post(ctx, service, data, header, "send")
+}
+
+// SendBatchToService отправляет пакет фреймов в сервис одним запросом
+// (JSON массив)
+func (sr *ServiceRegistry) SendBatchToService(ctx context.Context, service *ServiceEndpoint, frames []*proto.VideoFrame) error {
+	data, err := json.Marshal(frames)
+	if err != nil {
+		sr.updateServiceStats(service, false, 0)
+		return retry.Permanent(fmt.Errorf("failed to marshal batch: %v", err))
+	}
+
+	header := http.Header{}
+	header.Set("X-Batch-Size", strconv.Itoa(len(frames)))
+	return sr.post(ctx, service, data, header, "send batch")
+}
+
+// post отправляет JSON тело в эндпоинт и учитывает исход в статистике и
+// здоровье эндпоинта (recordOutcome). header - дополнительные заголовки,
+// op - название операции для текста ошибки.
+func (sr *ServiceRegistry) post(ctx context.Context, service *ServiceEndpoint, data []byte, header http.Header, op string) error {
+	startTime := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", service.URL, bytes.NewReader(data))
 	if err != nil {
 		sr.updateServiceStats(service, false, 0)
 		return fmt.Errorf("failed to create request: %v", err)
 	}
 
+	for name, values := range header {
+		req.Header[name] = values
+	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-API-Gateway", "video-streaming")
-	req.Header.Set("X-Client-ID", frame.ClientID)
+	if service.APIKey != "" {
+		req.Header.Set("X-API-Key", service.APIKey)
+	}
+	requestid.SetHeader(ctx, req.Header)
 
-	// Отправляем запрос
 	resp, err := sr.client.Do(req)
 	if err != nil {
-		sr.updateServiceStats(service, false, time.Since(startTime))
-		service.Healthy = false
-		return fmt.Errorf("failed to send to service %s: %v", service.URL, err)
+		if sendCancelled(ctx) {
+			return fmt.Errorf("%s to service %s cancelled: %w", op, service.URL, context.Cause(ctx))
+		}
+		sr.recordOutcome(service, false, time.Since(startTime))
+		return fmt.Errorf("failed to %s to service %s: %v", op, service.URL, err)
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
 
 	responseTime := time.Since(startTime)
+	if !service.IsSuccessStatus(resp.StatusCode) {
+		sr.recordOutcome(service, false, responseTime)
+		return serviceStatusError(service.URL, resp.StatusCode)
+	}
 
-	// Проверяем статус ответа
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		sr.updateServiceStats(service, true, responseTime)
-		service.Healthy = true
-		return nil
-	} else {
-		sr.updateServiceStats(service, false, responseTime)
+	sr.recordOutcome(service, true, responseTime)
+	return nil
+}
+
+// serviceStatusError ошибка неуспешного ответа сервиса. Ошибки клиента
+// (4xx, кроме 408 и 429) не повторяются: повтор того же фрейма не поможет.
+func serviceStatusError(url string, code int) error {
+	err := fmt.Errorf("service %s returned error status: %d", url, code)
+	if code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// ResetStats обнуляет статистику всех эндпоинтов
+func (sr *ServiceRegistry) ResetStats() {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	for _, endpoints := range sr.services {
+		for _, endpoint := range endpoints {
+			endpoint.Stats = ServiceStats{}
+		}
+	}
+	for _, route := range sr.partners.routes {
+		route.endpoint.Stats = ServiceStats{}
+	}
+}
+
+// recordOutcome учитывает исход запроса к эндпоинту в статистике и в его
+// прерывателе: services.breaker.failure_threshold сбоев подряд выводят
+// эндпоинт из ротации до health check, успех обнуляет счетчик. Все
+// изменения эндпоинта - под sr.mu.
+func (sr *ServiceRegistry) recordOutcome(service *ServiceEndpoint, success bool, responseTime time.Duration) {
+	sr.updateServiceStats(service, success, responseTime)
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if success {
+		service.Failures = 0
+		return
+	}
+	service.Failures++
+	if service.Healthy && service.Failures >= sr.config.GetBreakerFailureThreshold() {
 		service.Healthy = false
-		return fmt.Errorf("service %s returned error status: %d", service.URL, resp.StatusCode)
+		log.Printf("Service %s (%s) removed from rotation after %d consecutive failures",
+			service.ID, service.Service, service.Failures)
 	}
 }
 
 // updateServiceStats обновляет статистику сервиса
 func (sr *ServiceRegistry) updateServiceStats(service *ServiceEndpoint, success bool, responseTime time.Duration) {
+	// Теневой трафик учитывается отдельно, чтобы не смешивать его с боевым
+	statsService := service.Service
+	if service.Shadow {
+		statsService += "_shadow"
+	}
+	sr.sink.RecordServiceCall(statsService, service.ID, success, responseTime)
+
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
 
@@ -209,29 +673,61 @@ func (sr *ServiceRegistry) updateServiceStats(service *ServiceEndpoint, success
 	service.LastCheck = time.Now()
 }
 
-// CheckHealth проверяет здоровье всех сервисов
+// CheckHealth проверяет здоровье всех сервисов. Проверки идут параллельно и
+// без блокировки реестра, чтобы маршрутизация фреймов не ждала таймаутов
+// проверок; результаты применяются под блокировкой.
 func (sr *ServiceRegistry) CheckHealth() {
+	sr.mu.RLock()
+	var endpoints []*ServiceEndpoint
+	for _, typed := range sr.services {
+		endpoints = append(endpoints, typed...)
+	}
+	sr.mu.RUnlock()
+
+	results := make([]bool, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = sr.checkEndpointHealth(endpoint)
+		}()
+	}
+	wg.Wait()
+
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
 
-	for serviceType, endpoints := range sr.services {
-		for _, endpoint := range endpoints {
-			healthy := sr.checkEndpointHealth(endpoint)
-			endpoint.Healthy = healthy
-			endpoint.LastCheck = time.Now()
-
-			if !healthy {
-				log.Printf("Service %s (%s) is unhealthy", endpoint.ID, serviceType)
-			}
+	for i, endpoint := range endpoints {
+		healthy := results[i]
+		endpoint.Healthy = healthy
+		endpoint.LastCheck = time.Now()
+		if healthy {
+			endpoint.Failures = 0
+		} else {
+			log.Printf("Service %s (%s) is unhealthy", endpoint.ID, endpoint.Service)
 		}
 	}
 }
 
+// healthBodyLimit сколько байт ответа проверки здоровья читается для
+// expect_json
+const healthBodyLimit = 64 * 1024
+
+// checkEndpointHealth выполняет проверку здоровья эндпоинта; вызывается без
+// блокировки реестра
 func (sr *ServiceRegistry) checkEndpointHealth(endpoint *ServiceEndpoint) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		httpClientSetting(sr.config.Services.HealthCheckTimeout, 5*time.Second))
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint.URL+"/health", nil)
+	check := sr.config.Services.HealthChecks[endpoint.URL]
+	target, err := healthCheckURL(endpoint.URL, check.Path)
+	if err != nil {
+		return false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
 	if err != nil {
 		return false
 	}
@@ -240,9 +736,68 @@ func (sr *ServiceRegistry) checkEndpointHealth(endpoint *ServiceEndpoint) bool {
 	if err != nil {
 		return false
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
+
+	expectStatus := check.ExpectStatus
+	if expectStatus == 0 {
+		expectStatus = http.StatusOK
+	}
+	if resp.StatusCode != expectStatus {
+		return false
+	}
+	if len(check.ExpectJSON) == 0 {
+		return true
+	}
+	return healthBodyMatches(io.LimitReader(resp.Body, healthBodyLimit), check.ExpectJSON)
+}
+
+// healthCheckURL строит URL проверки здоровья: полный URL в path берется как
+// есть, иначе path дописывается к пути URL эндпоинта (по умолчанию /health)
+func healthCheckURL(endpointURL, path string) (string, error) {
+	if path == "" {
+		path = "/health"
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	if ref.IsAbs() {
+		return ref.String(), nil
+	}
+
+	base, err := url.Parse(endpointURL)
+	if err != nil {
+		return "", err
+	}
+	target := base.JoinPath(ref.Path)
+	target.RawQuery = ref.RawQuery
+	return target.String(), nil
+}
+
+// healthBodyMatches проверяет, что тело ответа - JSON объект с полями
+// expected. Значения сравниваются после приведения к JSON, поэтому 1 из
+// YAML совпадает с 1.0 из ответа.
+func healthBodyMatches(body io.Reader, expected map[string]interface{}) bool {
+	var got map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&got); err != nil {
+		return false
+	}
+
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	var want map[string]interface{}
+	if err := json.Unmarshal(data, &want); err != nil {
+		return false
+	}
 
-	return resp.StatusCode == http.StatusOK
+	for key, value := range want {
+		if !reflect.DeepEqual(got[key], value) {
+			return false
+		}
+	}
+	return true
 }
 
 // GetHealthStatus возвращает статус здоровья всех сервисов
@@ -255,8 +810,9 @@ func (sr *ServiceRegistry) GetHealthStatus() map[string]interface{} {
 	for serviceType, endpoints := range sr.services {
 		typeStatus := make(map[string]interface{})
 		for _, endpoint := range endpoints {
-			typeStatus[endpoint.ID] = map[string]interface{}{
+			endpointStatus := map[string]interface{}{
 				"healthy":     endpoint.Healthy,
+				"drained":     endpoint.Drained,
 				"last_check":  endpoint.LastCheck,
 				"url":         endpoint.URL,
 				"total_reqs":  endpoint.Stats.TotalRequests,
@@ -264,6 +820,10 @@ func (sr *ServiceRegistry) GetHealthStatus() map[string]interface{} {
 				"errors":      endpoint.Stats.ErrorCount,
 				"avg_time_ms": endpoint.Stats.AverageTime.Milliseconds(),
 			}
+			if endpoint.Shadow {
+				endpointStatus["shadow_percent"] = endpoint.ShadowPercent
+			}
+			typeStatus[endpoint.ID] = endpointStatus
 		}
 		status[serviceType] = typeStatus
 	}
@@ -271,32 +831,45 @@ func (sr *ServiceRegistry) GetHealthStatus() map[string]interface{} {
 	return status
 }
 
+// PartnerStats возвращает статистику партнеров
+func (sr *ServiceRegistry) PartnerStats() map[string]interface{} {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	return sr.partners.Stats()
+}
+
+// SamplingStats возвращает учет выборки фреймов по типам сервисов: сколько
+// фреймов могло уйти в сервис и сколько отправлено
+func (sr *ServiceRegistry) SamplingStats() map[string]interface{} {
+	return sr.sampling.Stats()
+}
+
 // Close закрывает все соединения
 func (sr *ServiceRegistry) Close() {
 	// Для HTTP клиента не нужно явное закрытие
 }
 
-// handleClientConnect - заглушка
+// handleClientConnect логирует подключение (подписчик по умолчанию)
 func (g *APIGateway) handleClientConnect(msg *ControlMessage) {
 	log.Printf("Client connect: %s", msg.ClientID)
 }
 
-// handleClientDisconnect - заглушка
+// handleClientDisconnect логирует отключение (подписчик по умолчанию)
 func (g *APIGateway) handleClientDisconnect(msg *ControlMessage) {
 	log.Printf("Client disconnect: %s", msg.ClientID)
 }
 
-// handleSubscribe - заглушка
+// handleSubscribe логирует подписку (подписчик по умолчанию)
 func (g *APIGateway) handleSubscribe(msg *ControlMessage) {
 	log.Printf("Subscribe: %s to %s", msg.ClientID, msg.Channel)
 }
 
-// handleUnsubscribe - заглушка
+// handleUnsubscribe логирует отписку (подписчик по умолчанию)
 func (g *APIGateway) handleUnsubscribe(msg *ControlMessage) {
 	log.Printf("Unsubscribe: %s from %s", msg.ClientID, msg.Channel)
 }
 
-// handleServiceHealth - заглушка
+// handleServiceHealth логирует проверку сервисов (подписчик по умолчанию)
 func (g *APIGateway) handleServiceHealth(msg *ControlMessage) {
 	log.Printf("Service health check")
 }
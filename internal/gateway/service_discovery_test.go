@@ -0,0 +1,442 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+// newTestRegistry создает реестр с одним эндпоинтом video_processing на url
+func newTestRegistry(t *testing.T, url string) (*ServiceRegistry, *ServiceEndpoint) {
+	t.Helper()
+	cfg := config.GetDefaultConfig()
+	cfg.Services.VideoProcessing = []string{url}
+	registry := NewServiceRegistry(cfg, NewMemoryStatsSink())
+	return registry, registry.services["video_processing"][0]
+}
+
+func TestSendToServiceBreakerThreshold(t *testing.T) {
+	var status atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	registry, endpoint := newTestRegistry(t, server.URL)
+	registry.config.Services.Breaker.FailureThreshold = 2
+	frame := &proto.VideoFrame{ClientID: "cam-1"}
+
+	// Шаги выполняются по порядку на одном эндпоинте
+	steps := []struct {
+		name      string
+		status    int
+		wantErr   bool
+		wantState string
+	}{
+		{"success", http.StatusOK, false, BreakerClosed},
+		{"first failure keeps breaker closed", http.StatusInternalServerError, true, BreakerClosed},
+		{"success resets failures", http.StatusOK, false, BreakerClosed},
+		{"failure below threshold", http.StatusBadGateway, true, BreakerClosed},
+		{"threshold opens breaker", http.StatusServiceUnavailable, true, BreakerOpen},
+	}
+	for _, step := range steps {
+		status.Store(int32(step.status))
+		err := registry.SendToService(context.Background(), endpoint, frame)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: SendToService() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		if state := registry.Breakers()[0].State; state != step.wantState {
+			t.Fatalf("%s: breaker state = %s, want %s", step.name, state, step.wantState)
+		}
+	}
+
+	if _, ok := registry.ResetBreaker(endpoint.ID); !ok {
+		t.Fatal("ResetBreaker() = false")
+	}
+	if state := registry.Breakers()[0]; state.State != BreakerClosed || state.ConsecutiveFailures != 0 {
+		t.Errorf("after reset: state = %s, failures = %d", state.State, state.ConsecutiveFailures)
+	}
+}
+
+func TestSendBatchToServiceSetsBatchHeader(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Batch-Size")
+	}))
+	defer server.Close()
+
+	registry, endpoint := newTestRegistry(t, server.URL)
+	frames := []*proto.VideoFrame{{ClientID: "a"}, {ClientID: "b"}}
+	if err := registry.SendBatchToService(context.Background(), endpoint, frames); err != nil {
+		t.Fatalf("SendBatchToService() error = %v", err)
+	}
+	if got != "2" {
+		t.Errorf("X-Batch-Size = %q, want 2", got)
+	}
+}
+
+// Запись здоровья и чтение состояния прерывателей идут под sr.mu
+// (проверяется go test -race)
+func TestSendToServiceConcurrentWithBreakers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	registry, endpoint := newTestRegistry(t, server.URL)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			registry.SendToService(context.Background(), endpoint, &proto.VideoFrame{})
+		}()
+		go func() {
+			defer wg.Done()
+			registry.Breakers()
+			registry.UnavailableServiceTypes([]string{"video_processing"})
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSendToServiceTimeoutClassification(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	tests := []struct {
+		name          string
+		frameTimeout  time.Duration
+		serviceMillis int
+		wantCancelled bool
+	}{
+		{"frame deadline expires first", 20 * time.Millisecond, 5000, true},
+		{"service timeout expires first", 5 * time.Second, 20, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, endpoint := newTestRegistry(t, server.URL)
+			registry.config.Services.Breaker.FailureThreshold = 1
+			registry.config.Services.Timeouts = map[string]int{"video_processing": tt.serviceMillis}
+
+			frameCtx, cancelFrame := context.WithTimeout(context.Background(), tt.frameTimeout)
+			defer cancelFrame()
+			ctx, cancel := registry.WithServiceTimeout(frameCtx, endpoint.Service)
+			defer cancel()
+
+			if err := registry.SendToService(ctx, endpoint, &proto.VideoFrame{}); err == nil {
+				t.Fatal("SendToService() error = nil, want timeout")
+			}
+			if got := sendCancelled(ctx); got != tt.wantCancelled {
+				t.Errorf("sendCancelled() = %v, want %v", got, tt.wantCancelled)
+			}
+			healthy := registry.Breakers()[0].State == BreakerClosed
+			if healthy != tt.wantCancelled {
+				t.Errorf("endpoint healthy = %v, want %v", healthy, tt.wantCancelled)
+			}
+		})
+	}
+}
+
+func TestGetServicesForFrameCameraRoutes(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.Services.VideoProcessing = []string{"http://video-1", "http://video-2"}
+	cfg.Services.Storage = nil
+	cfg.Services.CameraRoutes = map[string]string{
+		"front": "http://video-1",
+		"back":  "http://video-2",
+	}
+	registry := NewServiceRegistry(cfg, NewMemoryStatsSink())
+
+	tests := []struct {
+		name      string
+		cameraID  string
+		unhealthy string // ID эндпоинта, помеченного нездоровым
+		want      []string
+	}{
+		{"front camera", "front", "", []string{"http://video-1"}},
+		{"back camera", "back", "", []string{"http://video-2"}},
+		{"camera without route", "side", "", []string{"http://video-1", "http://video-2"}},
+		{"routed endpoint unhealthy", "back", "video_1", []string{"http://video-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.unhealthy != "" {
+				registry.SetEndpointHealthy(tt.unhealthy, false)
+				t.Cleanup(func() { registry.SetEndpointHealthy(tt.unhealthy, true) })
+			}
+
+			var got []string
+			for _, endpoint := range registry.GetServicesForFrame(&proto.VideoFrame{CameraID: tt.cameraID}) {
+				got = append(got, endpoint.URL)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("endpoints for camera %q = %v, want %v", tt.cameraID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHealthDoesNotBlockRouting(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	registry, _ := newTestRegistry(t, server.URL)
+	go registry.CheckHealth()
+	time.Sleep(50 * time.Millisecond) // проверка ждет ответа сервера
+
+	routed := make(chan struct{})
+	go func() {
+		registry.GetServicesForFrame(&proto.VideoFrame{ClientID: "cam-1"})
+		close(routed)
+	}()
+	select {
+	case <-routed:
+	case <-time.After(time.Second):
+		t.Fatal("GetServicesForFrame blocked by a running health check")
+	}
+}
+
+func TestCheckEndpointHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/ready":
+			w.WriteHeader(http.StatusNoContent)
+		case "/status":
+			w.Write([]byte(`{"status":"ok","workers":4}`))
+		case "/large":
+			w.Write([]byte(`{"status":"ok","pad":"` + strings.Repeat("x", healthBodyLimit) + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		check config.HealthCheckConfig
+		want  bool
+	}{
+		{"default path", config.HealthCheckConfig{}, true},
+		{"custom path", config.HealthCheckConfig{Path: "/ready", ExpectStatus: http.StatusNoContent}, true},
+		{"unexpected status", config.HealthCheckConfig{Path: "/ready"}, false},
+		{"missing path", config.HealthCheckConfig{Path: "/missing"}, false},
+		{"absolute path", config.HealthCheckConfig{Path: server.URL + "/health"}, true},
+		{"matching json", config.HealthCheckConfig{Path: "/status", ExpectJSON: map[string]interface{}{"status": "ok", "workers": 4}}, true},
+		{"mismatching json", config.HealthCheckConfig{Path: "/status", ExpectJSON: map[string]interface{}{"workers": 8}}, false},
+		{"json on empty body", config.HealthCheckConfig{Path: "/ready", ExpectStatus: http.StatusNoContent, ExpectJSON: map[string]interface{}{"status": "ok"}}, false},
+		{"body over limit", config.HealthCheckConfig{Path: "/large", ExpectJSON: map[string]interface{}{"status": "ok"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, endpoint := newTestRegistry(t, server.URL)
+			registry.config.Services.HealthChecks = map[string]config.HealthCheckConfig{server.URL: tt.check}
+
+			if got := registry.checkEndpointHealth(endpoint); got != tt.want {
+				t.Errorf("checkEndpointHealth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendToServiceAcceptedStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		accepted []int
+		status   int
+		wantErr  bool
+	}{
+		{"accepted 202", []int{http.StatusAccepted}, http.StatusAccepted, false},
+		{"200 outside accepted set", []int{http.StatusAccepted}, http.StatusOK, true},
+		{"default rejects redirect", nil, http.StatusFound, true},
+		{"default accepts 2xx", nil, http.StatusNoContent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			cfg := config.GetDefaultConfig()
+			cfg.Services.VideoProcessing = []string{server.URL}
+			cfg.Services.AcceptedStatuses = map[string][]int{server.URL: tt.accepted}
+			sink := NewMemoryStatsSink()
+			registry := NewServiceRegistry(cfg, sink)
+			endpoint := registry.services["video_processing"][0]
+
+			err := registry.SendToService(context.Background(), endpoint, &proto.VideoFrame{ClientID: "cam-1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendToService() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			wantSuccess, wantErrors, wantFailures := int64(1), int64(0), 0
+			if tt.wantErr {
+				wantSuccess, wantErrors, wantFailures = 0, 1, 1
+			}
+			if endpoint.Stats.SuccessCount != wantSuccess || endpoint.Stats.ErrorCount != wantErrors {
+				t.Errorf("stats success=%d errors=%d, want %d and %d",
+					endpoint.Stats.SuccessCount, endpoint.Stats.ErrorCount, wantSuccess, wantErrors)
+			}
+			if endpoint.Failures != wantFailures {
+				t.Errorf("consecutive failures = %d, want %d", endpoint.Failures, wantFailures)
+			}
+			if calls := sink.Snapshot().Services["video_processing"]; calls.Calls != 1 || calls.Errors != wantErrors {
+				t.Errorf("sink calls = %+v, want 1 call with %d errors", calls, wantErrors)
+			}
+		})
+	}
+}
+
+func TestServiceTransportSettings(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	hc := &cfg.Services.HTTPClient
+	hc.IdleConnTimeout = 42
+	hc.TLSHandshakeTimeout = 7
+	hc.MaxIdleConns = 10
+	hc.MaxIdleConnsPerHost = 4
+	hc.MaxConnsPerHost = 8
+
+	transport := newServiceTransport(cfg)
+	fields := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"IdleConnTimeout", transport.IdleConnTimeout, 42 * time.Second},
+		{"TLSHandshakeTimeout", transport.TLSHandshakeTimeout, 7 * time.Second},
+		{"MaxIdleConns", transport.MaxIdleConns, 10},
+		{"MaxIdleConnsPerHost", transport.MaxIdleConnsPerHost, 4},
+		{"MaxConnsPerHost", transport.MaxConnsPerHost, 8},
+	}
+	for _, field := range fields {
+		if field.got != field.want {
+			t.Errorf("%s = %v, want %v", field.name, field.got, field.want)
+		}
+	}
+}
+
+func TestSendToServiceReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	registry, endpoint := newTestRegistry(t, server.URL)
+	for i := 0; i < 10; i++ {
+		if err := registry.SendToService(context.Background(), endpoint, &proto.VideoFrame{ClientID: "cam-1"}); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	if got := conns.Load(); got != 1 {
+		t.Errorf("opened %d connections for 10 sequential sends, want 1", got)
+	}
+}
+
+func TestSendToServiceMaxConnsPerHost(t *testing.T) {
+	var open, peak atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			if n := open.Add(1); n > peak.Load() {
+				peak.Store(n)
+			}
+		case http.StateClosed, http.StateHijacked:
+			open.Add(-1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	cfg := config.GetDefaultConfig()
+	cfg.Services.VideoProcessing = []string{server.URL}
+	cfg.Services.HTTPClient.MaxConnsPerHost = 2
+	registry := NewServiceRegistry(cfg, NewMemoryStatsSink())
+	endpoint := registry.services["video_processing"][0]
+
+	// Лишние запросы ждут свободное соединение, а не открывают новые
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- registry.SendToService(context.Background(), endpoint, &proto.VideoFrame{ClientID: "cam-1"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("send: %v", err)
+		}
+	}
+	if got := peak.Load(); got > 2 {
+		t.Errorf("peak connections = %d, want at most 2", got)
+	}
+}
+
+// BenchmarkSendToService сравнивает отправку через пул keep-alive
+// соединений с новым соединением на каждый запрос
+func BenchmarkSendToService(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	for _, keepAlive := range []bool{true, false} {
+		name := "keep-alive"
+		if !keepAlive {
+			name = "connection-per-request"
+		}
+		b.Run(name, func(b *testing.B) {
+			cfg := config.GetDefaultConfig()
+			cfg.Services.VideoProcessing = []string{server.URL}
+			registry := NewServiceRegistry(cfg, NewMemoryStatsSink())
+			registry.client.Transport.(*http.Transport).DisableKeepAlives = !keepAlive
+			endpoint := registry.services["video_processing"][0]
+			frame := &proto.VideoFrame{ClientID: "cam-1", FrameData: "AAAA"}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := registry.SendToService(context.Background(), endpoint, frame); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
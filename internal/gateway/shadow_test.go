@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+func TestShadowServicesForFramePercent(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.Services.VideoProcessing = []string{"http://video.example"}
+	cfg.Services.Analytics = []string{"http://analytics.example"}
+	cfg.Services.Storage = nil
+	cfg.Services.Shadow = []config.ShadowConfig{
+		{Service: "video_processing", URL: "http://video-next.example", Percent: 25},
+		// Неаутентифицированные фреймы в аналитику не идут, и ее тень тоже
+		{Service: "analytics", URL: "http://analytics-next.example", Percent: 100},
+	}
+	registry := NewServiceRegistry(cfg, NewMemoryStatsSink())
+
+	const frames = 4000
+	mirrored := 0
+	frame := &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", ClientID: "cam-1"}
+	for i := 0; i < frames; i++ {
+		routed := registry.GetServicesForFrame(frame)
+		for _, endpoint := range routed {
+			if endpoint.Shadow {
+				t.Fatalf("shadow endpoint %s is routed as a regular one", endpoint.ID)
+			}
+		}
+		for _, shadow := range registry.ShadowServicesForFrame(routed) {
+			if shadow.Service != "video_processing" {
+				t.Fatalf("mirrored to %s shadow of a service the frame is not routed to", shadow.Service)
+			}
+			mirrored++
+		}
+	}
+
+	// 25% от 4000 - 1000, стандартное отклонение около 27
+	if mirrored < 850 || mirrored > 1150 {
+		t.Errorf("mirrored %d of %d frames, want about 25%%", mirrored, frames)
+	}
+}
+
+func TestShadowResponsesIgnored(t *testing.T) {
+	video := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer video.Close()
+	shadowHits := make(chan struct{}, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusInternalServerError)
+		shadowHits <- struct{}{}
+	}))
+	defer shadow.Close()
+
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.Services.VideoProcessing = []string{video.URL}
+		cfg.Services.Analytics = nil
+		cfg.Services.Storage = nil
+		cfg.Services.Notification = nil
+		cfg.Services.Retry.MaxAttempts = 1
+		cfg.Services.Shadow = []config.ShadowConfig{{Service: "video_processing", URL: shadow.URL, Percent: 100}}
+	})
+
+	results := g.ProcessFrameSync(context.Background(), &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", ClientID: "cam-1"})
+	waitSignal(t, shadowHits, "shadow request")
+	waitIdlePool(t, g)
+
+	// Ошибка теневого эндпоинта не попадает в результат
+	if want := map[string]string{"video_processing": "ok"}; !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %v, want %v", results, want)
+	}
+	if stats := g.sendPool.Stats(); stats.ShadowSubmitted != 1 {
+		t.Errorf("shadow submitted = %d, want 1", stats.ShadowSubmitted)
+	}
+}
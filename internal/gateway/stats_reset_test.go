@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api-gateway/pkg/proto"
+)
+
+func TestStatsResetZeroesCounters(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(service.Close)
+	g := newForwardingGateway(t, service.URL)
+	g.config.Gateway.AdminToken = "admin-secret"
+	reset := g.requireAdmin(g.handleStatsReset)
+
+	g.ProcessFrameSync(context.Background(), &proto.VideoFrame{FrameID: "f1", CameraID: "cam-1", ClientID: "cam-1"})
+	startTime := time.Now().Add(-time.Hour)
+	g.statsMutex.Lock()
+	g.stats.StartTime = startTime
+	g.stats.TotalRequests = 10
+	g.stats.TotalFrames = 8
+	g.stats.BytesProcessed = 4096
+	g.stats.ErrorCount = 2
+	g.stats.ClientFramesDropped = 3
+	g.stats.FramesCancelled = 1
+	g.statsMutex.Unlock()
+	endpoint := g.services.services["video_processing"][0]
+	if endpoint.Stats.TotalRequests == 0 {
+		t.Fatal("endpoint stats were not recorded before reset")
+	}
+
+	// Без токена администратора счетчики не сбрасываются
+	w := httptest.NewRecorder()
+	reset(w, httptest.NewRequest(http.MethodPost, "/api/v1/stats/reset", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("reset without token: status = %d, want 401", w.Code)
+	}
+	if g.stats.TotalFrames != 8 {
+		t.Fatalf("stats changed by unauthorized reset: %+v", *g.stats)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/stats/reset", nil)
+	r.Header.Set("Authorization", "Bearer admin-secret")
+	w = httptest.NewRecorder()
+	reset(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reset: status = %d (%s)", w.Code, w.Body.String())
+	}
+
+	g.statsMutex.RLock()
+	stats := *g.stats
+	g.statsMutex.RUnlock()
+	if stats.TotalRequests != 0 || stats.TotalFrames != 0 || stats.BytesProcessed != 0 ||
+		stats.ErrorCount != 0 || stats.ClientFramesDropped != 0 || stats.FramesCancelled != 0 {
+		t.Errorf("counters after reset = %+v, want zero", stats)
+	}
+	if !stats.StartTime.Equal(startTime) {
+		t.Errorf("start time = %v, want %v", stats.StartTime, startTime)
+	}
+	if endpoint.Stats != (ServiceStats{}) {
+		t.Errorf("endpoint stats after reset = %+v, want zero", endpoint.Stats)
+	}
+
+	// Uptime в /api/v1/stats считается от прежнего StartTime
+	w = httptest.NewRecorder()
+	g.handleStats(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+	var body struct {
+		Stats struct {
+			UptimeSeconds float64 `json:"uptime_seconds"`
+			TotalFrames   int64   `json:"total_frames"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if body.Stats.UptimeSeconds < time.Hour.Seconds() || body.Stats.TotalFrames != 0 {
+		t.Errorf("stats = %+v, want uptime >= 1h and no frames", body.Stats)
+	}
+}
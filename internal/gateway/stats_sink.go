@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/config"
+)
+
+// Событие клиента для RecordClient
+const (
+	ClientConnected    = "connected"
+	ClientDisconnected = "disconnected"
+	ClientRejected     = "rejected"
+)
+
+// StatsSink получает события учета шлюза. Реализации выбираются в
+// конфиге (metrics.backend) и не должны блокировать вызывающего.
+type StatsSink interface {
+	// RecordFrame учитывает принятый фрейм
+	RecordFrame(clientID string, bytes int)
+	// RecordServiceCall учитывает запрос к сервису
+	RecordServiceCall(service, endpointID string, success bool, latency time.Duration)
+	// RecordClient учитывает событие WebSocket клиента
+	RecordClient(event string)
+}
+
+// NewStatsSink создает sink по секции metrics
+func NewStatsSink(cfg *config.Config) (StatsSink, error) {
+	switch cfg.Metrics.Backend {
+	case "prometheus":
+		return NewPrometheusStatsSink(cfg.Metrics.Prefix), nil
+	case "statsd":
+		return NewStatsDSink(cfg.Metrics.StatsDAddress, cfg.Metrics.Prefix)
+	default:
+		return NewMemoryStatsSink(), nil
+	}
+}
+
+// MemoryStatsSink хранит счетчики в памяти процесса (по умолчанию)
+type MemoryStatsSink struct {
+	frames int64
+	bytes  int64
+
+	mu       sync.Mutex
+	services map[string]*ServiceCallStats
+	clients  map[string]int64
+}
+
+// ServiceCallStats счетчики запросов к типу сервиса
+type ServiceCallStats struct {
+	Calls          int64 `json:"calls"`
+	Errors         int64 `json:"errors"`
+	TotalLatencyMs int64 `json:"total_latency_ms"`
+}
+
+// MemoryStatsSnapshot копия счетчиков MemoryStatsSink
+type MemoryStatsSnapshot struct {
+	Frames   int64                       `json:"frames"`
+	Bytes    int64                       `json:"bytes"`
+	Services map[string]ServiceCallStats `json:"services"`
+	Clients  map[string]int64            `json:"clients"`
+}
+
+// NewMemoryStatsSink создает sink в памяти
+func NewMemoryStatsSink() *MemoryStatsSink {
+	return &MemoryStatsSink{
+		services: make(map[string]*ServiceCallStats),
+		clients:  make(map[string]int64),
+	}
+}
+
+// RecordFrame учитывает принятый фрейм
+func (s *MemoryStatsSink) RecordFrame(clientID string, bytes int) {
+	atomic.AddInt64(&s.frames, 1)
+	atomic.AddInt64(&s.bytes, int64(bytes))
+}
+
+// RecordServiceCall учитывает запрос к сервису
+func (s *MemoryStatsSink) RecordServiceCall(service, endpointID string, success bool, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.services[service]
+	if !ok {
+		stats = &ServiceCallStats{}
+		s.services[service] = stats
+	}
+	stats.Calls++
+	if !success {
+		stats.Errors++
+	}
+	stats.TotalLatencyMs += latency.Milliseconds()
+}
+
+// RecordClient учитывает событие клиента
+func (s *MemoryStatsSink) RecordClient(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clients[event]++
+}
+
+// Reset обнуляет счетчики
+func (s *MemoryStatsSink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	atomic.StoreInt64(&s.frames, 0)
+	atomic.StoreInt64(&s.bytes, 0)
+	s.services = make(map[string]*ServiceCallStats)
+	s.clients = make(map[string]int64)
+}
+
+// Snapshot возвращает копию счетчиков
+func (s *MemoryStatsSink) Snapshot() MemoryStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := MemoryStatsSnapshot{
+		Frames:   atomic.LoadInt64(&s.frames),
+		Bytes:    atomic.LoadInt64(&s.bytes),
+		Services: make(map[string]ServiceCallStats, len(s.services)),
+		Clients:  make(map[string]int64, len(s.clients)),
+	}
+	for service, stats := range s.services {
+		snapshot.Services[service] = *stats
+	}
+	for event, count := range s.clients {
+		snapshot.Clients[event] = count
+	}
+	return snapshot
+}
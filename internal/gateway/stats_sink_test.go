@@ -0,0 +1,127 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"api-gateway/internal/config"
+	"api-gateway/pkg/proto"
+)
+
+// fakeSink записывает вызовы StatsSink
+type fakeSink struct {
+	mu       sync.Mutex
+	frames   []string
+	services []string
+	clients  []string
+}
+
+func (s *fakeSink) RecordFrame(clientID string, bytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frames = append(s.frames, clientID+":"+strconv.Itoa(bytes))
+}
+
+func (s *fakeSink) RecordServiceCall(service, endpointID string, success bool, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := "error"
+	if success {
+		result = "ok"
+	}
+	if latency <= 0 {
+		result += " without latency"
+	}
+	s.services = append(s.services, service+"/"+endpointID+":"+result)
+}
+
+func (s *fakeSink) RecordClient(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients = append(s.clients, event)
+}
+
+// clientEvents возвращает копию событий клиентов
+func (s *fakeSink) clientEvents() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.clients...)
+}
+
+// newSinkGateway шлюз с fakeSink вместо sink из конфигурации
+func newSinkGateway(t *testing.T, configure func(*config.Config)) (*APIGateway, *fakeSink) {
+	t.Helper()
+	g := newTestGateway(t, configure)
+	sink := &fakeSink{}
+	g.sink = sink
+	g.services.sink = sink
+	return g, sink
+}
+
+func TestStatsSinkFrameAndServiceCalls(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	g, sink := newSinkGateway(t, func(cfg *config.Config) {
+		cfg.Services.VideoProcessing = []string{ok.URL}
+		cfg.Services.Storage = []string{failing.URL}
+		cfg.Services.Analytics = nil
+		cfg.Services.Notification = nil
+		cfg.Services.Retry.MaxAttempts = 1
+	})
+
+	g.ProcessFrameSync(context.Background(), &proto.VideoFrame{FrameID: "f", CameraID: "cam-1", ClientID: "cam-1", FrameData: "AAECAw=="})
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if want := []string{"cam-1:8"}; !reflect.DeepEqual(sink.frames, want) {
+		t.Errorf("RecordFrame calls = %v, want %v", sink.frames, want)
+	}
+	sort.Strings(sink.services)
+	if want := []string{"storage/storage_0:error", "video_processing/video_0:ok"}; !reflect.DeepEqual(sink.services, want) {
+		t.Errorf("RecordServiceCall calls = %v, want %v", sink.services, want)
+	}
+}
+
+func TestStatsSinkClientEvents(t *testing.T) {
+	g, sink := newSinkGateway(t, nil)
+	server := httptest.NewServer(http.HandlerFunc(g.handleWebSocketVideo))
+	defer server.Close()
+
+	// Без токена подключение закрывается с 1008
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/video"
+	rejected, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	rejected.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := rejected.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatalf("connection without token: %v, want close 1008", err)
+	}
+	rejected.Close()
+	conn := dialVideo(t, server, "token="+signTestToken(t, testJWTSecret, TokenClaims{Subject: "user-1", ClientID: "cam-1"}))
+	conn.Close()
+
+	want := []string{ClientRejected, ClientConnected, ClientDisconnected}
+	deadline := time.Now().Add(time.Second)
+	for !reflect.DeepEqual(sink.clientEvents(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("RecordClient calls = %v, want %v", sink.clientEvents(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
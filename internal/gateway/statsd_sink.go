@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// StatsDSink отправляет метрики по UDP в формате StatsD. Потеря пакета
+// допустима: запись не блокирует и ошибки игнорируются.
+type StatsDSink struct {
+	conn   net.Conn
+	prefix string
+}
+
+// NewStatsDSink создает sink для адреса host:port
+func NewStatsDSink(address, prefix string) (*StatsDSink, error) {
+	if address == "" {
+		address = "localhost:8125"
+	}
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("statsd dial %s: %w", address, err)
+	}
+	if prefix == "" {
+		prefix = "api_gateway"
+	}
+	return &StatsDSink{conn: conn, prefix: prefix}, nil
+}
+
+// RecordFrame учитывает принятый фрейм
+func (s *StatsDSink) RecordFrame(clientID string, bytes int) {
+	s.send(fmt.Sprintf("%s.frames:1|c\n%s.frame_bytes:%d|c", s.prefix, s.prefix, bytes))
+}
+
+// RecordServiceCall учитывает запрос к сервису
+func (s *StatsDSink) RecordServiceCall(service, endpointID string, success bool, latency time.Duration) {
+	result := "success"
+	if !success {
+		result = "error"
+	}
+	service = statsdName(service)
+	s.send(fmt.Sprintf("%s.service.%s.%s:1|c\n%s.service.%s.latency:%d|ms",
+		s.prefix, service, result, s.prefix, service, latency.Milliseconds()))
+}
+
+// RecordClient учитывает событие клиента
+func (s *StatsDSink) RecordClient(event string) {
+	s.send(fmt.Sprintf("%s.clients.%s:1|c", s.prefix, statsdName(event)))
+}
+
+// Close закрывает UDP сокет
+func (s *StatsDSink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsDSink) send(payload string) {
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Millisecond))
+	s.conn.Write([]byte(payload))
+}
+
+// statsdName заменяет символы, которые StatsD трактует как разделители
+func statsdName(name string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_").Replace(name)
+}
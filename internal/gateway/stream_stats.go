@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+)
+
+// StreamStats статистика стрима (camera_id фрейма), принятого шлюзом
+type StreamStats struct {
+	StreamID   string    `json:"stream_id"`
+	Frames     int64     `json:"frames"`
+	Bytes      int64     `json:"bytes"`
+	FirstFrame time.Time `json:"first_frame"`
+	LastFrame  time.Time `json:"last_frame"`
+}
+
+// StreamStatsRegistry счетчики фреймов по стримам
+type StreamStatsRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*StreamStats
+}
+
+// NewStreamStatsRegistry создает пустой реестр
+func NewStreamStatsRegistry() *StreamStatsRegistry {
+	return &StreamStatsRegistry{streams: make(map[string]*StreamStats)}
+}
+
+// Record учитывает фрейм стрима размером size байт
+func (r *StreamStatsRegistry) Record(streamID string, size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	stats, ok := r.streams[streamID]
+	if !ok {
+		stats = &StreamStats{StreamID: streamID, FirstFrame: now}
+		r.streams[streamID] = stats
+	}
+	stats.Frames++
+	stats.Bytes += int64(size)
+	stats.LastFrame = now
+}
+
+// Get возвращает копию статистики стрима
+func (r *StreamStatsRegistry) Get(streamID string) (StreamStats, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.streams[streamID]
+	if !ok {
+		return StreamStats{}, false
+	}
+	return *stats, true
+}
+
+// Cleanup забывает стримы без фреймов дольше maxAge
+func (r *StreamStatsRegistry) Cleanup(maxAge time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for streamID, stats := range r.streams {
+		if now.Sub(stats.LastFrame) > maxAge {
+			delete(r.streams, streamID)
+		}
+	}
+}
+
+// streamStatsReply отвечает на {"action":"stats","stream_id":"..."}
+// статистикой стрима; доступ тот же, что у подписки на его канал
+func (g *APIGateway) streamStatsReply(session *WebSocketSession, command map[string]interface{}) map[string]interface{} {
+	streamID, _ := command["stream_id"].(string)
+	if streamID == "" {
+		streamID, _ = command["channel"].(string)
+	}
+	if streamID == "" {
+		return map[string]interface{}{"action": "error", "error": "stream_id is required", "time": time.Now().Unix()}
+	}
+	if !g.canSubscribe(session, streamID) {
+		return map[string]interface{}{"action": "error", "error": "forbidden", "stream_id": streamID, "time": time.Now().Unix()}
+	}
+
+	stats, ok := g.streamStats.Get(streamID)
+	if !ok {
+		return map[string]interface{}{"action": "error", "error": "stream not found", "stream_id": streamID, "time": time.Now().Unix()}
+	}
+	producer, _ := g.producers.Producer(streamID)
+	return map[string]interface{}{
+		"action":      "stats",
+		"stream_id":   streamID,
+		"stats":       stats,
+		"producer":    producer,
+		"subscribers": len(g.clientMgr.GetClientsByChannel(streamID)),
+		"time":        time.Now().Unix(),
+	}
+}
@@ -0,0 +1,165 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// wsTokenProtocol подпротокол, за которым в Sec-WebSocket-Protocol идет
+// токен: браузерный WebSocket API не умеет выставлять Authorization, поэтому
+// клиент передает ["bearer", "<token>"]
+const wsTokenProtocol = "bearer"
+
+var (
+	ErrMissingToken = errors.New("missing access token")
+	ErrInvalidToken = errors.New("invalid access token")
+	ErrTokenExpired = errors.New("access token expired")
+)
+
+// TokenClaims утверждения JWT, которые использует шлюз
+type TokenClaims struct {
+	Subject  string   `json:"sub"`
+	ClientID string   `json:"client_id,omitempty"`
+	Channels []string `json:"channels,omitempty"` // стримы, которыми владеет пользователь
+	Roles    []string `json:"roles,omitempty"`
+	// Теги доступа к фреймам с access_tags (см. subscriberAccessTags)
+	AccessTags []string `json:"access_tags,omitempty"`
+	ExpiresAt  int64    `json:"exp,omitempty"`
+}
+
+// ValidateToken проверяет JWT с подписью HS256 секретом secret и сроком exp
+func ValidateToken(secret, token string) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeTokenPart(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims TokenClaims
+	if err := decodeTokenPart(parts[1], &claims); err != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt != 0 && time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+func decodeTokenPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WebSocketToken извлекает токен из query параметра token или из подпротокола.
+// viaProtocol сообщает, что токен пришел в Sec-WebSocket-Protocol и ответ
+// на апгрейд должен выбрать подпротокол bearer.
+func WebSocketToken(r *http.Request) (token string, viaProtocol bool) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, false
+	}
+
+	protocols := websocketProtocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == wsTokenProtocol {
+			return protocols[i+1], true
+		}
+	}
+	return "", false
+}
+
+func websocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(header, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				protocols = append(protocols, p)
+			}
+		}
+	}
+	return protocols
+}
+
+// authenticateWebSocket проверяет токен соединения. При отключенной
+// обязательной аутентификации запрос без токена пропускается с nil claims.
+func (g *APIGateway) authenticateWebSocket(r *http.Request) (*TokenClaims, bool, error) {
+	token, viaProtocol := WebSocketToken(r)
+	if token == "" {
+		if g.config.Auth.WebSocketRequired {
+			return nil, false, ErrMissingToken
+		}
+		return nil, false, nil
+	}
+
+	claims, err := ValidateToken(g.config.JWT.Secret, token)
+	if err != nil {
+		return nil, viaProtocol, err
+	}
+	return claims, viaProtocol, nil
+}
+
+// authenticateRequest проверяет Bearer JWT HTTP запроса по тем же правилам,
+// что и WebSocket: без токена запрос отклоняется при
+// auth.websocket_required, иначе пропускается с nil claims
+func (g *APIGateway) authenticateRequest(r *http.Request) (*TokenClaims, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		if g.config.Auth.WebSocketRequired {
+			return nil, ErrMissingToken
+		}
+		return nil, nil
+	}
+	return ValidateToken(g.config.JWT.Secret, token)
+}
+
+// claimsClientID клиент токена: claim client_id, иначе sub
+func claimsClientID(claims *TokenClaims) string {
+	if claims.ClientID != "" {
+		return claims.ClientID
+	}
+	return claims.Subject
+}
+
+// clientDataFromClaims заполняет данные аутентифицированного клиента из
+// токена, сохраняя SessionID и метаданные соединения
+func clientDataFromClaims(client *ClientInfo, claims *TokenClaims) *ClientData {
+	data := &ClientData{
+		SessionID:     client.ConnectionID,
+		Device:        client.UserAgent,
+		UserID:        claims.Subject,
+		Authenticated: true,
+		Roles:         append([]string(nil), claims.Roles...),
+		Metadata:      make(map[string]string),
+	}
+	if client.ClientData != nil {
+		for key, value := range client.ClientData.Metadata {
+			data.Metadata[key] = value
+		}
+	}
+	return data
+}
@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// signTestToken подписывает claims секретом secret (HS256)
+func signTestToken(t *testing.T, secret string, claims TokenClaims) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateToken(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", signTestToken(t, secret, TokenClaims{Subject: "u1"}), nil},
+		{"wrong secret", signTestToken(t, "another-secret-another-secret-!!", TokenClaims{Subject: "u1"}), ErrInvalidToken},
+		{"expired", signTestToken(t, secret, TokenClaims{Subject: "u1", ExpiresAt: time.Now().Add(-time.Minute).Unix()}), ErrTokenExpired},
+		{"no subject", signTestToken(t, secret, TokenClaims{ClientID: "c1"}), ErrInvalidToken},
+		{"malformed", "not-a-token", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateToken(secret, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package grpc_server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -9,10 +10,12 @@ import (
 	"time"
 
 	"api-gateway/internal/controller"
+	"api-gateway/internal/requestid"
 	pb "api-gateway/pkg/gen"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -23,6 +26,12 @@ type VideoStreamServer struct {
 	logger  *zap.Logger
 	streams map[string]*StreamSession
 	mu      sync.RWMutex
+
+	// defaultTimeout применяется к unary RPC без дедлайна клиента
+	defaultTimeout time.Duration
+
+	// grpcServer запущенный сервер (nil до Run)
+	grpcServer *grpc.Server
 }
 
 // StreamSession управляет сессией стрима
@@ -40,12 +49,60 @@ type StreamSession struct {
 func NewVideoStreamServer(
 	service *controller.VideoStreamServiceImpl,
 	logger *zap.Logger,
+	defaultTimeout time.Duration,
 ) *VideoStreamServer {
 	return &VideoStreamServer{
-		service: service,
-		logger:  logger,
-		streams: make(map[string]*StreamSession),
+		service:        service,
+		logger:         logger,
+		streams:        make(map[string]*StreamSession),
+		defaultTimeout: defaultTimeout,
+	}
+}
+
+// deadlineInterceptor задает таймаут по умолчанию, если клиент не указал
+// дедлайн, и превращает ошибки контекста в DeadlineExceeded/Canceled
+func (s *VideoStreamServer) deadlineInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok && s.defaultTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.defaultTimeout)
+		defer cancel()
+	}
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return resp, nil
+}
+
+// toStatusError переводит ошибки контекста и лимитов в соответствующие gRPC коды
+func toStatusError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return status.FromContextError(err).Err()
+	}
+	var throttled *controller.StreamThrottledError
+	if errors.Is(err, controller.ErrTooManyStarts) || errors.Is(err, controller.ErrStreamCapacity) ||
+		errors.As(err, &throttled) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, controller.ErrUnknownCamera) || errors.Is(err, controller.ErrIdempotencyKeyReused) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, controller.ErrStreamNotFound) {
+		return status.Error(codes.NotFound, err.Error())
 	}
+	if errors.Is(err, controller.ErrFormatChanged) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, controller.ErrFrameSignatureRequired) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return err
 }
 
 // StreamVideo - потоковая передача видео (бинарный режим)
@@ -120,7 +177,7 @@ func (s *VideoStreamServer) StreamVideo(
 			FrameData: chunk.Data,
 			Timestamp: time.Now().Unix(),
 			ClientId:  chunk.ClientId,
-			CameraId:  "grpc_stream",
+			CameraId:  cameraIDFromMetadata(chunk.Metadata),
 			Width:     1920, // Можно извлечь из метаданных
 			Height:    1080,
 			Format:    "jpeg",
@@ -128,12 +185,15 @@ func (s *VideoStreamServer) StreamVideo(
 		}
 
 		// Отправляем кадр в общую систему
-		s.service.SendFrameInternal(
+		if _, err := s.service.SendFrameInternal(
+			stream.Context(),
 			chunk.StreamId,
 			chunk.ClientId,
 			"gRPC Client",
 			frame,
-		)
+		); err != nil {
+			return toStatusError(err)
+		}
 
 		// Отправляем подтверждение клиенту
 		ack := &pb.ChunkAck{
@@ -161,7 +221,11 @@ func (s *VideoStreamServer) SendFrame(
 		zap.String("client_id", req.ClientId))
 
 	// Делегируем обработку основному сервису
-	return s.service.SendFrame(ctx, req)
+	resp, err := s.service.SendFrame(ctx, req)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return resp, nil
 }
 
 // StartStream - старт стрима
@@ -169,7 +233,11 @@ func (s *VideoStreamServer) StartStream(
 	ctx context.Context,
 	req *pb.StartStreamRequest,
 ) (*pb.StartStreamResponse, error) {
-	return s.service.StartStream(ctx, req)
+	resp, err := s.service.StartStream(withIdempotencyKey(ctx), req)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return resp, nil
 }
 
 // StopStream - остановка стрима
@@ -177,7 +245,24 @@ func (s *VideoStreamServer) StopStream(
 	ctx context.Context,
 	req *pb.StopStreamRequest,
 ) (*pb.ApiResponse, error) {
-	return s.service.StopStream(ctx, req)
+	resp, err := s.service.StopStream(withIdempotencyKey(ctx), req)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return resp, nil
+}
+
+// withIdempotencyKey переносит ключ идемпотентности из метаданных
+// idempotency-key в контекст сервиса
+func withIdempotencyKey(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if values := md.Get(controller.IdempotencyKeyHeader); len(values) > 0 {
+		return controller.WithIdempotencyKey(ctx, values[0])
+	}
+	return ctx
 }
 
 // GetActiveStreams - получение активных стримов
@@ -215,11 +300,54 @@ func (s *VideoStreamServer) Run(port string) error {
 	grpcServer := grpc.NewServer(
 		grpc.MaxRecvMsgSize(50*1024*1024), // 50MB для видео
 		grpc.MaxSendMsgSize(10*1024*1024), // 10MB
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor(), s.deadlineInterceptor),
+		grpc.StreamInterceptor(requestid.StreamServerInterceptor()),
 	)
 
 	pb.RegisterVideoStreamServiceServer(grpcServer, s)
 
+	s.mu.Lock()
+	s.grpcServer = grpcServer
+	s.mu.Unlock()
+
 	s.logger.Info("Starting gRPC server", zap.String("port", port))
 
 	return grpcServer.Serve(lis)
 }
+
+// Shutdown останавливает сервер: перестает принимать соединения и ждет
+// завершения текущих RPC. Если ctx истекает раньше, оставшиеся RPC
+// обрываются (Stop).
+func (s *VideoStreamServer) Shutdown(ctx context.Context) error {
+	s.mu.RLock()
+	grpcServer := s.grpcServer
+	s.mu.RUnlock()
+
+	if grpcServer == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.logger.Warn("gRPC graceful stop timed out, closing active RPCs")
+		grpcServer.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// cameraIDFromMetadata возвращает camera_id чанка; нужен многокамерным стримам
+func cameraIDFromMetadata(metadata map[string]string) string {
+	if cameraID := metadata["camera_id"]; cameraID != "" {
+		return cameraID
+	}
+	return "grpc_stream"
+}
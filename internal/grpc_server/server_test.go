@@ -0,0 +1,85 @@
+package grpc_server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"api-gateway/internal/controller"
+	pb "api-gateway/pkg/gen"
+)
+
+// newBufconnClient поднимает сервер с перехватчиком дедлайнов в памяти
+func newBufconnClient(t *testing.T, service *controller.VideoStreamServiceImpl, defaultTimeout time.Duration) pb.VideoStreamServiceClient {
+	t.Helper()
+	s := NewVideoStreamServer(service, zap.NewNop(), defaultTimeout)
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(s.deadlineInterceptor))
+	pb.RegisterVideoStreamServiceServer(server, s)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatalf("grpc client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewVideoStreamServiceClient(conn)
+}
+
+func TestHandlerDeadline(t *testing.T) {
+	tests := []struct {
+		name           string
+		clientDeadline time.Duration // 0 - без дедлайна клиента
+		defaultTimeout time.Duration
+	}{
+		{"client deadline", 100 * time.Millisecond, time.Minute},
+		{"server default timeout", 0, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1000 байт/с: второй кадр ждет погашения долга первого ~9s
+			service := controller.NewVideoStreamService(zap.NewNop(),
+				controller.WithStreamBitrateLimit(8000, time.Minute))
+			t.Cleanup(service.Close)
+			client := newBufconnClient(t, service, tt.defaultTimeout)
+
+			frame := &pb.SendFrameRequest{
+				StreamId: "stream-1",
+				ClientId: "cam-1",
+				Frame:    &pb.VideoFrame{FrameId: "f", ClientId: "cam-1", Format: "jpeg", FrameData: make([]byte, 10000)},
+			}
+			if _, err := client.SendFrame(context.Background(), frame); err != nil {
+				t.Fatalf("first frame: %v", err)
+			}
+
+			ctx := context.Background()
+			if tt.clientDeadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.clientDeadline)
+				defer cancel()
+			}
+			start := time.Now()
+			_, err := client.SendFrame(ctx, frame)
+			if code := status.Code(err); code != codes.DeadlineExceeded {
+				t.Fatalf("throttled frame error = %v, want DeadlineExceeded", err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("handler returned after %v, want about 100ms", elapsed)
+			}
+		})
+	}
+}
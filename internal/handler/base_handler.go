@@ -7,6 +7,8 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+
+	"api-gateway/internal/requestid"
 )
 
 type BaseHandler struct {
@@ -17,6 +19,11 @@ func NewBaseHandler(logger *zap.Logger) *BaseHandler {
 	return &BaseHandler{logger: logger}
 }
 
+// requestLogger возвращает логгер с request_id текущего запроса
+func requestLogger(c *gin.Context, logger *zap.Logger) *zap.Logger {
+	return requestid.Logger(c.Request.Context(), logger)
+}
+
 // BindProtoJSON - привязка JSON к protobuf сообщению
 func (h *BaseHandler) BindProtoJSON(c *gin.Context, msg proto.Message) error {
 	body, err := c.GetRawData()
@@ -57,7 +64,7 @@ func (h *BaseHandler) SuccessResponse(c *gin.Context, data interface{}) {
 
 // ErrorResponse - ответ с ошибкой (с поддержкой error)
 func (h *BaseHandler) ErrorResponse(c *gin.Context, status int, message string, err error) {
-	h.logger.Error(message,
+	requestLogger(c, h.logger).Error(message,
 		zap.Error(err),
 		zap.Int("status", status),
 		zap.String("path", c.Request.URL.Path))
@@ -75,7 +82,7 @@ func (h *BaseHandler) ErrorResponse(c *gin.Context, status int, message string,
 
 // SimpleErrorResponse - упрощенный ответ с ошибкой (для обратной совместимости)
 func (h *BaseHandler) SimpleErrorResponse(c *gin.Context, code int, message string) {
-	h.logger.Warn("API error",
+	requestLogger(c, h.logger).Warn("API error",
 		zap.Int("status", code),
 		zap.String("message", message),
 		zap.String("path", c.Request.URL.Path))
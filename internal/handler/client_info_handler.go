@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -36,7 +37,8 @@ func (h *ClientInfoHandler) RegisterRoutes(router *gin.RouterGroup) {
 		clients.POST("/disconnected", h.ClientDisconnected)
 		clients.PUT("/:client_id", h.UpdateClientInfo)
 		clients.GET("/:client_id", h.GetClientInfo)
-		clients.GET("/active", h.ListActiveClients)
+		clients.DELETE("/:client_id", h.DisconnectClient)
+		clients.GET("/active", RequireAdmin(), h.ListActiveClients)
 	}
 }
 
@@ -50,10 +52,15 @@ func (h *ClientInfoHandler) ClientConnected(c *gin.Context) {
 		})
 		return
 	}
+	clientID, ok := bindClientID(c, req.ClientId)
+	if !ok {
+		return
+	}
+	req.ClientId = clientID
 
 	resp, err := h.service.ClientConnected(c.Request.Context(), &req)
 	if err != nil {
-		h.logger.Error("Failed to process client connection",
+		requestLogger(c, h.logger).Error("Failed to process client connection",
 			zap.Error(err),
 			zap.String("client_id", req.ClientId))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -76,10 +83,15 @@ func (h *ClientInfoHandler) ClientDisconnected(c *gin.Context) {
 		})
 		return
 	}
+	clientID, ok := bindClientID(c, req.ClientId)
+	if !ok {
+		return
+	}
+	req.ClientId = clientID
 
 	resp, err := h.service.ClientDisconnected(c.Request.Context(), &req)
 	if err != nil {
-		h.logger.Error("Failed to process client disconnection",
+		requestLogger(c, h.logger).Error("Failed to process client disconnection",
 			zap.Error(err),
 			zap.String("client_id", req.ClientId))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -95,6 +107,9 @@ func (h *ClientInfoHandler) ClientDisconnected(c *gin.Context) {
 // UpdateClientInfo обновляет информацию о клиенте
 func (h *ClientInfoHandler) UpdateClientInfo(c *gin.Context) {
 	clientID := c.Param("client_id")
+	if !authorizeClient(c, clientID) {
+		return
+	}
 
 	var req pb.UpdateClientRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -110,7 +125,7 @@ func (h *ClientInfoHandler) UpdateClientInfo(c *gin.Context) {
 
 	resp, err := h.service.UpdateClientInfo(c.Request.Context(), &req)
 	if err != nil {
-		h.logger.Error("Failed to update client info",
+		requestLogger(c, h.logger).Error("Failed to update client info",
 			zap.Error(err),
 			zap.String("client_id", clientID))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -126,6 +141,9 @@ func (h *ClientInfoHandler) UpdateClientInfo(c *gin.Context) {
 // GetClientInfo получает информацию о клиенте
 func (h *ClientInfoHandler) GetClientInfo(c *gin.Context) {
 	clientID := c.Param("client_id")
+	if !authorizeClient(c, clientID) {
+		return
+	}
 
 	req := &pb.GetClientInfoRequest{
 		ClientId: clientID,
@@ -133,7 +151,7 @@ func (h *ClientInfoHandler) GetClientInfo(c *gin.Context) {
 
 	client, err := h.service.GetClientInfo(c.Request.Context(), req)
 	if err != nil {
-		h.logger.Error("Failed to get client info",
+		requestLogger(c, h.logger).Error("Failed to get client info",
 			zap.Error(err),
 			zap.String("client_id", clientID))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -157,6 +175,35 @@ func (h *ClientInfoHandler) GetClientInfo(c *gin.Context) {
 	})
 }
 
+// DisconnectClient принудительно отключает клиента
+func (h *ClientInfoHandler) DisconnectClient(c *gin.Context) {
+	clientID := c.Param("client_id")
+	if !authorizeClient(c, clientID) {
+		return
+	}
+
+	resp, err := h.service.ForceDisconnect(c.Request.Context(), clientID)
+	if errors.Is(err, controller.ErrClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Client not found",
+			"message": "No client found with ID: " + clientID,
+		})
+		return
+	}
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to disconnect client",
+			zap.Error(err),
+			zap.String("client_id", clientID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to disconnect client",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 // ListActiveClients возвращает список активных клиентов
 func (h *ClientInfoHandler) ListActiveClients(c *gin.Context) {
 	// Получаем параметры пагинации
@@ -180,7 +227,7 @@ func (h *ClientInfoHandler) ListActiveClients(c *gin.Context) {
 
 	resp, err := h.service.ListActiveClients(c.Request.Context(), req)
 	if err != nil {
-		h.logger.Error("Failed to list active clients",
+		requestLogger(c, h.logger).Error("Failed to list active clients",
 			zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to list clients",
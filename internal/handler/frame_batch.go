@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+	"api-gateway/internal/tracing"
+	"api-gateway/internal/types"
+	gen "api-gateway/pkg/gen"
+)
+
+var (
+	errBatchEmpty         = errors.New("batch contains no frames")
+	errBatchTooManyFrames = errors.New("batch contains too many frames")
+	errBatchFrameTooLarge = errors.New("frame too large")
+)
+
+// frameBatchError ошибка кадра пакета с его индексом
+type frameBatchError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// SendFrames принимает пакет кадров одного стрима и обрабатывает их по
+// порядку. Форматы тела:
+//   - application/json: {"stream_id", "client_id", "user_name", "frames": [...]},
+//     элементы frames как поле frame в /video/frame;
+//   - application/octet-stream: кадры подряд, перед каждым длина uint32
+//     big-endian; stream_id, client_id, user_name, camera_id, format, width,
+//     height передаются в query.
+//
+// Каждый кадр ограничен max_frame_size, число кадров - max_batch_frames,
+// тело запроса - max_batch_bytes.
+// Клиенты с секретом в auth.frame_signing_keys подписывают все тело пакета
+// заголовком X-Frame-Signature.
+// Ответ содержит сводку: сколько кадров принято, байты и ошибки по индексам.
+func (h *VideoStreamHandler) SendFrames(c *gin.Context) {
+	var (
+		streamID, clientID, userName string
+		frames                       []*gen.VideoFrame
+		err                          error
+	)
+
+	// Тело нужно целиком для проверки подписи пакета
+	body, ok := h.bufferSignedBody(c, h.maxBatchBytes, h.respondBatchTooLarge)
+	if !ok {
+		return
+	}
+
+	if strings.Contains(c.GetHeader("Content-Type"), "application/octet-stream") {
+		streamID, clientID, userName = c.Query("stream_id"), c.Query("client_id"), c.Query("user_name")
+		frames, err = h.readBinaryBatch(c, clientID)
+	} else {
+		streamID, clientID, userName, frames, err = h.readJSONBatch(c)
+	}
+
+	var index *batchIndexError
+	switch {
+	case isBodyTooLarge(err):
+		h.respondBatchTooLarge(c)
+		return
+	case errors.Is(err, errBatchFrameTooLarge):
+		response := gin.H{
+			"error":          "Frame too large",
+			"message":        fmt.Sprintf("frame data must not exceed %d bytes", h.maxFrameSize),
+			"max_frame_size": h.maxFrameSize,
+		}
+		if errors.As(err, &index) {
+			response["index"] = index.index
+		}
+		c.JSON(http.StatusRequestEntityTooLarge, response)
+		return
+	case errors.Is(err, errBatchTooManyFrames):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":            "Batch too large",
+			"message":          fmt.Sprintf("batch must not contain more than %d frames", h.maxBatchFrames),
+			"max_batch_frames": h.maxBatchFrames,
+		})
+		return
+	case err != nil:
+		requestLogger(c, h.logger).Warn("Invalid frame batch", zap.Error(err))
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if clientID, ok = bindClientID(c, clientID); !ok {
+		return
+	}
+	userName = callerUserID(c, userName)
+
+	// Автогенерация stream_id если не указан
+	if streamID == "" {
+		if clientID == "" {
+			clientID = fmt.Sprintf("batch_%d", time.Now().Unix())
+		}
+		streamID = fmt.Sprintf("stream_%s_%d", clientID, time.Now().UnixNano())
+		requestLogger(c, h.logger).Info("Auto-generated stream_id",
+			zap.String("stream_id", streamID),
+			zap.String("client_id", clientID))
+	}
+	if clientID, ok = h.resolveFrameOwner(c, streamID, clientID); !ok {
+		return
+	}
+	if !h.checkFrameSignature(c, streamID, clientID, body) {
+		return
+	}
+	if userName == "" {
+		userName = clientID
+	}
+
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(
+		tracing.AttrStreamID.String(streamID),
+		tracing.AttrClientID.String(clientID),
+	)
+
+	started := time.Now()
+	accepted := 0
+	var bytes int64
+	failures := []frameBatchError{}
+	ctx := serviceContext(c)
+	for i, frame := range frames {
+		frame.ClientId = clientID
+		_, err := h.service.SendFrameInternal(ctx, streamID, clientID, userName, frame)
+		if err == nil {
+			accepted++
+			bytes += int64(len(frame.FrameData))
+			continue
+		}
+
+		// Лимит битрейта: остальные кадры пакета тоже будут отклонены
+		var throttled *controller.StreamThrottledError
+		if errors.As(err, &throttled) && accepted == 0 {
+			h.respondThrottled(c, err)
+			return
+		}
+		// Стрим не создан из-за лимита стримов - принимать некуда
+		if errors.Is(err, controller.ErrStreamCapacity) && accepted == 0 {
+			h.respondCapacity(c, err)
+			return
+		}
+		failures = append(failures, frameBatchError{Index: i, Error: err.Error()})
+		if throttled != nil || c.Request.Context().Err() != nil {
+			break
+		}
+	}
+
+	status := "success"
+	if accepted < len(frames) {
+		status = "partial"
+	}
+
+	c.JSON(200, gin.H{
+		"status":      status,
+		"stream_id":   streamID,
+		"received":    len(frames),
+		"accepted":    accepted,
+		"failed":      len(failures),
+		"skipped":     len(frames) - accepted - len(failures),
+		"bytes":       bytes,
+		"errors":      failures,
+		"duration_ms": time.Since(started).Milliseconds(),
+	})
+}
+
+// batchIndexError ошибка разбора кадра пакета с индексом кадра
+type batchIndexError struct {
+	index int
+	err   error
+}
+
+func (e *batchIndexError) Error() string {
+	return fmt.Sprintf("frame %d: %v", e.index, e.err)
+}
+
+func (e *batchIndexError) Unwrap() error {
+	return e.err
+}
+
+// readJSONBatch разбирает JSON пакет кадров
+func (h *VideoStreamHandler) readJSONBatch(c *gin.Context) (streamID, clientID, userName string, frames []*gen.VideoFrame, err error) {
+	var req struct {
+		StreamID string                   `json:"stream_id"`
+		ClientID string                   `json:"client_id"`
+		UserName string                   `json:"user_name"`
+		Frames   []map[string]interface{} `json:"frames"`
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBatchBytes)
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return "", "", "", nil, err
+	}
+	if len(req.Frames) == 0 {
+		return "", "", "", nil, errBatchEmpty
+	}
+	if len(req.Frames) > h.maxBatchFrames {
+		return "", "", "", nil, errBatchTooManyFrames
+	}
+
+	frames = make([]*gen.VideoFrame, 0, len(req.Frames))
+	for i, raw := range req.Frames {
+		frame, err := jsonFrameToGen(raw, fmt.Sprintf("frame_%d_%d", time.Now().UnixNano(), i), req.ClientID)
+		if err != nil {
+			return "", "", "", nil, &batchIndexError{index: i, err: err}
+		}
+		if int64(len(frame.FrameData)) > h.maxFrameSize {
+			return "", "", "", nil, &batchIndexError{index: i, err: errBatchFrameTooLarge}
+		}
+		frames = append(frames, frame)
+	}
+	return req.StreamID, req.ClientID, req.UserName, frames, nil
+}
+
+// readBinaryBatch читает кадры с префиксом длины; метаданные кадров общие
+// и берутся из query
+func (h *VideoStreamHandler) readBinaryBatch(c *gin.Context, clientID string) ([]*gen.VideoFrame, error) {
+	reader := bufio.NewReader(http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBatchBytes))
+
+	width, _ := strconv.Atoi(c.DefaultQuery("width", "1920"))
+	height, _ := strconv.Atoi(c.DefaultQuery("height", "1080"))
+	cameraID := c.DefaultQuery("camera_id", "batch_camera")
+	format := c.DefaultQuery("format", "jpeg")
+
+	var frames []*gen.VideoFrame
+	for i := 0; ; i++ {
+		var size uint32
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, &batchIndexError{index: i, err: err}
+		}
+		if len(frames) == h.maxBatchFrames {
+			return nil, errBatchTooManyFrames
+		}
+		if int64(size) > h.maxFrameSize {
+			return nil, &batchIndexError{index: i, err: errBatchFrameTooLarge}
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, &batchIndexError{index: i, err: err}
+		}
+
+		frames = append(frames, (&types.VideoFrame{
+			FrameID:   fmt.Sprintf("frame_%d_%d", time.Now().UnixNano(), i),
+			FrameData: data,
+			Timestamp: time.Now().Unix(),
+			ClientID:  clientID,
+			CameraID:  cameraID,
+			Width:     int32(width),
+			Height:    int32(height),
+			Format:    format,
+		}).ToGen())
+	}
+
+	if len(frames) == 0 {
+		return nil, errBatchEmpty
+	}
+	return frames, nil
+}
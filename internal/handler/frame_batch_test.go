@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+)
+
+// binaryBatch собирает тело application/octet-stream из кадров
+func binaryBatch(frames ...[]byte) []byte {
+	var buf bytes.Buffer
+	for _, frame := range frames {
+		binary.Write(&buf, binary.BigEndian, uint32(len(frame)))
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+func TestSendFramesLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+
+	// кадр до 16 байт, до 3 кадров, тело до 48 байт
+	h := NewVideoStreamHandler(zap.NewNop(), service, WithMaxFrameSize(16), WithBatchLimits(3, 48))
+	t.Cleanup(h.Close)
+	router := gin.New()
+	h.RegisterRoutes(router.Group("/api/v1"))
+
+	frame := bytes.Repeat([]byte{1}, 8)
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantStatus  int
+		wantError   string
+	}{
+		{"binary batch", "application/octet-stream", binaryBatch(frame, frame), http.StatusOK, ""},
+		{"empty batch", "application/octet-stream", nil, http.StatusBadRequest, "Invalid request"},
+		{"too many frames", "application/octet-stream", binaryBatch(frame, frame, frame, frame), http.StatusRequestEntityTooLarge, "Batch too large"},
+		{"frame too large", "application/octet-stream", binaryBatch(bytes.Repeat([]byte{1}, 17)), http.StatusRequestEntityTooLarge, "Frame too large"},
+		{"body too large", "application/octet-stream", binaryBatch(bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{1}, 16)), http.StatusRequestEntityTooLarge, "Batch too large"},
+		{"json body too large", "application/json", []byte(`{"frames":[{"frame_data":"` + strings.Repeat("A", 80) + `"}]}`), http.StatusRequestEntityTooLarge, "Batch too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/video/frames?client_id=cam-1", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var response struct {
+				Error string `json:"error"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &response)
+			if response.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", response.Error, tt.wantError)
+			}
+		})
+	}
+}
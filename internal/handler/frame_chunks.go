@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+	"api-gateway/internal/types"
+)
+
+// maxChunksPerFrame предел числа частей одного кадра
+const maxChunksPerFrame = 10000
+
+var (
+	errChunkedFrameTooLarge = errors.New("chunked frame exceeds the size limit")
+	errChunkMismatch        = errors.New("chunk does not match the frame upload")
+	errClientChunkLimit     = errors.New("too many pending chunked frames for this client")
+	errChunkCapacity        = errors.New("too many pending chunked frames")
+)
+
+// ChunkTimeoutError - не все части кадра пришли за отведенное время
+type ChunkTimeoutError struct {
+	Missing []int // индексы недоставленных частей
+}
+
+func (e *ChunkTimeoutError) Error() string {
+	return fmt.Sprintf("chunked upload timed out, missing chunks %v", e.Missing)
+}
+
+// chunkedFrame кадр, собираемый из частей
+type chunkedFrame struct {
+	clientID string
+	total    int
+	chunks   map[int][]byte
+	size     int64
+	deadline time.Time
+}
+
+// missing возвращает индексы еще не полученных частей
+func (f *chunkedFrame) missing() []int {
+	var missing []int
+	for i := 0; i < f.total; i++ {
+		if _, ok := f.chunks[i]; !ok {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// ChunkLimits пределы сборки кадров по частям; нулевые значения -
+// пределы по умолчанию
+type ChunkLimits struct {
+	MaxFrameSize int64         // собранный кадр, байты (64MB)
+	Timeout      time.Duration // доставка всех частей кадра (30 секунд)
+
+	MaxPending      int   // незавершенных сборок всего (256)
+	MaxPendingBytes int64 // байт во всех незавершенных сборках (512MB)
+	MaxClientFrames int   // незавершенных сборок одного клиента (16)
+	MaxClientBytes  int64 // байт в незавершенных сборках клиента (128MB)
+}
+
+// chunkUsage незавершенные сборки клиента
+type chunkUsage struct {
+	frames int
+	bytes  int64
+}
+
+// ChunkAssembler собирает кадры, загружаемые по частям. Все части кадра
+// должны прийти за timeout с первой части; незавершенные сборки удаляются
+// фоновой очисткой до Close. Число и объем незавершенных сборок ограничены
+// в целом и на клиента.
+type ChunkAssembler struct {
+	limits ChunkLimits
+
+	mu      sync.Mutex
+	frames  map[string]*chunkedFrame // stream_id + frame_id -> сборка
+	clients map[string]*chunkUsage   // client_id -> его сборки
+	bytes   int64                    // байт во всех сборках
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewChunkAssembler создает сборщик с пределами limits и запускает
+// очистку просроченных сборок; остановить ее - Close
+func NewChunkAssembler(limits ChunkLimits) *ChunkAssembler {
+	if limits.MaxFrameSize <= 0 {
+		limits.MaxFrameSize = 64 * 1024 * 1024
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = 30 * time.Second
+	}
+	if limits.MaxPending <= 0 {
+		limits.MaxPending = 256
+	}
+	if limits.MaxPendingBytes <= 0 {
+		limits.MaxPendingBytes = 512 * 1024 * 1024
+	}
+	if limits.MaxClientFrames <= 0 {
+		limits.MaxClientFrames = 16
+	}
+	if limits.MaxClientBytes <= 0 {
+		limits.MaxClientBytes = 128 * 1024 * 1024
+	}
+	a := &ChunkAssembler{
+		limits:  limits,
+		frames:  make(map[string]*chunkedFrame),
+		clients: make(map[string]*chunkUsage),
+		stop:    make(chan struct{}),
+	}
+	go a.purgeLoop()
+	return a
+}
+
+// Close останавливает очистку просроченных сборок
+func (a *ChunkAssembler) Close() {
+	a.closeOnce.Do(func() { close(a.stop) })
+}
+
+// Add добавляет часть index из total кадра key. Когда получены все части,
+// возвращает собранные данные и удаляет сборку; до этого data nil, а
+// received - число полученных частей. Повтор части заменяет ее. Часть
+// сверх пределов клиента - errClientChunkLimit, общих - errChunkCapacity;
+// уже полученные части сборки при этом сохраняются.
+func (a *ChunkAssembler) Add(key, clientID string, index, total int, chunk []byte) (data []byte, received int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	frame, exists := a.frames[key]
+	if exists && now.After(frame.deadline) {
+		a.removeLocked(key, frame)
+		return nil, 0, &ChunkTimeoutError{Missing: frame.missing()}
+	}
+
+	usage := a.clients[clientID]
+	if usage == nil {
+		usage = &chunkUsage{}
+	}
+	if !exists {
+		if len(a.frames) >= a.limits.MaxPending {
+			return nil, 0, errChunkCapacity
+		}
+		if usage.frames >= a.limits.MaxClientFrames {
+			return nil, 0, errClientChunkLimit
+		}
+		frame = &chunkedFrame{
+			clientID: clientID,
+			total:    total,
+			chunks:   make(map[int][]byte),
+			deadline: now.Add(a.limits.Timeout),
+		}
+	}
+	if frame.total != total || frame.clientID != clientID {
+		return nil, len(frame.chunks), errChunkMismatch
+	}
+
+	delta := int64(len(chunk)) - int64(len(frame.chunks[index]))
+	if frame.size+delta > a.limits.MaxFrameSize {
+		if exists {
+			a.removeLocked(key, frame)
+		}
+		return nil, 0, errChunkedFrameTooLarge
+	}
+	if a.bytes+delta > a.limits.MaxPendingBytes {
+		return nil, len(frame.chunks), errChunkCapacity
+	}
+	if usage.bytes+delta > a.limits.MaxClientBytes {
+		return nil, len(frame.chunks), errClientChunkLimit
+	}
+
+	if !exists {
+		a.frames[key] = frame
+		a.clients[clientID] = usage
+		usage.frames++
+	}
+	frame.size += delta
+	usage.bytes += delta
+	a.bytes += delta
+	frame.chunks[index] = chunk
+
+	if len(frame.chunks) < frame.total {
+		return nil, len(frame.chunks), nil
+	}
+
+	a.removeLocked(key, frame)
+	data = make([]byte, 0, frame.size)
+	for i := 0; i < frame.total; i++ {
+		data = append(data, frame.chunks[i]...)
+	}
+	return data, frame.total, nil
+}
+
+// removeLocked удаляет сборку и освобождает ее место в пределах
+func (a *ChunkAssembler) removeLocked(key string, frame *chunkedFrame) {
+	delete(a.frames, key)
+	a.bytes -= frame.size
+	if usage := a.clients[frame.clientID]; usage != nil {
+		usage.frames--
+		usage.bytes -= frame.size
+		if usage.frames == 0 {
+			delete(a.clients, frame.clientID)
+		}
+	}
+}
+
+// purgeLoop периодически удаляет просроченные сборки
+func (a *ChunkAssembler) purgeLoop() {
+	ticker := time.NewTicker(a.limits.Timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.purgeExpired(time.Now())
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// purgeExpired удаляет сборки с истекшим сроком
+func (a *ChunkAssembler) purgeExpired(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, frame := range a.frames {
+		if now.After(frame.deadline) {
+			a.removeLocked(key, frame)
+		}
+	}
+}
+
+// SendFrameChunk принимает часть большого кадра: тело запроса - данные
+// части, параметры в query: frame_id, index (с 0), total, stream_id,
+// client_id и, для собранного кадра, user_name, camera_id, width, height,
+// format (берутся из последней части). Пока кадр не собран, ответ 202;
+// часть, завершившая сборку, обрабатывается как обычный кадр.
+func (h *VideoStreamHandler) SendFrameChunk(c *gin.Context) {
+	frameID := c.Query("frame_id")
+	streamID := c.Query("stream_id")
+	index, indexErr := strconv.Atoi(c.Query("index"))
+	total, totalErr := strconv.Atoi(c.Query("total"))
+	if frameID == "" || streamID == "" || indexErr != nil || totalErr != nil ||
+		total < 1 || total > maxChunksPerFrame || index < 0 || index >= total {
+		c.JSON(400, gin.H{
+			"error": "Invalid request",
+			"message": fmt.Sprintf("frame_id, stream_id, index and total (1-%d, 0 <= index < total) are required",
+				maxChunksPerFrame),
+		})
+		return
+	}
+
+	chunk, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, h.maxFrameSize))
+	if isBodyTooLarge(err) {
+		h.respondTooLarge(c)
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	clientID, ok := bindClientID(c, c.Query("client_id"))
+	if !ok {
+		return
+	}
+	if clientID, ok = h.resolveFrameOwner(c, streamID, clientID); !ok {
+		return
+	}
+	if !h.checkFrameSignature(c, streamID, clientID, chunk) {
+		return
+	}
+
+	data, received, err := h.chunks.Add(streamID+"\x00"+frameID, clientID, index, total, chunk)
+	var timeout *ChunkTimeoutError
+	switch {
+	case errors.As(err, &timeout):
+		requestLogger(c, h.logger).Warn("Chunked frame upload timed out",
+			zap.String("stream_id", streamID),
+			zap.String("frame_id", frameID),
+			zap.Ints("missing_chunks", timeout.Missing))
+		c.JSON(http.StatusRequestTimeout, gin.H{
+			"error":          "Chunked upload timed out",
+			"message":        err.Error(),
+			"frame_id":       frameID,
+			"missing_chunks": timeout.Missing,
+		})
+		return
+	case errors.Is(err, errChunkedFrameTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":                  "Frame too large",
+			"message":                err.Error(),
+			"max_chunked_frame_size": h.chunks.limits.MaxFrameSize,
+		})
+		return
+	case errors.Is(err, errClientChunkLimit):
+		c.Header("Retry-After", strconv.Itoa(int(h.chunks.limits.Timeout.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":             "Too many pending chunked frames",
+			"message":           err.Error(),
+			"max_client_frames": h.chunks.limits.MaxClientFrames,
+			"max_client_bytes":  h.chunks.limits.MaxClientBytes,
+		})
+		return
+	case errors.Is(err, errChunkCapacity):
+		c.Header("Retry-After", strconv.Itoa(int(h.chunks.limits.Timeout.Seconds())))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Chunked upload capacity reached",
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, errChunkMismatch):
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": "total and client_id must be the same for all chunks of a frame",
+		})
+		return
+	}
+
+	if data == nil {
+		c.JSON(http.StatusAccepted, gin.H{
+			"status":    "pending",
+			"frame_id":  frameID,
+			"stream_id": streamID,
+			"received":  received,
+			"total":     total,
+		})
+		return
+	}
+
+	width, _ := strconv.Atoi(c.DefaultQuery("width", "1920"))
+	height, _ := strconv.Atoi(c.DefaultQuery("height", "1080"))
+	frame := (&types.VideoFrame{
+		FrameID:   frameID,
+		FrameData: data,
+		Timestamp: time.Now().Unix(),
+		ClientID:  clientID,
+		CameraID:  c.DefaultQuery("camera_id", "chunked_stream"),
+		Width:     int32(width),
+		Height:    int32(height),
+		Format:    c.Query("format"),
+	}).ToGen()
+
+	response, err := h.service.SendFrameInternal(serviceContext(c), streamID, clientID,
+		callerUserID(c, c.DefaultQuery("user_name", "chunked_client")), frame)
+	if h.respondThrottled(c, err) || h.respondCapacity(c, err) {
+		return
+	}
+	if errors.Is(err, controller.ErrUnknownCamera) {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, controller.ErrFormatChanged) {
+		c.JSON(409, gin.H{
+			"error":   "Frame format changed",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to process chunked frame", zap.Error(err))
+		c.JSON(500, gin.H{
+			"error":   "Failed to process frame",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":     response.Status,
+		"message":    response.Message,
+		"timestamp":  response.Timestamp,
+		"metadata":   response.Metadata,
+		"format":     "chunked",
+		"frame_id":   frameID,
+		"frame_size": len(data),
+		"chunks":     total,
+		"stream_id":  streamID,
+	})
+}
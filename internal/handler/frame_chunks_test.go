@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChunkAssemblerLimits(t *testing.T) {
+	chunk := make([]byte, 10)
+
+	// chunkAdd часть index из total кадра frame клиента client
+	type chunkAdd struct {
+		frame, client string
+		index, total  int
+		size          int
+		wantErr       error
+	}
+	tests := []struct {
+		name   string
+		limits ChunkLimits
+		adds   []chunkAdd
+	}{
+		{"assembled frame frees limits", ChunkLimits{MaxPending: 1, MaxClientFrames: 1}, []chunkAdd{
+			{"f1", "cam-1", 0, 1, 10, nil},
+			{"f2", "cam-1", 0, 2, 10, nil},
+		}},
+		{"frame too large", ChunkLimits{MaxFrameSize: 15}, []chunkAdd{
+			{"f1", "cam-1", 0, 2, 10, nil},
+			{"f1", "cam-1", 1, 2, 10, errChunkedFrameTooLarge},
+		}},
+		{"client frames", ChunkLimits{MaxClientFrames: 2}, []chunkAdd{
+			{"f1", "cam-1", 0, 2, 10, nil},
+			{"f2", "cam-1", 0, 2, 10, nil},
+			{"f3", "cam-1", 0, 2, 10, errClientChunkLimit},
+			{"f3", "cam-2", 0, 2, 10, nil},
+		}},
+		{"client bytes", ChunkLimits{MaxClientBytes: 25}, []chunkAdd{
+			{"f1", "cam-1", 0, 3, 10, nil},
+			{"f1", "cam-1", 1, 3, 10, nil},
+			{"f1", "cam-1", 2, 3, 10, errClientChunkLimit},
+			{"f2", "cam-2", 0, 2, 10, nil},
+		}},
+		{"pending frames", ChunkLimits{MaxPending: 2}, []chunkAdd{
+			{"f1", "cam-1", 0, 2, 10, nil},
+			{"f2", "cam-2", 0, 2, 10, nil},
+			{"f3", "cam-3", 0, 2, 10, errChunkCapacity},
+			{"f1", "cam-1", 1, 2, 10, nil},
+			{"f3", "cam-3", 0, 2, 10, nil},
+		}},
+		{"pending bytes", ChunkLimits{MaxPendingBytes: 25}, []chunkAdd{
+			{"f1", "cam-1", 0, 2, 10, nil},
+			{"f2", "cam-2", 0, 2, 10, nil},
+			{"f3", "cam-3", 0, 2, 10, errChunkCapacity},
+			{"f1", "cam-1", 0, 2, 5, nil}, // повтор части меньшего размера
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewChunkAssembler(tt.limits)
+			t.Cleanup(a.Close)
+			for i, add := range tt.adds {
+				_, _, err := a.Add(add.frame, add.client, add.index, add.total, chunk[:add.size])
+				if !errors.Is(err, add.wantErr) {
+					t.Fatalf("Add #%d (%s/%d) error = %v, want %v", i, add.frame, add.index, err, add.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkAssemblerExpiry(t *testing.T) {
+	a := NewChunkAssembler(ChunkLimits{Timeout: time.Hour, MaxClientFrames: 1})
+	t.Cleanup(a.Close)
+
+	if _, _, err := a.Add("f1", "cam-1", 0, 2, []byte{1}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	a.purgeExpired(time.Now().Add(2 * time.Hour))
+
+	if _, _, err := a.Add("f2", "cam-1", 0, 2, []byte{1}); err != nil {
+		t.Errorf("Add after purge = %v, want nil: expired frame must free the client limit", err)
+	}
+	if a.bytes != 1 || len(a.frames) != 1 {
+		t.Errorf("pending = %d frames, %d bytes, want 1 frame, 1 byte", len(a.frames), a.bytes)
+	}
+}
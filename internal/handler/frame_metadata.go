@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// metadataStringFields и metadataNumberFields поля metadata multipart кадра
+// и их ожидаемые JSON типы
+var (
+	metadataStringFields = []string{"stream_id", "client_id", "user_name", "camera_id"}
+	metadataNumberFields = []string{"width", "height"}
+)
+
+// parseFrameMetadata разбирает поле metadata multipart кадра. Невалидный
+// JSON и поля неверного типа возвращаются как problems: в строгом режиме
+// кадр с ними отклоняется, иначе используются значения по умолчанию.
+func parseFrameMetadata(raw string) (metadata map[string]interface{}, problems []string) {
+	if raw == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+		return nil, []string{fmt.Sprintf("metadata is not a valid JSON object: %v", err)}
+	}
+
+	for _, key := range metadataStringFields {
+		if value, ok := metadata[key]; ok {
+			if _, isString := value.(string); !isString {
+				problems = append(problems, fmt.Sprintf("metadata.%s must be a string", key))
+			}
+		}
+	}
+	for _, key := range metadataNumberFields {
+		if value, ok := metadata[key]; ok {
+			if _, isNumber := value.(float64); !isNumber {
+				problems = append(problems, fmt.Sprintf("metadata.%s must be a number", key))
+			}
+		}
+	}
+	return metadata, problems
+}
+
+// resolveFrameOwner сверяет client_id кадра с владельцем существующего
+// стрима: пустой client_id берется из стрима, чужой отклоняется с 400.
+// Возвращает итоговый client_id; при false ответ уже отправлен.
+func (h *VideoStreamHandler) resolveFrameOwner(c *gin.Context, streamID, clientID string) (string, bool) {
+	stream, _ := h.service.GetStream(streamID)
+	if stream == nil || stream.ClientId == "" {
+		return clientID, true
+	}
+	if clientID == "" {
+		return stream.ClientId, true
+	}
+	if clientID == stream.ClientId {
+		return clientID, true
+	}
+
+	requestLogger(c, h.logger).Warn("Frame client_id does not own the stream",
+		zap.String("stream_id", streamID),
+		zap.String("client_id", clientID),
+		zap.String("owner_client_id", stream.ClientId))
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Invalid metadata",
+		"message": fmt.Sprintf("stream %s belongs to another client", streamID),
+	})
+	return "", false
+}
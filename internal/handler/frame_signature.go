@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// FrameSignatureHeader заголовок с HMAC-SHA256 в hex (допускается
+	// префикс "sha256=") от "<X-Frame-Timestamp>.<тело запроса>"
+	FrameSignatureHeader = "X-Frame-Signature"
+	// FrameTimestampHeader заголовок с unix временем подписи в секундах
+	FrameTimestampHeader = "X-Frame-Timestamp"
+)
+
+// DefaultFrameSignatureWindow допустимое расхождение времени подписи с
+// часами сервера, если окно не задано
+const DefaultFrameSignatureWindow = 5 * time.Minute
+
+// signatureVerifiedKey ключ gin контекста: подпись кадра проверена
+const signatureVerifiedKey = "frame_signature_verified"
+
+var (
+	errMissingFrameSignature  = errors.New("frame signature is required for this client")
+	errInvalidFrameSignature  = errors.New("frame signature does not match the body")
+	errInvalidFrameTimestamp  = errors.New("frame signature timestamp is missing or invalid")
+	errStaleFrameSignature    = errors.New("frame signature timestamp is outside the allowed window")
+	errReplayedFrameSignature = errors.New("frame signature has already been used")
+)
+
+// FrameVerifier проверяет подписи кадров edge устройств. Проверка включается
+// для клиента наличием его общего секрета; кадры остальных клиентов
+// принимаются без подписи. Подпись действительна window от времени
+// X-Frame-Timestamp и принимается один раз.
+type FrameVerifier struct {
+	secrets map[string][]byte // client_id -> общий секрет
+	window  time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // client_id:подпись -> когда устареет
+	lastPurge time.Time
+}
+
+// NewFrameVerifier создает проверку по секретам client_id -> secret.
+// Пустой набор секретов - проверка выключена. window <= 0 -
+// DefaultFrameSignatureWindow.
+func NewFrameVerifier(secrets map[string]string, window time.Duration) *FrameVerifier {
+	if window <= 0 {
+		window = DefaultFrameSignatureWindow
+	}
+	v := &FrameVerifier{
+		secrets: make(map[string][]byte, len(secrets)),
+		window:  window,
+		seen:    make(map[string]time.Time),
+	}
+	for clientID, secret := range secrets {
+		v.secrets[clientID] = []byte(secret)
+	}
+	return v
+}
+
+// SigningClients возвращает клиентов, обязанных подписывать кадры
+func (v *FrameVerifier) SigningClients() []string {
+	if v == nil {
+		return nil
+	}
+	clients := make([]string, 0, len(v.secrets))
+	for clientID := range v.secrets {
+		clients = append(clients, clientID)
+	}
+	return clients
+}
+
+// Enabled сообщает, что хотя бы один клиент должен подписывать кадры
+func (v *FrameVerifier) Enabled() bool {
+	return v != nil && len(v.secrets) > 0
+}
+
+// Verify проверяет подпись тела body клиента clientID, сделанную в момент
+// timestamp (unix секунды), и запоминает ее до выхода из окна, чтобы
+// перехваченный запрос нельзя было повторить. Для клиентов без секрета
+// всегда возвращает nil.
+func (v *FrameVerifier) Verify(clientID string, body []byte, timestamp, signature string) error {
+	if !v.Enabled() {
+		return nil
+	}
+	secret, ok := v.secrets[clientID]
+	if !ok {
+		return nil
+	}
+
+	signature = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(signature), "sha256="))
+	if signature == "" {
+		return errMissingFrameSignature
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return errInvalidFrameSignature
+	}
+	timestamp = strings.TrimSpace(timestamp)
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errInvalidFrameTimestamp
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errInvalidFrameSignature
+	}
+
+	now := time.Now()
+	signed := time.Unix(signedAt, 0)
+	if signed.Before(now.Add(-v.window)) || signed.After(now.Add(v.window)) {
+		return errStaleFrameSignature
+	}
+	return v.remember(clientID+":"+signature, signed.Add(v.window), now)
+}
+
+// remember отмечает подпись использованной до expires; повтор -
+// errReplayedFrameSignature. Устаревшие подписи удаляются раз в окно.
+func (v *FrameVerifier) remember(key string, expires, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if now.Sub(v.lastPurge) >= v.window {
+		for k, exp := range v.seen {
+			if now.After(exp) {
+				delete(v.seen, k)
+			}
+		}
+		v.lastPurge = now
+	}
+
+	if exp, ok := v.seen[key]; ok && !now.After(exp) {
+		return errReplayedFrameSignature
+	}
+	v.seen[key] = expires
+	return nil
+}
+
+// bufferSignedBody читает тело запроса (не больше limit байт, иначе ответ
+// tooLarge) для проверки подписи и подставляет его копию обратно. Без
+// настроенных секретов тело не буферизуется и возвращается nil, true. При
+// ошибке ответ уже отправлен.
+func (h *VideoStreamHandler) bufferSignedBody(c *gin.Context, limit int64, tooLarge gin.HandlerFunc) ([]byte, bool) {
+	if !h.signatures.Enabled() {
+		return nil, true
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+	if isBodyTooLarge(err) {
+		tooLarge(c)
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return nil, false
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+// checkFrameSignature проверяет X-Frame-Signature тела body для clientID и
+// владельца существующего стрима streamID (чтобы чужой client_id не обходил
+// подпись) и отвечает 401 при отсутствующей, неверной, устаревшей или
+// повторной подписи. Успешная проверка отмечается в контексте: сервис
+// принимает кадры подписывающих клиентов только с этой отметкой.
+func (h *VideoStreamHandler) checkFrameSignature(c *gin.Context, streamID, clientID string, body []byte) bool {
+	if !h.signatures.Enabled() {
+		return true
+	}
+
+	timestamp, signature := c.GetHeader(FrameTimestampHeader), c.GetHeader(FrameSignatureHeader)
+	err := h.signatures.Verify(clientID, body, timestamp, signature)
+	if err == nil {
+		if stream, _ := h.service.GetStream(streamID); stream != nil && stream.ClientId != clientID {
+			err = h.signatures.Verify(stream.ClientId, body, timestamp, signature)
+		}
+	}
+	if err == nil {
+		c.Set(signatureVerifiedKey, true)
+		return true
+	}
+
+	requestLogger(c, h.logger).Warn("Frame signature rejected",
+		zap.String("client_id", clientID),
+		zap.Error(err))
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":   "Invalid frame signature",
+		"message": err.Error(),
+	})
+	return false
+}
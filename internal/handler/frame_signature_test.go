@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signFrame(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestFrameVerifier(t *testing.T) {
+	body := []byte(`{"frame_id":"f-1"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		clientID  string
+		timestamp string
+		signature string
+		wantErr   error
+	}{
+		{"unsigned client", "cam-2", "", "", nil},
+		{"valid", "cam-1", now, signFrame("secret", now, body), nil},
+		{"sha256 prefix", "cam-1", now, "sha256=" + signFrame("secret", now, body), nil},
+		{"missing signature", "cam-1", now, "", errMissingFrameSignature},
+		{"missing timestamp", "cam-1", "", signFrame("secret", "", body), errInvalidFrameTimestamp},
+		{"wrong secret", "cam-1", now, signFrame("other", now, body), errInvalidFrameSignature},
+		{"timestamp not signed", "cam-1", now, signFrame("secret", stale, body), errInvalidFrameSignature},
+		{"stale", "cam-1", stale, signFrame("secret", stale, body), errStaleFrameSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewFrameVerifier(map[string]string{"cam-1": "secret"}, time.Minute)
+			if err := v.Verify(tt.clientID, body, tt.timestamp, tt.signature); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		v := NewFrameVerifier(map[string]string{"cam-1": "secret"}, time.Minute)
+		signature := signFrame("secret", now, body)
+		if err := v.Verify("cam-1", body, now, signature); err != nil {
+			t.Fatalf("first Verify() = %v", err)
+		}
+		if err := v.Verify("cam-1", body, now, signature); !errors.Is(err, errReplayedFrameSignature) {
+			t.Errorf("repeated Verify() = %v, want errReplayedFrameSignature", err)
+		}
+	})
+}
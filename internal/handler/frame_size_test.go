@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+)
+
+// multipartFrame собирает multipart тело с файлом кадра размером size
+func multipartFrame(t *testing.T, size int) (body []byte, contentType string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	w.WriteField("metadata", `{"client_id":"cam-1"}`)
+	part, err := w.CreateFormFile("frame", "frame.jpg")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	part.Write(bytes.Repeat([]byte{1}, size))
+	w.Close()
+	return buf.Bytes(), w.FormDataContentType()
+}
+
+// jsonFrame собирает JSON тело с кадром размером size
+func jsonFrame(size int) []byte {
+	body, _ := json.Marshal(map[string]interface{}{
+		"client_id": "cam-1",
+		"frame":     map[string]interface{}{"frame_data": base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, size))},
+	})
+	return body
+}
+
+func TestSendFrameBodyLimit(t *testing.T) {
+	const maxFrameSize = 1024
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+	router := newVideoTestRouter(t, service, WithMaxFrameSize(maxFrameSize))
+
+	// Больше лимита тела: кадр и запас frameBodyOverhead на поля запроса
+	oversized := maxFrameSize*2 + frameBodyOverhead
+
+	tests := []struct {
+		name       string
+		multipart  bool
+		size       int
+		wantStatus int
+	}{
+		{"multipart within limit", true, maxFrameSize, http.StatusOK},
+		{"multipart frame over limit", true, maxFrameSize + 1, http.StatusRequestEntityTooLarge},
+		{"multipart oversized body", true, oversized, http.StatusRequestEntityTooLarge},
+		{"json within limit", false, maxFrameSize, http.StatusOK},
+		{"json frame over limit", false, maxFrameSize + 1, http.StatusRequestEntityTooLarge},
+		{"json oversized body", false, oversized, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := jsonFrame(tt.size), "application/json"
+			if tt.multipart {
+				body, contentType = multipartFrame(t, tt.size)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/video/frame", bytes.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusRequestEntityTooLarge {
+				return
+			}
+			var resp struct {
+				Error        string `json:"error"`
+				MaxFrameSize int64  `json:"max_frame_size"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &resp)
+			if resp.Error != "Frame too large" || resp.MaxFrameSize != maxFrameSize {
+				t.Errorf("response = %s, want Frame too large with max_frame_size", rec.Body.String())
+			}
+		})
+	}
+}
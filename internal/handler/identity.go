@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"api-gateway/internal/controller"
+	"api-gateway/internal/types"
+	pb "api-gateway/pkg/gen"
+)
+
+// identityFrom возвращает личность вызывающего, установленную middleware
+// аутентификации; nil - анонимный запрос (аутентификация не обязательна)
+func identityFrom(c *gin.Context) *types.Identity {
+	value, ok := c.Get(types.IdentityContextKey)
+	if !ok {
+		return nil
+	}
+	identity, _ := value.(*types.Identity)
+	return identity
+}
+
+// RequireIdentity отклоняет с 401 запросы без аутентифицированной личности
+func RequireIdentity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if identityFrom(c) == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "authentication required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin пропускает только администраторов: без личности - 401,
+// без прав администратора - 403
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := identityFrom(c)
+		if identity == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "authentication required",
+			})
+			return
+		}
+		if !identity.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": "admin role required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// bindClientID привязывает client_id запроса к аутентифицированному
+// клиенту: пустой заменяется клиентом личности, чужой отклоняется с 403.
+// Администраторы и анонимные запросы проходят без изменений. При false
+// ответ уже отправлен.
+func bindClientID(c *gin.Context, clientID string) (string, bool) {
+	identity := identityFrom(c)
+	if identity == nil || identity.Admin {
+		return clientID, true
+	}
+	if clientID == "" {
+		return identity.ClientID, true
+	}
+	if clientID == identity.ClientID {
+		return clientID, true
+	}
+	respondForbidden(c, "client_id does not match the authenticated client")
+	return "", false
+}
+
+// callerUserID возвращает пользователя аутентифицированного клиента (или
+// сам клиент, если у ключа нет пользователя) вместо переданного в запросе;
+// администраторы и анонимные запросы указывают пользователя сами
+func callerUserID(c *gin.Context, userID string) string {
+	identity := identityFrom(c)
+	if identity == nil || identity.Admin {
+		return userID
+	}
+	if identity.UserID == "" {
+		return identity.ClientID
+	}
+	return identity.UserID
+}
+
+// serviceContext контекст вызова сервиса. Для аутентифицированного запроса
+// client_id и пользователь уже привязаны к личности (bindClientID,
+// callerUserID), и создаваемые стримы получают подтвержденного владельца.
+// Проверенная подпись кадра (checkFrameSignature) тоже передается сервису.
+func serviceContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	if identityFrom(c) != nil {
+		ctx = controller.WithVerifiedOwner(ctx)
+	}
+	if c.GetBool(signatureVerifiedKey) {
+		ctx = controller.WithVerifiedSignature(ctx)
+	}
+	return ctx
+}
+
+// authorizeClient разрешает операции над ресурсами клиента clientID
+// самому клиенту и администраторам; иначе отвечает 403
+func authorizeClient(c *gin.Context, clientID string) bool {
+	identity := identityFrom(c)
+	if identity == nil || identity.Admin || identity.ClientID == clientID {
+		return true
+	}
+	respondForbidden(c, "access to another client is not allowed")
+	return false
+}
+
+// authorizeStream разрешает операции над стримом его клиенту, другим
+// клиентам того же пользователя и администраторам; иначе отвечает 403
+func authorizeStream(c *gin.Context, stream *pb.ActiveStream) bool {
+	if ownsStream(identityFrom(c), stream) {
+		return true
+	}
+	respondForbidden(c, "access to stream denied")
+	return false
+}
+
+// ownsStream сообщает, может ли identity управлять стримом (nil - аноним,
+// когда аутентификация не обязательна)
+func ownsStream(identity *types.Identity, stream *pb.ActiveStream) bool {
+	if identity == nil || identity.Admin {
+		return true
+	}
+	if identity.ClientID != "" && identity.ClientID == stream.ClientId {
+		return true
+	}
+	return identity.UserID != "" && identity.UserID == stream.UserName
+}
+
+func respondForbidden(c *gin.Context, message string) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   "Forbidden",
+		"message": message,
+	})
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// ContentTypeProtobuf бинарный protobuf ответ
+	ContentTypeProtobuf = "application/x-protobuf"
+	// ContentTypeProtobufDelimited последовательность сообщений с префиксом длины (varint)
+	ContentTypeProtobufDelimited = "application/x-protobuf; delimited=true"
+)
+
+// acceptsProtobuf проверяет, запросил ли клиент protobuf через Accept
+func acceptsProtobuf(c *gin.Context) bool {
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		switch strings.ToLower(mediaType) {
+		case "application/x-protobuf", "application/protobuf":
+			return true
+		}
+	}
+	return false
+}
+
+// respond отвечает сообщением msg в protobuf, если его запросили через
+// Accept, иначе - jsonBody в привычном JSON формате
+func respond(c *gin.Context, status int, msg proto.Message, jsonBody interface{}) {
+	if !acceptsProtobuf(c) {
+		c.JSON(status, jsonBody)
+		return
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to marshal response",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.Data(status, ContentTypeProtobuf, data)
+}
+
+// respondList как respond, но для списка сообщений: в protobuf режиме
+// сообщения пишутся подряд, каждое с префиксом длины
+func respondList[T proto.Message](c *gin.Context, status int, msgs []T, jsonBody interface{}) {
+	if !acceptsProtobuf(c) {
+		c.JSON(status, jsonBody)
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		if _, err := protodelim.MarshalTo(&buf, msg); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to marshal response",
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	c.Data(status, ContentTypeProtobufDelimited, buf.Bytes())
+}
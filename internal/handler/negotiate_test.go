@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+
+	"api-gateway/internal/controller"
+	gen "api-gateway/pkg/gen"
+)
+
+// newVideoTestRouter собирает gin роутер с маршрутами видео хендлера
+func newVideoTestRouter(t *testing.T, service *controller.VideoStreamServiceImpl, opts ...VideoStreamHandlerOption) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h := NewVideoStreamHandler(zap.NewNop(), service, opts...)
+	t.Cleanup(h.Close)
+	router := gin.New()
+	h.RegisterRoutes(router.Group("/api/v1"))
+	return router
+}
+
+// startTestStream начинает стрим клиента и отправляет в него frames кадров
+func startTestStream(t *testing.T, service *controller.VideoStreamServiceImpl, clientID string, frames int) string {
+	t.Helper()
+	ctx := context.Background()
+	started, err := service.StartStream(ctx, &gen.StartStreamRequest{ClientId: clientID})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+	for i := 0; i < frames; i++ {
+		frame := &gen.VideoFrame{FrameId: "f", ClientId: clientID, Format: "jpeg", FrameData: []byte{1, 2, 3, 4}}
+		if _, err := service.SendFrameInternal(ctx, started.StreamId, clientID, clientID, frame); err != nil {
+			t.Fatalf("SendFrame: %v", err)
+		}
+	}
+	return started.StreamId
+}
+
+func TestContentNegotiation(t *testing.T) {
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+	router := newVideoTestRouter(t, service)
+	streamID := startTestStream(t, service, "cam-1", 3)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s (%s): status %d (%s)", path, accept, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	t.Run("single stream stats", func(t *testing.T) {
+		path := "/api/v1/video/stats/stream/" + streamID
+
+		jsonRec := get(path, "application/json")
+		if ct := jsonRec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("JSON Content-Type = %q", ct)
+		}
+		var body struct {
+			Stats struct {
+				StreamID       string `json:"stream_id"`
+				FramesReceived int64  `json:"frames_received"`
+				BytesReceived  int64  `json:"bytes_received"`
+			} `json:"stats"`
+		}
+		if err := json.Unmarshal(jsonRec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode JSON: %v", err)
+		}
+
+		protoRec := get(path, "application/x-protobuf")
+		if ct := protoRec.Header().Get("Content-Type"); ct != ContentTypeProtobuf {
+			t.Errorf("protobuf Content-Type = %q, want %q", ct, ContentTypeProtobuf)
+		}
+		var stats gen.StreamStats
+		if err := proto.Unmarshal(protoRec.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("decode protobuf: %v", err)
+		}
+
+		if stats.StreamId != body.Stats.StreamID || stats.FramesReceived != body.Stats.FramesReceived ||
+			stats.BytesReceived != body.Stats.BytesReceived {
+			t.Errorf("protobuf %v differs from JSON %+v", &stats, body.Stats)
+		}
+		if stats.FramesReceived != 3 {
+			t.Errorf("frames_received = %d, want 3", stats.FramesReceived)
+		}
+	})
+
+	t.Run("client stats list", func(t *testing.T) {
+		startTestStream(t, service, "cam-1", 1)
+		path := "/api/v1/video/stats/cam-1"
+
+		var body struct {
+			Stats []struct {
+				StreamID string `json:"stream_id"`
+			} `json:"stats"`
+		}
+		if err := json.Unmarshal(get(path, "application/json").Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode JSON: %v", err)
+		}
+
+		protoRec := get(path, "application/protobuf;q=0.9, */*;q=0.1")
+		if ct := protoRec.Header().Get("Content-Type"); ct != ContentTypeProtobufDelimited {
+			t.Errorf("protobuf Content-Type = %q, want %q", ct, ContentTypeProtobufDelimited)
+		}
+		reader := bufio.NewReader(bytes.NewReader(protoRec.Body.Bytes()))
+		ids := map[string]bool{}
+		for {
+			var stats gen.StreamStats
+			if err := protodelim.UnmarshalFrom(reader, &stats); err != nil {
+				break
+			}
+			ids[stats.StreamId] = true
+		}
+
+		if len(ids) != 2 || len(body.Stats) != 2 {
+			t.Fatalf("protobuf streams %v, JSON %+v, want 2 each", ids, body.Stats)
+		}
+		for _, stats := range body.Stats {
+			if !ids[stats.StreamID] {
+				t.Errorf("stream %s is missing from protobuf response", stats.StreamID)
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+)
+
+func TestSetRecordingReflectedInActiveStreams(t *testing.T) {
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+	router := newVideoTestRouter(t, service)
+	streamID := startTestStream(t, service, "cam-1", 0)
+
+	activeStream := func(t *testing.T) (recording, streaming bool) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/video/active", nil))
+		var body struct {
+			Streams []struct {
+				StreamID    string `json:"stream_id"`
+				IsRecording bool   `json:"is_recording"`
+				IsStreaming bool   `json:"is_streaming"`
+			} `json:"streams"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode active streams: %v", err)
+		}
+		for _, stream := range body.Streams {
+			if stream.StreamID == streamID {
+				return stream.IsRecording, stream.IsStreaming
+			}
+		}
+		t.Fatalf("stream %s is missing from active streams: %s", streamID, rec.Body.String())
+		return false, false
+	}
+
+	// Шаги выполняются по порядку на одном стриме
+	steps := []struct {
+		name          string
+		streamID      string
+		body          string
+		wantStatus    int
+		wantRecording bool
+	}{
+		{"start recording", streamID, `{"enabled":true}`, http.StatusOK, true},
+		{"pause recording", streamID, `{"enabled":false}`, http.StatusOK, false},
+		{"resume recording", streamID, `{"enabled":true}`, http.StatusOK, true},
+		{"enabled is required", streamID, `{}`, http.StatusBadRequest, true},
+		{"unknown stream", "no-such-stream", `{"enabled":false}`, http.StatusNotFound, true},
+	}
+	for _, step := range steps {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/video/stream/"+step.streamID+"/recording", strings.NewReader(step.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rec, req)
+		if rec.Code != step.wantStatus {
+			t.Fatalf("%s: status %d, want %d (%s)", step.name, rec.Code, step.wantStatus, rec.Body.String())
+		}
+
+		// Запись переключается, не останавливая live стрим
+		recording, streaming := activeStream(t)
+		if recording != step.wantRecording || !streaming {
+			t.Errorf("%s: is_recording=%v is_streaming=%v, want %v and true", step.name, recording, streaming, step.wantRecording)
+		}
+	}
+}
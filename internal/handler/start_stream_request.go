@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	gen "api-gateway/pkg/gen"
+)
+
+// videoFileExtensions допустимые расширения filename стрима
+var videoFileExtensions = []string{".mp4", ".mkv", ".webm", ".mov", ".avi", ".ts"}
+
+// identifierPattern допустимые символы client_id
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// startStreamBody тело запроса StartStream с правилами проверки:
+// сгенерированный gen.StartStreamRequest не несет тегов binding
+type startStreamBody struct {
+	ClientID   string   `json:"client_id" binding:"omitempty,max=64,identifier"`
+	UserID     string   `json:"user_id" binding:"omitempty,max=128,safe_name"`
+	CameraName string   `json:"camera_name" binding:"omitempty,max=128,safe_name"`
+	Filename   string   `json:"filename" binding:"omitempty,max=255,video_filename"`
+	Cameras    []string `json:"cameras" binding:"omitempty,max=16,dive,required,max=128,safe_name"` // камеры многокамерного стрима
+}
+
+// toRequest переносит проверенные поля в запрос сервиса
+func (b *startStreamBody) toRequest() *gen.StartStreamRequest {
+	return &gen.StartStreamRequest{
+		ClientId:   b.ClientID,
+		UserId:     b.UserID,
+		CameraName: b.CameraName,
+		Filename:   b.Filename,
+	}
+}
+
+var registerValidatorsOnce sync.Once
+
+// registerValidators добавляет в валидатор gin проверки identifier,
+// safe_name и video_filename и имена полей из json тегов для ошибок
+func registerValidators() {
+	registerValidatorsOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
+		v.RegisterValidation("identifier", func(fl validator.FieldLevel) bool {
+			return identifierPattern.MatchString(fl.Field().String())
+		})
+		// safe_name: без управляющих символов и "/" (камера - часть
+		// подканала "<stream_id>/<camera_id>")
+		v.RegisterValidation("safe_name", func(fl validator.FieldLevel) bool {
+			return !strings.ContainsFunc(fl.Field().String(), func(r rune) bool {
+				return unicode.IsControl(r) || r == '/' || r == '\\'
+			})
+		})
+		v.RegisterValidation("video_filename", func(fl validator.FieldLevel) bool {
+			name := fl.Field().String()
+			if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+				return false
+			}
+			ext := strings.ToLower(filepath.Ext(name))
+			for _, allowed := range videoFileExtensions {
+				if ext == allowed {
+					return true
+				}
+			}
+			return false
+		})
+	})
+}
+
+// fieldErrors возвращает ошибки проверки по полям запроса; false - err не
+// ошибка проверки
+func fieldErrors(err error) (map[string]string, bool) {
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return nil, false
+	}
+
+	fields := make(map[string]string, len(invalid))
+	for _, fe := range invalid {
+		field := strings.TrimPrefix(fe.Namespace(), "startStreamBody.")
+		fields[field] = fieldErrorMessage(fe)
+	}
+	return fields, true
+}
+
+// fieldErrorMessage описывает нарушенное правило поля
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "max":
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at most %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "required":
+		return "must not be empty"
+	case "identifier":
+		return "may contain only letters, digits, '_', '.' and '-'"
+	case "safe_name":
+		return "must not contain control characters or slashes"
+	case "video_filename":
+		return fmt.Sprintf("must be a file name without path with extension %s",
+			strings.Join(videoFileExtensions, ", "))
+	default:
+		return fmt.Sprintf("failed %s validation", fe.Tag())
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+)
+
+func TestStartStreamValidation(t *testing.T) {
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+	router := newVideoTestRouter(t, service)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantFields []string // поля с ошибками при 400
+	}{
+		{"valid request", `{"client_id":"cam-1.main_2","camera_name":"Front door","filename":"front.mp4"}`, http.StatusOK, nil},
+		{"client_id with space", `{"client_id":"cam 1"}`, http.StatusBadRequest, []string{"client_id"}},
+		{"client_id with slash", `{"client_id":"cam/1"}`, http.StatusBadRequest, []string{"client_id"}},
+		{"client_id too long", `{"client_id":"` + strings.Repeat("c", 65) + `"}`, http.StatusBadRequest, []string{"client_id"}},
+		{"camera_name too long", `{"client_id":"cam-2","camera_name":"` + strings.Repeat("n", 129) + `"}`, http.StatusBadRequest, []string{"camera_name"}},
+		{"camera_name with slash", `{"client_id":"cam-2","camera_name":"a/b"}`, http.StatusBadRequest, []string{"camera_name"}},
+		{"filename with path", `{"client_id":"cam-2","filename":"../etc/passwd.mp4"}`, http.StatusBadRequest, []string{"filename"}},
+		{"filename extension", `{"client_id":"cam-2","filename":"video.exe"}`, http.StatusBadRequest, []string{"filename"}},
+		{"empty camera in list", `{"client_id":"cam-2","cameras":["front",""]}`, http.StatusBadRequest, []string{"cameras[1]"}},
+		{"several fields", `{"client_id":"cam 2","camera_name":"a\u0001b"}`, http.StatusBadRequest, []string{"client_id", "camera_name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/video/start", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusBadRequest {
+				return
+			}
+
+			var body struct {
+				Error  string            `json:"error"`
+				Fields map[string]string `json:"fields"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Error != "Invalid request" || len(body.Fields) != len(tt.wantFields) {
+				t.Fatalf("body = %s, want errors for %v", rec.Body.String(), tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if body.Fields[field] == "" {
+					t.Errorf("no error for %s: %v", field, body.Fields)
+				}
+			}
+		})
+	}
+
+	// Отклоненные запросы не создают стримов
+	if count := service.GetActiveStreamsCount(); count != 1 {
+		t.Errorf("active streams = %d, want only the valid one", count)
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+	gen "api-gateway/pkg/gen"
+)
+
+// sseEvent событие Server-Sent Events
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSEEvent читает следующее событие из потока
+func readSSEEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if event.name != "" {
+				return event
+			}
+		case strings.HasPrefix(line, "event:"):
+			event.name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			event.data = strings.TrimPrefix(line, "data:")
+		}
+	}
+}
+
+func TestStreamStatsEvents(t *testing.T) {
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+	server := httptest.NewServer(newVideoTestRouter(t, service))
+	defer server.Close()
+	streamID := startTestStream(t, service, "cam-1", 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/video/stream/"+streamID+"/frames?interval=100", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	for i := 0; i < 2; i++ {
+		event := readSSEEvent(t, reader)
+		if event.name != "stats" {
+			t.Fatalf("event %d = %q, want stats", i, event.name)
+		}
+		var stats struct {
+			StreamID       string   `json:"stream_id"`
+			FramesReceived int64    `json:"frames_received"`
+			BytesReceived  int64    `json:"bytes_received"`
+			CurrentFPS     *float64 `json:"current_fps"`
+			Timestamp      int64    `json:"timestamp"`
+		}
+		if err := json.Unmarshal([]byte(event.data), &stats); err != nil {
+			t.Fatalf("event %d data %q: %v", i, event.data, err)
+		}
+		if stats.StreamID != streamID || stats.FramesReceived != 2 || stats.BytesReceived != 8 ||
+			stats.CurrentFPS == nil || stats.Timestamp == 0 {
+			t.Errorf("event %d data = %s", i, event.data)
+		}
+	}
+
+	// Остановленный стрим завершает поток событием end
+	if _, err := service.StopStream(ctx, &gen.StopStreamRequest{StreamId: streamID, ClientId: "cam-1"}); err != nil {
+		t.Fatalf("StopStream: %v", err)
+	}
+	for {
+		event := readSSEEvent(t, reader)
+		if event.name == "stats" {
+			continue
+		}
+		if event.name != "end" || !strings.Contains(event.data, `"reason":"stream_stopped"`) {
+			t.Errorf("final event = %+v, want end with stream_stopped", event)
+		}
+		break
+	}
+}
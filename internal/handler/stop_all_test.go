@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+)
+
+func TestStopAllForClient(t *testing.T) {
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+	router := newVideoTestRouter(t, service)
+
+	streams := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		streams[startTestStream(t, service, "cam-1", 1)] = true
+	}
+	other := startTestStream(t, service, "cam-2", 1)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/video/client/cam-1/stop-all", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		Stopped int `json:"stopped"`
+		Streams []struct {
+			StreamID string `json:"stream_id"`
+			EndTime  string `json:"end_time"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Stopped != 3 || len(body.Streams) != 3 {
+		t.Fatalf("stopped %d streams %+v, want 3", body.Stopped, body.Streams)
+	}
+	for _, stopped := range body.Streams {
+		if !streams[stopped.StreamID] || stopped.EndTime == "" {
+			t.Errorf("stopped stream %+v, want one of cam-1 streams with end_time", stopped)
+		}
+	}
+
+	if remaining := service.GetStreamsByClient("cam-1"); len(remaining) != 0 {
+		t.Errorf("cam-1 still has %d streams", len(remaining))
+	}
+	if stream, _ := service.GetStream(other); stream == nil {
+		t.Error("stream of another client was stopped")
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+)
+
+func TestGetStreamInfo(t *testing.T) {
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+	router := newVideoTestRouter(t, service)
+	streamID := startTestStream(t, service, "cam-1", 2)
+
+	tests := []struct {
+		name       string
+		streamID   string
+		wantStatus int
+	}{
+		{"existing stream", streamID, http.StatusOK},
+		{"missing stream", "no-such-stream", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/video/stream/"+tt.streamID, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			var body struct {
+				StreamID string `json:"stream_id"`
+				Stream   struct {
+					StreamID    string `json:"stream_id"`
+					ClientID    string `json:"client_id"`
+					IsStreaming bool   `json:"is_streaming"`
+				} `json:"stream"`
+				Stats *struct {
+					FramesReceived int64 `json:"frames_received"`
+					BytesReceived  int64 `json:"bytes_received"`
+					UptimeSeconds  int64 `json:"uptime_seconds"`
+				} `json:"stats"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			if tt.wantStatus == http.StatusNotFound {
+				if body.StreamID != tt.streamID {
+					t.Errorf("stream_id = %q, want %q", body.StreamID, tt.streamID)
+				}
+				return
+			}
+			if body.Stream.StreamID != streamID || body.Stream.ClientID != "cam-1" || !body.Stream.IsStreaming {
+				t.Errorf("stream = %+v", body.Stream)
+			}
+			if body.Stats == nil {
+				t.Fatal("stats are missing")
+			}
+			if body.Stats.FramesReceived != 2 || body.Stats.BytesReceived != 8 || body.Stats.UptimeSeconds < 0 {
+				t.Errorf("stats = %+v, want 2 frames, 8 bytes", *body.Stats)
+			}
+		})
+	}
+}
+
+func TestGetSingleStreamStats(t *testing.T) {
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+	router := newVideoTestRouter(t, service)
+	// Второй стрим того же клиента не должен попасть в ответ
+	streamID := startTestStream(t, service, "cam-1", 3)
+	startTestStream(t, service, "cam-1", 1)
+
+	tests := []struct {
+		name       string
+		streamID   string
+		wantStatus int
+		wantFrames int64
+	}{
+		{"present stream", streamID, http.StatusOK, 3},
+		{"absent stream", "no-such-stream", http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/video/stats/stream/"+tt.streamID, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			var body struct {
+				Error    string `json:"error"`
+				StreamID string `json:"stream_id"`
+				Stats    struct {
+					StreamID       string `json:"stream_id"`
+					ClientID       string `json:"client_id"`
+					FramesReceived int64  `json:"frames_received"`
+				} `json:"stats"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if tt.wantStatus == http.StatusNotFound {
+				if body.Error != "Stream not found" || body.StreamID != tt.streamID {
+					t.Errorf("body = %s", rec.Body.String())
+				}
+				return
+			}
+			if body.Stats.StreamID != streamID || body.Stats.ClientID != "cam-1" || body.Stats.FramesReceived != tt.wantFrames {
+				t.Errorf("stats = %+v, want stream %s with %d frames", body.Stats, streamID, tt.wantFrames)
+			}
+		})
+	}
+}
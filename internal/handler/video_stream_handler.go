@@ -1,34 +1,127 @@
 package handler
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"api-gateway/internal/controller"
+	"api-gateway/internal/tracing"
+	"api-gateway/internal/types"
 	gen "api-gateway/pkg/gen"
+	"api-gateway/pkg/proto"
 )
 
 // VideoStreamHandler обрабатывает HTTP запросы для видеостримов
 type VideoStreamHandler struct {
 	logger  *zap.Logger
 	service *controller.VideoStreamServiceImpl
+
+	// maxFrameSize максимальный размер данных кадра, байты
+	maxFrameSize int64
+	// maxBatchFrames максимальное число кадров в /video/frames
+	maxBatchFrames int
+	// maxBatchBytes максимальный размер тела /video/frames, байты
+	maxBatchBytes int64
+	// signatures проверка X-Frame-Signature (nil - без проверки)
+	signatures *FrameVerifier
+	// strictMetadata отклонять multipart кадры с невалидным metadata
+	strictMetadata bool
+	// chunks сборка кадров, загружаемых по частям
+	chunks *ChunkAssembler
+}
+
+// frameBodyOverhead запас на поля формы/JSON сверх данных кадра
+const frameBodyOverhead = 64 * 1024
+
+// VideoStreamHandlerOption настраивает хендлер при создании
+type VideoStreamHandlerOption func(*VideoStreamHandler)
+
+// WithMaxFrameSize задает лимит данных кадра в байтах (<= 0 - 10MB).
+// Тело запроса больше лимита отклоняется с 413.
+func WithMaxFrameSize(maxFrameSize int64) VideoStreamHandlerOption {
+	return func(h *VideoStreamHandler) {
+		if maxFrameSize > 0 {
+			h.maxFrameSize = maxFrameSize
+		}
+	}
+}
+
+// WithBatchLimits задает лимит кадров в одном пакете (<= 0 - 100) и
+// лимит тела пакета в байтах (<= 0 - 16MB)
+func WithBatchLimits(maxFrames int, maxBytes int64) VideoStreamHandlerOption {
+	return func(h *VideoStreamHandler) {
+		if maxFrames > 0 {
+			h.maxBatchFrames = maxFrames
+		}
+		if maxBytes > 0 {
+			h.maxBatchBytes = maxBytes
+		}
+	}
+}
+
+// WithFrameVerifier включает проверку X-Frame-Signature
+func WithFrameVerifier(signatures *FrameVerifier) VideoStreamHandlerOption {
+	return func(h *VideoStreamHandler) {
+		h.signatures = signatures
+	}
+}
+
+// WithStrictMetadata отвечает 400 на невалидный metadata multipart кадра
+// вместо предупреждения
+func WithStrictMetadata(strict bool) VideoStreamHandlerOption {
+	return func(h *VideoStreamHandler) {
+		h.strictMetadata = strict
+	}
+}
+
+// WithChunkAssembler задает сборку кадров по частям (по умолчанию -
+// пределы ChunkLimits{})
+func WithChunkAssembler(chunks *ChunkAssembler) VideoStreamHandlerOption {
+	return func(h *VideoStreamHandler) {
+		if chunks != nil {
+			h.chunks = chunks
+		}
+	}
 }
 
 // NewVideoStreamHandler создает новый хендлер
 func NewVideoStreamHandler(
 	logger *zap.Logger,
 	service *controller.VideoStreamServiceImpl,
+	opts ...VideoStreamHandlerOption,
 ) *VideoStreamHandler {
-	return &VideoStreamHandler{
-		logger:  logger,
-		service: service,
+	h := &VideoStreamHandler{
+		logger:         logger,
+		service:        service,
+		maxFrameSize:   10 * 1024 * 1024,
+		maxBatchFrames: 100,
+		maxBatchBytes:  16 * 1024 * 1024,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	if h.chunks == nil {
+		h.chunks = NewChunkAssembler(ChunkLimits{})
+	}
+	registerValidators()
+	return h
+}
+
+// Close останавливает фоновые задачи хендлера
+func (h *VideoStreamHandler) Close() {
+	h.chunks.Close()
 }
 
 // RegisterRoutes регистрирует маршруты
@@ -37,34 +130,75 @@ func (h *VideoStreamHandler) RegisterRoutes(router *gin.RouterGroup) {
 	{
 		video.POST("/start", h.StartStream)
 		video.POST("/frame", h.SendFrame)
+		video.POST("/frames", h.SendFrames)
+		video.POST("/frame/chunk", h.SendFrameChunk)
 		video.POST("/stop", h.StopStream)
 		video.GET("/active", h.GetActiveStreams)
 		video.GET("/stats/:client_id", h.GetStreamStats)
+		video.GET("/stats/stream/:stream_id", h.GetSingleStreamStats)
 		video.GET("/client/:client_id/streams", h.GetClientStreams)
+		video.POST("/client/:client_id/stop-all", h.StopAllForClient)
 		video.GET("/stream/:stream_id", h.GetStreamInfo)
+		video.GET("/stream/:stream_id/frames", h.StreamStatsEvents)
+		video.POST("/stream/:stream_id/recording", h.SetRecording)
+		video.POST("/stream/:stream_id/transfer", h.TransferStream)
 		video.GET("/all-stats", h.GetAllStats)
 	}
+
+	admin := router.Group("/admin", RequireAdmin())
+	{
+		admin.GET("/streams/:stream_id/sample", h.SampleStreamFrames)
+	}
 }
 
 // StartStream обрабатывает начало стрима
 func (h *VideoStreamHandler) StartStream(c *gin.Context) {
-	var req gen.StartStreamRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Error("Invalid request", zap.Error(err))
+	var body startStreamBody
+	if err := c.ShouldBindBodyWith(&body, binding.JSON); err != nil {
+		requestLogger(c, h.logger).Error("Invalid request", zap.Error(err))
+		if fields, ok := fieldErrors(err); ok {
+			c.JSON(400, gin.H{
+				"error":   "Invalid request",
+				"message": "request validation failed",
+				"fields":  fields,
+			})
+			return
+		}
 		c.JSON(400, gin.H{
 			"error":   "Invalid request",
 			"message": err.Error(),
 		})
 		return
 	}
+	req := body.toRequest()
+
+	// Аутентифицированный клиент стартует стримы только от своего имени
+	clientID, ok := bindClientID(c, req.ClientId)
+	if !ok {
+		return
+	}
+	req.ClientId = clientID
+	req.UserId = callerUserID(c, req.UserId)
+
+	idempotencyKey := c.GetHeader(controller.IdempotencyKeyHeader)
+	// Повторы сравниваются по запросу клиента: значения по умолчанию ниже
+	// зависят от времени
+	fingerprint := controller.RequestFingerprint(req, body.Cameras...)
 
 	// Устанавливаем значения по умолчанию
+	if req.ClientId == "" && idempotencyKey != "" {
+		// Повтор с тем же ключом должен попасть на того же клиента
+		req.ClientId = fmt.Sprintf("client_%x", sha256.Sum256([]byte(idempotencyKey)))[:23]
+	}
 	if req.ClientId == "" {
 		req.ClientId = fmt.Sprintf("client_%d", time.Now().Unix())
 	}
 	if req.UserId == "" {
 		req.UserId = req.ClientId
 	}
+	if req.CameraName == "" && len(body.Cameras) > 0 {
+		req.CameraName = body.Cameras[0]
+	}
 	if req.CameraName == "" {
 		req.CameraName = "default_camera"
 	}
@@ -72,14 +206,30 @@ func (h *VideoStreamHandler) StartStream(c *gin.Context) {
 		req.Filename = fmt.Sprintf("stream_%s_%d.mp4", req.ClientId, time.Now().Unix())
 	}
 
-	h.logger.Info("Starting stream",
+	requestLogger(c, h.logger).Info("Starting stream",
 		zap.String("client_id", req.ClientId),
 		zap.String("camera", req.CameraName))
 
 	// Вызываем сервис
-	response, err := h.service.StartStream(c.Request.Context(), &req)
+	ctx := controller.WithIdempotencyKey(serviceContext(c), idempotencyKey)
+	ctx = controller.WithRequestFingerprint(ctx, fingerprint)
+	response, err := h.service.StartMultiCameraStream(ctx, req, body.Cameras)
+	if h.respondKeyReused(c, err) {
+		return
+	}
+	if errors.Is(err, controller.ErrTooManyStarts) {
+		c.Header("Retry-After", "1")
+		c.JSON(429, gin.H{
+			"error":   "Too many requests",
+			"message": err.Error(),
+		})
+		return
+	}
+	if h.respondCapacity(c, err) {
+		return
+	}
 	if err != nil {
-		h.logger.Error("Failed to start stream", zap.Error(err))
+		requestLogger(c, h.logger).Error("Failed to start stream", zap.Error(err))
 		c.JSON(500, gin.H{
 			"error":   "Internal server error",
 			"message": err.Error(),
@@ -87,17 +237,28 @@ func (h *VideoStreamHandler) StartStream(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, gin.H{
+	details := gin.H{
+		"client_id":   req.ClientId,
+		"user_id":     req.UserId,
+		"camera_name": req.CameraName,
+		"cameras":     body.Cameras,
+		"filename":    req.Filename,
+	}
+	if len(body.Cameras) > 0 {
+		// Подканалы FrameHub, в которые публикуются кадры каждой камеры
+		channels := make(map[string]string, len(body.Cameras))
+		for _, camera := range body.Cameras {
+			channels[camera] = controller.CameraChannel(response.StreamId, camera)
+		}
+		details["camera_channels"] = channels
+	}
+
+	respond(c, 200, response, gin.H{
 		"status":    "ok",
 		"stream_id": response.StreamId,
 		"message":   response.Message,
 		"timestamp": time.Now().Unix(),
-		"details": gin.H{
-			"client_id":   req.ClientId,
-			"user_id":     req.UserId,
-			"camera_name": req.CameraName,
-			"filename":    req.Filename,
-		},
+		"details":   details,
 	})
 }
 
@@ -105,20 +266,32 @@ func (h *VideoStreamHandler) StartStream(c *gin.Context) {
 func (h *VideoStreamHandler) SendFrame(c *gin.Context) {
 	contentType := c.GetHeader("Content-Type")
 
+	// Тело нужно целиком для проверки подписи кадра
+	body, ok := h.bufferSignedBody(c, h.maxFrameSize*4/3+frameBodyOverhead, h.respondTooLarge)
+	if !ok {
+		return
+	}
+
 	// Определяем формат запроса
 	if strings.Contains(contentType, "multipart/form-data") {
-		h.handleMultipartFrame(c)
+		h.handleMultipartFrame(c, body)
 	} else {
-		h.handleJSONFrame(c)
+		h.handleJSONFrame(c, body)
 	}
 }
 
 // handleMultipartFrame обрабатывает multipart запрос с бинарными данными
-func (h *VideoStreamHandler) handleMultipartFrame(c *gin.Context) {
+func (h *VideoStreamHandler) handleMultipartFrame(c *gin.Context, body []byte) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxFrameSize+frameBodyOverhead)
+
 	// Получаем файл
 	file, header, err := c.Request.FormFile("frame")
+	if isBodyTooLarge(err) {
+		h.respondTooLarge(c)
+		return
+	}
 	if err != nil {
-		h.logger.Error("No frame file in multipart", zap.Error(err))
+		requestLogger(c, h.logger).Error("No frame file in multipart", zap.Error(err))
 		c.JSON(400, gin.H{
 			"error":   "No frame file",
 			"message": "Please include 'frame' file in multipart form",
@@ -128,9 +301,13 @@ func (h *VideoStreamHandler) handleMultipartFrame(c *gin.Context) {
 	defer file.Close()
 
 	// Читаем данные
-	frameData, err := io.ReadAll(file)
+	frameData, err := io.ReadAll(io.LimitReader(file, h.maxFrameSize+1))
+	if err == nil && int64(len(frameData)) > h.maxFrameSize {
+		h.respondTooLarge(c)
+		return
+	}
 	if err != nil {
-		h.logger.Error("Failed to read frame data", zap.Error(err))
+		requestLogger(c, h.logger).Error("Failed to read frame data", zap.Error(err))
 		c.JSON(500, gin.H{
 			"error":   "Failed to read frame",
 			"message": err.Error(),
@@ -139,18 +316,28 @@ func (h *VideoStreamHandler) handleMultipartFrame(c *gin.Context) {
 	}
 
 	// Получаем метаданные
-	metadataStr := c.PostForm("metadata")
-	var metadata map[string]interface{}
-	if metadataStr != "" {
-		if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
-			h.logger.Warn("Failed to parse metadata", zap.Error(err))
+	metadata, warnings := parseFrameMetadata(c.PostForm("metadata"))
+	if len(warnings) > 0 {
+		requestLogger(c, h.logger).Warn("Invalid frame metadata",
+			zap.Strings("problems", warnings),
+			zap.Bool("strict", h.strictMetadata))
+		if h.strictMetadata {
+			c.JSON(400, gin.H{
+				"error":    "Invalid metadata",
+				"message":  warnings[0],
+				"problems": warnings,
+			})
+			return
 		}
 	}
 
 	// Извлекаем параметры
 	streamID := getStringFromMap(metadata, "stream_id", "")
-	clientID := getStringFromMap(metadata, "client_id", "")
-	userName := getStringFromMap(metadata, "user_name", "multipart_client")
+	clientID, ok := bindClientID(c, getStringFromMap(metadata, "client_id", ""))
+	if !ok {
+		return
+	}
+	userName := callerUserID(c, getStringFromMap(metadata, "user_name", "multipart_client"))
 	width := getIntFromMap(metadata, "width", 1920)
 	height := getIntFromMap(metadata, "height", 1080)
 
@@ -160,27 +347,59 @@ func (h *VideoStreamHandler) handleMultipartFrame(c *gin.Context) {
 			clientID = fmt.Sprintf("multipart_%d", time.Now().Unix())
 		}
 		streamID = fmt.Sprintf("stream_%s_%d", clientID, time.Now().UnixNano())
-		h.logger.Info("Auto-generated stream_id",
+		requestLogger(c, h.logger).Info("Auto-generated stream_id",
 			zap.String("stream_id", streamID),
 			zap.String("client_id", clientID))
 	}
 
+	clientID, ok = h.resolveFrameOwner(c, streamID, clientID)
+	if !ok {
+		return
+	}
+
+	if !h.checkFrameSignature(c, streamID, clientID, body) {
+		return
+	}
+
 	// Создаем frame
-	frame := &gen.VideoFrame{
-		FrameId:   fmt.Sprintf("frame_%d", time.Now().UnixNano()),
+	frame := (&types.VideoFrame{
+		FrameID:   fmt.Sprintf("frame_%d", time.Now().UnixNano()),
 		FrameData: frameData,
 		Timestamp: time.Now().Unix(),
-		ClientId:  clientID,
-		CameraId:  "multipart_stream",
+		ClientID:  clientID,
+		CameraID:  getStringFromMap(metadata, "camera_id", "multipart_stream"),
 		Width:     int32(width),
 		Height:    int32(height),
 		Format:    header.Header.Get("Content-Type"),
-	}
+	}).ToGen()
 
 	// Обрабатываем кадр
-	response, err := h.service.SendFrameInternal(streamID, clientID, userName, frame)
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(
+		tracing.AttrStreamID.String(streamID),
+		tracing.AttrClientID.String(clientID),
+		tracing.AttrFrameSize.Int(len(frameData)),
+	)
+
+	response, err := h.service.SendFrameInternal(serviceContext(c), streamID, clientID, userName, frame)
+	if h.respondThrottled(c, err) || h.respondCapacity(c, err) {
+		return
+	}
+	if errors.Is(err, controller.ErrUnknownCamera) {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, controller.ErrFormatChanged) {
+		c.JSON(409, gin.H{
+			"error":   "Frame format changed",
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		h.logger.Error("Failed to process frame", zap.Error(err))
+		requestLogger(c, h.logger).Error("Failed to process frame", zap.Error(err))
 		c.JSON(500, gin.H{
 			"error":   "Failed to process frame",
 			"message": err.Error(),
@@ -188,7 +407,7 @@ func (h *VideoStreamHandler) handleMultipartFrame(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, gin.H{
+	result := gin.H{
 		"status":     response.Status,
 		"message":    response.Message,
 		"timestamp":  response.Timestamp,
@@ -196,11 +415,15 @@ func (h *VideoStreamHandler) handleMultipartFrame(c *gin.Context) {
 		"format":     "multipart",
 		"frame_size": len(frameData),
 		"stream_id":  streamID,
-	})
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+	c.JSON(200, result)
 }
 
 // handleJSONFrame обрабатывает JSON запрос (обратная совместимость)
-func (h *VideoStreamHandler) handleJSONFrame(c *gin.Context) {
+func (h *VideoStreamHandler) handleJSONFrame(c *gin.Context, body []byte) {
 	var req struct {
 		StreamID string                 `json:"stream_id"`
 		ClientID string                 `json:"client_id"`
@@ -208,8 +431,15 @@ func (h *VideoStreamHandler) handleJSONFrame(c *gin.Context) {
 		Frame    map[string]interface{} `json:"frame"`
 	}
 
+	// base64 увеличивает данные на треть
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxFrameSize*4/3+frameBodyOverhead)
+
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Error("Invalid JSON request", zap.Error(err))
+		if isBodyTooLarge(err) {
+			h.respondTooLarge(c)
+			return
+		}
+		requestLogger(c, h.logger).Error("Invalid JSON request", zap.Error(err))
 		c.JSON(400, gin.H{
 			"error":   "Invalid JSON",
 			"message": err.Error(),
@@ -217,48 +447,76 @@ func (h *VideoStreamHandler) handleJSONFrame(c *gin.Context) {
 		return
 	}
 
+	clientID, ok := bindClientID(c, req.ClientID)
+	if !ok {
+		return
+	}
+	req.ClientID = clientID
+	req.UserName = callerUserID(c, req.UserName)
+
 	// Автогенерация stream_id если не указан
 	if req.StreamID == "" {
 		if req.ClientID == "" {
 			req.ClientID = fmt.Sprintf("json_%d", time.Now().Unix())
 		}
 		req.StreamID = fmt.Sprintf("stream_%s_%d", req.ClientID, time.Now().UnixNano())
-		h.logger.Info("Auto-generated stream_id",
+		requestLogger(c, h.logger).Info("Auto-generated stream_id",
 			zap.String("stream_id", req.StreamID),
 			zap.String("client_id", req.ClientID))
 	}
 
+	if req.ClientID, ok = h.resolveFrameOwner(c, req.StreamID, req.ClientID); !ok {
+		return
+	}
+
+	if !h.checkFrameSignature(c, req.StreamID, req.ClientID, body) {
+		return
+	}
+
 	if req.UserName == "" {
 		req.UserName = req.ClientID
 	}
 
-	// Извлекаем данные кадра
-	frameData, ok := req.Frame["frame_data"].(string)
-	if !ok {
+	frame, err := jsonFrameToGen(req.Frame, fmt.Sprintf("frame_%d", time.Now().UnixNano()), req.ClientID)
+	if err != nil {
 		c.JSON(400, gin.H{
 			"error":   "Invalid frame data",
-			"message": "frame.frame_data is required and must be base64 string",
+			"message": err.Error(),
 		})
 		return
 	}
-
-	// Преобразуем base64 в байты (если нужно)
-	// В прото файле уже указано что это base64, так что предполагаем что это строка
-	frame := &gen.VideoFrame{
-		FrameId:   fmt.Sprintf("frame_%d", time.Now().UnixNano()),
-		FrameData: []byte(frameData), // Здесь можно добавить base64 decoding
-		Timestamp: getInt64FromMap(req.Frame, "timestamp", time.Now().Unix()),
-		ClientId:  req.ClientID,
-		CameraId:  getStringFromMap(req.Frame, "camera_id", "json_camera"),
-		Width:     int32(getIntFromMap(req.Frame, "width", 1920)),
-		Height:    int32(getIntFromMap(req.Frame, "height", 1080)),
-		Format:    getStringFromMap(req.Frame, "format", "jpeg"),
+	if int64(len(frame.FrameData)) > h.maxFrameSize {
+		h.respondTooLarge(c)
+		return
 	}
 
 	// Обрабатываем кадр
-	response, err := h.service.SendFrameInternal(req.StreamID, req.ClientID, req.UserName, frame)
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(
+		tracing.AttrStreamID.String(req.StreamID),
+		tracing.AttrClientID.String(req.ClientID),
+		tracing.AttrFrameSize.Int(len(frame.FrameData)),
+	)
+
+	response, err := h.service.SendFrameInternal(serviceContext(c), req.StreamID, req.ClientID, req.UserName, frame)
+	if h.respondThrottled(c, err) || h.respondCapacity(c, err) {
+		return
+	}
+	if errors.Is(err, controller.ErrUnknownCamera) {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, controller.ErrFormatChanged) {
+		c.JSON(409, gin.H{
+			"error":   "Frame format changed",
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		h.logger.Error("Failed to process frame", zap.Error(err))
+		requestLogger(c, h.logger).Error("Failed to process frame", zap.Error(err))
 		c.JSON(500, gin.H{
 			"error":   "Failed to process frame",
 			"message": err.Error(),
@@ -272,7 +530,7 @@ func (h *VideoStreamHandler) handleJSONFrame(c *gin.Context) {
 		"timestamp":  response.Timestamp,
 		"metadata":   response.Metadata,
 		"format":     "json_base64",
-		"frame_size": len(frameData),
+		"frame_size": len(frame.FrameData),
 		"stream_id":  req.StreamID,
 	})
 }
@@ -288,7 +546,7 @@ func (h *VideoStreamHandler) StopStream(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Error("Invalid request", zap.Error(err))
+		requestLogger(c, h.logger).Error("Invalid request", zap.Error(err))
 		c.JSON(400, gin.H{
 			"error":   "Invalid request",
 			"message": err.Error(),
@@ -296,8 +554,13 @@ func (h *VideoStreamHandler) StopStream(c *gin.Context) {
 		return
 	}
 
-	if req.EndTime == 0 {
-		req.EndTime = time.Now().Unix()
+	clientID, ok := bindClientID(c, req.ClientID)
+	if !ok {
+		return
+	}
+	req.ClientID = clientID
+	if stream, _ := h.service.GetStream(req.StreamID); stream != nil && !authorizeStream(c, stream) {
+		return
 	}
 
 	stopReq := &gen.StopStreamRequest{
@@ -307,10 +570,20 @@ func (h *VideoStreamHandler) StopStream(c *gin.Context) {
 		EndTime:  req.EndTime,
 		FileSize: req.FileSize,
 	}
+	// Отпечаток до подстановки end_time, иначе повторы не совпадут
+	fingerprint := controller.RequestFingerprint(stopReq)
+	if stopReq.EndTime == 0 {
+		stopReq.EndTime = time.Now().Unix()
+	}
 
-	response, err := h.service.StopStream(c.Request.Context(), stopReq)
+	ctx := controller.WithIdempotencyKey(c.Request.Context(), c.GetHeader(controller.IdempotencyKeyHeader))
+	ctx = controller.WithRequestFingerprint(ctx, fingerprint)
+	response, err := h.service.StopStream(ctx, stopReq)
+	if h.respondKeyReused(c, err) {
+		return
+	}
 	if err != nil {
-		h.logger.Error("Failed to stop stream", zap.Error(err))
+		requestLogger(c, h.logger).Error("Failed to stop stream", zap.Error(err))
 		c.JSON(500, gin.H{
 			"error":   "Internal server error",
 			"message": err.Error(),
@@ -318,7 +591,7 @@ func (h *VideoStreamHandler) StopStream(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, gin.H{
+	respond(c, 200, response, gin.H{
 		"status":    response.Status,
 		"message":   response.Message,
 		"timestamp": response.Timestamp,
@@ -330,8 +603,14 @@ func (h *VideoStreamHandler) StopStream(c *gin.Context) {
 func (h *VideoStreamHandler) GetActiveStreams(c *gin.Context) {
 	activeStreams := h.service.GetAllActiveStreams()
 
+	// Клиенту без прав администратора видны только его стримы
+	identity := identityFrom(c)
+
 	streams := make([]gin.H, 0, len(activeStreams))
 	for _, stream := range activeStreams {
+		if !ownsStream(identity, stream) {
+			continue
+		}
 		streams = append(streams, gin.H{
 			"stream_id":    stream.StreamId,
 			"client_id":    stream.ClientId,
@@ -353,11 +632,15 @@ func (h *VideoStreamHandler) GetActiveStreams(c *gin.Context) {
 // GetStreamStats возвращает статистику стрима
 func (h *VideoStreamHandler) GetStreamStats(c *gin.Context) {
 	clientID := c.Param("client_id")
+	if !authorizeClient(c, clientID) {
+		return
+	}
 
 	// Получаем все стримы клиента
 	clientStreams := h.service.GetStreamsByClient(clientID)
 
 	stats := make([]gin.H, 0, len(clientStreams))
+	statsMsgs := make([]*gen.StreamStats, 0, len(clientStreams))
 	for _, stream := range clientStreams {
 		streamStats, err := h.service.GetStreamStats(c.Request.Context(), &gen.GetStreamStatsRequest{
 			StreamId: stream.StreamId,
@@ -365,25 +648,12 @@ func (h *VideoStreamHandler) GetStreamStats(c *gin.Context) {
 		})
 
 		if err == nil {
-			stats = append(stats, gin.H{
-				"stream_id":       streamStats.StreamId,
-				"client_id":       streamStats.ClientId,
-				"start_time":      streamStats.StartTime,
-				"duration":        streamStats.Duration,
-				"frames_received": streamStats.FramesReceived,
-				"bytes_received":  streamStats.BytesReceived,
-				"average_fps":     streamStats.AverageFps,
-				"current_fps":     streamStats.CurrentFps,
-				"width":           streamStats.Width,
-				"height":          streamStats.Height,
-				"codec":           streamStats.Codec,
-				"is_recording":    streamStats.IsRecording,
-				"is_streaming":    streamStats.IsStreaming,
-			})
+			statsMsgs = append(statsMsgs, streamStats)
+			stats = append(stats, streamStatsView(streamStats, h.service.GetFormatHistogram(streamStats.StreamId)))
 		}
 	}
 
-	c.JSON(200, gin.H{
+	respondList(c, 200, statsMsgs, gin.H{
 		"status":    "ok",
 		"client_id": clientID,
 		"stats":     stats,
@@ -391,9 +661,64 @@ func (h *VideoStreamHandler) GetStreamStats(c *gin.Context) {
 	})
 }
 
+// GetSingleStreamStats возвращает статистику одного стрима
+func (h *VideoStreamHandler) GetSingleStreamStats(c *gin.Context) {
+	streamID := c.Param("stream_id")
+	if stream, _ := h.service.GetStream(streamID); stream != nil && !authorizeStream(c, stream) {
+		return
+	}
+
+	stats, err := h.service.GetStreamStats(c.Request.Context(), &gen.GetStreamStatsRequest{
+		StreamId: streamID,
+	})
+	if errors.Is(err, controller.ErrStreamNotFound) {
+		c.JSON(404, gin.H{
+			"error":     "Stream not found",
+			"stream_id": streamID,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{
+			"error":   "Failed to get stream stats",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	respond(c, 200, stats, gin.H{
+		"status":    "ok",
+		"stats":     streamStatsView(stats, h.service.GetFormatHistogram(stats.StreamId)),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// streamStatsView JSON представление статистики стрима для HTTP и WebSocket
+func streamStatsView(stats *gen.StreamStats, formats map[string]int64) gin.H {
+	return gin.H{
+		"stream_id":       stats.StreamId,
+		"client_id":       stats.ClientId,
+		"start_time":      stats.StartTime,
+		"duration":        stats.Duration,
+		"frames_received": stats.FramesReceived,
+		"bytes_received":  stats.BytesReceived,
+		"average_fps":     stats.AverageFps,
+		"current_fps":     stats.CurrentFps,
+		"width":           stats.Width,
+		"height":          stats.Height,
+		"codec":           stats.Codec,
+		"formats":         formats,
+		"is_recording":    stats.IsRecording,
+		"is_streaming":    stats.IsStreaming,
+	}
+}
+
 // GetClientStreams возвращает стримы клиента
 func (h *VideoStreamHandler) GetClientStreams(c *gin.Context) {
 	clientID := c.Param("client_id")
+	if !authorizeClient(c, clientID) {
+		return
+	}
 
 	streams := h.service.GetStreamsByClient(clientID)
 
@@ -418,25 +743,284 @@ func (h *VideoStreamHandler) GetClientStreams(c *gin.Context) {
 	})
 }
 
+// StopAllForClient останавливает все стримы клиента
+func (h *VideoStreamHandler) StopAllForClient(c *gin.Context) {
+	clientID := c.Param("client_id")
+	if !authorizeClient(c, clientID) {
+		return
+	}
+
+	results, err := h.service.StopAllForClient(c.Request.Context(), clientID)
+	if err != nil {
+		requestLogger(c, h.logger).Error("Failed to stop client streams",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		c.JSON(500, gin.H{
+			"error":   "Failed to stop streams",
+			"message": err.Error(),
+			"stopped": len(results),
+		})
+		return
+	}
+
+	stopped := make([]gin.H, 0, len(results))
+	for _, result := range results {
+		stopped = append(stopped, gin.H{
+			"stream_id": result.Metadata["stream_id"],
+			"end_time":  result.Metadata["end_time"],
+		})
+	}
+
+	c.JSON(200, gin.H{
+		"status":    "ok",
+		"client_id": clientID,
+		"stopped":   len(stopped),
+		"streams":   stopped,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
 // GetStreamInfo возвращает информацию о конкретном стриме
 func (h *VideoStreamHandler) GetStreamInfo(c *gin.Context) {
 	streamID := c.Param("stream_id")
 
-	// Здесь можно добавить логику получения конкретного стрима
-	// Пока возвращаем заглушку
+	stream, stats := h.service.GetStream(streamID)
+	if stream == nil {
+		c.JSON(404, gin.H{
+			"error":     "Stream not found",
+			"stream_id": streamID,
+		})
+		return
+	}
+	if !authorizeStream(c, stream) {
+		return
+	}
+
+	response := gin.H{
+		"status": "ok",
+		"stream": gin.H{
+			"stream_id":    stream.StreamId,
+			"client_id":    stream.ClientId,
+			"user_name":    stream.UserName,
+			"camera_name":  stream.CameraName,
+			"is_recording": stream.IsRecording,
+			"is_streaming": stream.IsStreaming,
+		},
+		"timestamp": time.Now().Unix(),
+	}
+
+	if cameras := h.service.GetStreamCameras(streamID); len(cameras) > 0 {
+		channels := make(map[string]string, len(cameras))
+		for _, camera := range cameras {
+			channels[camera] = controller.CameraChannel(streamID, camera)
+		}
+		response["cameras"] = channels
+	}
+
+	if stats != nil {
+		response["stats"] = gin.H{
+			"start_time":      stats.StartTime,
+			"uptime_seconds":  time.Now().Unix() - stats.StartTime,
+			"frames_received": stats.FramesReceived,
+			"bytes_received":  stats.BytesReceived,
+			"average_fps":     stats.AverageFps,
+			"current_fps":     stats.CurrentFps,
+			"width":           stats.Width,
+			"height":          stats.Height,
+			"codec":           stats.Codec,
+		}
+		if lastFrameAt, ok := h.service.GetLastFrameAt(streamID); ok {
+			response["stats"].(gin.H)["last_frame_at"] = lastFrameAt.Unix()
+		}
+	}
+
+	c.JSON(200, response)
+}
+
+// StreamStatsEvents отдает статистику стрима как Server-Sent Events:
+// событие "stats" каждые interval миллисекунд (100..10000, по умолчанию
+// 1000) до отключения клиента, затем "end", когда стрим остановлен.
+func (h *VideoStreamHandler) StreamStatsEvents(c *gin.Context) {
+	streamID := c.Param("stream_id")
+
+	intervalMs, err := strconv.Atoi(c.DefaultQuery("interval", "1000"))
+	if err != nil || intervalMs < 100 || intervalMs > 10000 {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": "interval must be between 100 and 10000 ms",
+		})
+		return
+	}
+
+	stream, _ := h.service.GetStream(streamID)
+	if stream == nil {
+		c.JSON(404, gin.H{
+			"error":     "Stream not found",
+			"stream_id": streamID,
+		})
+		return
+	}
+	if !authorizeStream(c, stream) {
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	ctx := c.Request.Context()
+	first := true
+
+	c.Stream(func(w io.Writer) bool {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-ticker.C:
+			}
+		}
+		first = false
+
+		stream, stats := h.service.GetStream(streamID)
+		if stream == nil || stats == nil {
+			c.SSEvent("end", gin.H{
+				"stream_id": streamID,
+				"reason":    "stream_stopped",
+				"timestamp": time.Now().Unix(),
+			})
+			return false
+		}
+
+		c.SSEvent("stats", gin.H{
+			"stream_id":       streamID,
+			"frames_received": stats.FramesReceived,
+			"bytes_received":  stats.BytesReceived,
+			"average_fps":     stats.AverageFps,
+			"current_fps":     stats.CurrentFps,
+			"is_recording":    stats.IsRecording,
+			"is_streaming":    stats.IsStreaming,
+			"timestamp":       time.Now().Unix(),
+		})
+		return true
+	})
+}
+
+// SetRecording включает или приостанавливает запись стрима
+func (h *VideoStreamHandler) SetRecording(c *gin.Context) {
+	streamID := c.Param("stream_id")
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Enabled == nil {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": "enabled (bool) is required",
+		})
+		return
+	}
+	if stream, _ := h.service.GetStream(streamID); stream != nil && !authorizeStream(c, stream) {
+		return
+	}
+
+	if err := h.service.SetRecording(streamID, *req.Enabled); err != nil {
+		if errors.Is(err, controller.ErrStreamNotFound) {
+			c.JSON(404, gin.H{
+				"error":     "Stream not found",
+				"stream_id": streamID,
+			})
+			return
+		}
+		c.JSON(500, gin.H{
+			"error":   "Internal server error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":       "ok",
+		"stream_id":    streamID,
+		"is_recording": *req.Enabled,
+		"timestamp":    time.Now().Unix(),
+	})
+}
+
+// TransferStream передает стрим аутентифицированному клиенту того же
+// пользователя; new_client_id необязателен и должен совпадать с клиентом
+// вызывающего. Администратор передает стрим любому new_client_id.
+func (h *VideoStreamHandler) TransferStream(c *gin.Context) {
+	streamID := c.Param("stream_id")
+
+	identity := identityFrom(c)
+	if identity == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "authentication required",
+		})
+		return
+	}
+
+	var req struct {
+		NewClientID string `json:"new_client_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Пользователя сравниваем только подтвержденного: из личности
+	// вызывающего и владельца, записанного при создании стрима
+	newClientID, userID := req.NewClientID, ""
+	if !identity.Admin {
+		var ok bool
+		if newClientID, ok = bindClientID(c, newClientID); !ok {
+			return
+		}
+		userID = callerUserID(c, "")
+	}
+	if newClientID == "" {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": "new_client_id is required",
+		})
+		return
+	}
+
+	stream, err := h.service.TransferStream(c.Request.Context(), streamID, newClientID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, controller.ErrStreamNotFound):
+			c.JSON(404, gin.H{
+				"error":     "Stream not found",
+				"stream_id": streamID,
+			})
+		case errors.Is(err, controller.ErrStreamUserMismatch), errors.Is(err, controller.ErrStreamOwnerUnverified):
+			c.JSON(403, gin.H{
+				"error":   "Transfer not allowed",
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(500, gin.H{
+				"error":   "Internal server error",
+				"message": err.Error(),
+			})
+		}
+		return
+	}
+
 	c.JSON(200, gin.H{
 		"status":    "ok",
 		"stream_id": streamID,
-		"message":   "Stream info endpoint",
+		"client_id": stream.ClientId,
+		"user_id":   stream.UserName,
 		"timestamp": time.Now().Unix(),
-		"endpoints": []string{
-			"/api/v1/video/start - Start stream",
-			"/api/v1/video/frame - Send frame",
-			"/api/v1/video/stop - Stop stream",
-			"/api/v1/video/active - Get active streams",
-			"/api/v1/video/stats/{client_id} - Get stats",
-			"/api/v1/video/client/{client_id}/streams - Get client streams",
-		},
 	})
 }
 
@@ -444,6 +1028,17 @@ func (h *VideoStreamHandler) GetStreamInfo(c *gin.Context) {
 func (h *VideoStreamHandler) GetAllStats(c *gin.Context) {
 	allStats := h.service.GetAllStats()
 
+	// Клиенту без прав администратора видна только статистика его стримов
+	if identity := identityFrom(c); identity != nil && !identity.Admin {
+		visible := allStats[:0:0]
+		for _, stat := range allStats {
+			if stream, _ := h.service.GetStream(stat.StreamId); stream != nil && ownsStream(identity, stream) {
+				visible = append(visible, stat)
+			}
+		}
+		allStats = visible
+	}
+
 	stats := make([]gin.H, 0, len(allStats))
 	totalFrames := int64(0)
 	totalBytes := int64(0)
@@ -464,7 +1059,7 @@ func (h *VideoStreamHandler) GetAllStats(c *gin.Context) {
 		})
 	}
 
-	c.JSON(200, gin.H{
+	respondList(c, 200, allStats, gin.H{
 		"status":        "ok",
 		"total_streams": len(stats),
 		"total_frames":  totalFrames,
@@ -474,6 +1069,87 @@ func (h *VideoStreamHandler) GetAllStats(c *gin.Context) {
 	})
 }
 
+// SampleStreamFrames возвращает метаданные следующих N кадров стрима.
+// Параметры: count (1..100, по умолчанию 10), timeout в секундах
+// (по умолчанию 10, максимум 60), thumbnail=true - добавить данные кадра.
+// Только для администраторов; неизвестный стрим - 404, сверх
+// controller.MaxConcurrentSamples одновременных выборок - 429.
+func (h *VideoStreamHandler) SampleStreamFrames(c *gin.Context) {
+	streamID := c.Param("stream_id")
+
+	count, err := strconv.Atoi(c.DefaultQuery("count", "10"))
+	if err != nil || count <= 0 || count > controller.MaxSampleFrames {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": fmt.Sprintf("count must be between 1 and %d", controller.MaxSampleFrames),
+		})
+		return
+	}
+
+	timeoutSec, err := strconv.Atoi(c.DefaultQuery("timeout", "10"))
+	timeout := time.Duration(timeoutSec) * time.Second
+	if err != nil || timeout <= 0 || timeout > controller.MaxSampleTimeout {
+		c.JSON(400, gin.H{
+			"error":   "Invalid request",
+			"message": fmt.Sprintf("timeout must be between 1 and %d seconds", int(controller.MaxSampleTimeout.Seconds())),
+		})
+		return
+	}
+
+	withThumbnail := c.Query("thumbnail") == "true"
+
+	samples, err := h.service.SampleFrames(c.Request.Context(), streamID, count, withThumbnail, timeout)
+	switch {
+	case errors.Is(err, controller.ErrStreamNotFound):
+		c.JSON(404, gin.H{
+			"error":     "Stream not found",
+			"stream_id": streamID,
+		})
+		return
+	case errors.Is(err, controller.ErrTooManySamples):
+		c.Header("Retry-After", strconv.Itoa(int(timeout.Seconds())))
+		c.JSON(429, gin.H{
+			"error":   "Too many requests",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":    "ok",
+		"stream_id": streamID,
+		"requested": count,
+		"captured":  len(samples),
+		"complete":  len(samples) == count,
+		"frames":    samples,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// jsonFrameToGen собирает кадр из JSON объекта frame; frame_data
+// приходит в base64 и декодируется через канонический тип
+func jsonFrameToGen(raw map[string]interface{}, frameID, clientID string) (*gen.VideoFrame, error) {
+	frameData, ok := raw["frame_data"].(string)
+	if !ok {
+		return nil, errors.New("frame.frame_data is required and must be base64 string")
+	}
+
+	canonical, err := types.FromProto(&proto.VideoFrame{
+		FrameID:   frameID,
+		FrameData: frameData,
+		Timestamp: getInt64FromMap(raw, "timestamp", time.Now().Unix()),
+		ClientID:  clientID,
+		CameraID:  getStringFromMap(raw, "camera_id", "json_camera"),
+		Width:     int32(getIntFromMap(raw, "width", 1920)),
+		Height:    int32(getIntFromMap(raw, "height", 1080)),
+		Format:    getStringFromMap(raw, "format", "jpeg"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return canonical.ToGen(), nil
+}
+
 // Вспомогательные функции
 func getStringFromMap(m map[string]interface{}, key, defaultValue string) string {
 	if m == nil {
@@ -514,3 +1190,75 @@ func getInt64FromMap(m map[string]interface{}, key string, defaultValue int64) i
 	}
 	return defaultValue
 }
+
+// isBodyTooLarge проверяет, что чтение тела прервал http.MaxBytesReader
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// respondTooLarge отвечает 413 с лимитом размера кадра
+func (h *VideoStreamHandler) respondTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":          "Frame too large",
+		"message":        fmt.Sprintf("frame data must not exceed %d bytes", h.maxFrameSize),
+		"max_frame_size": h.maxFrameSize,
+	})
+}
+
+// respondBatchTooLarge отвечает 413 с лимитом тела пакета кадров
+func (h *VideoStreamHandler) respondBatchTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":           "Batch too large",
+		"message":         fmt.Sprintf("batch body must not exceed %d bytes", h.maxBatchBytes),
+		"max_batch_bytes": h.maxBatchBytes,
+	})
+}
+
+// respondKeyReused отвечает 422, если Idempotency-Key уже использован с
+// другим запросом
+func (h *VideoStreamHandler) respondKeyReused(c *gin.Context, err error) bool {
+	if !errors.Is(err, controller.ErrIdempotencyKeyReused) {
+		return false
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":   "Idempotency key reused",
+		"message": err.Error(),
+	})
+	return true
+}
+
+// respondThrottled отвечает 429 с Retry-After, если кадр отклонен лимитом
+// битрейта стрима
+func (h *VideoStreamHandler) respondThrottled(c *gin.Context, err error) bool {
+	var throttled *controller.StreamThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(429, gin.H{
+		"error":          "Stream throttled",
+		"message":        err.Error(),
+		"retry_after_ms": throttled.RetryAfter.Milliseconds(),
+	})
+	return true
+}
+
+// respondCapacity отвечает 503, если err - лимит активных стримов
+func (h *VideoStreamHandler) respondCapacity(c *gin.Context, err error) bool {
+	if !errors.Is(err, controller.ErrStreamCapacity) {
+		return false
+	}
+
+	c.JSON(503, gin.H{
+		"error":   "Stream capacity reached",
+		"message": err.Error(),
+	})
+	return true
+}
@@ -0,0 +1,421 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"api-gateway/internal/controller"
+	"api-gateway/internal/gateway"
+	"api-gateway/internal/types"
+	gen "api-gateway/pkg/gen"
+)
+
+const (
+	wsPongWait       = 60 * time.Second
+	wsPingInterval   = 50 * time.Second
+	wsWriteTimeout   = 10 * time.Second
+	wsMaxMessageSize = 64 * 1024
+	wsCloseTimeout   = time.Second // запись close фрейма и ожидание ответа клиента
+)
+
+// wsShutdownReason причина в close фрейме при остановке сервера
+const wsShutdownReason = "server shutting down"
+
+// WebSocketHandler раздает кадры стримов WebSocket клиентам
+type WebSocketHandler struct {
+	logger        *zap.Logger
+	videoService  *controller.VideoStreamServiceImpl
+	clientService *controller.ClientInfoServiceImpl
+	upgrader      websocket.Upgrader
+	// channels права JWT клиентов на каналы (nil - только свои стримы)
+	channels gateway.ChannelAuthorizer
+	// buffers размер очереди кадров соединения по client_id
+	buffers gateway.ClientLimits
+
+	mu       sync.Mutex
+	closing  bool
+	shutdown chan struct{}  // закрывается Shutdown
+	conns    sync.WaitGroup // открытые соединения
+}
+
+// NewWebSocketHandler создает хендлер. allowedOrigins - origin браузерных
+// страниц, которым разрешено подключаться (security.allowed_origins, "*" -
+// любым); channels - права JWT клиентов на каналы, как на /ws/video шлюза;
+// buffers - очереди кадров соединений (gateway.send_buffer_size и
+// client_send_buffers, лимиты соединений не используются).
+func NewWebSocketHandler(
+	logger *zap.Logger,
+	videoService *controller.VideoStreamServiceImpl,
+	clientService *controller.ClientInfoServiceImpl,
+	allowedOrigins []string,
+	channels gateway.ChannelAuthorizer,
+	buffers gateway.ClientLimits,
+) *WebSocketHandler {
+	return &WebSocketHandler{
+		logger:        logger,
+		videoService:  videoService,
+		clientService: clientService,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     originChecker(allowedOrigins),
+		},
+		channels: channels,
+		buffers:  buffers,
+		shutdown: make(chan struct{}),
+	}
+}
+
+// originChecker разрешает апгрейд без заголовка Origin (не браузер) и с
+// Origin из allowed; без этой проверки любая страница могла бы открыть
+// соединение с cookie и правами пользователя
+func originChecker(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, o := range allowed {
+			if o == "*" || o == origin {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Shutdown отправляет всем клиентам close фрейм GoingAway "server shutting
+// down" и ждет закрытия соединений до истечения ctx. Новые соединения после
+// вызова отклоняются. HTTP сервер не закрывает соединения, перехваченные
+// WebSocket, поэтому без этого клиенты увидели бы обрыв соединения.
+func (h *WebSocketHandler) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closing {
+		h.closing = true
+		close(h.shutdown)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track учитывает соединение до вызова release; false - сервер
+// останавливается
+func (h *WebSocketHandler) track() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closing {
+		return false
+	}
+	h.conns.Add(1)
+	return true
+}
+
+// RegisterRoutes регистрирует маршруты
+func (h *WebSocketHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/ws/video", h.HandleVideo)
+}
+
+// wsCommand команда клиента
+type wsCommand struct {
+	Action   string `json:"action"`
+	Channel  string `json:"channel"`   // stream_id
+	StreamID string `json:"stream_id"` // для stats; по умолчанию channel
+}
+
+// HandleVideo апгрейдит соединение и отправляет клиенту кадры стримов,
+// на которые он подписан командой {"action":"subscribe","channel":"<stream_id>"}.
+// Команда {"action":"stats","stream_id":"<stream_id>"} возвращает текущую
+// статистику стрима.
+func (h *WebSocketHandler) HandleVideo(c *gin.Context) {
+	if !h.track() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   http.StatusText(http.StatusServiceUnavailable),
+			"message": wsShutdownReason,
+		})
+		return
+	}
+	defer h.conns.Done()
+
+	// Аутентифицированный клиент подключается только от своего имени
+	clientID, ok := bindClientID(c, c.Query("client_id"))
+	if !ok {
+		return
+	}
+	if clientID == "" {
+		clientID = c.ClientIP()
+	}
+	access, ok := h.resolveAccess(c)
+	if !ok {
+		return
+	}
+
+	_, viaProtocol := gateway.WebSocketToken(c.Request)
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, gateway.BearerProtocolHeader(viaProtocol))
+	if err != nil {
+		requestLogger(c, h.logger).Warn("WebSocket upgrade failed", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	// Регистрируем клиента
+	now := time.Now().Unix()
+	event := &gen.ConnectionEvent{
+		ClientId:    clientID,
+		IpAddress:   c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		ConnectedAt: now,
+		EventType:   "connected",
+		ClientInfo: &gen.ClientInfo{
+			ClientId:    clientID,
+			IpAddress:   c.ClientIP(),
+			UserAgent:   c.Request.UserAgent(),
+			ConnectedAt: now,
+		},
+	}
+	h.clientService.ClientConnected(c.Request.Context(), event)
+	defer func() {
+		h.clientService.ClientDisconnected(context.Background(), &gen.ConnectionEvent{
+			ClientId:       clientID,
+			DisconnectedAt: time.Now().Unix(),
+			EventType:      "disconnected",
+		})
+		// Стримы отключившегося клиента больше никто не остановит
+		if _, err := h.videoService.StopAllForClient(context.Background(), clientID); err != nil {
+			requestLogger(c, h.logger).Warn("Failed to stop streams of disconnected client",
+				zap.String("client_id", clientID),
+				zap.Error(err))
+		}
+	}()
+
+	hub := h.videoService.FrameHub()
+	sub := hub.NewSubscriber(h.buffers.SendBuffer(clientID))
+	defer hub.Remove(sub)
+
+	replies := make(chan interface{}, 16)
+	readDone := make(chan struct{})
+	go h.readCommands(conn, sub, access, replies, readDone)
+
+	h.writeLoop(conn, sub, replies, readDone)
+}
+
+// subscribeAccess права соединения на каналы
+type subscribeAccess struct {
+	identity  *types.Identity     // nil - аноним, аутентификация не обязательна
+	permitted *gateway.ChannelSet // каналы из JWT (nil - аутентификация ключом)
+}
+
+// resolveAccess определяет права соединения до апгрейда; при false
+// ответ уже отправлен
+func (h *WebSocketHandler) resolveAccess(c *gin.Context) (*subscribeAccess, bool) {
+	access := &subscribeAccess{identity: identityFrom(c)}
+
+	value, ok := c.Get(types.TokenClaimsContextKey)
+	claims, _ := value.(*gateway.TokenClaims)
+	if !ok || claims == nil || h.channels == nil {
+		return access, true
+	}
+	permitted, err := h.channels.PermittedChannels(claims)
+	if err != nil {
+		requestLogger(c, h.logger).Warn("Channel authorization failed", zap.Error(err))
+		respondForbidden(c, "channel authorization failed")
+		return nil, false
+	}
+	access.permitted = permitted
+	return access, true
+}
+
+// allows разрешает канал (stream_id или "stream_id/camera"), если его
+// разрешает JWT, как на /ws/video шлюза, или стрим канала принадлежит
+// клиенту/пользователю соединения
+func (a *subscribeAccess) allows(videoService *controller.VideoStreamServiceImpl, channel string) bool {
+	if a.identity == nil || a.identity.Admin {
+		return true
+	}
+	if a.permitted != nil && a.permitted.Allows(channel) {
+		return true
+	}
+	streamID, _, _ := strings.Cut(channel, "/")
+	stream, _ := videoService.GetStream(streamID)
+	return stream != nil && ownsStream(a.identity, stream)
+}
+
+// readCommands читает команды клиента до ошибки или закрытия соединения
+func (h *WebSocketHandler) readCommands(
+	conn *websocket.Conn,
+	sub *controller.FrameSubscriber,
+	access *subscribeAccess,
+	replies chan<- interface{},
+	done chan<- struct{},
+) {
+	defer close(done)
+
+	hub := h.videoService.FrameHub()
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		if messageType != websocket.TextMessage {
+			continue
+		}
+
+		var cmd wsCommand
+		if err := json.Unmarshal(message, &cmd); err != nil {
+			h.reply(replies, gin.H{"action": "error", "message": "invalid command"})
+			continue
+		}
+
+		switch cmd.Action {
+		case "subscribe":
+			if cmd.Channel == "" {
+				h.reply(replies, gin.H{"action": "error", "message": "channel is required"})
+				continue
+			}
+			if !access.allows(h.videoService, cmd.Channel) {
+				h.reply(replies, gin.H{"action": "error", "message": "access to channel denied", "channel": cmd.Channel})
+				continue
+			}
+			hub.Subscribe(sub, cmd.Channel)
+			h.reply(replies, gin.H{"action": "subscribed", "channel": cmd.Channel, "time": time.Now().Unix()})
+
+		case "unsubscribe":
+			hub.Unsubscribe(sub, cmd.Channel)
+			h.reply(replies, gin.H{"action": "unsubscribed", "channel": cmd.Channel, "time": time.Now().Unix()})
+
+		case "ping":
+			h.reply(replies, gin.H{"action": "pong", "time": time.Now().Unix()})
+
+		case "stats":
+			h.reply(replies, h.streamStatsReply(cmd, access))
+
+		default:
+			h.reply(replies, gin.H{"action": "error", "message": "unknown action"})
+		}
+	}
+}
+
+// streamStatsReply отвечает на {"action":"stats","stream_id":"..."} текущей
+// статистикой стрима в том же виде, что и GET /video/stats/stream/:stream_id
+func (h *WebSocketHandler) streamStatsReply(cmd wsCommand, access *subscribeAccess) gin.H {
+	streamID := cmd.StreamID
+	if streamID == "" {
+		streamID = cmd.Channel
+	}
+	if streamID == "" {
+		return gin.H{"action": "error", "message": "stream_id is required"}
+	}
+	if !access.allows(h.videoService, streamID) {
+		return gin.H{"action": "error", "message": "access to stream denied", "stream_id": streamID}
+	}
+
+	_, stats := h.videoService.GetStream(streamID)
+	if stats == nil {
+		return gin.H{"action": "error", "message": "stream not found", "stream_id": streamID}
+	}
+	return gin.H{
+		"action":    "stats",
+		"stream_id": streamID,
+		"stats":     streamStatsView(stats, h.videoService.GetFormatHistogram(streamID)),
+		"time":      time.Now().Unix(),
+	}
+}
+
+// reply ставит ответ в очередь записи, не блокируя чтение
+func (h *WebSocketHandler) reply(replies chan<- interface{}, msg interface{}) {
+	select {
+	case replies <- msg:
+	default:
+	}
+}
+
+// writeLoop единственный писатель в соединение: кадры, ответы и ping
+func (h *WebSocketHandler) writeLoop(
+	conn *websocket.Conn,
+	sub *controller.FrameSubscriber,
+	replies <-chan interface{},
+	readDone <-chan struct{},
+) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	marshaler := protojson.MarshalOptions{UseProtoNames: true}
+
+	for {
+		var data []byte
+		var err error
+
+		select {
+		case frame := <-sub.C:
+			data, err = marshaler.Marshal(frame)
+		case msg := <-replies:
+			data, err = json.Marshal(msg)
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+			continue
+		case <-readDone:
+			return
+		case <-h.shutdown:
+			h.closeGoingAway(conn, readDone)
+			return
+		}
+
+		if err != nil {
+			h.logger.Warn("Failed to marshal WebSocket message", zap.Error(err))
+			continue
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+	}
+}
+
+// closeGoingAway отправляет close фрейм остановки сервера и коротко ждет
+// ответного close фрейма клиента, чтобы закрытие прошло без обрыва
+func (h *WebSocketHandler) closeGoingAway(conn *websocket.Conn, readDone <-chan struct{}) {
+	err := conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, wsShutdownReason),
+		time.Now().Add(wsCloseTimeout))
+	if err != nil {
+		return
+	}
+
+	select {
+	case <-readDone:
+	case <-time.After(wsCloseTimeout):
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/controller"
+	"api-gateway/internal/gateway"
+)
+
+func TestWebSocketShutdownSendsCloseFrame(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := controller.NewVideoStreamService(zap.NewNop())
+	t.Cleanup(service.Close)
+	h := NewWebSocketHandler(zap.NewNop(), service, controller.NewClientInfoService(zap.NewNop()),
+		nil, nil, gateway.ClientLimits{})
+	router := gin.New()
+	h.RegisterRoutes(router.Group(""))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/video?client_id=cam-1"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Клиент читает соединение, чтобы ответить на close фрейм сервера
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	select {
+	case err := <-readErr:
+		closeErr, ok := err.(*websocket.CloseError)
+		if !ok || closeErr.Code != websocket.CloseGoingAway || closeErr.Text != wsShutdownReason {
+			t.Fatalf("read error = %v, want close 1001 %q", err, wsShutdownReason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("client did not receive a close frame")
+	}
+
+	// После остановки новые соединения отклоняются
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("dial after shutdown succeeded")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("dial after shutdown: response %v, want 503", resp)
+	}
+}
@@ -0,0 +1,129 @@
+// Package redisconn подключение к Redis - общему хранилищу лимитов и
+// клиентов для реплик шлюза: пул соединений go-redis и размыкатель, который
+// после сбоев не ждет таймаута на каждой команде.
+package redisconn
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrUnavailable - Redis недавно не отвечал, команда не отправлялась до
+// истечения паузы размыкателя
+var ErrUnavailable = errors.New("redis unavailable, retry after backoff")
+
+const (
+	// minBackoff пауза после первого сбоя; каждый следующий сбой подряд ее
+	// удваивает до maxBackoff
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// Options параметры подключения
+type Options struct {
+	Addr     string
+	Password string
+	DB       int
+	// Timeout таймаут подключения и каждой команды
+	Timeout time.Duration
+	// PoolSize соединений в пуле (<= 0 - по умолчанию go-redis)
+	PoolSize int
+}
+
+// New создает клиента с пулом соединений; соединения открываются по мере
+// надобности. Команды не повторяются: после сетевой ошибки размыкатель
+// отвечает ErrUnavailable в течение паузы, затем пропускает пробную команду.
+func New(opts Options) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:         opts.Addr,
+		Password:     opts.Password,
+		DB:           opts.DB,
+		DialTimeout:  opts.Timeout,
+		ReadTimeout:  opts.Timeout,
+		WriteTimeout: opts.Timeout,
+		PoolSize:     opts.PoolSize,
+		MaxRetries:   -1,
+	})
+	client.AddHook(&breaker{})
+	return client
+}
+
+// breaker размыкатель: после сетевой ошибки команды отклоняются
+// ErrUnavailable на паузу, растущую с каждым сбоем подряд
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// DialHook не учитывает сбои подключения: они возвращаются командой и
+// учитываются в ProcessHook
+func (b *breaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (b *breaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := b.allow(); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err := next(ctx, cmd)
+		b.record(err)
+		return err
+	}
+}
+
+func (b *breaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := b.allow(); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err := next(ctx, cmds)
+		b.record(err)
+		return err
+	}
+}
+
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if time.Now().Before(b.openUntil) {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// record учитывает результат команды. Ответ Redis (в том числе ошибка
+// скрипта или nil) значит, что сервер доступен; отмена запроса вызывающим
+// ничего не говорит о сервере.
+func (b *breaker) record(err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrUnavailable) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var redisErr redis.Error
+	if err == nil || errors.As(err, &redisErr) {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+
+	b.failures++
+	backoff := maxBackoff
+	if b.failures <= 16 {
+		backoff = min(minBackoff<<(b.failures-1), maxBackoff)
+	}
+	b.openUntil = time.Now().Add(backoff)
+}
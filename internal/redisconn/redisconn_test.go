@@ -0,0 +1,49 @@
+package redisconn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestBreaker(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := New(Options{Addr: server.Addr(), Timeout: 200 * time.Millisecond})
+	t.Cleanup(func() { client.Close() })
+	ctx := context.Background()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	// Ошибка Redis - сервер доступен, размыкатель не срабатывает
+	if err := client.Do(ctx, "NOSUCHCOMMAND").Err(); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("unknown command error = %v, want Redis error", err)
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Fatalf("Ping after Redis error: %v", err)
+	}
+
+	server.Close()
+	if err := client.Ping(ctx).Err(); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Ping with Redis down = %v, want network error", err)
+	}
+	started := time.Now()
+	if err := client.Ping(ctx).Err(); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Ping during backoff = %v, want ErrUnavailable", err)
+	}
+	if elapsed := time.Since(started); elapsed > 50*time.Millisecond {
+		t.Errorf("Ping during backoff took %v, want fail fast", elapsed)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	time.Sleep(minBackoff + 50*time.Millisecond)
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Errorf("Ping after backoff = %v, want nil", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package requestid переносит идентификатор запроса (X-Request-ID) через
+// контекст: HTTP middleware, логи, запросы к сервисам и gRPC metadata.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Header HTTP заголовок с идентификатором запроса
+	Header = "X-Request-ID"
+	// MetadataKey ключ gRPC metadata с идентификатором запроса
+	MetadataKey = "x-request-id"
+
+	maxLength = 128
+)
+
+type contextKey struct{}
+
+// New генерирует новый идентификатор
+func New() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Valid проверяет присланный клиентом идентификатор: непустой, не длиннее
+// 128 символов, только печатные ASCII символы без пробелов
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext сохраняет идентификатор в контексте
+func NewContext(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext возвращает идентификатор из контекста или ""
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// FromRequest возвращает X-Request-ID запроса или новый идентификатор, если
+// заголовка нет или он некорректен
+func FromRequest(r *http.Request) string {
+	if id := r.Header.Get(Header); Valid(id) {
+		return id
+	}
+	return New()
+}
+
+// SetHeader выставляет X-Request-ID исходящего запроса из контекста
+func SetHeader(ctx context.Context, h http.Header) {
+	if id := FromContext(ctx); id != "" {
+		h.Set(Header, id)
+	}
+}
+
+// Logger возвращает логгер с полем request_id, если оно есть в контексте
+func Logger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if id := FromContext(ctx); id != "" {
+		return logger.With(zap.String("request_id", id))
+	}
+	return logger
+}
+
+// fromIncoming берет идентификатор из входящих gRPC metadata или создает новый
+func fromIncoming(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(MetadataKey); len(values) > 0 && Valid(values[0]) {
+			return values[0]
+		}
+	}
+	return New()
+}
+
+// UnaryServerInterceptor кладет идентификатор из metadata (или новый) в
+// контекст RPC и возвращает его клиенту в заголовке ответа
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := fromIncoming(ctx)
+		grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+		return handler(NewContext(ctx, id), req)
+	}
+}
+
+// StreamServerInterceptor то же для потоковых RPC
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id := fromIncoming(ss.Context())
+		ss.SetHeader(metadata.Pairs(MetadataKey, id))
+		return handler(srv, &contextStream{ServerStream: ss, ctx: NewContext(ss.Context(), id)})
+	}
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
+
+// UnaryClientInterceptor передает идентификатор из контекста в исходящие
+// gRPC вызовы (например, к user-service)
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := FromContext(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,122 @@
+// Package retry повторяет вызовы с экспоненциальной задержкой и jitter.
+// Ошибки gRPC повторяются только для временных кодов; остальные ошибки
+// повторяются, если не помечены Permanent.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Policy параметры повторов
+type Policy struct {
+	MaxAttempts    int           // всего попыток, включая первую (<= 1 - без повторов)
+	InitialBackoff time.Duration // задержка перед вторым вызовом
+	MaxBackoff     time.Duration // потолок задержки
+	Multiplier     float64       // рост задержки между попытками
+	Jitter         float64       // доля случайного разброса задержки, 0..1
+}
+
+// DefaultPolicy политика по умолчанию: 3 попытки с паузами ~100ms и ~200ms
+var DefaultPolicy = Policy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// retryableCodes коды gRPC, при которых повтор может помочь
+var retryableCodes = map[codes.Code]bool{
+	codes.Unavailable:       true,
+	codes.ResourceExhausted: true,
+	codes.Aborted:           true,
+	codes.DeadlineExceeded:  true,
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку как не подлежащую повтору
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable сообщает, имеет ли смысл повторить вызов после err
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if st, ok := status.FromError(err); ok {
+		return retryableCodes[st.Code()]
+	}
+	return true
+}
+
+// Backoff возвращает задержку перед попыткой attempt+1 без учета jitter
+// (attempt считается с 1)
+func (p Policy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	for i := 1; i < attempt; i++ {
+		backoff *= multiplier
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	return time.Duration(backoff)
+}
+
+func (p Policy) jittered(attempt int) time.Duration {
+	backoff := p.Backoff(attempt)
+	if p.Jitter <= 0 || backoff <= 0 {
+		return backoff
+	}
+	delta := float64(backoff) * p.Jitter
+	return time.Duration(float64(backoff) - delta + rand.Float64()*2*delta)
+}
+
+// Do вызывает fn до policy.MaxAttempts раз, пока ошибка временная. Ожидание
+// между попытками прерывается отменой ctx; тогда возвращается последняя
+// ошибка fn.
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(policy.jittered(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
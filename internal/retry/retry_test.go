@@ -0,0 +1,132 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fastPolicy политика с короткими задержками без jitter
+var fastPolicy = Policy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     10 * time.Millisecond,
+	Multiplier:     2,
+}
+
+func TestDoRetriesOnlyTransientErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantAttempts int
+	}{
+		{"unavailable", status.Error(codes.Unavailable, "down"), 3},
+		{"resource exhausted", status.Error(codes.ResourceExhausted, "busy"), 3},
+		{"not found", status.Error(codes.NotFound, "no user"), 1},
+		{"unauthenticated", status.Error(codes.Unauthenticated, "no token"), 1},
+		{"plain error", errors.New("connection reset"), 3},
+		{"permanent", Permanent(errors.New("bad request")), 1},
+		{"canceled", context.Canceled, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := Do(context.Background(), fastPolicy, func(context.Context) error {
+				attempts++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) && status.Code(err) != status.Code(tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestDoStopsOnSuccess(t *testing.T) {
+	attempts := 0
+	err := Do(context.Background(), fastPolicy, func(context.Context) error {
+		attempts++
+		if attempts < 2 {
+			return status.Error(codes.Unavailable, "down")
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("err = %v, attempts = %d, want nil after 2", err, attempts)
+	}
+}
+
+func TestBackoffGrows(t *testing.T) {
+	policy := Policy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		Multiplier:     2,
+	}
+	want := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		50 * time.Millisecond, // потолок MaxBackoff
+		50 * time.Millisecond,
+	}
+	for i, backoff := range want {
+		if got := policy.Backoff(i + 1); got != backoff {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, backoff)
+		}
+	}
+}
+
+func TestDoWaitsBetweenAttempts(t *testing.T) {
+	policy := Policy{
+		MaxAttempts:    4,
+		InitialBackoff: 20 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+
+	var calls []time.Time
+	Do(context.Background(), policy, func(context.Context) error {
+		calls = append(calls, time.Now())
+		return status.Error(codes.Unavailable, "down")
+	})
+	if len(calls) != 4 {
+		t.Fatalf("attempts = %d, want 4", len(calls))
+	}
+
+	// Паузы 20, 40 и 80ms с разбросом jitter 20%
+	for i := 1; i < len(calls); i++ {
+		gap := calls[i].Sub(calls[i-1])
+		if min := policy.Backoff(i) * 8 / 10; gap < min {
+			t.Errorf("pause before attempt %d = %v, want at least %v", i+1, gap, min)
+		}
+	}
+	if first, last := calls[1].Sub(calls[0]), calls[3].Sub(calls[2]); last <= first {
+		t.Errorf("last pause %v is not longer than first %v", last, first)
+	}
+}
+
+func TestDoHonorsContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	policy := Policy{MaxAttempts: 10, InitialBackoff: time.Second}
+
+	start := time.Now()
+	attempts := 0
+	err := Do(ctx, policy, func(context.Context) error {
+		attempts++
+		return status.Error(codes.Unavailable, "down")
+	})
+	if status.Code(err) != codes.Unavailable || attempts != 1 {
+		t.Errorf("err = %v, attempts = %d, want Unavailable after 1", err, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Do waited %v after context cancel", elapsed)
+	}
+}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"api-gateway/internal/config"
+)
+
+const instrumentationName = "api-gateway"
+
+// Атрибуты спанов, общие для всех слоев
+const (
+	AttrStreamID  = attribute.Key("stream.id")
+	AttrClientID  = attribute.Key("client.id")
+	AttrFrameSize = attribute.Key("frame.size")
+)
+
+// ShutdownFunc сбрасывает буфер спанов и останавливает экспортер
+type ShutdownFunc func(ctx context.Context) error
+
+// Init настраивает глобальный TracerProvider с экспортом по OTLP.
+// Если трейсинг выключен, остается no-op провайдер по умолчанию.
+func Init(ctx context.Context, cfg *config.Config, logger *zap.Logger) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.Tracing.Enabled {
+		logger.Info("Tracing disabled")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(cfg.Tracing.OTLPEndpoint),
+	}
+	if cfg.Tracing.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %v", err)
+	}
+
+	serviceName := cfg.Tracing.ServiceName
+	if serviceName == "" {
+		serviceName = instrumentationName
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	logger.Info("Tracing enabled",
+		zap.String("endpoint", cfg.Tracing.OTLPEndpoint),
+		zap.String("service_name", serviceName),
+		zap.Float64("sample_ratio", cfg.Tracing.SampleRatio))
+
+	return provider.Shutdown, nil
+}
+
+// Tracer возвращает трейсер шлюза из глобального провайдера
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// StartSpan начинает дочерний спан с указанными атрибутами
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
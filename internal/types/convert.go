@@ -0,0 +1,131 @@
+package types
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"api-gateway/pkg/gen"
+	"api-gateway/pkg/proto"
+)
+
+// Канонический внутренний фрейм - VideoFrame из этого пакета: данные
+// всегда хранятся как сырые байты. Base64 появляется только на границе
+// с JSON структурами pkg/proto, protobuf (pkg/gen) хранит байты как есть.
+
+// FromProto создает фрейм из JSON структуры, декодируя base64 данные
+func FromProto(f *proto.VideoFrame) (*VideoFrame, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(f.FrameData)
+	if err != nil {
+		return nil, fmt.Errorf("frame_data is not valid base64: %v", err)
+	}
+
+	return &VideoFrame{
+		FrameID:    f.FrameID,
+		FrameData:  data,
+		Timestamp:  f.Timestamp,
+		CameraID:   f.CameraID,
+		ClientID:   f.ClientID,
+		Width:      f.Width,
+		Height:     f.Height,
+		Format:     f.Format,
+		Metadata:   f.Metadata,
+		ClientData: clientDataFromProto(f.ClientData),
+		AccessTags: f.AccessTags,
+	}, nil
+}
+
+// ToProto переводит фрейм в JSON структуру, кодируя данные в base64
+func (f *VideoFrame) ToProto() *proto.VideoFrame {
+	if f == nil {
+		return nil
+	}
+
+	return &proto.VideoFrame{
+		FrameID:    f.FrameID,
+		FrameData:  base64.StdEncoding.EncodeToString(f.FrameData),
+		Timestamp:  f.Timestamp,
+		CameraID:   f.CameraID,
+		ClientID:   f.ClientID,
+		Width:      f.Width,
+		Height:     f.Height,
+		Format:     f.Format,
+		Metadata:   f.Metadata,
+		ClientData: f.ClientData.toProto(),
+		AccessTags: f.AccessTags,
+	}
+}
+
+// FromGen создает фрейм из protobuf сообщения. ClientData и AccessTags в
+// protobuf нет, они остаются пустыми.
+func FromGen(f *gen.VideoFrame) *VideoFrame {
+	if f == nil {
+		return nil
+	}
+
+	return &VideoFrame{
+		FrameID:   f.GetFrameId(),
+		FrameData: f.GetFrameData(),
+		Timestamp: f.GetTimestamp(),
+		CameraID:  f.GetCameraId(),
+		ClientID:  f.GetClientId(),
+		Width:     f.GetWidth(),
+		Height:    f.GetHeight(),
+		Format:    f.GetFormat(),
+		Metadata:  f.GetMetadata(),
+	}
+}
+
+// ToGen переводит фрейм в protobuf сообщение (без ClientData и AccessTags)
+func (f *VideoFrame) ToGen() *gen.VideoFrame {
+	if f == nil {
+		return nil
+	}
+
+	return &gen.VideoFrame{
+		FrameId:   f.FrameID,
+		FrameData: f.FrameData,
+		Timestamp: f.Timestamp,
+		CameraId:  f.CameraID,
+		ClientId:  f.ClientID,
+		Width:     f.Width,
+		Height:    f.Height,
+		Format:    f.Format,
+		Metadata:  f.Metadata,
+	}
+}
+
+func clientDataFromProto(d *proto.ClientData) *ClientData {
+	if d == nil {
+		return nil
+	}
+
+	return &ClientData{
+		UserID:        d.UserID,
+		SessionID:     d.SessionID,
+		Device:        d.Device,
+		Location:      d.Location,
+		Authenticated: d.Authenticated,
+		Roles:         d.Roles,
+		Metadata:      d.Metadata,
+	}
+}
+
+func (d *ClientData) toProto() *proto.ClientData {
+	if d == nil {
+		return nil
+	}
+
+	return &proto.ClientData{
+		UserID:        d.UserID,
+		SessionID:     d.SessionID,
+		Device:        d.Device,
+		Location:      d.Location,
+		Authenticated: d.Authenticated,
+		Roles:         d.Roles,
+		Metadata:      d.Metadata,
+	}
+}
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"api-gateway/pkg/gen"
+	jsonproto "api-gateway/pkg/proto"
+)
+
+// testFrame фрейм с бинарными данными, которые не являются UTF-8
+func testFrame() *VideoFrame {
+	return &VideoFrame{
+		FrameID:   "f-1",
+		FrameData: []byte{0xff, 0xd8, 0x00, 0x01, 0xfe},
+		Timestamp: 1700000000,
+		CameraID:  "cam-1",
+		ClientID:  "client-1",
+		Width:     640,
+		Height:    480,
+		Format:    "jpeg",
+		Metadata:  map[string]string{"codec": "mjpeg"},
+		ClientData: &ClientData{
+			UserID:        "user-1",
+			Authenticated: true,
+			Roles:         []string{"viewer"},
+		},
+		AccessTags: []string{"internal"},
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	frame := testFrame()
+
+	encoded := frame.ToProto()
+	if encoded.FrameData != "/9gAAf4=" {
+		t.Errorf("ToProto frame_data = %q, want base64 of the raw bytes", encoded.FrameData)
+	}
+
+	decoded, err := FromProto(encoded)
+	if err != nil {
+		t.Fatalf("FromProto: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, frame) {
+		t.Errorf("round trip = %+v, want %+v", decoded, frame)
+	}
+}
+
+func TestFromProtoRejectsInvalidBase64(t *testing.T) {
+	if _, err := FromProto(&jsonproto.VideoFrame{FrameData: "not base64!"}); err == nil {
+		t.Error("FromProto accepted invalid base64")
+	}
+}
+
+func TestGenRoundTrip(t *testing.T) {
+	frame := testFrame()
+
+	msg := frame.ToGen()
+	if string(msg.FrameData) != string(frame.FrameData) {
+		t.Errorf("ToGen frame_data = %v, want raw bytes %v", msg.FrameData, frame.FrameData)
+	}
+
+	// Через сериализацию protobuf, как по сети
+	wire, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var received gen.VideoFrame
+	if err := proto.Unmarshal(wire, &received); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	// ClientData и AccessTags в protobuf не передаются
+	want := testFrame()
+	want.ClientData = nil
+	want.AccessTags = nil
+	if got := FromGen(&received); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestConvertNil(t *testing.T) {
+	var frame *VideoFrame
+	if frame.ToProto() != nil || frame.ToGen() != nil || FromGen(nil) != nil {
+		t.Error("nil frame converted to non-nil")
+	}
+	if got, err := FromProto(nil); got != nil || err != nil {
+		t.Errorf("FromProto(nil) = %v, %v", got, err)
+	}
+}
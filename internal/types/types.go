@@ -12,6 +12,7 @@ type VideoFrame struct {
 	Format     string            `json:"format"`
 	Metadata   map[string]string `json:"metadata"`
 	ClientData *ClientData       `json:"client_data"`
+	AccessTags []string          `json:"access_tags,omitempty"`
 }
 
 type ClientData struct {
@@ -30,3 +31,20 @@ type ApiResponse struct {
 	Timestamp int64             `json:"timestamp"`
 	Metadata  map[string]string `json:"metadata"`
 }
+
+// IdentityContextKey ключ gin контекста с *Identity аутентифицированного
+// вызывающего. Заполняется middleware аутентификации независимо от способа.
+const IdentityContextKey = "identity"
+
+// TokenClaimsContextKey ключ gin контекста с утверждениями JWT вызывающего
+// (*gateway.TokenClaims); есть только при аутентификации по токену
+const TokenClaimsContextKey = "token_claims"
+
+// Identity аутентифицированный вызывающий
+type Identity struct {
+	ClientID string `json:"client_id"`
+	UserID   string `json:"user_id"`
+	Method   string `json:"method"` // способ аутентификации: api_key, jwt
+	// Admin управляет чужими клиентами и стримами и /api/v1/admin/*
+	Admin bool `json:"admin"`
+}
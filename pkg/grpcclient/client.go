@@ -0,0 +1,141 @@
+package grpcclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	pb "api-gateway/pkg/gen"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Config настройки подключения к gRPC API шлюза
+type Config struct {
+	Address        string        // адрес сервера, например localhost:9090
+	TLS            bool          // использовать TLS
+	CACertFile     string        // CA сертификат для проверки сервера (опционально)
+	ServerName     string        // имя сервера для проверки сертификата (опционально)
+	MaxRecvMsgSize int           // максимальный размер входящего сообщения
+	Timeout        time.Duration // таймаут одного вызова
+}
+
+// DefaultConfig возвращает конфигурацию по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		Address:        "localhost:9090",
+		MaxRecvMsgSize: 50 * 1024 * 1024,
+		Timeout:        10 * time.Second,
+	}
+}
+
+// Client типизированный клиент VideoStreamService
+type Client struct {
+	conn    *grpc.ClientConn
+	video   pb.VideoStreamServiceClient
+	timeout time.Duration
+}
+
+// New создает клиента. Дополнительные опции (например, bufconn dialer)
+// добавляются после опций из конфигурации.
+func New(cfg Config, opts ...grpc.DialOption) (*Client, error) {
+	creds, err := transportCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if cfg.MaxRecvMsgSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(cfg.MaxRecvMsgSize)))
+	}
+	dialOpts = append(dialOpts, opts...)
+
+	conn, err := grpc.NewClient(cfg.Address, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", cfg.Address, err)
+	}
+
+	return &Client{
+		conn:    conn,
+		video:   pb.NewVideoStreamServiceClient(conn),
+		timeout: cfg.Timeout,
+	}, nil
+}
+
+// transportCredentials собирает учетные данные транспорта
+func transportCredentials(cfg Config) (credentials.TransportCredentials, error) {
+	if !cfg.TLS {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: cfg.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.CACertFile != "" {
+		pem, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA cert %s: %v", cfg.CACertFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse CA cert %s", cfg.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// Close закрывает соединение
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// StartStream начинает стрим
+func (c *Client) StartStream(ctx context.Context, req *pb.StartStreamRequest) (*pb.StartStreamResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.video.StartStream(ctx, req)
+}
+
+// SendFrame отправляет единичный кадр
+func (c *Client) SendFrame(ctx context.Context, req *pb.SendFrameRequest) (*pb.ApiResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.video.SendFrame(ctx, req)
+}
+
+// GetStats возвращает статистику стрима
+func (c *Client) GetStats(ctx context.Context, streamID, clientID string) (*pb.StreamStats, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.video.GetStreamStats(ctx, &pb.GetStreamStatsRequest{
+		StreamId: streamID,
+		ClientId: clientID,
+	})
+}
+
+// StopStream останавливает стрим
+func (c *Client) StopStream(ctx context.Context, req *pb.StopStreamRequest) (*pb.ApiResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.video.StopStream(ctx, req)
+}
+
+// withTimeout добавляет таймаут вызова, если он задан
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
@@ -0,0 +1,119 @@
+package grpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"api-gateway/internal/controller"
+	"api-gateway/internal/grpc_server"
+	pb "api-gateway/pkg/gen"
+)
+
+// newBufconnClient поднимает VideoStreamService в памяти и подключает к
+// нему клиента
+func newBufconnClient(t *testing.T) *Client {
+	t.Helper()
+	logger := zap.NewNop()
+	service := controller.NewVideoStreamService(logger)
+	t.Cleanup(service.Close)
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	pb.RegisterVideoStreamServiceServer(server, grpc_server.NewVideoStreamServer(service, logger, 0))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	cfg := DefaultConfig()
+	cfg.Address = "passthrough:///bufnet"
+	cfg.Timeout = 2 * time.Second
+	client, err := New(cfg, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestClientStreamLifecycle(t *testing.T) {
+	client := newBufconnClient(t)
+	ctx := context.Background()
+
+	started, err := client.StartStream(ctx, &pb.StartStreamRequest{ClientId: "cam-1", CameraName: "front"})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+	if started.StreamId == "" {
+		t.Fatal("StartStream returned empty stream_id")
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.SendFrame(ctx, &pb.SendFrameRequest{
+			StreamId: started.StreamId,
+			ClientId: "cam-1",
+			Frame:    &pb.VideoFrame{FrameId: "f", ClientId: "cam-1", Format: "jpeg", FrameData: []byte{1, 2, 3, 4}},
+		})
+		if err != nil {
+			t.Fatalf("SendFrame %d: %v", i, err)
+		}
+		if resp.Status != "ok" {
+			t.Fatalf("SendFrame %d status %q: %s", i, resp.Status, resp.Message)
+		}
+	}
+
+	stats, err := client.GetStats(ctx, started.StreamId, "cam-1")
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.FramesReceived != 3 || stats.BytesReceived != 12 {
+		t.Errorf("stats frames=%d bytes=%d, want 3 and 12", stats.FramesReceived, stats.BytesReceived)
+	}
+
+	if _, err := client.StopStream(ctx, &pb.StopStreamRequest{StreamId: started.StreamId, ClientId: "cam-1"}); err != nil {
+		t.Fatalf("StopStream: %v", err)
+	}
+	if _, err := client.GetStats(ctx, started.StreamId, "cam-1"); err == nil {
+		t.Error("GetStats after StopStream succeeded, want error")
+	}
+}
+
+func TestReporterJSON(t *testing.T) {
+	var out bytes.Buffer
+	reporter := NewReporter(&out, OutputJSON)
+	reporter.Report("start", "stream started", map[string]string{"stream_id": "s1"}, nil)
+	reporter.Report("send", "", nil, errors.New("boom"))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), out.String())
+	}
+	tests := []struct {
+		line      string
+		wantStep  string
+		wantOK    bool
+		wantError string
+	}{
+		{lines[0], "start", true, ""},
+		{lines[1], "send", false, "boom"},
+	}
+	for _, tt := range tests {
+		var result StepResult
+		if err := json.Unmarshal([]byte(tt.line), &result); err != nil {
+			t.Fatalf("line %q is not JSON: %v", tt.line, err)
+		}
+		if result.Step != tt.wantStep || result.OK != tt.wantOK || result.Error != tt.wantError {
+			t.Errorf("result = %+v, want step %q ok %v error %q", result, tt.wantStep, tt.wantOK, tt.wantError)
+		}
+	}
+}
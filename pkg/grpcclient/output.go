@@ -0,0 +1,73 @@
+package grpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// OutputFormat формат вывода результатов
+type OutputFormat string
+
+const (
+	OutputText OutputFormat = "text"
+	OutputJSON OutputFormat = "json"
+)
+
+// ParseOutputFormat разбирает формат вывода
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch OutputFormat(s) {
+	case OutputText, OutputJSON:
+		return OutputFormat(s), nil
+	default:
+		return "", fmt.Errorf("unknown output format: %s (expected text or json)", s)
+	}
+}
+
+// StepResult результат одного шага сценария
+type StepResult struct {
+	Step      string      `json:"step"`
+	OK        bool        `json:"ok"`
+	Message   string      `json:"message,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// Reporter выводит результаты шагов в выбранном формате
+type Reporter struct {
+	w      io.Writer
+	format OutputFormat
+}
+
+// NewReporter создает репортер
+func NewReporter(w io.Writer, format OutputFormat) *Reporter {
+	return &Reporter{w: w, format: format}
+}
+
+// Report выводит результат шага. JSON формат пишет по одному объекту на строку.
+func (r *Reporter) Report(step, message string, data interface{}, err error) {
+	result := StepResult{
+		Step:      step,
+		OK:        err == nil,
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now().Unix(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	if r.format == OutputJSON {
+		line, _ := json.Marshal(result)
+		fmt.Fprintln(r.w, string(line))
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(r.w, "❌ %s: %v\n", step, err)
+		return
+	}
+	fmt.Fprintf(r.w, "✅ %s: %s\n", step, message)
+}
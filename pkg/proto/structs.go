@@ -12,6 +12,7 @@ type VideoFrame struct {
 	Format     string            `json:"format"`
 	Metadata   map[string]string `json:"metadata"`
 	ClientData *ClientData       `json:"client_data"`
+	AccessTags []string          `json:"access_tags,omitempty"` // теги, требуемые от подписчика
 }
 
 type ClientData struct {
@@ -2,45 +2,61 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
+	"os"
 	"time"
 
 	pb "api-gateway/pkg/gen"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"api-gateway/pkg/grpcclient"
 )
 
 func main() {
+	cfg := grpcclient.DefaultConfig()
+
+	flag.StringVar(&cfg.Address, "addr", cfg.Address, "Адрес gRPC сервера")
+	flag.BoolVar(&cfg.TLS, "tls", false, "Использовать TLS")
+	flag.StringVar(&cfg.CACertFile, "ca", "", "CA сертификат сервера")
+	flag.StringVar(&cfg.ServerName, "server-name", "", "Имя сервера для проверки сертификата")
+	output := flag.String("output", "text", "Формат вывода: text, json")
+	flag.Parse()
+
+	format, err := grpcclient.ParseOutputFormat(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	reporter := grpcclient.NewReporter(os.Stdout, format)
+
 	// Подключаемся к gRPC серверу
-	conn, err := grpc.Dial(
-		"localhost:9090",
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(50*1024*1024)),
-	)
+	client, err := grpcclient.New(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+		reporter.Report("connect", "", nil, err)
+		os.Exit(1)
 	}
-	defer conn.Close()
+	defer client.Close()
 
-	client := pb.NewVideoStreamServiceClient(conn)
+	if err := run(context.Background(), client, reporter); err != nil {
+		os.Exit(1)
+	}
+}
 
+// run выполняет сценарий: старт, кадр, статистика, остановка
+func run(ctx context.Context, client *grpcclient.Client, reporter *grpcclient.Reporter) error {
 	// Тест 1: StartStream
-	fmt.Println("Test 1: Starting stream...")
-	startResp, err := client.StartStream(context.Background(), &pb.StartStreamRequest{
+	startResp, err := client.StartStream(ctx, &pb.StartStreamRequest{
 		ClientId:   "test_client_grpc",
 		UserId:     "user_001",
 		CameraName: "test_camera",
 		Filename:   "test_stream.mp4",
 	})
+	reporter.Report("start_stream", fmt.Sprintf("Stream started: %s", startResp.GetStreamId()), startResp, err)
 	if err != nil {
-		log.Fatalf("StartStream failed: %v", err)
+		return err
 	}
-	fmt.Printf("Stream started: %s\n", startResp.StreamId)
 
 	// Тест 2: SendFrame (единичный кадр)
-	fmt.Println("\nTest 2: Sending single frame...")
-	frameResp, err := client.SendFrame(context.Background(), &pb.SendFrameRequest{
+	frameResp, err := client.SendFrame(ctx, &pb.SendFrameRequest{
 		StreamId: startResp.StreamId,
 		ClientId: "test_client_grpc",
 		UserName: "Test User",
@@ -53,36 +69,27 @@ func main() {
 			Format:    "jpeg",
 		},
 	})
+	reporter.Report("send_frame", fmt.Sprintf("Frame sent: %s", frameResp.GetMessage()), frameResp, err)
 	if err != nil {
-		log.Fatalf("SendFrame failed: %v", err)
+		return err
 	}
-	fmt.Printf("Frame sent: %s\n", frameResp.Message)
 
 	// Тест 3: GetStreamStats
-	fmt.Println("\nTest 3: Getting stream stats...")
-	stats, err := client.GetStreamStats(context.Background(), &pb.GetStreamStatsRequest{
-		StreamId: startResp.StreamId,
-		ClientId: "test_client_grpc",
-	})
+	stats, err := client.GetStats(ctx, startResp.StreamId, "test_client_grpc")
+	reporter.Report("get_stats", fmt.Sprintf("Stats: %d frames, %d bytes, %.2f fps",
+		stats.GetFramesReceived(), stats.GetBytesReceived(), stats.GetAverageFps()), stats, err)
 	if err != nil {
-		log.Fatalf("GetStreamStats failed: %v", err)
+		return err
 	}
-	fmt.Printf("Stats: %d frames, %d bytes, %.2f fps\n",
-		stats.FramesReceived, stats.BytesReceived, stats.AverageFps)
 
 	// Тест 4: StopStream
-	fmt.Println("\nTest 4: Stopping stream...")
-	stopResp, err := client.StopStream(context.Background(), &pb.StopStreamRequest{
+	stopResp, err := client.StopStream(ctx, &pb.StopStreamRequest{
 		StreamId: startResp.StreamId,
 		ClientId: "test_client_grpc",
 		Filename: "test_stream.mp4",
 		EndTime:  time.Now().Unix(),
 		FileSize: 1024 * 1024, // 1MB
 	})
-	if err != nil {
-		log.Fatalf("StopStream failed: %v", err)
-	}
-	fmt.Printf("Stream stopped: %s\n", stopResp.Message)
-
-	fmt.Println("\n✅ All gRPC tests completed successfully!")
+	reporter.Report("stop_stream", fmt.Sprintf("Stream stopped: %s", stopResp.GetMessage()), stopResp, err)
+	return err
 }